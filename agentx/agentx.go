@@ -0,0 +1,469 @@
+// Package agentx implements an AgentX (RFC 2741) subagent that serves a
+// read-only table of values under one subtree, so that a master agent
+// such as net-snmp's snmpd can expose them over SNMP.
+package agentx
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// DefaultMaster is where net-snmp listens for subagents by default.
+const DefaultMaster = "/var/agentx/master"
+
+// PDU types.
+const (
+	pduOpen       = 1
+	pduClose      = 2
+	pduRegister   = 3
+	pduGet        = 5
+	pduGetNext    = 6
+	pduGetBulk    = 7
+	pduTestSet    = 8
+	pduCommitSet  = 9
+	pduUndoSet    = 10
+	pduCleanupSet = 11
+	pduResponse   = 18
+)
+
+// Header flags.
+const (
+	flagNonDefaultContext = 0x08
+	flagNetworkByteOrder  = 0x10
+)
+
+// Variable binding types.
+const (
+	typeInteger        = 2
+	typeOctetString    = 4
+	typeNoSuchObject   = 128
+	typeNoSuchInstance = 129
+	typeEndOfMibView   = 130
+)
+
+// Response errors.
+const (
+	errNotWritable        = 17
+	errUnsupportedContext = 262
+	errParse              = 266
+	errProcessing         = 268
+)
+
+// OID is an object identifier.
+type OID []uint32
+
+// ParseOID parses dotted notation such as "1.3.6.1.4.1.8072".
+func ParseOID(s string) (OID, error) {
+	var o OID
+	for _, p := range strings.Split(strings.TrimPrefix(s, "."), ".") {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("agentx: bad OID %q", s)
+		}
+		o = append(o, uint32(n))
+	}
+	return o, nil
+}
+
+func (o OID) String() string {
+	var b strings.Builder
+	for i, n := range o {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.FormatUint(uint64(n), 10))
+	}
+	return b.String()
+}
+
+// Value is an INTEGER (int) or OCTET STRING (string) variable.
+type Value any
+
+// Var is one variable of the served table.
+type Var struct {
+	OID   OID
+	Value Value
+}
+
+// Subagent serves the variables set with Update under Root.
+type Subagent struct {
+	// Master is the master agent's socket path, or host:port for TCP;
+	// DefaultMaster when empty.
+	Master string
+	Root   OID
+	// Description names the subagent to the master.
+	Description string
+	// Timeout is the master's wait for answers; 5 seconds when zero.
+	Timeout time.Duration
+
+	mu   sync.RWMutex
+	vars []Var // sorted by OID
+
+	session uint32
+	packet  uint32
+	w       io.Writer
+}
+
+// Update replaces the served variables.
+func (s *Subagent) Update(vars []Var) {
+	vars = slices.Clone(vars)
+	slices.SortFunc(vars, func(a, b Var) int { return slices.Compare(a.OID, b.OID) })
+	s.mu.Lock()
+	s.vars = vars
+	s.mu.Unlock()
+}
+
+// Run connects to the master agent, registers Root and answers requests
+// until ctx is done or the master closes the session.
+func (s *Subagent) Run(ctx context.Context) error {
+	master := s.Master
+	if master == "" {
+		master = DefaultMaster
+	}
+	network := "unix"
+	if !strings.HasPrefix(master, "/") {
+		network = "tcp"
+	}
+	conn, err := fdcap.Default.DialContext(ctx, &net.Dialer{}, network, master)
+	if err != nil {
+		return fmt.Errorf("agentx: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	r := bufio.NewReader(conn)
+	s.w = conn
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	// Open: timeout, reserved, subagent OID, description.
+	p := []byte{byte(timeout / time.Second), 0, 0, 0}
+	p = appendOID(p, s.Root, false)
+	p = appendString(p, s.Description)
+	if err := s.send(pduOpen, 0, p); err != nil {
+		return err
+	}
+	h, body, err := readPDU(r)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(h, body); err != nil {
+		return fmt.Errorf("agentx: open: %w", err)
+	}
+	s.session = h.session
+
+	// Register: timeout, priority, range_subid, reserved, subtree.
+	p = []byte{0, 127, 0, 0}
+	p = appendOID(p, s.Root, false)
+	if err := s.send(pduRegister, h.transaction, p); err != nil {
+		return err
+	}
+	if h, body, err = readPDU(r); err != nil {
+		return err
+	}
+	if err := checkResponse(h, body); err != nil {
+		return fmt.Errorf("agentx: register %s: %w", s.Root, err)
+	}
+
+	for {
+		h, body, err := readPDU(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				s.send(pduClose, 0, []byte{5, 0, 0, 0}) // reasonShutdown
+				return nil
+			}
+			return err
+		}
+		switch h.typ {
+		case pduClose:
+			return nil
+		case pduResponse:
+			// Answers to our pings; none are sent.
+		default:
+			if err := s.answer(h, body); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type header struct {
+	typ, flags                   byte
+	session, transaction, packet uint32
+	order                        binary.ByteOrder
+}
+
+func readPDU(r io.Reader) (header, []byte, error) {
+	var b [20]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return header{}, nil, fmt.Errorf("agentx: %w", err)
+	}
+	if b[0] != 1 {
+		return header{}, nil, fmt.Errorf("agentx: unsupported version %d", b[0])
+	}
+	h := header{typ: b[1], flags: b[2], order: binary.LittleEndian}
+	if h.flags&flagNetworkByteOrder != 0 {
+		h.order = binary.BigEndian
+	}
+	h.session = h.order.Uint32(b[4:])
+	h.transaction = h.order.Uint32(b[8:])
+	h.packet = h.order.Uint32(b[12:])
+	n := h.order.Uint32(b[16:])
+	if n > 1<<20 || n%4 != 0 {
+		return header{}, nil, errors.New("agentx: bad payload length")
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return header{}, nil, fmt.Errorf("agentx: %w", err)
+	}
+	return h, body, nil
+}
+
+func checkResponse(h header, body []byte) error {
+	if h.typ != pduResponse || len(body) < 8 {
+		return errors.New("unexpected PDU")
+	}
+	if e := h.order.Uint16(body[4:]); e != 0 {
+		return fmt.Errorf("error %d", e)
+	}
+	return nil
+}
+
+// send writes a PDU in network byte order.
+func (s *Subagent) send(typ byte, transaction uint32, payload []byte) error {
+	s.packet++
+	return s.write(typ, transaction, s.packet, payload)
+}
+
+func (s *Subagent) write(typ byte, transaction, packet uint32, payload []byte) error {
+	b := []byte{1, typ, flagNetworkByteOrder, 0}
+	b = binary.BigEndian.AppendUint32(b, s.session)
+	b = binary.BigEndian.AppendUint32(b, transaction)
+	b = binary.BigEndian.AppendUint32(b, packet)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	if _, err := s.w.Write(append(b, payload...)); err != nil {
+		return fmt.Errorf("agentx: %w", err)
+	}
+	return nil
+}
+
+// answer handles a request of the master.
+func (s *Subagent) answer(h header, body []byte) error {
+	var res []byte
+	var code uint16
+	d := decoder{b: body, order: h.order}
+	if h.flags&flagNonDefaultContext != 0 {
+		d.string()
+		code = errUnsupportedContext
+	}
+	switch {
+	case code != 0:
+	case h.typ == pduGet || h.typ == pduGetNext:
+		for d.err == nil && len(d.b) > 0 {
+			start, include := d.oid()
+			end, _ := d.oid()
+			if d.err != nil {
+				break
+			}
+			if h.typ == pduGet {
+				res = s.get(res, start)
+			} else {
+				res = s.next(res, start, include, end)
+			}
+		}
+	case h.typ == pduGetBulk:
+		nonRep, maxRep := int(d.uint16()), int(d.uint16())
+		type rng struct {
+			start, end OID
+			include    bool
+		}
+		var ranges []rng
+		for d.err == nil && len(d.b) > 0 {
+			start, include := d.oid()
+			end, _ := d.oid()
+			ranges = append(ranges, rng{start, end, include})
+		}
+		for i := 0; i < len(ranges) && i < nonRep; i++ {
+			res = s.next(res, ranges[i].start, ranges[i].include, ranges[i].end)
+		}
+		rep := ranges[min(nonRep, len(ranges)):]
+		for n := 0; n < maxRep && len(rep) > 0; n++ {
+			for i := range rep {
+				v, ok := s.following(rep[i].start, rep[i].include, rep[i].end)
+				if !ok {
+					res = appendNull(res, typeEndOfMibView, rep[i].start)
+					continue
+				}
+				res = appendVar(res, v)
+				rep[i].start, rep[i].include = v.OID, false
+			}
+		}
+	case h.typ == pduTestSet:
+		code = errNotWritable
+	case h.typ == pduCommitSet, h.typ == pduUndoSet, h.typ == pduCleanupSet:
+	default:
+		code = errProcessing
+	}
+	if d.err != nil {
+		res, code = nil, errParse
+	}
+	p := make([]byte, 8, 8+len(res))
+	binary.BigEndian.PutUint16(p[4:], code)
+	return s.write(pduResponse, h.transaction, h.packet, append(p, res...))
+}
+
+// get appends the varbind answering a Get of name.
+func (s *Subagent) get(res []byte, name OID) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, found := slices.BinarySearchFunc(s.vars, name, func(v Var, o OID) int { return slices.Compare(v.OID, o) })
+	if found {
+		return appendVar(res, s.vars[i])
+	}
+	typ := uint16(typeNoSuchObject)
+	// An object whose instances exist but not this one.
+	if i < len(s.vars) && len(name) > 1 && hasPrefix(s.vars[i].OID, name[:len(name)-1]) {
+		typ = typeNoSuchInstance
+	}
+	return appendNull(res, typ, name)
+}
+
+// following returns the first variable after start, or at it with
+// include, and before end unless end is empty.
+func (s *Subagent) following(start OID, include bool, end OID) (Var, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, found := slices.BinarySearchFunc(s.vars, start, func(v Var, o OID) int { return slices.Compare(v.OID, o) })
+	if found && !include {
+		i++
+	}
+	if i >= len(s.vars) || len(end) > 0 && slices.Compare(s.vars[i].OID, end) >= 0 {
+		return Var{}, false
+	}
+	return s.vars[i], true
+}
+
+// next appends the varbind answering a GetNext of the range.
+func (s *Subagent) next(res []byte, start OID, include bool, end OID) []byte {
+	if v, ok := s.following(start, include, end); ok {
+		return appendVar(res, v)
+	}
+	return appendNull(res, typeEndOfMibView, start)
+}
+
+func hasPrefix(o, prefix OID) bool {
+	return len(o) >= len(prefix) && slices.Equal(o[:len(prefix)], prefix)
+}
+
+func appendOID(b []byte, o OID, include bool) []byte {
+	inc := byte(0)
+	if include {
+		inc = 1
+	}
+	b = append(b, byte(len(o)), 0, inc, 0)
+	for _, n := range o {
+		b = binary.BigEndian.AppendUint32(b, n)
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	b = append(b, s...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func appendNull(b []byte, typ uint16, name OID) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = append(b, 0, 0)
+	return appendOID(b, name, false)
+}
+
+func appendVar(b []byte, v Var) []byte {
+	switch x := v.Value.(type) {
+	case int:
+		b = binary.BigEndian.AppendUint16(b, typeInteger)
+		b = appendOID(append(b, 0, 0), v.OID, false)
+		return binary.BigEndian.AppendUint32(b, uint32(int32(x)))
+	default:
+		b = binary.BigEndian.AppendUint16(b, typeOctetString)
+		b = appendOID(append(b, 0, 0), v.OID, false)
+		return appendString(b, fmt.Sprint(x))
+	}
+}
+
+// decoder reads the fields of a payload; the first error sticks.
+type decoder struct {
+	b     []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.b) {
+		d.err = errors.New("agentx: truncated PDU")
+		return nil
+	}
+	p := d.b[:n]
+	d.b = d.b[n:]
+	return p
+}
+
+func (d *decoder) uint16() uint16 {
+	if p := d.take(2); p != nil {
+		return d.order.Uint16(p)
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	p := d.take(4)
+	if p == nil {
+		return ""
+	}
+	n := int(d.order.Uint32(p))
+	s := d.take((n + 3) &^ 3)
+	if s == nil {
+		return ""
+	}
+	return string(s[:n])
+}
+
+// oid reads an OID and its include flag, expanding the 1.3.6.1.<prefix>
+// shorthand.
+func (d *decoder) oid() (OID, bool) {
+	p := d.take(4)
+	if p == nil {
+		return nil, false
+	}
+	n, prefix, include := int(p[0]), p[1], p[2] != 0
+	var o OID
+	if prefix != 0 {
+		o = OID{1, 3, 6, 1, uint32(prefix)}
+	}
+	for range n {
+		q := d.take(4)
+		if q == nil {
+			return nil, false
+		}
+		o = append(o, d.order.Uint32(q))
+	}
+	return o, include
+}
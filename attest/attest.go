@@ -0,0 +1,232 @@
+// Package attest produces and verifies TPM 2.0 quotes that bind the
+// fingerprint hash to the platform's boot state.
+//
+// The quote is signed by an attestation key (AK) derived deterministically
+// from the endorsement hierarchy, so the same TPM always yields the same AK.
+// The AK is not certified here: verifiers are expected to pin AKName on
+// first enrollment (or establish it through their own EK credential flow)
+// and compare it on every subsequent verification.
+package attest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// DefaultPCRs are the SHA-256 PCRs quoted when none are requested: firmware,
+// firmware configuration, boot loader and Secure Boot policy.
+var DefaultPCRs = []uint{0, 1, 2, 3, 4, 5, 6, 7}
+
+// Attestation is the self-contained artifact handed to a verifier.
+type Attestation struct {
+	SnapshotHash string          `json:"snapshot_hash"`
+	Nonce        []byte          `json:"nonce,omitempty"`
+	AKPublic     []byte          `json:"ak_public"`
+	Quoted       []byte          `json:"quoted"`
+	Signature    []byte          `json:"signature"`
+	PCRs         map[uint][]byte `json:"pcrs"`
+}
+
+// Result is the verified content of an attestation.
+type Result struct {
+	SnapshotHash string
+	// AKName is the hex TPM name of the attestation key, suitable for pinning.
+	AKName string
+	PCRs   map[uint][]byte
+}
+
+// Quote creates an attestation over pcrs (DefaultPCRs when empty) whose
+// extra data commits to the fingerprint hash of snap and the verifier nonce.
+func Quote(tpm transport.TPM, snap fingerprint.Snapshot, nonce []byte, pcrs []uint) (*Attestation, error) {
+	if len(pcrs) == 0 {
+		pcrs = DefaultPCRs
+	}
+	hash := snap.Hash()
+	ak, err := tpm2.CreatePrimary{
+		PrimaryHandle: tpm2.TPMRHEndorsement,
+		InPublic:      tpm2.New2B(akTemplate),
+	}.Execute(tpm)
+	if err != nil {
+		return nil, fmt.Errorf("attest: create AK: %w", err)
+	}
+	defer tpm2.FlushContext{FlushHandle: ak.ObjectHandle}.Execute(tpm)
+
+	sel := selection(pcrs)
+	q, err := tpm2.Quote{
+		SignHandle: tpm2.NamedHandle{
+			Handle: ak.ObjectHandle,
+			Name:   ak.Name,
+		},
+		QualifyingData: tpm2.TPM2BData{Buffer: extraData(hash, nonce)},
+		InScheme:       tpm2.TPMTSigScheme{Scheme: tpm2.TPMAlgNull},
+		PCRSelect:      sel,
+	}.Execute(tpm)
+	if err != nil {
+		return nil, fmt.Errorf("attest: quote: %w", err)
+	}
+	values, err := readPCRs(tpm, pcrs)
+	if err != nil {
+		return nil, err
+	}
+	return &Attestation{
+		SnapshotHash: hash,
+		Nonce:        nonce,
+		AKPublic:     ak.OutPublic.Bytes(),
+		Quoted:       q.Quoted.Bytes(),
+		Signature:    tpm2.Marshal(q.Signature),
+		PCRs:         values,
+	}, nil
+}
+
+// Verify checks the quote signature against the embedded AK, the binding of
+// the snapshot hash and nonce, and that the reported PCR values match the
+// quoted digest. Callers must additionally compare Result.AKName with the
+// AK they trust for this machine.
+func Verify(a *Attestation, nonce []byte) (*Result, error) {
+	pub, err := tpm2.Unmarshal[tpm2.TPMTPublic](a.AKPublic)
+	if err != nil {
+		return nil, fmt.Errorf("attest: ak public: %w", err)
+	}
+	if !pub.ObjectAttributes.Restricted || !pub.ObjectAttributes.FixedTPM {
+		return nil, errors.New("attest: AK is not a restricted TPM-resident key")
+	}
+	key, err := tpm2.Pub(*pub)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("attest: AK is not an ECDSA key")
+	}
+	sig, err := tpm2.Unmarshal[tpm2.TPMTSignature](a.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("attest: signature: %w", err)
+	}
+	es, err := sig.Signature.ECDSA()
+	if err != nil {
+		return nil, fmt.Errorf("attest: signature: %w", err)
+	}
+	digest := sha256.Sum256(a.Quoted)
+	r := new(big.Int).SetBytes(es.SignatureR.Buffer)
+	s := new(big.Int).SetBytes(es.SignatureS.Buffer)
+	if !ecdsa.Verify(ecKey, digest[:], r, s) {
+		return nil, errors.New("attest: bad quote signature")
+	}
+
+	att, err := tpm2.Unmarshal[tpm2.TPMSAttest](a.Quoted)
+	if err != nil {
+		return nil, fmt.Errorf("attest: quoted: %w", err)
+	}
+	if att.Type != tpm2.TPMSTAttestQuote {
+		return nil, errors.New("attest: not a quote")
+	}
+	if !bytes.Equal(att.ExtraData.Buffer, extraData(a.SnapshotHash, nonce)) {
+		return nil, errors.New("attest: snapshot hash or nonce mismatch")
+	}
+	info, err := att.Attested.Quote()
+	if err != nil {
+		return nil, err
+	}
+	idx := sortedIndices(a.PCRs)
+	if len(info.PCRSelect.PCRSelections) != 1 ||
+		info.PCRSelect.PCRSelections[0].Hash != tpm2.TPMAlgSHA256 ||
+		!bytes.Equal(info.PCRSelect.PCRSelections[0].PCRSelect, tpm2.PCClientCompatible.PCRs(idx...)) {
+		return nil, errors.New("attest: PCR selection does not match quote")
+	}
+	if !bytes.Equal(info.PCRDigest.Buffer, pcrDigest(a.PCRs)) {
+		return nil, errors.New("attest: PCR values do not match quote")
+	}
+	name, err := tpm2.ObjectName(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{SnapshotHash: a.SnapshotHash, AKName: hex.EncodeToString(name.Buffer), PCRs: a.PCRs}, nil
+}
+
+var akTemplate = tpm2.TPMTPublic{
+	Type:    tpm2.TPMAlgECC,
+	NameAlg: tpm2.TPMAlgSHA256,
+	ObjectAttributes: tpm2.TPMAObject{
+		FixedTPM:            true,
+		FixedParent:         true,
+		SensitiveDataOrigin: true,
+		UserWithAuth:        true,
+		NoDA:                true,
+		Restricted:          true,
+		SignEncrypt:         true,
+	},
+	Parameters: tpm2.NewTPMUPublicParms(
+		tpm2.TPMAlgECC,
+		&tpm2.TPMSECCParms{
+			Scheme: tpm2.TPMTECCScheme{
+				Scheme: tpm2.TPMAlgECDSA,
+				Details: tpm2.NewTPMUAsymScheme(
+					tpm2.TPMAlgECDSA,
+					&tpm2.TPMSSigSchemeECDSA{HashAlg: tpm2.TPMAlgSHA256},
+				),
+			},
+			CurveID: tpm2.TPMECCNistP256,
+		},
+	),
+}
+
+func extraData(hash string, nonce []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(hash))
+	h.Write(nonce)
+	return h.Sum(nil)
+}
+
+func selection(pcrs []uint) tpm2.TPMLPCRSelection {
+	return tpm2.TPMLPCRSelection{
+		PCRSelections: []tpm2.TPMSPCRSelection{{
+			Hash:      tpm2.TPMAlgSHA256,
+			PCRSelect: tpm2.PCClientCompatible.PCRs(pcrs...),
+		}},
+	}
+}
+
+func readPCRs(tpm transport.TPM, pcrs []uint) (map[uint][]byte, error) {
+	out := map[uint][]byte{}
+	for _, p := range pcrs {
+		rsp, err := tpm2.PCRRead{PCRSelectionIn: selection([]uint{p})}.Execute(tpm)
+		if err != nil {
+			return nil, fmt.Errorf("attest: read PCR %d: %w", p, err)
+		}
+		if len(rsp.PCRValues.Digests) != 1 {
+			return nil, fmt.Errorf("attest: PCR %d not available in SHA-256 bank", p)
+		}
+		out[p] = rsp.PCRValues.Digests[0].Buffer
+	}
+	return out, nil
+}
+
+// pcrDigest mirrors the TPM's quote digest: SHA-256 over the selected PCR
+// values in ascending index order.
+func pcrDigest(values map[uint][]byte) []byte {
+	h := sha256.New()
+	for _, i := range sortedIndices(values) {
+		h.Write(values[i])
+	}
+	return h.Sum(nil)
+}
+
+func sortedIndices(values map[uint][]byte) []uint {
+	idx := make([]uint, 0, len(values))
+	for i := range values {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool { return idx[a] < idx[b] })
+	return idx
+}
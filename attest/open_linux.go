@@ -0,0 +1,18 @@
+package attest
+
+import (
+	"errors"
+	"os"
+
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/linuxtpm"
+)
+
+// Open opens the kernel resource manager, falling back to the raw device.
+func Open() (transport.TPMCloser, error) {
+	t, err := linuxtpm.Open("/dev/tpmrm0")
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return t, err
+	}
+	return linuxtpm.Open("/dev/tpm0")
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package attest
+
+import (
+	"errors"
+
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+// Open fails: the TPM device is only opened on Linux. Quotes can still be
+// verified anywhere.
+func Open() (transport.TPMCloser, error) {
+	return nil, errors.New("attest: TPM access needs Linux")
+}
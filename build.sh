@@ -1,15 +1,23 @@
+#!/bin/sh
+# Reproducible static build: the same source tree, Go version and flags give
+# a bit-identical binary. -trimpath drops local paths, an empty build ID
+# removes the only remaining per-host input, and the module list, versions
+# and VCS revision stay embedded for the `sbom` command.
+set -eu
+
 unset GOROOT
 unset GOTOOLDIR
 export PATH=/usr/local/go/bin:$PATH
 hash -r
 
-which go
 go version
-go env GOROOT GOTOOLDIR
-ls /usr/local/go/pkg/tool/linux_amd64/compile
-
-go clean -cache
-                      CGO_ENABLED=0 GOOS=linux GOARCH=amd64 \
-                      go build -trimpath -tags netgo,osusergo \
-                        -ldflags="-s -w" \
-                        -o fingerprint
+
+OUT=${OUT:-linuxsystemfingerprint}
+EXTRA_LDFLAGS=${EXTRA_LDFLAGS:-}
+
+CGO_ENABLED=0 GOOS=${GOOS:-linux} GOARCH=${GOARCH:-amd64} GOFLAGS=-mod=readonly \
+	go build -trimpath -buildvcs=auto -tags netgo,osusergo \
+	-ldflags="-s -w -buildid= $EXTRA_LDFLAGS" \
+	-o "$OUT"
+
+sha256sum "$OUT"
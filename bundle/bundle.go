@@ -0,0 +1,293 @@
+// Package bundle packs snapshots into a signed and encrypted file for
+// sites without a network path to the fleet server: the file is carried
+// across the air gap and imported there.
+//
+// A bundle is a JSON document
+//
+//	{"format": "lsf-bundle/1", "recipient": "<kid>", "epk": "...", "nonce": "...", "ciphertext": "..."}
+//
+// whose ciphertext is AES-256-GCM under a key agreed between a fresh X25519
+// key (epk) and the recipient's, through HKDF-SHA256. The plaintext is the
+// gzip-compressed signed contents, signed before encryption so that the
+// signer's identity travels encrypted too:
+//
+//	{"contents": {"manifest": {...}, "snapshots": [...]}, "alg": "EdDSA", "kid": "<kid>", "sig": "..."}
+//
+// Key IDs are fingerprint.KeyID of the public keys.
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Format identifies the bundle layout.
+const Format = "lsf-bundle/1"
+
+// maxBytes bounds a bundle and its decompressed contents.
+const maxBytes = 256 << 20
+
+var (
+	// ErrNotRecipient is returned when a bundle is encrypted to another key.
+	ErrNotRecipient = errors.New("bundle: encrypted to a different key")
+	// ErrUntrusted is returned when a bundle's signer is not trusted.
+	ErrUntrusted = errors.New("bundle: signed by an untrusted key")
+)
+
+// Manifest describes a bundle and the agent that wrote it.
+type Manifest struct {
+	// ID is random; importers use it to refuse a bundle twice.
+	ID       string                 `json:"id"`
+	Created  time.Time              `json:"created"`
+	Hostname string                 `json:"hostname,omitempty"`
+	Agent    *fingerprint.BuildInfo `json:"agent,omitempty"`
+}
+
+// Entry is a snapshot with the time it was collected or queued.
+type Entry struct {
+	Time     time.Time       `json:"time"`
+	Snapshot json.RawMessage `json:"snapshot"`
+}
+
+// Contents is what a bundle carries.
+type Contents struct {
+	Manifest  Manifest `json:"manifest"`
+	Snapshots []Entry  `json:"snapshots"`
+}
+
+// NewID returns a random bundle ID.
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type envelope struct {
+	Format     string `json:"format"`
+	Recipient  string `json:"recipient"`
+	Ephemeral  []byte `json:"epk"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type signed struct {
+	Contents  json.RawMessage `json:"contents"`
+	Algorithm string          `json:"alg"`
+	KeyID     string          `json:"kid"`
+	Signature []byte          `json:"sig"`
+}
+
+// Seal signs c with s, encrypts it to the X25519 key recipient and writes
+// the bundle to w.
+func Seal(w io.Writer, c Contents, s crypto.Signer, recipient *ecdh.PublicKey) error {
+	if recipient.Curve() != ecdh.X25519() {
+		return errors.New("bundle: recipient key is not an X25519 key")
+	}
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	alg, sig, err := fingerprint.SignBytes(s, body)
+	if err != nil {
+		return err
+	}
+	inner, err := json.Marshal(signed{Contents: body, Algorithm: alg, KeyID: fingerprint.KeyID(s.Public()), Signature: sig})
+	if err != nil {
+		return err
+	}
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	zw.Write(inner)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	shared, err := eph.ECDH(recipient)
+	if err != nil {
+		return err
+	}
+	env := envelope{Format: Format, Recipient: fingerprint.KeyID(recipient), Ephemeral: eph.PublicKey().Bytes()}
+	aead, err := newAEAD(shared, env.Ephemeral, recipient.Bytes())
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(env.Nonce)
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plain.Bytes(), env.aad())
+	return json.NewEncoder(w).Encode(env)
+}
+
+// Open decrypts a bundle with the recipient key and checks that one of
+// trusted signed it.
+func Open(r io.Reader, key *ecdh.PrivateKey, trusted []crypto.PublicKey) (*Contents, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxBytes {
+		return nil, errors.New("bundle: too large")
+	}
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil || env.Format != Format {
+		return nil, fmt.Errorf("bundle: not an %s file", Format)
+	}
+	if env.Recipient != fingerprint.KeyID(key.PublicKey()) {
+		return nil, ErrNotRecipient
+	}
+	eph, err := ecdh.X25519().NewPublicKey(env.Ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	shared, err := key.ECDH(eph)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	aead, err := newAEAD(shared, env.Ephemeral, key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, errors.New("bundle: malformed nonce")
+	}
+	plain, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.aad())
+	if err != nil {
+		return nil, errors.New("bundle: decryption failed; the file is damaged or was altered")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	inner, err := io.ReadAll(io.LimitReader(zr, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if len(inner) > maxBytes {
+		return nil, errors.New("bundle: too large")
+	}
+
+	var sc signed
+	if err := json.Unmarshal(inner, &sc); err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	var signer crypto.PublicKey
+	for _, k := range trusted {
+		if fingerprint.KeyID(k) == sc.KeyID {
+			signer = k
+			break
+		}
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("%w (kid %s)", ErrUntrusted, sc.KeyID)
+	}
+	if err := fingerprint.VerifyBytes(signer, sc.Algorithm, sc.Contents, sc.Signature); err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	var c Contents
+	if err := json.Unmarshal(sc.Contents, &c); err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if c.Manifest.ID == "" {
+		return nil, errors.New("bundle: manifest without an ID")
+	}
+	return &c, nil
+}
+
+// aad binds the cleartext header to the ciphertext.
+func (e envelope) aad() []byte {
+	return []byte(e.Format + "\n" + e.Recipient)
+}
+
+func newAEAD(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, Format, 32)
+	if err != nil {
+		return nil, err
+	}
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(blk)
+}
+
+// LoadRecipient reads the X25519 public key of a bundle recipient from a
+// PEM file, as written by "openssl pkey -pubout".
+func LoadRecipient(path string) (*ecdh.PublicKey, error) {
+	keys, err := LoadPublicKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := keys[0].(*ecdh.PublicKey)
+	if !ok || k.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("bundle: %s: not an X25519 public key", path)
+	}
+	return k, nil
+}
+
+// LoadPublicKeys reads every public key of a PEM file.
+func LoadPublicKeys(path string) ([]crypto.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []crypto.PublicKey
+	for {
+		var blk *pem.Block
+		if blk, b = pem.Decode(b); blk == nil {
+			break
+		}
+		k, err := x509.ParsePKIXPublicKey(blk.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %s: %w", path, err)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("bundle: %s: no PEM public key", path)
+	}
+	return keys, nil
+}
+
+// LoadKey reads the recipient's X25519 private key from a PKCS#8 PEM
+// file, as written by "openssl genpkey -algorithm X25519".
+func LoadKey(path string) (*ecdh.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, fmt.Errorf("bundle: %s: no PEM data", path)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %s: %w", path, err)
+	}
+	ek, ok := k.(*ecdh.PrivateKey)
+	if !ok || ek.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("bundle: %s: not an X25519 private key", path)
+	}
+	return ek, nil
+}
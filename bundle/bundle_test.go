@@ -0,0 +1,97 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func sealed(t *testing.T, signer ed25519.PrivateKey, to *ecdh.PublicKey) ([]byte, Contents) {
+	t.Helper()
+	c := Contents{
+		Manifest:  Manifest{ID: NewID(), Created: time.Unix(1700000000, 0).UTC(), Hostname: "app-03"},
+		Snapshots: []Entry{{Time: time.Unix(1700000000, 0).UTC(), Snapshot: json.RawMessage(`{"hostname":"app-03"}`)}},
+	}
+	var buf bytes.Buffer
+	if err := Seal(&buf, c, signer, to); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), c
+}
+
+func keys(t *testing.T) (ed25519.PrivateKey, *ecdh.PrivateKey) {
+	t.Helper()
+	_, sk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rk, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, rk
+}
+
+func TestRoundTrip(t *testing.T) {
+	sk, rk := keys(t)
+	b, want := sealed(t, sk, rk.PublicKey())
+	got, err := Open(bytes.NewReader(b), rk, []crypto.PublicKey{sk.Public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest.ID != want.Manifest.ID || !got.Manifest.Created.Equal(want.Manifest.Created) ||
+		len(got.Snapshots) != 1 || string(got.Snapshots[0].Snapshot) != `{"hostname":"app-03"}` {
+		t.Errorf("Open = %+v, want %+v", got, want)
+	}
+	if bytes.Contains(b, []byte("app-03")) {
+		t.Error("bundle leaks its contents in clear text")
+	}
+}
+
+func TestTamperedCiphertext(t *testing.T) {
+	sk, rk := keys(t)
+	b, _ := sealed(t, sk, rk.PublicKey())
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		t.Fatal(err)
+	}
+	env.Ciphertext[len(env.Ciphertext)/2] ^= 1
+	b, _ = json.Marshal(env)
+	if _, err := Open(bytes.NewReader(b), rk, []crypto.PublicKey{sk.Public()}); err == nil {
+		t.Fatal("Open accepted a tampered ciphertext")
+	}
+}
+
+func TestUntrustedSigner(t *testing.T) {
+	sk, rk := keys(t)
+	other, _ := keys(t)
+	b, _ := sealed(t, sk, rk.PublicKey())
+	if _, err := Open(bytes.NewReader(b), rk, []crypto.PublicKey{other.Public()}); !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("Open = %v, want ErrUntrusted", err)
+	}
+}
+
+func TestWrongRecipient(t *testing.T) {
+	sk, rk := keys(t)
+	_, other := keys(t)
+	b, _ := sealed(t, sk, rk.PublicKey())
+	if _, err := Open(bytes.NewReader(b), other, []crypto.PublicKey{sk.Public()}); !errors.Is(err, ErrNotRecipient) {
+		t.Fatalf("Open = %v, want ErrNotRecipient", err)
+	}
+	// A forged recipient ID does not help either: the key agreement fails.
+	var env envelope
+	json.Unmarshal(b, &env)
+	env.Recipient = fingerprint.KeyID(other.PublicKey())
+	b, _ = json.Marshal(env)
+	if _, err := Open(bytes.NewReader(b), other, []crypto.PublicKey{sk.Public()}); err == nil {
+		t.Fatal("Open decrypted a bundle for another key")
+	}
+}
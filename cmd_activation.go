@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func runActivationCode(args []string) error {
+	fs := flag.NewFlagSet("activation-code", flag.ExitOnError)
+	secret := fs.String("secret", "", "shared activation secret")
+	secretFile := fs.String("secret-file", "", "read the activation secret from a file")
+	hash := fs.String("hash", "", "fingerprint hash to use instead of collecting one")
+	verify := fs.String("verify", "", "verify the given code instead of printing one")
+	fs.Parse(args)
+
+	key := []byte(*secret)
+	if *secretFile != "" {
+		b, err := os.ReadFile(*secretFile)
+		if err != nil {
+			return err
+		}
+		key = []byte(strings.TrimSpace(string(b)))
+	}
+	if len(key) == 0 {
+		return errors.New("-secret or -secret-file is required")
+	}
+	h := *hash
+	if h == "" {
+		h = fingerprint.GetSnapshot().Hash()
+	}
+	if *verify != "" {
+		if !fingerprint.VerifyActivationCode(key, h, *verify) {
+			return errors.New("activation code does not match")
+		}
+		fmt.Println("ok")
+		return nil
+	}
+	fmt.Println(fingerprint.ActivationCode(key, h))
+	return nil
+}
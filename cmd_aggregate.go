@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/fleet"
+)
+
+// runAggregate reports per-field uniqueness over snapshot files collected
+// from a fleet and warns about fields that are duplicated across machines.
+func runAggregate(args []string) error {
+	fs := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	all := fs.Bool("all", false, "analyze every snapshot field, not only the hash components")
+	asJSON := fs.Bool("json", false, "print statistics as JSON")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: aggregate [-all] [-json] <snapshot files or directories>...")
+	}
+
+	snaps, err := loadSnapshots(fs.Args())
+	if err != nil {
+		return err
+	}
+	fields := fleet.Components
+	if *all {
+		fields = fleet.All
+	}
+	stats := fleet.Stats(snaps, fields)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+	fmt.Printf("%d snapshots\n\n", len(snaps))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tMACHINES\tDISTINCT\tSHARED\tUNIQUENESS\tENTROPY")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.3f\t%.2f\n", s.Field, s.Machines, s.Distinct, s.Shared, s.Uniqueness, s.EntropyBits)
+	}
+	tw.Flush()
+	for _, s := range stats {
+		if s.Warning == "" {
+			continue
+		}
+		fmt.Printf("\nwarning: %s: %s\n", s.Field, s.Warning)
+		for _, t := range s.Top {
+			fmt.Printf("  %4d × %s\n", t.Machines, t.Value)
+		}
+	}
+	return nil
+}
+
+// loadSnapshots reads snapshot JSON files; directories contribute their
+// *.json files.
+func loadSnapshots(paths []string) ([]fingerprint.Snapshot, error) {
+	var files []string
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		m, err := filepath.Glob(filepath.Join(p, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, m...)
+	}
+	var out []fingerprint.Snapshot
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s, err := fingerprint.Unmarshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"AurFingerprintAgent/attest"
+	"AurFingerprintAgent/fingerprint"
+)
+
+func runAttest(args []string) error {
+	fs := flag.NewFlagSet("attest", flag.ExitOnError)
+	nonceHex := fs.String("nonce", "", "verifier nonce in hex")
+	pcrList := fs.String("pcrs", "", "comma separated SHA-256 PCR indices (default 0-7)")
+	verify := fs.String("verify", "", "verify an attestation file instead of producing one")
+	fs.Parse(args)
+
+	nonce, err := hex.DecodeString(*nonceHex)
+	if err != nil {
+		return fmt.Errorf("-nonce: %w", err)
+	}
+	if *verify != "" {
+		b, err := os.ReadFile(*verify)
+		if err != nil {
+			return err
+		}
+		var a attest.Attestation
+		if err := json.Unmarshal(b, &a); err != nil {
+			return err
+		}
+		res, err := attest.Verify(&a, nonce)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(res)
+	}
+
+	var pcrs []uint
+	for _, f := range strings.Split(*pcrList, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(f, 10, 8)
+		if err != nil {
+			return fmt.Errorf("-pcrs: %w", err)
+		}
+		pcrs = append(pcrs, uint(n))
+	}
+	tpm, err := attest.Open()
+	if err != nil {
+		return err
+	}
+	defer tpm.Close()
+	a, err := attest.Quote(tpm, fingerprint.GetSnapshot(), nonce, pcrs)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(a)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"AurFingerprintAgent/bundle"
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/fleet"
+	"AurFingerprintAgent/redact"
+	"AurFingerprintAgent/signer"
+	"AurFingerprintAgent/spool"
+)
+
+// runExportBundle packs snapshots into a signed bundle encrypted to the
+// fleet server, for sites that carry inventory across an air gap.
+func runExportBundle(args []string) error {
+	fs := flag.NewFlagSet("export-bundle", flag.ExitOnError)
+	out := fs.String("o", "inventory.lsfb", "write the bundle here")
+	recipient := fs.String("recipient", "", "PEM X25519 public key of the fleet server that imports the bundle")
+	signKey := fs.String("sign-key", "", "signing key file, tpm:// handle or pkcs11: URI")
+	fresh := fs.Bool("collect", true, "pack a freshly collected snapshot")
+	spoolDir := fs.String("spool-dir", "", "also pack the snapshots queued here, removing them once the bundle is written")
+	profile := fs.String("profile", "", "redaction profile (JSON) applied to every packed snapshot")
+	collect := collectFlags(fs)
+	fs.Parse(args)
+	if *recipient == "" || *signKey == "" {
+		return errors.New("usage: export-bundle -recipient server.pub -sign-key key [flags] [snapshot.json ...]")
+	}
+	to, err := bundle.LoadRecipient(*recipient)
+	if err != nil {
+		return err
+	}
+	var prof redact.Profile
+	if *profile != "" {
+		if prof, err = redact.Load(*profile); err != nil {
+			return err
+		}
+	}
+	k, err := signer.Open(*signKey)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	c := bundle.Contents{Manifest: bundle.Manifest{ID: bundle.NewID(), Created: time.Now().UTC(), Agent: fingerprint.AgentBuild()}}
+	c.Manifest.Hostname, _ = os.Hostname()
+	add := func(t time.Time, snap fingerprint.Snapshot) error {
+		view, err := prof.Apply(snap)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(view)
+		if err != nil {
+			return err
+		}
+		c.Snapshots = append(c.Snapshots, bundle.Entry{Time: t.UTC(), Snapshot: b})
+		return nil
+	}
+	var q *spool.Queue
+	var queued []string
+	if *spoolDir != "" {
+		if q, err = spool.Open(*spoolDir, 0, 0); err != nil {
+			return err
+		}
+		if queued, err = q.List(); err != nil {
+			return err
+		}
+		for _, name := range queued {
+			b, err := q.Read(name)
+			if err != nil {
+				return err
+			}
+			snap, err := fingerprint.Unmarshal(b)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := add(spool.QueuedAt(name), snap); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range fs.Args() {
+		snap, fi, err := readSnapshotFile(name)
+		if err != nil {
+			return err
+		}
+		if err := add(fi.ModTime(), snap); err != nil {
+			return err
+		}
+	}
+	if *fresh {
+		if err := add(time.Now(), collect()); err != nil {
+			return err
+		}
+	}
+	if len(c.Snapshots) == 0 {
+		return errors.New("export-bundle: nothing to pack")
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.Seal(&buf, c, k, to); err != nil {
+		return err
+	}
+	tmp := *out + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	for _, name := range queued {
+		if err := q.Remove(name); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "bundle %s: %d snapshots written to %s\n", c.Manifest.ID, len(c.Snapshots), *out)
+	return nil
+}
+
+func readSnapshotFile(name string) (fingerprint.Snapshot, os.FileInfo, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fingerprint.Snapshot{}, nil, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fingerprint.Snapshot{}, nil, err
+	}
+	snap, err := fingerprint.Unmarshal(b)
+	if err != nil {
+		return fingerprint.Snapshot{}, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return snap, fi, nil
+}
+
+// runImportBundle opens bundles written by export-bundle and files their
+// snapshots in the fleet server's store, printing each decision.
+func runImportBundle(args []string) error {
+	fs := flag.NewFlagSet("import-bundle", flag.ExitOnError)
+	keyPath := fs.String("key", "", "PKCS#8 PEM X25519 private key the bundles are encrypted to")
+	var trusted []crypto.PublicKey
+	fs.Func("trust", "PEM file of public keys whose bundles are accepted (repeatable)", func(v string) error {
+		keys, err := bundle.LoadPublicKeys(v)
+		trusted = append(trusted, keys...)
+		return err
+	})
+	openStore := fleetStoreFlags(fs)
+	fs.Parse(args)
+	if *keyPath == "" || len(trusted) == 0 || fs.NArg() == 0 {
+		return errors.New("usage: import-bundle -key server.key -trust agents.pem [flags] bundle.lsfb ...")
+	}
+	key, err := bundle.LoadKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	st, policy, closeStore, err := openStore(context.Background())
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	enc := json.NewEncoder(os.Stdout)
+	var errs []error
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c, err := bundle.Open(f, key, trusted)
+		f.Close()
+		if err == nil {
+			var ds []fleet.Decision
+			ds, err = fleet.Import(st, policy, c)
+			for _, d := range ds {
+				enc.Encode(d)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// runCollectors lists the built-in collectors with the files, commands and
+// sockets they use, for allow-lists and security reviews.
+func runCollectors(args []string) error {
+	fs := flag.NewFlagSet("collectors", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the list as JSON, with all paths")
+	fs.Parse(args)
+
+	list := fingerprint.Collectors()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tOPTION\tROOT\tNETWORK\tCOMMANDS\tSOCKETS")
+	for _, c := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, orDash(c.Option), yesNo(c.RequiresRoot), yesNo(c.Network),
+			orDash(strings.Join(c.Commands, ",")), orDash(strings.Join(c.Sockets, ",")))
+	}
+	return tw.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/csr"
+	"AurFingerprintAgent/enroll"
+	"AurFingerprintAgent/fingerprint"
+)
+
+// enrollPasswordEnv holds the basic auth password of enroll, kept off the
+// command line where other users could read it.
+const enrollPasswordEnv = "LSF_ENROLL_PASSWORD"
+
+func runEnroll(args []string) error {
+	fs := flag.NewFlagSet("enroll", flag.ExitOnError)
+	url := fs.String("url", "", "enrollment endpoint (EST base or full URL)")
+	keyPath := fs.String("key", "device.key", "device private key, generated when missing")
+	certPath := fs.String("cert", "device.crt", "where to store the issued certificate chain")
+	user := fs.String("user", "", "basic auth user name")
+	passFile := fs.String("pass-file", "", "read the basic auth password from this file instead of $"+enrollPasswordEnv)
+	org := fs.String("org", "", "subject organization")
+	snapHash := fs.String("snapshot-hash-oid", "", "embed the snapshot hash extension under this OID from your own enterprise arc")
+	timeout := fs.Duration("timeout", 5*time.Minute, "overall enrollment timeout")
+	configureHTTP := httpFlags(fs)
+	fs.Parse(args)
+	if *url == "" {
+		return errors.New("-url is required")
+	}
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+	pass := os.Getenv(enrollPasswordEnv)
+	if *passFile != "" {
+		b, err := os.ReadFile(*passFile)
+		if err != nil {
+			return err
+		}
+		pass = strings.TrimRight(string(b), "\r\n")
+	}
+
+	opts := csr.Options{Organization: *org}
+	if *snapHash != "" {
+		oid, err := csr.ParseOID(*snapHash)
+		if err != nil {
+			return err
+		}
+		opts.SnapshotHash, opts.SnapshotHashOID = true, oid
+	}
+
+	key, err := loadOrCreateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	der, err := csr.Create(key, fingerprint.GetSnapshot(), opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := &enroll.Client{URL: *url, Username: *user, Password: pass}
+	certs, err := c.Enroll(ctx, der)
+	if err != nil {
+		return err
+	}
+	if certs, err = leafFirst(certs, key.Public()); err != nil {
+		return err
+	}
+	var out []byte
+	for _, c := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	if err := os.WriteFile(*certPath, out, 0o644); err != nil {
+		return err
+	}
+	fmt.Println(certs[0].Subject.CommonName)
+	return nil
+}
+
+func loadOrCreateKey(path string) (crypto.Signer, error) {
+	k, err := readPrivateKey(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return k, err
+	}
+	ek, err := csr.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(ek)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+	return ek, nil
+}
+
+// readPrivateKey loads a PEM encoded PKCS#8 private key.
+func readPrivateKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type", path)
+	}
+	return s, nil
+}
+
+// leafFirst moves the certificate issued for pub to the front of certs. It
+// fails when none is, so a chain for another key is never stored.
+func leafFirst(certs []*x509.Certificate, pub crypto.PublicKey) ([]*x509.Certificate, error) {
+	eq, ok := pub.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil, fmt.Errorf("enroll: unsupported key type %T", pub)
+	}
+	for i, c := range certs {
+		if eq.Equal(c.PublicKey) {
+			certs[0], certs[i] = certs[i], certs[0]
+			return certs, nil
+		}
+	}
+	return nil, errors.New("enroll: no issued certificate matches the device key")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+)
+
+func TestLeafFirst(t *testing.T) {
+	device, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{PublicKey: other.Public()}
+	leaf := &x509.Certificate{PublicKey: device.Public()}
+
+	certs, err := leafFirst([]*x509.Certificate{ca, leaf}, device.Public())
+	if err != nil || len(certs) != 2 || certs[0] != leaf || certs[1] != ca {
+		t.Errorf("leafFirst = %v, %v, want the leaf first", certs, err)
+	}
+	if _, err := leafFirst([]*x509.Certificate{ca}, device.Public()); err == nil {
+		t.Error("leafFirst accepted a chain without the device certificate")
+	}
+	if _, err := leafFirst(nil, device.Public()); err == nil {
+		t.Error("leafFirst accepted an empty chain")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"AurFingerprintAgent/bundle"
+	"AurFingerprintAgent/fleet"
+)
+
+// runFleetServer receives snapshots from push, files them under the host
+// they belong to, and serves each host's history of field changes.
+func runFleetServer(args []string) error {
+	fs := flag.NewFlagSet("fleetserver", flag.ExitOnError)
+	addr := fs.String("listen", "127.0.0.1:8090", "HTTP listen address")
+	var keys []crypto.PublicKey
+	fs.Func("trust", "PEM file of public keys whose signed snapshots are accepted (repeatable)", func(v string) error {
+		k, err := bundle.LoadPublicKeys(v)
+		keys = append(keys, k...)
+		return err
+	})
+	certFile := fs.String("tls-cert", "", "serve HTTPS with this PEM certificate chain")
+	keyFile := fs.String("tls-key", "", "private key of -tls-cert")
+	clientCA := fs.String("client-ca", "", "with -tls-cert, accept unsigned snapshots from clients with a certificate issued by these PEM CAs")
+	insecure := fs.Bool("insecure", false, "accept unsigned snapshots from anyone, e.g. behind an authenticating proxy")
+	publicRead := fs.Bool("public-read", false, "serve the host list, change feeds and decisions to clients without a -client-ca certificate")
+	maxHistory := fs.Int("max-history", 1000, "snapshots kept per host, dropping the oldest (0 keeps all)")
+	openStore := fleetStoreFlags(fs)
+	fs.Parse(args)
+	if len(keys) == 0 && *clientCA == "" && !*insecure {
+		return errors.New("fleetserver: one of -trust, -client-ca or -insecure is required")
+	}
+	if (*certFile == "") != (*keyFile == "") || *clientCA != "" && *certFile == "" {
+		return errors.New("fleetserver: -tls-cert and -tls-key go together, and -client-ca needs them")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	st, policy, closeStore, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	cfg := fleet.Config{Store: st, Policy: policy, Keys: keys, ClientCerts: *clientCA != "", Insecure: *insecure, PublicRead: *publicRead, MaxHistory: *maxHistory}
+	srv := &http.Server{Addr: *addr, Handler: fleet.Handler(cfg), ReadHeaderTimeout: 10 * time.Second}
+	if *clientCA != "" {
+		pem, err := os.ReadFile(*clientCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("fleetserver: %s: no PEM certificates", *clientCA)
+		}
+		// Clients without a certificate may still push signed snapshots.
+		srv.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// fleetStoreFlags registers the storage and matching policy flags shared by
+// fleetserver and import-bundle and returns a function opening the store.
+func fleetStoreFlags(fs *flag.FlagSet) func(context.Context) (fleet.Store, fleet.Policy, func(), error) {
+	dir := fs.String("dir", "/var/lib/linuxsystemfingerprint/fleet", "directory storing the pushed snapshots")
+	pg := fs.String("postgres", "", "store snapshots in the PostgreSQL database at this URL instead of -dir")
+	policyFile := fs.String("policy", "", "JSON matching policy deciding which host a snapshot belongs to")
+	return func(ctx context.Context) (fleet.Store, fleet.Policy, func(), error) {
+		policy := fleet.DefaultPolicy
+		if *policyFile != "" {
+			var err error
+			if policy, err = fleet.LoadPolicy(*policyFile); err != nil {
+				return nil, policy, nil, err
+			}
+		}
+		if *pg == "" {
+			return fleet.DirStore{Dir: *dir}, policy, func() {}, nil
+		}
+		pgs, err := fleet.OpenPGStore(ctx, *pg)
+		if err != nil {
+			return nil, policy, nil, fmt.Errorf("postgres: %w", err)
+		}
+		return pgs, policy, func() { pgs.Close() }, nil
+	}
+}
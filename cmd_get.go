@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/query"
+)
+
+// runGet prints the values a query selects from a snapshot, one per line,
+// for shell scripts: get dmi.product_uuid, get 'network[?physical].mac'.
+// The snapshot is collected unless a file, or - for stdin, follows the
+// query. The query syntax is documented in package query.
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print each value as JSON, strings quoted")
+	collect := collectFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: get [flags] QUERY [FILE|-]")
+	}
+	q, err := query.Parse(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var snap fingerprint.Snapshot
+	switch fs.Arg(1) {
+	case "":
+		snap = collect()
+	default:
+		var b []byte
+		if fs.Arg(1) == "-" {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			b, err = os.ReadFile(fs.Arg(1))
+		}
+		if err != nil {
+			return err
+		}
+		if snap, err = fingerprint.Unmarshal(b); err != nil {
+			return err
+		}
+	}
+	doc, err := getDocument(snap)
+	if err != nil {
+		return err
+	}
+	values := q.Eval(doc)
+	if len(values) == 0 {
+		return fmt.Errorf("%s: no match", fs.Arg(0))
+	}
+	for _, v := range values {
+		if *asJSON {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+		} else {
+			fmt.Println(query.Format(v))
+		}
+	}
+	return nil
+}
+
+// getDocument is snap as decoded JSON, with the derived hash, as in
+// Snapshot.Flatten, and device ID added at the top level.
+func getDocument(snap fingerprint.Snapshot) (map[string]any, error) {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc map[string]any
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc["hash"] = snap.Hash()
+	doc["id"] = snap.ID()
+	return doc, nil
+}
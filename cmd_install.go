@@ -0,0 +1,520 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// networkCommands are the subcommands that talk to a server.
+var networkCommands = map[string]bool{"push": true, "provision": true, "enroll": true, "serve": true}
+
+// runInstall writes a hardened systemd unit and an AppArmor profile for
+// running the agent command after "--", confined to what the collectors
+// that command enables declare in the collector registry.
+func runInstall(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	systemd := fs.Bool("systemd", false, "write a systemd service unit")
+	apparmor := fs.Bool("apparmor", false, "write an AppArmor profile")
+	name := fs.String("name", "linux-fingerprint", "unit and profile name")
+	root := fs.String("root", "/", "install below this directory, e.g. an image being built")
+	exe := fs.String("exe", "", "path of the installed agent binary (default: this executable)")
+	toStdout := fs.Bool("print", false, "print the files instead of writing them")
+	var writable []string
+	fs.Func("writable", "a path the agent may write, e.g. a -spool-dir (repeatable)", func(v string) error {
+		writable = append(writable, v)
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: install [-systemd] [-apparmor] [flags] -- command [command flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cmdline := fs.Args()
+	if !*systemd && !*apparmor {
+		return errors.New("install: one of -systemd or -apparmor is required")
+	}
+	if len(cmdline) == 0 || strings.HasPrefix(cmdline[0], "-") {
+		return errors.New(`install: name the agent command after "--", e.g. -- push -url https://inventory.example.com -interval 1h`)
+	}
+	if *exe == "" {
+		p, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		*exe = p
+	}
+
+	s, err := commandSandbox(cmdline)
+	if err != nil {
+		return err
+	}
+	writable = append(writable, s.writable...)
+
+	var files []installFile
+	if *systemd {
+		files = append(files, installFile{"/etc/systemd/system/" + *name + ".service",
+			systemdUnit(*name, *exe, cmdline, s, writable, *apparmor)})
+	}
+	if *apparmor {
+		files = append(files, installFile{"/etc/apparmor.d/" + *name, apparmorProfile(*name, *exe, s, writable)})
+	}
+	for _, f := range files {
+		if *toStdout {
+			fmt.Printf("# %s\n%s\n", f.path, f.content)
+			continue
+		}
+		p := filepath.Join(*root, f.path)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(f.content), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "wrote", p)
+	}
+	if !*toStdout && *root == "/" {
+		if *apparmor {
+			fmt.Fprintf(os.Stderr, "load the profile: apparmor_parser -r /etc/apparmor.d/%s\n", *name)
+		}
+		if *systemd {
+			fmt.Fprintf(os.Stderr, "start the service: systemctl daemon-reload && systemctl enable --now %s\n", *name)
+		}
+	}
+	return nil
+}
+
+type installFile struct {
+	path, content string
+}
+
+// inputFlags name files the agent commands read: keys, certificates,
+// redaction profiles, target lists and policies.
+var inputFlags = []string{"sign-key", "targets", "profile", "tls-cert", "tls-key", "client-ca", "trust", "policy"}
+
+// commandSandbox derives what the agent command cmdline needs from its
+// flags: the collectors they enable, the files they name and the host
+// root they read below.
+func commandSandbox(cmdline []string) (sandbox, error) {
+	// The collection flags of the command decide which collectors run.
+	cfs := flag.NewFlagSet(cmdline[0], flag.ContinueOnError)
+	cfs.SetOutput(io.Discard)
+	opts := optionFlags(cfs)
+	if err := cfs.Parse(collectionArgs(cfs, cmdline[1:])); err != nil {
+		return sandbox{}, fmt.Errorf("install: %w", err)
+	}
+	// Labels and plugins are the agent's own files, wherever the flags
+	// put them, rather than the host's.
+	var own sandbox
+	info := slices.DeleteFunc(fingerprint.ActiveCollectors(opts()...), func(c fingerprint.CollectorInfo) bool {
+		switch c.Name {
+		case "labels":
+			if f := cfs.Lookup("labels-file").Value.String(); f != "" {
+				own.inputs = append(own.inputs, f)
+			}
+		case "plugins":
+			dir := cfs.Lookup("plugin-dir").Value.String()
+			own.paths = append(own.paths, dir+"/*")
+			own.commands = append(own.commands, dir+"/*")
+		default:
+			return false
+		}
+		return true
+	})
+	s := sandboxFor(info)
+	if root := cfs.Lookup("host-root").Value.String(); root != "" {
+		s = s.under(root)
+	}
+	s.paths = append(s.paths, own.paths...)
+	s.commands = append(s.commands, own.commands...)
+	s.inputs = own.inputs
+	s.network = s.network || networkCommands[cmdline[0]]
+	args := cmdline[1:]
+	d, _ := time.ParseDuration(flagValue(args, "interval"))
+	s.daemon = d > 0 || cmdline[0] == "serve"
+
+	for _, name := range inputFlags {
+		for _, v := range flagValues(args, name) {
+			switch {
+			case strings.HasPrefix(v, "tpm://"):
+				s.devices = append(s.devices, "/dev/tpm0", "/dev/tpmrm0")
+			case strings.HasPrefix(v, "pkcs11:"):
+				if _, q, ok := strings.Cut(v, "?"); ok {
+					if m, err := url.ParseQuery(q); err == nil && m.Get("module-path") != "" {
+						s.modules = append(s.modules, m.Get("module-path"))
+					}
+				}
+			default:
+				s.inputs = append(s.inputs, strings.TrimPrefix(v, "file://"))
+			}
+		}
+	}
+	if cmdline[0] == "provision" {
+		s.writable = append(s.writable, filepath.Dir(defaultIDFile))
+	}
+	if d := flagValue(args, "spool-dir"); d != "" {
+		s.writable = append(s.writable, d)
+	}
+	if f := flagValue(args, "delta-state"); f != "" {
+		s.writable = append(s.writable, filepath.Dir(f))
+	}
+	// Only a push with a release channel replaces its own binary.
+	s.selfUpdate = cmdline[0] == "push" && flagValue(args, "self-update") != ""
+	for _, l := range []*[]string{&s.paths, &s.commands, &s.inputs, &s.devices, &s.modules} {
+		slices.Sort(*l)
+		*l = slices.Compact(*l)
+	}
+	// The service runs from /, not from where install ran.
+	for _, p := range slices.Concat(s.paths, s.inputs, s.modules, s.writable) {
+		if !filepath.IsAbs(p) {
+			return sandbox{}, fmt.Errorf("install: %s: the command's files need absolute paths", p)
+		}
+	}
+	return s, nil
+}
+
+// collectionArgs picks the flags registered in fs out of args, which also
+// hold flags of the command fs does not know. Values of unknown flags are
+// dropped with them, so commands must use -flag=value for unknown boolean
+// flags followed by an argument.
+func collectionArgs(fs *flag.FlagSet, args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || !strings.HasPrefix(a, "-") {
+			break
+		}
+		n, _, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		f := fs.Lookup(n)
+		boolean := f != nil && isBoolFlag(f)
+		takesNext := !hasValue && !boolean && i+1 < len(args)
+		if f == nil {
+			// Unknown: skip a value unless the next item is a flag.
+			takesNext = !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-")
+		}
+		if f != nil {
+			out = append(out, a)
+			if takesNext {
+				out = append(out, args[i+1])
+			}
+		}
+		if takesNext {
+			i++
+		}
+	}
+	return out
+}
+
+// flagValue returns the value of flag name in args, the last one when
+// given more than once.
+func flagValue(args []string, name string) string {
+	if v := flagValues(args, name); len(v) > 0 {
+		return v[len(v)-1]
+	}
+	return ""
+}
+
+// flagValues returns the values of the repeatable flag name in args.
+func flagValues(args []string, name string) []string {
+	var out []string
+	for i, a := range args {
+		n, v, ok := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || n != name {
+			continue
+		}
+		if ok {
+			out = append(out, v)
+		} else if i+1 < len(args) {
+			out = append(out, args[i+1])
+		}
+	}
+	return out
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// sandbox is what the active collectors need from the system.
+type sandbox struct {
+	paths    []string
+	commands []string
+	sockets  []string
+	caps     []string
+	network  bool
+	// netns reports that namespaces are entered, which rules out
+	// RestrictNamespaces.
+	netns bool
+	// selfUpdate reports that the agent replaces and runs its own binary.
+	selfUpdate bool
+	// daemon reports that the command keeps running.
+	daemon bool
+	// inputs are the files the command's flags name, devices the device
+	// nodes it opens read-write and modules the libraries it loads.
+	inputs   []string
+	devices  []string
+	modules  []string
+	writable []string
+}
+
+// under moves the system paths the collectors read below root, where
+// -host-root mounts the host. Files of the agent process itself stay.
+func (s sandbox) under(root string) sandbox {
+	move := func(p string) string {
+		if strings.HasPrefix(p, "/proc/self/") {
+			return p
+		}
+		return filepath.Join(root, p)
+	}
+	paths := make([]string, len(s.paths))
+	for i, p := range s.paths {
+		paths[i] = move(p)
+	}
+	sockets := make([]string, len(s.sockets))
+	for i, so := range s.sockets {
+		kind, p, _ := strings.Cut(so, ":")
+		sockets[i] = kind + ":" + move(p)
+	}
+	s.paths, s.sockets = paths, sockets
+	return s
+}
+
+func sandboxFor(list []fingerprint.CollectorInfo) sandbox {
+	var s sandbox
+	for _, c := range list {
+		s.paths = append(s.paths, c.Paths...)
+		s.commands = append(s.commands, c.Commands...)
+		s.sockets = append(s.sockets, c.Sockets...)
+		s.network = s.network || c.Network
+		s.netns = s.netns || c.Name == "netns"
+		for _, r := range c.Requirements {
+			// Running as root, files only root may read need no capability.
+			if len(r.Capabilities) == 0 || slices.Contains(r.Access, "root-read") {
+				continue
+			}
+			s.caps = append(s.caps, r.Capabilities[0])
+		}
+	}
+	for _, l := range []*[]string{&s.paths, &s.commands, &s.sockets, &s.caps} {
+		slices.Sort(*l)
+		*l = slices.Compact(*l)
+	}
+	return s
+}
+
+// systemdUnit renders a service unit running cmdline as root with the
+// capabilities the collectors need and the file system read-only.
+func systemdUnit(name, exe string, cmdline []string, s sandbox, writable []string, apparmor bool) string {
+	var b strings.Builder
+	b.WriteString("# Generated by fingerprint install; regenerate after changing the command.\n")
+	b.WriteString("[Unit]\nDescription=Linux system fingerprint agent\n")
+	if s.network {
+		b.WriteString("Wants=network-online.target\nAfter=network-online.target\n")
+	}
+	b.WriteString("\n[Service]\n")
+	if s.daemon {
+		b.WriteString("Type=exec\nRestart=on-failure\nRestartSec=30s\n")
+	} else {
+		b.WriteString("Type=oneshot\n")
+	}
+	args := []string{exe}
+	args = append(args, cmdline...)
+	for i, a := range args {
+		args[i] = unitQuote(a)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	if apparmor {
+		fmt.Fprintf(&b, "AppArmorProfile=%s\n", name)
+	}
+	fmt.Fprintf(&b, "CapabilityBoundingSet=%s\n", strings.Join(s.caps, " "))
+	protectHome := "yes"
+	if slices.ContainsFunc(slices.Concat(s.inputs, writable), inHome) {
+		protectHome = "read-only"
+	}
+	fmt.Fprintf(&b, "NoNewPrivileges=yes\nProtectSystem=strict\nProtectHome=%s\nPrivateTmp=yes\n", protectHome)
+	b.WriteString("ProtectKernelTunables=yes\nProtectKernelModules=yes\nProtectKernelLogs=yes\nProtectControlGroups=yes\nProtectClock=yes\n")
+	b.WriteString("RestrictSUIDSGID=yes\nRestrictRealtime=yes\nLockPersonality=yes\nMemoryDenyWriteExecute=yes\nSystemCallArchitectures=native\n")
+	if !s.netns {
+		b.WriteString("RestrictNamespaces=yes\n")
+	}
+	families := "AF_UNIX AF_NETLINK"
+	if s.network {
+		families += " AF_INET AF_INET6"
+	}
+	fmt.Fprintf(&b, "RestrictAddressFamilies=%s\n", families)
+	if ro := readOnlyPaths(slices.Concat(s.paths, s.inputs)); len(ro) > 0 {
+		fmt.Fprintf(&b, "ReadOnlyPaths=%s\n", strings.Join(ro, " "))
+	}
+	if s.selfUpdate {
+		// Releases are renamed into place next to the executable.
+		writable = append(slices.Clip(writable), filepath.Dir(exe))
+	}
+	if len(writable) > 0 {
+		fmt.Fprintf(&b, "ReadWritePaths=%s\n", strings.Join(writable, " "))
+	}
+	b.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// inHome reports whether p lies below a directory ProtectHome=yes hides.
+func inHome(p string) bool {
+	for _, d := range []string{"/home", "/root", "/run/user"} {
+		if p == d || strings.HasPrefix(p, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyPaths reduces path patterns to the directories holding them,
+// optional ("-") since not every system has them. The kernel's virtual
+// file systems are left to the Protect* settings.
+func readOnlyPaths(patterns []string) []string {
+	var dirs []string
+	for _, p := range patterns {
+		if i := strings.IndexAny(p, "*?["); i >= 0 {
+			p = filepath.Dir(p[:i+1])
+		}
+		if p == "/" || p == "/proc" || p == "/dev" || strings.HasPrefix(p, "/proc/") || strings.HasPrefix(p, "/dev/") {
+			continue
+		}
+		dirs = append(dirs, p)
+	}
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+	var out []string
+	for _, d := range dirs {
+		if len(out) > 0 && strings.HasPrefix(d, strings.TrimPrefix(out[len(out)-1], "-")+"/") {
+			continue
+		}
+		out = append(out, "-"+d)
+	}
+	return out
+}
+
+// unitQuote quotes a word of a unit's command line.
+func unitQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;$") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$").Replace(s) + `"`
+}
+
+// apparmorProfile renders a profile allowing the reads, commands and
+// sockets the collectors declare.
+func apparmorProfile(name, exe string, s sandbox, writable []string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by fingerprint install; regenerate after changing the command.\n")
+	b.WriteString("abi <abi/3.0>,\ninclude <tunables/global>\n\n")
+	fmt.Fprintf(&b, "profile %s %s flags=(attach_disconnected) {\n", name, exe)
+	b.WriteString("  include <abstractions/base>\n")
+	if s.network {
+		b.WriteString("  include <abstractions/nameservice>\n  include <abstractions/ssl_certs>\n\n")
+		b.WriteString("  network inet stream,\n  network inet6 stream,\n  network inet dgram,\n  network inet6 dgram,\n")
+	}
+	b.WriteString("  network unix,\n  network netlink raw,\n\n")
+	for _, c := range s.caps {
+		fmt.Fprintf(&b, "  capability %s,\n", strings.ToLower(strings.TrimPrefix(c, "CAP_")))
+	}
+	if slices.Contains(s.caps, "CAP_SYS_PTRACE") {
+		b.WriteString("  ptrace (read),\n")
+	}
+	if s.selfUpdate {
+		// Health checks and restarts run new releases in this profile,
+		// which are downloaded next to the executable and renamed over it.
+		dir, base := filepath.Split(exe)
+		fmt.Fprintf(&b, "\n  %s mrwix,\n", exe)
+		fmt.Fprintf(&b, "  %s{.old,.sig,.sig.tmp} rw,\n", exe)
+		fmt.Fprintf(&b, "  %s.%s.new-* rw,\n", dir, base)
+		fmt.Fprintf(&b, "  %s r,\n", dir)
+	} else {
+		fmt.Fprintf(&b, "\n  %s mr,\n", exe)
+	}
+	var rules []string
+	sysfs := false
+	for _, p := range s.paths {
+		p = apparmorPath(p)
+		i := strings.IndexAny(p, "*?[")
+		if i < 0 {
+			rules = append(rules, p+"{,/,/**} r")
+			continue
+		}
+		rules = append(rules, p+" r")
+		if strings.HasSuffix(p, "/*") {
+			// The collector reads below the entries it matches.
+			rules = append(rules, p+"/** r")
+		}
+		// The directories listed to expand the pattern.
+		for d := strings.LastIndex(p[:i], "/"); d >= 0; {
+			rules = append(rules, p[:d]+"/ r")
+			n := strings.Index(p[d+1:], "/")
+			if n < 0 {
+				break
+			}
+			d += 1 + n
+		}
+		sysfs = sysfs || strings.HasPrefix(p, "/sys/class/") || strings.HasPrefix(p, "/sys/block/") || strings.HasPrefix(p, "/sys/bus/")
+	}
+	for _, f := range s.inputs {
+		rules = append(rules, f+" r")
+	}
+	for _, d := range s.devices {
+		rules = append(rules, d+" rw")
+	}
+	for _, m := range s.modules {
+		rules = append(rules, m+" mr")
+	}
+	for _, so := range s.sockets {
+		kind, p, _ := strings.Cut(so, ":")
+		switch kind {
+		case "socket":
+			rules = append(rules, p+" rw")
+		case "ioctl":
+			rules = append(rules, p+" r")
+		}
+	}
+	if sysfs {
+		// Class, block and bus entries are links into the device tree,
+		// which AppArmor checks after resolving them.
+		rules = append(rules, "/sys/devices/** r")
+	}
+	slices.Sort(rules)
+	for _, r := range slices.Compact(rules) {
+		fmt.Fprintf(&b, "  %s,\n", r)
+	}
+	for _, c := range s.commands {
+		if !strings.HasPrefix(c, "/") {
+			c = "/{,usr/}{,s}bin/" + c
+		}
+		// External tools keep their own profile where they have one.
+		fmt.Fprintf(&b, "  %s PUx,\n", c)
+	}
+	for _, w := range writable {
+		fmt.Fprintf(&b, "  %s/** rwk,\n", strings.TrimSuffix(w, "/"))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// apparmorPath maps /proc/self and process directories to the AppArmor
+// variables.
+func apparmorPath(p string) string {
+	if r, ok := strings.CutPrefix(p, "/proc/self/"); ok {
+		return "@{PROC}/@{pid}/" + r
+	}
+	if r, ok := strings.CutPrefix(p, "/proc/"); ok {
+		return "@{PROC}/" + r
+	}
+	return p
+}
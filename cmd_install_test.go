@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandSandbox(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmdline []string
+		// unit and profile lines that must and must not appear.
+		unit, notUnit       []string
+		profile, notProfile []string
+	}{{
+		name:    "oneshot",
+		cmdline: []string{"push", "-url", "https://inventory.example.com"},
+		unit:    []string{"Type=oneshot", "ProtectHome=yes"},
+		profile: []string{"  /etc/linux-fingerprint/labels r,", "  /etc/machine-id{,/,/**} r,"},
+	}, {
+		name:    "double-dash interval",
+		cmdline: []string{"push", "--interval", "1h", "-url", "https://inventory.example.com"},
+		unit:    []string{"Type=exec", "Restart=on-failure"},
+		notUnit: []string{"Type=oneshot"},
+	}, {
+		name: "inputs",
+		cmdline: []string{"push", "-interval=1h", "-targets", "/etc/lsf/targets.json", "-sign-key", "file:///root/agent.pem",
+			"-profile", "/etc/lsf/redact.json", "-labels-file", "/srv/labels", "-spool-dir", "/var/spool/lsf"},
+		unit: []string{"ProtectHome=read-only", "ReadWritePaths=/var/spool/lsf", " -/root/agent.pem", " -/srv/labels"},
+		profile: []string{"  /etc/lsf/targets.json r,", "  /root/agent.pem r,", "  /etc/lsf/redact.json r,",
+			"  /srv/labels r,", "  /var/spool/lsf/** rwk,"},
+		notProfile: []string{"  /etc/linux-fingerprint/labels r,"},
+	}, {
+		name:    "tpm key",
+		cmdline: []string{"serve", "-sign-key", "tpm://0x81000010"},
+		unit:    []string{"Type=exec"},
+		profile: []string{"  /dev/tpmrm0 rw,", "  /dev/tpm0 rw,"},
+	}, {
+		name:    "fleet server",
+		cmdline: []string{"fleetserver", "-tls-cert", "/etc/lsf/tls.crt", "-tls-key", "/etc/lsf/tls.key", "-client-ca", "/etc/lsf/ca.pem", "-trust", "/etc/lsf/a.pem", "-trust", "/etc/lsf/b.pem"},
+		profile: []string{"  /etc/lsf/tls.crt r,", "  /etc/lsf/tls.key r,", "  /etc/lsf/ca.pem r,",
+			"  /etc/lsf/a.pem r,", "  /etc/lsf/b.pem r,"},
+	}, {
+		name:       "host root",
+		cmdline:    []string{"push", "-url", "https://inventory.example.com", "-host-root", "/host"},
+		unit:       []string{" -/host/etc/machine-id"},
+		profile:    []string{"  /host/etc/machine-id{,/,/**} r,", "  @{PROC}/@{pid}/mountinfo{,/,/**} r,", "  /etc/linux-fingerprint/labels r,"},
+		notProfile: []string{"  /etc/machine-id{,/,/**} r,", "  /host/etc/linux-fingerprint/labels r,"},
+	}, {
+		name:       "self-update",
+		cmdline:    []string{"push", "-url", "https://inventory.example.com", "-self-update", "https://releases.example.com/stable"},
+		unit:       []string{"ReadWritePaths=/usr/local/bin"},
+		profile:    []string{"  /usr/local/bin/fingerprint mrwix,"},
+		notProfile: []string{"  /usr/local/bin/fingerprint mr,"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := commandSandbox(tt.cmdline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			const exe = "/usr/local/bin/fingerprint"
+			unit := systemdUnit("lsf", exe, tt.cmdline, s, s.writable, true)
+			profile := apparmorProfile("lsf", exe, s, s.writable)
+			check(t, "unit", unit, tt.unit, tt.notUnit)
+			check(t, "profile", profile, tt.profile, tt.notProfile)
+		})
+	}
+}
+
+func check(t *testing.T, what, out string, want, notWant []string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("%s lacks %q:\n%s", what, w, out)
+		}
+	}
+	for _, w := range notWant {
+		if strings.Contains(out, w) {
+			t.Errorf("%s has %q:\n%s", what, w, out)
+		}
+	}
+}
+
+func TestCommandSandboxRelativePath(t *testing.T) {
+	if _, err := commandSandbox([]string{"push", "-url", "https://inventory.example.com", "-profile", "redact.json"}); err == nil {
+		t.Fatal("commandSandbox accepted a relative -profile")
+	}
+}
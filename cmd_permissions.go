@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// runPermissions collects a snapshot and lists the values the agent could
+// not read for lack of privileges, or reads a snapshot file with -in.
+func runPermissions(args []string) error {
+	fs := flag.NewFlagSet("permissions", flag.ExitOnError)
+	in := fs.String("in", "", "report on this snapshot file instead of collecting one")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	collect := collectFlags(fs)
+	fs.Parse(args)
+
+	var snap fingerprint.Snapshot
+	if *in != "" {
+		b, err := os.ReadFile(*in)
+		if err != nil {
+			return err
+		}
+		if snap, err = fingerprint.Unmarshal(b); err != nil {
+			return err
+		}
+	} else {
+		snap = collect()
+	}
+	issues := snap.Permissions()
+	if *asJSON {
+		if issues == nil {
+			issues = []fingerprint.PermissionIssue{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(issues)
+	}
+	if len(issues) == 0 {
+		fmt.Println("no values withheld for lack of privileges")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELDS\tPATH\tREQUIRES\tAGENT")
+	for _, p := range issues {
+		fields := strings.Join(p.Fields, ",")
+		if fields == "" {
+			fields = p.Collector
+		}
+		var agent []string
+		if len(p.Missing) > 0 {
+			agent = append(agent, "without "+strings.Join(p.Missing, "/"))
+		}
+		for _, n := range p.Namespaces {
+			agent = append(agent, "own "+n+" namespace")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", fields, p.Path, p.Requires, orDash(strings.Join(agent, ", ")))
+	}
+	return tw.Flush()
+}
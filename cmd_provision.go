@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/push"
+	"AurFingerprintAgent/retry"
+	"AurFingerprintAgent/signer"
+)
+
+// defaultIDFile is where provision stores the device ID.
+const defaultIDFile = "/etc/linuxsystemfingerprint/id"
+
+var idPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// runProvision is meant for first boot: it derives the device ID from the
+// fingerprint, stores it with the component digests once and optionally
+// registers it. Later runs keep the stored ID, only retry a registration
+// that has not succeeded yet, and reconcile the live fingerprint with the
+// stored components.
+func runProvision(args []string) error {
+	fs := flag.NewFlagSet("provision", flag.ExitOnError)
+	idFile := fs.String("id-file", defaultIDFile, "where the device ID is stored; never overwritten once written")
+	url := fs.String("url", "", "register the device ID and snapshot with this endpoint")
+	signKey := fs.String("sign-key", "", "sign the registered snapshot with a key file, tpm:// handle or pkcs11: URI")
+	retries := fs.Int("register-retries", 5, "tries of the registration on network errors and 408, 429 or 5xx answers")
+	regTimeout := fs.Duration("register-timeout", 5*time.Minute, "overall registration timeout")
+	maxMismatches := fs.Int("max-mismatches", 1, "components allowed to differ from the stored identity")
+	require := fs.String("require", "", "comma-separated components that must match the stored identity, e.g. dmi.product_uuid")
+	interval := fs.Duration("interval", 0, "keep reconciling with this period and print an event when the identity diverges (daemon mode)")
+	collect := collectFlags(fs)
+	configureHTTP := httpFlags(fs)
+	fs.Parse(args)
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+	tol := fingerprint.Tolerance{MaxMismatches: *maxMismatches}
+	for _, c := range strings.Split(*require, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			tol.Required = append(tol.Required, c)
+		}
+	}
+
+	var snap *fingerprint.Snapshot
+	current := func() fingerprint.Snapshot {
+		if snap == nil {
+			s := collect()
+			snap = &s
+		}
+		return *snap
+	}
+	id, err := readID(*idFile)
+	if errors.Is(err, os.ErrNotExist) {
+		id, err = storeID(*idFile, current())
+	}
+	if err != nil {
+		return err
+	}
+	if *url != "" {
+		if err := registerOnce(*idFile, id, func() error {
+			return register(*url, *signKey, *retries, *regTimeout, id, current())
+		}); err != nil {
+			return err
+		}
+	}
+	fmt.Println(id)
+
+	if *interval <= 0 {
+		d, err := reconcile(*idFile, id, current(), tol)
+		if err != nil {
+			return err
+		}
+		if d.Severity != fingerprint.SeverityNone {
+			fmt.Fprintf(os.Stderr, "identity diverged (%s): %s\n", d.Severity, strings.Join(d.Mismatched, ", "))
+		}
+		if !d.Within {
+			return withExitCode(exitIdentity, fmt.Errorf("live fingerprint no longer matches stored ID %s", id))
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	enc := json.NewEncoder(os.Stdout)
+	within := true
+	for {
+		s := current()
+		snap = nil
+		d, err := reconcile(*idFile, id, s, tol)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "reconcile error:", err)
+		} else if d.Within != within {
+			// Report transitions only, not every check.
+			within = d.Within
+			ev := identityEvent{Time: time.Now(), Event: "identity_mismatch", StoredID: id, LiveID: s.ID(), Divergence: d}
+			if within {
+				ev.Event = "identity_restored"
+			}
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+		t := time.NewTimer(*interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// identityEvent is printed by provision -interval when the live fingerprint
+// leaves or returns to the tolerance of the stored identity.
+type identityEvent struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	StoredID string    `json:"stored_id"`
+	LiveID   string    `json:"live_id"`
+	fingerprint.Divergence
+}
+
+// reconcile compares snap with the identity stored at idFile. Without
+// stored component digests only the ID is compared, and any difference is
+// major.
+func reconcile(idFile, id string, snap fingerprint.Snapshot, tol fingerprint.Tolerance) (fingerprint.Divergence, error) {
+	want, err := readComponents(idFile + ".components")
+	if errors.Is(err, os.ErrNotExist) {
+		if snap.ID() == id {
+			return fingerprint.Divergence{Severity: fingerprint.SeverityNone, Within: true}, nil
+		}
+		return fingerprint.Divergence{Severity: fingerprint.SeverityMajor}, nil
+	}
+	if err != nil {
+		return fingerprint.Divergence{}, err
+	}
+	return fingerprint.Diverge(want, snap.ComponentDigests(), tol), nil
+}
+
+// registerOnce registers the device unless an earlier run did, as recorded
+// by a marker file next to the ID.
+func registerOnce(idFile, id string, register func() error) error {
+	marker := idFile + ".registered"
+	if _, err := os.Stat(marker); !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := register(); err != nil {
+		return withExitCode(exitPushFailure, err)
+	}
+	return os.WriteFile(marker, []byte(id+"\n"), 0o644)
+}
+
+// readID returns the stored device ID.
+func readID(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(b))
+	if !idPattern.MatchString(id) {
+		return "", fmt.Errorf("%s: malformed device ID %q", path, id)
+	}
+	return id, nil
+}
+
+func readComponents(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c map[string]string
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// storeID stores the ID and component digests of snap unless a device ID
+// is already stored at path, and returns the ID in effect. The digests go
+// first so that a stored ID always has them.
+func storeID(path string, snap fingerprint.Snapshot) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	digests, err := json.Marshal(snap.ComponentDigests())
+	if err != nil {
+		return "", err
+	}
+	if err := createOnce(path+".components", append(digests, '\n')); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+	id := snap.ID()
+	if err := createOnce(path, []byte(id+"\n")); errors.Is(err, os.ErrExist) {
+		return readID(path)
+	} else if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// createOnce writes b to path unless it exists. The file is linked into
+// place so that concurrent runs cannot replace each other's file or leave
+// a partial one.
+func createOnce(path string, b []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Link(tmp, path)
+}
+
+func register(url, signKey string, retries int, timeout time.Duration, id string, snap fingerprint.Snapshot) error {
+	c := &push.Client{URL: url,
+		Retry: retry.Policy{Attempts: retries, Base: time.Second, Max: 30 * time.Second, Jitter: 0.5}}
+	if signKey != "" {
+		k, err := signer.Open(signKey)
+		if err != nil {
+			return err
+		}
+		defer k.Close()
+		c.Signer = k
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.Register(ctx, id, snap)
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/httpenc"
+	"AurFingerprintAgent/push"
+	"AurFingerprintAgent/redact"
+	"AurFingerprintAgent/retry"
+	"AurFingerprintAgent/selfupdate"
+	"AurFingerprintAgent/signer"
+	"AurFingerprintAgent/sink"
+	"AurFingerprintAgent/spool"
+	"AurFingerprintAgent/tlog"
+)
+
+// minRetry is the first retry delay after a failed push while spooling.
+const minRetry = 5 * time.Second
+
+func runPush(args []string) error {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	url := fs.String("url", "", "inventory endpoint receiving snapshots")
+	profile := fs.String("profile", "", "redaction profile (JSON) applied to snapshots pushed to -url")
+	targets := fs.String("targets", "", "JSON file listing several endpoints, each with its own redaction profile, instead of -url")
+	signKey := fs.String("sign-key", "", "signing key file, tpm:// handle or pkcs11: URI")
+	nonce := fs.String("nonce", "", "static nonce to embed in the signed snapshot")
+	nonceURL := fs.String("nonce-url", "", "fetch a fresh nonce from this URL before every push")
+	tlogURL := fs.String("tlog", "", "record each snapshot in this Rekor-compatible transparency log, e.g. "+tlog.DefaultURL)
+	interval := fs.Duration("interval", 0, "push repeatedly with this period (daemon mode)")
+	compress := fs.String("compress", "", "compress pushed bodies with gzip or zstd")
+	deltaState := fs.String("delta-state", "", "file remembering the last acknowledged snapshot; enables JSON Patch delta pushes")
+	spoolDir := fs.String("spool-dir", "", "queue snapshots here while the endpoint is unreachable")
+	spoolItems := fs.Int("spool-max-items", 1000, "maximum number of spooled snapshots")
+	retries := fs.Int("push-retries", 3, "tries per push on network errors and 408, 429 or 5xx answers")
+	spoolBytes := fs.Int64("spool-max-bytes", 64<<20, "maximum total size of spooled snapshots")
+	updateURL := fs.String("self-update", "", "release channel URL; install newer signed agent releases from it and restart")
+	updateEvery := fs.Duration("self-update-interval", 24*time.Hour, "how often to check the -self-update channel")
+	var sinks []sink.Sink
+	fs.Func("sink", "also deliver every fresh snapshot to stdout, a file, journal, mqtt://, s3:// or http(s):// sink (repeatable)", func(v string) error {
+		k, err := sink.Parse(v)
+		if err == nil {
+			sinks = append(sinks, k)
+		}
+		return err
+	})
+	collect := collectFlags(fs)
+	configureHTTP := httpFlags(fs)
+	fs.Parse(args)
+	if *url != "" && *targets != "" {
+		return errors.New("-url and -targets are mutually exclusive")
+	}
+	if *url == "" && *targets == "" && len(sinks) == 0 {
+		return errors.New("one of -url, -targets or -sink is required")
+	}
+	if err := configureHTTP(); err != nil {
+		return err
+	}
+	if _, err := httpenc.Encode(*compress, nil); err != nil {
+		return err
+	}
+	if (*nonce != "" || *nonceURL != "") && *signKey == "" {
+		return errors.New("-nonce and -nonce-url require -sign-key")
+	}
+	if *tlogURL != "" && *signKey == "" {
+		return errors.New("-tlog requires -sign-key")
+	}
+	var updater *selfupdate.Updater
+	if *updateURL != "" {
+		key := selfCheckPublicKey()
+		if key == nil {
+			return errors.New("-self-update needs a build with an embedded release key (main.selfCheckKey)")
+		}
+		// Resolved now: once replaced, the running image is "<exe>.old".
+		exe, err := os.Executable()
+		if err == nil {
+			exe, err = filepath.EvalSymlinks(exe)
+		}
+		if err != nil {
+			return err
+		}
+		bi := fingerprint.AgentBuild()
+		if bi == nil {
+			return errors.New("-self-update needs a build with module information")
+		}
+		updater = &selfupdate.Updater{URL: *updateURL, Key: key, Executable: exe, Version: bi.Main.Version, Check: releaseHealthCheck}
+	}
+
+	var list []pushTarget
+	if *targets != "" {
+		var err error
+		if list, err = loadTargets(*targets); err != nil {
+			return err
+		}
+	} else if *url != "" {
+		t := pushTarget{URL: *url}
+		if *profile != "" {
+			var err error
+			if t.Profile, err = redact.Load(*profile); err != nil {
+				return err
+			}
+		}
+		list = []pushTarget{t}
+	}
+	out := sink.Multi(sinks...)
+	var sign crypto.Signer
+	if *signKey != "" {
+		k, err := signer.Open(*signKey)
+		if err != nil {
+			return err
+		}
+		defer k.Close()
+		sign = k
+		if *tlogURL != "" {
+			if err := checkTlogKey(k); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range list {
+		t := &list[i]
+		t.c = &push.Client{URL: t.URL, Compression: *compress, Signer: sign,
+			Retry: retry.Policy{Attempts: *retries, Base: time.Second, Max: 30 * time.Second, Jitter: 0.5}}
+		if *deltaState != "" {
+			t.c.DeltaState = t.path(*deltaState)
+		}
+		if *spoolDir != "" {
+			var err error
+			if t.q, err = spool.Open(t.path(*spoolDir), *spoolItems, *spoolBytes); err != nil {
+				return err
+			}
+		}
+	}
+	getNonce := func(ctx context.Context) (string, error) {
+		if *nonceURL != "" {
+			return list[0].c.FetchNonce(ctx, *nonceURL)
+		}
+		return *nonce, nil
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// deliver sends a fresh snapshot to every target, or with a spool
+	// queues it behind earlier undelivered ones and drains the queue oldest
+	// first. One collection serves all targets and sinks; sinks only get
+	// fresh snapshots.
+	deliver := func(fresh bool) error {
+		var snap *fingerprint.Snapshot
+		var errs []error
+		if fresh || *spoolDir == "" {
+			s := collect()
+			snap = &s
+			// A log outage should not hold back the inventory.
+			if *tlogURL != "" {
+				if err := logSnapshot(ctx, *tlogURL, sign, snap); err != nil {
+					errs = append(errs, fmt.Errorf("transparency log: %w", err))
+				}
+			}
+		}
+		var logView func(*fingerprint.Snapshot) error
+		if *tlogURL != "" {
+			logView = func(s *fingerprint.Snapshot) error { return logSnapshot(ctx, *tlogURL, sign, s) }
+		}
+		for _, t := range list {
+			if err := t.deliver(ctx, snap, getNonce, logView); err != nil {
+				errs = append(errs, t.wrap(err))
+			}
+		}
+		if snap != nil {
+			errs = append(errs, out.Write(ctx, *snap))
+		}
+		return errors.Join(errs...)
+	}
+	if *interval <= 0 {
+		err := withExitCode(exitPushFailure, deliver(true))
+		if updater != nil {
+			// The next run starts the new release.
+			if _, uerr := updater.Update(ctx); uerr != nil {
+				fmt.Fprintln(os.Stderr, "self-update:", uerr)
+			}
+		}
+		return err
+	}
+
+	next, nextUpdate := time.Now(), time.Now()
+	var backoff time.Duration
+	for {
+		if updater != nil && !time.Now().Before(nextUpdate) {
+			nextUpdate = time.Now().Add(*updateEvery)
+			if err := selfUpdate(ctx, updater); err != nil {
+				fmt.Fprintln(os.Stderr, "self-update:", err)
+			}
+		}
+		fresh := !time.Now().Before(next)
+		if fresh {
+			next = next.Add(*interval)
+		}
+		wait := time.Until(next)
+		if err := deliver(fresh); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, "push error:", err)
+			if *spoolDir != "" {
+				backoff = min(max(2*backoff, minRetry), *interval)
+				wait = min(backoff, wait)
+			}
+		} else {
+			backoff = 0
+		}
+		t := time.NewTimer(max(wait, 0))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// pushTarget is an endpoint of push -targets with the view of the snapshot
+// it receives.
+type pushTarget struct {
+	// Name tells the target's delta state and spool apart: they get
+	// ".<name>" appended.
+	Name    string         `json:"name"`
+	URL     string         `json:"url"`
+	Profile redact.Profile `json:"profile"`
+
+	c *push.Client
+	q *spool.Queue
+}
+
+func loadTargets(path string) ([]pushTarget, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []pushTarget
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: no targets", path)
+	}
+	seen := map[string]bool{}
+	for _, t := range list {
+		if t.Name == "" || t.URL == "" {
+			return nil, fmt.Errorf("%s: every target needs a name and a url", path)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("%s: target %q listed twice", path, t.Name)
+		}
+		if err := t.Profile.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: target %q: %w", path, t.Name, err)
+		}
+		seen[t.Name] = true
+	}
+	return list, nil
+}
+
+// path derives the target's own file from a shared one.
+func (t pushTarget) path(p string) string {
+	if t.Name == "" {
+		return p
+	}
+	return p + "." + t.Name
+}
+
+func (t pushTarget) wrap(err error) error {
+	if t.Name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", t.Name, err)
+}
+
+// deliver pushes the target's view of snap, which is nil when a spooling
+// target only drains its queue. A redacted view no longer matches the
+// transparency log entry of snap; it is logged on its own with logView,
+// when set, or sent without an entry.
+func (t pushTarget) deliver(ctx context.Context, snap *fingerprint.Snapshot, nonce func(context.Context) (string, error), logView func(*fingerprint.Snapshot) error) error {
+	var view fingerprint.Snapshot
+	var logErr error
+	if snap != nil {
+		var err error
+		if view, err = t.Profile.Apply(*snap); err != nil {
+			return err
+		}
+		if m := view.Meta; m != nil && m.Transparency != nil && !t.Profile.IsZero() {
+			if d, err := fingerprint.TransparencyDigest(view); err != nil || hex.EncodeToString(d) != m.Transparency.ArtifactHash {
+				m.Transparency = nil
+				if logView != nil {
+					if err := logView(&view); err != nil {
+						logErr = fmt.Errorf("transparency log: %w", err)
+					}
+				}
+			}
+		}
+	}
+	if t.q == nil {
+		return errors.Join(logErr, t.c.PushNonce(ctx, view, nonce))
+	}
+	if snap != nil {
+		b, err := json.Marshal(view)
+		if err != nil {
+			return err
+		}
+		if err := t.q.Put(b); err != nil {
+			return err
+		}
+	}
+	return errors.Join(logErr, t.c.Drain(ctx, t.q, nonce))
+}
+
+// selfUpdate installs a newer release and replaces the process with it,
+// keeping the PID so that a service manager does not notice.
+func selfUpdate(ctx context.Context, u *selfupdate.Updater) error {
+	updated, err := u.Update(ctx)
+	if err != nil || !updated {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "self-update: restarting into the new release")
+	return syscall.Exec(u.Executable, os.Args, os.Environ())
+}
+
+// releaseHealthCheck runs a freshly installed release once: it must
+// collect a snapshot and verify its own signature, so that it can verify
+// the releases after it too.
+func releaseHealthCheck(ctx context.Context, exe string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, exe, "-self-check", "-budget", "60s")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && (ee.ExitCode() == exitPartial || ee.ExitCode() == exitPermission) {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	snap, err := fingerprint.Unmarshal(out)
+	if err != nil {
+		return err
+	}
+	if snap.Meta == nil || snap.Meta.SelfCheck == nil || !snap.Meta.SelfCheck.Verified {
+		return errors.New("new release does not verify its own signature")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// runRecord collects a snapshot, prints it and archives the raw files the
+// collectors read, for replaying a customer's system offline.
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	out := fs.String("o", "fingerprint-bundle.tar.gz", "write the bundle of raw source files here")
+	opts := optionFlags(fs)
+	fs.Parse(args)
+
+	rec := fingerprint.NewRecorder()
+	snap := fingerprint.GetSnapshot(append(opts(), fingerprint.WithRecorder(rec))...)
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := rec.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return printSnapshot(snap)
+}
+
+// runReplay reconstructs and prints the snapshot of a bundle written by
+// record. Opt-in collectors are enabled with the usual flags.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	opts := optionFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: replay [flags] bundle.tar.gz")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	snap, err := fingerprint.Replay(f, opts()...)
+	if err != nil {
+		return err
+	}
+	return printSnapshot(snap)
+}
+
+func printSnapshot(snap fingerprint.Snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/report"
+)
+
+// runReport writes a self-contained HTML report of the current snapshot,
+// optionally with a timeline built from earlier snapshot files.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	out := fs.String("html", "", "write the HTML report to this file")
+	history := fs.String("history", "", "directory of earlier snapshot JSON files, e.g. a push spool")
+	in := fs.String("snapshot", "", "report this snapshot file instead of collecting one")
+	collect := collectFlags(fs)
+	fs.Parse(args)
+	if *out == "" {
+		return errors.New("-html is required")
+	}
+
+	var cur fingerprint.Snapshot
+	if *in != "" {
+		b, err := os.ReadFile(*in)
+		if err != nil {
+			return err
+		}
+		if cur, err = fingerprint.Unmarshal(b); err != nil {
+			return err
+		}
+	} else {
+		cur = collect()
+	}
+	var entries []report.Entry
+	if *history != "" {
+		files, err := filepath.Glob(filepath.Join(*history, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				continue
+			}
+			b, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			s, err := fingerprint.Unmarshal(b)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, report.Entry{Time: fi.ModTime(), Source: filepath.Base(f), Snapshot: s})
+		}
+	}
+
+	tmp := *out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := report.HTML(f, cur, entries); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, *out)
+}
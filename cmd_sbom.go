@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// runSbom prints the bill of materials embedded in the binary by the Go
+// toolchain, either raw or as a CycloneDX document.
+func runSbom(args []string) error {
+	fs := flag.NewFlagSet("sbom", flag.ExitOnError)
+	format := fs.String("format", "cyclonedx", "output format: cyclonedx or json")
+	fs.Parse(args)
+
+	bi := fingerprint.AgentBuild()
+	if bi == nil {
+		return errors.New("binary carries no build information")
+	}
+	var v any
+	switch *format {
+	case "json":
+		v = bi
+	case "cyclonedx":
+		v = cycloneDX(bi)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func cycloneDX(bi *fingerprint.BuildInfo) any {
+	comp := func(m fingerprint.Module) cdxComponent {
+		if m.Replace != nil {
+			m = *m.Replace
+		}
+		c := cdxComponent{Type: "library", Name: m.Path, Version: m.Version}
+		if m.Version != "" && m.Version != "(devel)" {
+			c.PURL = "pkg:golang/" + m.Path + "@" + m.Version
+		}
+		if m.Sum != "" {
+			c.Properties = []cdxProperty{{Name: "go:sum", Value: m.Sum}}
+		}
+		return c
+	}
+	main := comp(bi.Main)
+	main.Type = "application"
+	main.Properties = append(main.Properties, cdxProperty{Name: "go:version", Value: bi.GoVersion})
+	for _, k := range []string{"vcs.revision", "vcs.time", "vcs.modified", "-trimpath", "GOOS", "GOARCH", "CGO_ENABLED", "-tags"} {
+		if v, ok := bi.Settings[k]; ok {
+			main.Properties = append(main.Properties, cdxProperty{Name: "go:build:" + k, Value: v})
+		}
+	}
+	var deps []cdxComponent
+	for _, d := range bi.Deps {
+		deps = append(deps, comp(d))
+	}
+	return map[string]any{
+		"bomFormat":   "CycloneDX",
+		"specVersion": "1.5",
+		"version":     1,
+		"metadata":    map[string]any{"component": main},
+		"components":  deps,
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/server"
+	"AurFingerprintAgent/signer"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("listen", "127.0.0.1:8080", "HTTP listen address")
+	signKey := fs.String("sign-key", "", "signing key file, tpm:// handle or pkcs11: URI")
+	opts := optionFlags(fs)
+	cacheTTL := fs.Duration("cache-ttl", 0, "serve a snapshot collected at most once per this duration (0 collects per request)")
+	volatileTTL := fs.Duration("volatile-ttl", 0, "with -cache-ttl, refresh the network sections after this duration")
+	requireNonce := fs.Bool("require-nonce", false, "reject snapshot requests without an X-LSF-Nonce header")
+	fs.Parse(args)
+	if *requireNonce && *signKey == "" {
+		return errors.New("-require-nonce requires -sign-key")
+	}
+
+	cfg := server.Config{Snapshot: func() fingerprint.Snapshot { return fingerprint.GetSnapshot(opts()...) }}
+	if *cacheTTL > 0 {
+		p := fingerprint.NewCachedProvider(*cacheTTL, opts()...)
+		p.VolatileTTL = *volatileTTL
+		cfg.Snapshot = p.Snapshot
+	}
+	cfg.RequireNonce = *requireNonce
+	if *signKey != "" {
+		k, err := signer.Open(*signKey)
+		if err != nil {
+			return err
+		}
+		defer k.Close()
+		cfg.Signer = k
+	}
+	srv := &http.Server{Addr: *addr, Handler: server.Handler(cfg), ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
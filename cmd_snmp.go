@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"AurFingerprintAgent/agentx"
+	"AurFingerprintAgent/fingerprint"
+)
+
+// defaultSNMPRoot is in net-snmp's playpen, which is free for local use;
+// sites with a private enterprise number use their own with -oid.
+const defaultSNMPRoot = "1.3.6.1.4.1.8072.9999.9999.42"
+
+var defaultSNMPFields = []string{
+	"hostname", "machine_id", "os.name", "os.version", "os.kernel_release",
+	"dmi.product_uuid", "dmi.product_serial", "dmi.sys_vendor", "dmi.product_name",
+	"cpu.model", "memory.mem_total_kb",
+}
+
+// runSNMP serves the fingerprint to a master agent over AgentX. Under the
+// root OID:
+//
+//	.1.0  hash
+//	.2.0  device ID
+//	.3.0  schema version
+//	.4.0  number of rows in the field table
+//	.5.1.1.<n>  field index
+//	.5.1.2.<n>  field name, e.g. "dmi.product_uuid"
+//	.5.1.3.<n>  field value
+func runSNMP(args []string) error {
+	fs := flag.NewFlagSet("snmp", flag.ExitOnError)
+	master := fs.String("master", agentx.DefaultMaster, "master agent socket path, or host:port for TCP")
+	oid := fs.String("oid", defaultSNMPRoot, "root OID of the fingerprint subtree")
+	fields := fs.String("fields", strings.Join(defaultSNMPFields, ","), "comma-separated flattened fields to publish, with * patterns")
+	refresh := fs.Duration("refresh", time.Hour, "collect a fresh snapshot with this period")
+	collect := collectFlags(fs)
+	fs.Parse(args)
+	root, err := agentx.ParseOID(*oid)
+	if err != nil {
+		return err
+	}
+	if *refresh <= 0 {
+		return fmt.Errorf("-refresh must be positive")
+	}
+	patterns := strings.Split(*fields, ",")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	sub := &agentx.Subagent{Master: *master, Root: root, Description: "Linux system fingerprint"}
+	sub.Update(snmpVars(root, collect(), patterns))
+	go func() {
+		t := time.NewTicker(*refresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				sub.Update(snmpVars(root, collect(), patterns))
+			}
+		}
+	}()
+	// The master agent may restart, e.g. on snmpd upgrades.
+	for delay := time.Second; ; delay = min(2*delay, time.Minute) {
+		start := time.Now()
+		err := sub.Run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(start) > time.Minute {
+			delay = time.Second
+		}
+		if err == nil {
+			err = fmt.Errorf("master agent closed the session")
+		}
+		fmt.Fprintf(os.Stderr, "snmp: %v; reconnecting in %s\n", err, delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// snmpVars lays out snap under root as documented at runSNMP.
+func snmpVars(root agentx.OID, snap fingerprint.Snapshot, patterns []string) []agentx.Var {
+	at := func(sub ...uint32) agentx.OID { return append(slices.Clone(root), sub...) }
+	flat := snap.Flatten()
+	var names []string
+	for k := range flat {
+		if slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(strings.TrimSpace(p), k)
+			return ok
+		}) {
+			names = append(names, k)
+		}
+	}
+	slices.Sort(names)
+	vars := []agentx.Var{
+		{OID: at(1, 0), Value: snap.Hash()},
+		{OID: at(2, 0), Value: snap.ID()},
+		{OID: at(3, 0), Value: snap.SchemaVersion},
+		{OID: at(4, 0), Value: len(names)},
+	}
+	for i, k := range names {
+		n := uint32(i + 1)
+		vars = append(vars,
+			agentx.Var{OID: at(5, 1, 1, n), Value: int(n)},
+			agentx.Var{OID: at(5, 1, 2, n), Value: k},
+			agentx.Var{OID: at(5, 1, 3, n), Value: flat[k]})
+	}
+	return vars
+}
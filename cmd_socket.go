@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/localsock"
+)
+
+func runSocket(args []string) error {
+	fs := flag.NewFlagSet("socket", flag.ExitOnError)
+	path := fs.String("path", localsock.DefaultPath, "unix socket path")
+	group := fs.String("group", "", "group allowed to query the socket (default: the agent's group)")
+	opts := optionFlags(fs)
+	cacheTTL := fs.Duration("cache-ttl", time.Minute, "collect at most once per this duration, however many clients query")
+	volatileTTL := fs.Duration("volatile-ttl", 0, "refresh the network sections after this duration")
+	fs.Parse(args)
+	if *cacheTTL < time.Second {
+		return errors.New("-cache-ttl must be at least 1s")
+	}
+
+	gid := -1
+	if *group != "" {
+		g, err := user.LookupGroup(*group)
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("group %s: %w", *group, err)
+		}
+	}
+	p := fingerprint.NewCachedProvider(*cacheTTL, opts()...)
+	p.VolatileTTL = *volatileTTL
+	l, err := localsock.Listen(*path, gid)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*path)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return localsock.Serve(ctx, l, p.Snapshot)
+}
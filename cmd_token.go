@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/token"
+)
+
+func runToken(args []string) error {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	keyPath := fs.String("key", "", "PKCS#8 PEM signing key (Ed25519 or P-256)")
+	secret := fs.String("secret", "", "HMAC secret for HS256 tokens")
+	ttl := fs.Duration("ttl", 15*time.Minute, "token lifetime")
+	fs.Parse(args)
+
+	var key any
+	switch {
+	case *keyPath != "":
+		k, err := readPrivateKey(*keyPath)
+		if err != nil {
+			return err
+		}
+		key = k
+	case *secret != "":
+		key = []byte(*secret)
+	default:
+		return errors.New("-key or -secret is required")
+	}
+	tok, err := token.Issue(key, fingerprint.GetSnapshot(), *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(tok)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+
+	"AurFingerprintAgent/tui"
+)
+
+// runView shows a snapshot, or its differences from an older one, in an
+// interactive tree. Without a terminal the tree is printed instead.
+func runView(args []string) error {
+	fs := flag.NewFlagSet("view", flag.ExitOnError)
+	diffWith := fs.String("diff", "", "older snapshot file to compare against")
+	collect := collectFlags(fs)
+	fs.Parse(args)
+
+	var cur []byte
+	var err error
+	switch fs.Arg(0) {
+	case "":
+		cur, err = json.Marshal(collect())
+	case "-":
+		cur, err = io.ReadAll(os.Stdin)
+	default:
+		cur, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	root, err := tui.Parse(cur)
+	if err != nil {
+		return err
+	}
+	if *diffWith != "" {
+		b, err := os.ReadFile(*diffWith)
+		if err != nil {
+			return err
+		}
+		old, err := tui.Parse(b)
+		if err != nil {
+			return err
+		}
+		root = tui.Diff(old, root)
+	} else {
+		root.SetExpanded(false)
+		root.Expanded = true
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		tui.Print(os.Stdout, root)
+		return nil
+	}
+	defer tty.Close()
+	return tui.Run(tty, root)
+}
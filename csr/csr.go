@@ -0,0 +1,166 @@
+// Package csr builds certificate signing requests bound to the machine
+// fingerprint for device-identity mTLS enrollment.
+//
+// The subject common name and a URI SAN of the form
+// "urn:lsf:machine:<hash>" carry the derived machine ID. Optionally the
+// request embeds a snapshot-hash extension so the issuing CA can record the
+// exact snapshot the device presented at enrollment time. The project has
+// no OID arc of its own, so the extension goes under an OID the deploying
+// organization assigns from its private enterprise number.
+package csr
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+const uriPrefix = "urn:lsf:machine:"
+
+// Options tunes the generated request.
+type Options struct {
+	// Organization is placed into the subject O attribute when set.
+	Organization string
+	// SnapshotHash adds the snapshot hash extension to the request, under
+	// SnapshotHashOID, which it requires.
+	SnapshotHash    bool
+	SnapshotHashOID asn1.ObjectIdentifier
+	// HashSigner, when set together with SnapshotHash, signs the snapshot
+	// digest so the extension can be verified independently of the CSR key.
+	HashSigner crypto.Signer
+}
+
+// SnapshotHashExt is the ASN.1 payload of the snapshot hash extension.
+type SnapshotHashExt struct {
+	Digest    []byte
+	Signature []byte `asn1:"optional"`
+}
+
+// GenerateKey creates a new P-256 key suitable for device certificates.
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// Create returns a DER encoded CSR signed by key whose subject and SAN
+// encode the machine ID derived from snap.
+func Create(key crypto.Signer, snap fingerprint.Snapshot, opts Options) ([]byte, error) {
+	id := snap.Hash()
+	tmpl := &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: id},
+		URIs:    []*url.URL{MachineURI(id)},
+	}
+	if opts.Organization != "" {
+		tmpl.Subject.Organization = []string{opts.Organization}
+	}
+	if opts.SnapshotHash {
+		if len(opts.SnapshotHashOID) == 0 {
+			return nil, errors.New("csr: the snapshot hash extension needs an OID")
+		}
+		ext, err := snapshotHashExt(snap, opts.SnapshotHashOID, opts.HashSigner)
+		if err != nil {
+			return nil, err
+		}
+		tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, ext)
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+}
+
+// MachineURI returns the SAN URI used to carry a machine ID.
+func MachineURI(id string) *url.URL {
+	return &url.URL{Scheme: "urn", Opaque: strings.TrimPrefix(uriPrefix, "urn:") + id}
+}
+
+// MachineID extracts the machine ID from the URIs of a CSR or certificate.
+func MachineID(uris []*url.URL) (string, bool) {
+	for _, u := range uris {
+		if s := u.String(); strings.HasPrefix(s, uriPrefix) {
+			return strings.TrimPrefix(s, uriPrefix), true
+		}
+	}
+	return "", false
+}
+
+// SnapshotDigest returns the SHA-256 digest of the snapshot JSON encoding.
+func SnapshotDigest(snap fingerprint.Snapshot) ([]byte, error) {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// ParseSnapshotHash returns the decoded snapshot hash extension of req,
+// stored under oid.
+func ParseSnapshotHash(req *x509.CertificateRequest, oid asn1.ObjectIdentifier) (*SnapshotHashExt, error) {
+	for _, e := range req.Extensions {
+		if !e.Id.Equal(oid) {
+			continue
+		}
+		var v SnapshotHashExt
+		if _, err := asn1.Unmarshal(e.Value, &v); err != nil {
+			return nil, err
+		}
+		return &v, nil
+	}
+	return nil, errors.New("csr: snapshot hash extension not present")
+}
+
+// EncodePEM wraps a DER encoded CSR into a PEM block.
+func EncodePEM(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+// ParseOID parses a dotted OID such as "1.3.6.1.4.1.99999.1.1".
+func ParseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("csr: %q is not a dotted OID", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("csr: %q is not a dotted OID", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
+
+func snapshotHashExt(snap fingerprint.Snapshot, oid asn1.ObjectIdentifier, signer crypto.Signer) (pkix.Extension, error) {
+	digest, err := SnapshotDigest(snap)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	v := SnapshotHashExt{Digest: digest}
+	if signer != nil {
+		var opts crypto.SignerOpts = crypto.SHA256
+		if _, ok := signer.Public().(ed25519.PublicKey); ok {
+			opts = crypto.Hash(0)
+		}
+		if v.Signature, err = signer.Sign(rand.Reader, digest, opts); err != nil {
+			return pkix.Extension{}, err
+		}
+	}
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oid, Value: b}, nil
+}
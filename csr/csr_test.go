@@ -0,0 +1,50 @@
+package csr
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func TestSnapshotHashOID(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := fingerprint.Snapshot{MachineID: "4c4c4544004d3110"}
+	if _, err := Create(key, snap, Options{SnapshotHash: true}); err == nil {
+		t.Fatal("Create embedded the extension without an OID")
+	}
+	oid, err := ParseOID("1.3.6.1.4.1.99999.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := Create(key, snap, Options{SnapshotHash: true, SnapshotHashOID: oid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, err := ParseSnapshotHash(req, oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := SnapshotDigest(snap); string(ext.Digest) != string(want) {
+		t.Errorf("digest %x, want %x", ext.Digest, want)
+	}
+	other, _ := ParseOID("1.3.6.1.4.1.99999.1.2")
+	if _, err := ParseSnapshotHash(req, other); err == nil {
+		t.Error("ParseSnapshotHash found the extension under another OID")
+	}
+}
+
+func TestParseOID(t *testing.T) {
+	for _, s := range []string{"", "1", "1..2", "1.x.2", "1.-2"} {
+		if _, err := ParseOID(s); err == nil {
+			t.Errorf("ParseOID(%q) succeeded", s)
+		}
+	}
+}
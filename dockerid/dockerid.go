@@ -0,0 +1,291 @@
+// Package dockerid queries the Docker Engine API for the daemon ID and the
+// details of a container.
+//
+// A Client uses the httpclient transport shared for its endpoint, so
+// repeated queries from a long-running agent reuse connections instead of
+// opening a socket per call. Before the first request the client pings the
+// daemon and pins the API version to the lower of the daemon's and
+// MaxAPIVersion; daemons too old to report a version are addressed without
+// a version prefix. Failed requests are retried with jittered exponential
+// backoff when the failure may be transient: a refused connection while
+// the daemon restarts, a timeout or a 5xx answer.
+package dockerid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"AurFingerprintAgent/httpclient"
+	"AurFingerprintAgent/retry"
+)
+
+// DefaultSocket is the daemon socket of a standard installation.
+const DefaultSocket = "/var/run/docker.sock"
+
+// API versions the client speaks. 1.24 is Docker 1.12, the first release
+// with the /info ID field in its current form.
+const (
+	MinAPIVersion = "1.24"
+	MaxAPIVersion = "1.45"
+)
+
+// DefaultRetry is the retry policy of clients that set none.
+var DefaultRetry = retry.Policy{Attempts: 3, Base: 100 * time.Millisecond, Max: time.Second, Jitter: 0.2}
+
+// ErrUnsupportedVersion is returned when the daemon's API is older than
+// MinAPIVersion.
+var ErrUnsupportedVersion = errors.New("dockerid: daemon API version unsupported")
+
+// StatusError is an unexpected HTTP answer.
+type StatusError struct {
+	Path   string
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("docker api %s: %d %s", e.Path, e.Status, http.StatusText(e.Status))
+}
+
+// Client talks to one daemon. Set its fields before the first request; it
+// is safe for concurrent use afterwards.
+type Client struct {
+	// Endpoint is the daemon address: a socket path, a unix:// URL, or a
+	// tcp:// or http:// URL. DefaultSocket when empty.
+	Endpoint string
+	// Retry repeats transiently failed requests; DefaultRetry when zero.
+	Retry retry.Policy
+	// Dial, when set, opens the connections on a transport of the client's
+	// own instead of the shared one. It receives the resolved network and
+	// address.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	once    sync.Once
+	http    *http.Client
+	base    string
+	network string
+	addr    string
+	err     error
+
+	mu      sync.Mutex
+	version string
+	pinned  bool
+}
+
+// New returns a client for endpoint.
+func New(endpoint string) *Client {
+	return &Client{Endpoint: endpoint}
+}
+
+func (c *Client) init() {
+	ep := c.Endpoint
+	if ep == "" {
+		ep = DefaultSocket
+	}
+	switch {
+	case strings.HasPrefix(ep, "unix://"):
+		c.network, c.addr, c.base = "unix", strings.TrimPrefix(ep, "unix://"), "http://docker"
+	case strings.HasPrefix(ep, "/"):
+		c.network, c.addr, c.base = "unix", ep, "http://docker"
+	case strings.HasPrefix(ep, "tcp://"):
+		c.addr = strings.TrimPrefix(ep, "tcp://")
+		c.network, c.base = "tcp", "http://"+c.addr
+	case strings.HasPrefix(ep, "http://"):
+		c.addr = strings.TrimSuffix(strings.TrimPrefix(ep, "http://"), "/")
+		c.network, c.base = "tcp", "http://"+c.addr
+	default:
+		c.err = fmt.Errorf("dockerid: unsupported endpoint %q", ep)
+		return
+	}
+	dial := c.Dial
+	switch {
+	case dial != nil:
+	case c.network == "unix":
+		c.http = httpclient.Unix(c.addr)
+		return
+	default:
+		c.http = httpclient.Shared()
+		return
+	}
+	network, addr := c.network, c.addr
+	c.http = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+		// Idle connections hold descriptors; keep few, briefly.
+		MaxIdleConns:    2,
+		IdleConnTimeout: 30 * time.Second,
+	}}
+}
+
+// Info is the part of GET /info the package reads.
+type Info struct {
+	ID            string `json:"ID"`
+	Name          string `json:"Name"`
+	ServerVersion string `json:"ServerVersion"`
+}
+
+// Info returns the daemon information.
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	var v Info
+	err := c.Get(ctx, "/info", &v)
+	v.ID = strings.TrimSpace(v.ID)
+	return v, err
+}
+
+// DaemonID returns the daemon's ID, stable across restarts.
+func (c *Client) DaemonID(ctx context.Context) (string, error) {
+	v, err := c.Info(ctx)
+	if err == nil && v.ID == "" {
+		err = errors.New("dockerid: daemon reported no ID")
+	}
+	return v.ID, err
+}
+
+// Container is the part of GET /containers/{id}/json the package reads.
+type Container struct {
+	ID string `json:"Id"`
+	// Image is the image ID (digest) the container runs.
+	Image  string `json:"Image"`
+	Config struct {
+		// Image is the reference the container was created from.
+		Image string `json:"Image"`
+	} `json:"Config"`
+}
+
+// Container returns the details of the container with the given ID or name.
+func (c *Client) Container(ctx context.Context, id string) (Container, error) {
+	var v Container
+	err := c.Get(ctx, "/containers/"+id+"/json", &v)
+	return v, err
+}
+
+// Get decodes the JSON answer of the API at path, e.g. "/info", retrying
+// transient failures.
+func (c *Client) Get(ctx context.Context, path string, v any) error {
+	c.once.Do(c.init)
+	if c.err != nil {
+		return c.err
+	}
+	p := c.Retry
+	if p.IsZero() {
+		p = DefaultRetry
+	}
+	return p.Do(ctx, func(ctx context.Context) error {
+		err := c.try(ctx, path, v)
+		if err != nil && !transient(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+}
+
+func (c *Client) try(ctx context.Context, path string, v any) error {
+	version, err := c.negotiate(ctx)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		path = "/v" + version + path
+	}
+	return c.get(ctx, path, v)
+}
+
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return &StatusError{path, resp.StatusCode}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// negotiate pins the API version on first use. A failed ping is not
+// remembered, so the next call tries again.
+func (c *Client) negotiate(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pinned {
+		return c.version, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.base+"/_ping", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return "", &StatusError{"/_ping", resp.StatusCode}
+	}
+	version := resp.Header.Get("API-Version")
+	if version != "" {
+		if compareVersions(version, MinAPIVersion) < 0 {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+		}
+		if compareVersions(version, MaxAPIVersion) > 0 {
+			version = MaxAPIVersion
+		}
+	}
+	c.version, c.pinned = version, true
+	return version, nil
+}
+
+// transient reports whether a retry may succeed.
+func transient(err error) bool {
+	var se *StatusError
+	var ne net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &se):
+		return se.Status >= 500 || se.Status == http.StatusTooManyRequests
+	case errors.Is(err, fs.ErrNotExist):
+		// No socket: no daemon to wait for.
+		return false
+	case errors.As(err, &ne):
+		return true
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions such as "1.41".
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,243 @@
+package dockerid
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/retry"
+)
+
+// fakeDaemon is a minimal Docker Engine API: /_ping answers with version
+// (no header when empty) and the other paths with the handlers in routes,
+// keyed by path without version prefix.
+type fakeDaemon struct {
+	version string
+	// fail makes the first fail requests other than /_ping answer 503.
+	fail     int32
+	requests atomic.Int32
+	conns    atomic.Int32
+	mu       sync.Mutex
+	paths    []string
+	routes   map[string]string
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/_ping" {
+		if d.version != "" {
+			w.Header().Set("API-Version", d.version)
+		}
+		w.Write([]byte("OK"))
+		return
+	}
+	d.mu.Lock()
+	d.paths = append(d.paths, r.URL.Path)
+	d.mu.Unlock()
+	if d.requests.Add(1) <= d.fail {
+		http.Error(w, "restarting", http.StatusServiceUnavailable)
+		return
+	}
+	p := r.URL.Path
+	if strings.HasPrefix(p, "/v") {
+		p = p[strings.Index(p[1:], "/")+1:]
+	}
+	body, ok := d.routes[p]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+// serveUnix runs d on a socket in a temporary directory.
+func serveUnix(t *testing.T, d *fakeDaemon) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: d, ConnState: func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			d.conns.Add(1)
+		}
+	}}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return sock
+}
+
+func newFake(version string) *fakeDaemon {
+	return &fakeDaemon{version: version, routes: map[string]string{
+		"/info":                   `{"ID":" 7TRN:IPZB:QYBB \n","Name":"node1","ServerVersion":"24.0.7"}`,
+		"/containers/abc123/json": `{"Id":"abc123","Image":"sha256:feed","Config":{"Image":"nginx:1.25"}}`,
+	}}
+}
+
+func TestDaemonIDUnixSocket(t *testing.T) {
+	d := newFake("1.43")
+	for _, ep := range []string{serveUnix(t, d), "unix://" + serveUnix(t, d)} {
+		c := New(ep)
+		id, err := c.DaemonID(context.Background())
+		if err != nil || id != "7TRN:IPZB:QYBB" {
+			t.Errorf("%s: DaemonID = %q, %v", ep, id, err)
+		}
+	}
+	if d.paths[0] != "/v1.43/info" {
+		t.Errorf("path = %q, want the negotiated version prefix", d.paths[0])
+	}
+}
+
+func TestDaemonIDTCP(t *testing.T) {
+	d := newFake("1.41")
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	for _, ep := range []string{srv.URL, "tcp://" + srv.Listener.Addr().String()} {
+		id, err := New(ep).DaemonID(context.Background())
+		if err != nil || id != "7TRN:IPZB:QYBB" {
+			t.Errorf("%s: DaemonID = %q, %v", ep, id, err)
+		}
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	for _, tc := range []struct {
+		daemon, path string
+		err          error
+	}{
+		{"1.45", "/v1.45/info", nil},
+		{"1.47", "/v" + MaxAPIVersion + "/info", nil},
+		{"1.24", "/v1.24/info", nil},
+		{"", "/info", nil},
+		{"1.12", "", ErrUnsupportedVersion},
+	} {
+		d := newFake(tc.daemon)
+		c := New(serveUnix(t, d))
+		_, err := c.Info(context.Background())
+		if !errors.Is(err, tc.err) {
+			t.Errorf("daemon %q: err = %v, want %v", tc.daemon, err, tc.err)
+			continue
+		}
+		if tc.path != "" && (len(d.paths) != 1 || d.paths[0] != tc.path) {
+			t.Errorf("daemon %q: requested %q, want %q", tc.daemon, d.paths, tc.path)
+		}
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	d := newFake("1.43")
+	d.fail = 2
+	c := New(serveUnix(t, d))
+	c.Retry = retry.Policy{Attempts: 3, Base: time.Millisecond}
+	id, err := c.DaemonID(context.Background())
+	if err != nil || id == "" {
+		t.Fatalf("DaemonID = %q, %v", id, err)
+	}
+	if n := d.requests.Load(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+
+	d = newFake("1.43")
+	d.fail = 5
+	c = New(serveUnix(t, d))
+	c.Retry = retry.Policy{Attempts: 2, Base: time.Millisecond}
+	var se *StatusError
+	if _, err := c.Info(context.Background()); !errors.As(err, &se) || se.Status != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want 503", err)
+	}
+	if n := d.requests.Load(); n != 2 {
+		t.Errorf("%d requests with one retry, want 2", n)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	d := newFake("1.43")
+	c := New(serveUnix(t, d))
+	c.Retry = retry.Policy{Attempts: 3, Base: time.Millisecond}
+	var se *StatusError
+	if _, err := c.Container(context.Background(), "missing"); !errors.As(err, &se) || se.Status != http.StatusNotFound {
+		t.Errorf("err = %v, want 404", err)
+	}
+	if n := d.requests.Load(); n != 1 {
+		t.Errorf("%d requests for a 404, want 1", n)
+	}
+
+	c = New(filepath.Join(t.TempDir(), "absent.sock"))
+	c.Retry = retry.Policy{Attempts: 3, Base: time.Hour}
+	done := make(chan error, 1)
+	go func() { _, err := c.Info(context.Background()); done <- err }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Info on a missing socket succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("missing socket was retried")
+	}
+}
+
+func TestTransportReuse(t *testing.T) {
+	d := newFake("1.43")
+	c := New(serveUnix(t, d))
+	for range 10 {
+		if _, err := c.Info(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := d.conns.Load(); n != 1 {
+		t.Errorf("%d connections for 10 requests, want 1", n)
+	}
+}
+
+func TestContainer(t *testing.T) {
+	c := New(serveUnix(t, newFake("1.43")))
+	v, err := c.Container(context.Background(), "abc123")
+	if err != nil || v.ID != "abc123" || v.Image != "sha256:feed" || v.Config.Image != "nginx:1.25" {
+		t.Errorf("Container = %+v, %v", v, err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	sock := serveUnix(t, newFake("1.43"))
+	var dialed string
+	c := New("unix://" + sock)
+	c.Dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = network + ":" + addr
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	if _, err := c.Info(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if dialed != "unix:"+sock {
+		t.Errorf("dialed %q", dialed)
+	}
+}
+
+func TestBadEndpoint(t *testing.T) {
+	if _, err := New("ftp://host").Info(context.Background()); err == nil {
+		t.Error("unsupported endpoint accepted")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.24", "1.24", 0}, {"1.9", "1.24", -1}, {"1.45", "1.100", -1}, {"2.0", "1.45", 1}, {"1.24.1", "1.24", 1},
+	} {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
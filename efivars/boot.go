@@ -0,0 +1,102 @@
+package efivars
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// LoadOption is a decoded EFI_LOAD_OPTION as stored in Boot#### variables.
+type LoadOption struct {
+	Attributes  uint32
+	Description string
+	// Path is the file path node of the device path, e.g.
+	// \EFI\debian\shimx64.efi.
+	Path string
+	// PartitionGUID is the signature of the hard drive media node.
+	PartitionGUID string
+}
+
+// Active reports whether LOAD_OPTION_ACTIVE is set.
+func (o LoadOption) Active() bool { return o.Attributes&0x1 != 0 }
+
+// ParseLoadOption decodes an EFI_LOAD_OPTION.
+func ParseLoadOption(b []byte) (*LoadOption, error) {
+	if len(b) < 6 {
+		return nil, errors.New("efivars: load option too short")
+	}
+	o := &LoadOption{Attributes: binary.LittleEndian.Uint32(b)}
+	fpLen := int(binary.LittleEndian.Uint16(b[4:]))
+	desc, n := ucs2(b[6:])
+	o.Description = desc
+	rest := b[6+n:]
+	if fpLen > len(rest) {
+		return o, errors.New("efivars: device path exceeds load option")
+	}
+	o.Path, o.PartitionGUID = walkDevicePath(rest[:fpLen])
+	return o, nil
+}
+
+// BootOrder decodes the BootOrder variable into Boot#### names.
+func BootOrder(b []byte) []string {
+	var out []string
+	for i := 0; i+2 <= len(b); i += 2 {
+		out = append(out, BootName(binary.LittleEndian.Uint16(b[i:])))
+	}
+	return out
+}
+
+// BootName formats a load option number as its variable name.
+func BootName(n uint16) string {
+	return fmt.Sprintf("Boot%04X", n)
+}
+
+// walkDevicePath extracts the file path and hard drive partition signature
+// from a device path list.
+func walkDevicePath(b []byte) (path, partGUID string) {
+	for len(b) >= 4 {
+		typ, sub := b[0], b[1]
+		l := int(binary.LittleEndian.Uint16(b[2:]))
+		if l < 4 || l > len(b) {
+			break
+		}
+		node := b[4:l]
+		switch {
+		case typ == 0x7f && sub == 0xff:
+			return
+		case typ == 0x04 && sub == 0x01 && len(node) >= 38 && node[37] == 0x02:
+			partGUID = FormatGUID(node[20:36])
+		case typ == 0x04 && sub == 0x04 && path == "":
+			path, _ = ucs2(node)
+		}
+		b = b[l:]
+	}
+	return
+}
+
+// ucs2 decodes a NUL-terminated UCS-2 string and returns the number of
+// bytes consumed including the terminator.
+func ucs2(b []byte) (string, int) {
+	var u []uint16
+	i := 0
+	for ; i+2 <= len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			return string(utf16.Decode(u)), i + 2
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u)), i
+}
+
+// FormatGUID renders a 16-byte EFI_GUID in its canonical lowercase form.
+func FormatGUID(b []byte) string {
+	if len(b) < 16 {
+		return ""
+	}
+	return strings.ToLower(fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16]))
+}
@@ -0,0 +1,199 @@
+// Package efivars reads UEFI variables from efivarfs.
+//
+// Files under /sys/firmware/efi/efivars are named <Name>-<VendorGUID> and
+// start with a 4-byte little-endian attribute mask followed by the variable
+// payload. Most variables are marked immutable by the kernel to protect
+// against accidental deletion; this does not affect reading but is reported
+// so callers know a write would first have to clear the flag.
+//
+// Some firmware implementations stall GetVariable calls indefinitely, so all
+// reads are bounded by a timeout.
+package efivars
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// DefaultDir is the efivarfs mount point.
+const DefaultDir = "/sys/firmware/efi/efivars"
+
+// GlobalGUID is EFI_GLOBAL_VARIABLE, the vendor of the standard variables
+// such as SecureBoot, PK and Boot####.
+const GlobalGUID = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+// DefaultTimeout bounds a single variable read.
+const DefaultTimeout = 2 * time.Second
+
+// Variable attribute bits.
+const (
+	AttrNonVolatile                       = 0x00000001
+	AttrBootserviceAccess                 = 0x00000002
+	AttrRuntimeAccess                     = 0x00000004
+	AttrHardwareErrorRecord               = 0x00000008
+	AttrAuthenticatedWriteAccess          = 0x00000010
+	AttrTimeBasedAuthenticatedWriteAccess = 0x00000020
+	AttrAppendWrite                       = 0x00000040
+)
+
+var (
+	// ErrTimeout is returned when the firmware does not answer in time.
+	ErrTimeout = errors.New("efivars: read timed out")
+	// ErrShort is returned for files lacking the attribute prefix.
+	ErrShort = errors.New("efivars: variable shorter than attribute header")
+)
+
+// Variable is a decoded efivarfs entry.
+type Variable struct {
+	Attributes uint32
+	Data       []byte
+	// Immutable reports whether the file carries the immutable inode flag.
+	Immutable bool
+}
+
+// Reader reads variables from Dir with a per-read Timeout. The zero value
+// uses DefaultDir and DefaultTimeout.
+type Reader struct {
+	Dir     string
+	Timeout time.Duration
+	// FS, when set, is read instead of the operating system; Dir is then
+	// a name within it, such as "sys/firmware/efi/efivars".
+	FS fs.FS
+}
+
+// Available reports whether efivarfs is mounted, i.e. the system booted via
+// UEFI and the kernel exposes its variables.
+func (r Reader) Available() bool {
+	var fi fs.FileInfo
+	var err error
+	if r.FS != nil {
+		fi, err = fs.Stat(r.FS, r.dir())
+	} else {
+		fi, err = os.Stat(r.dir())
+	}
+	return err == nil && fi.IsDir()
+}
+
+// Read returns variable name of vendor guid. Missing variables yield an
+// error satisfying errors.Is(err, fs.ErrNotExist).
+func (r Reader) Read(name, guid string) (*Variable, error) {
+	file := name + "-" + strings.ToLower(guid)
+	type result struct {
+		v   *Variable
+		err error
+	}
+	// The goroutine is abandoned on timeout: a read stuck in the firmware
+	// cannot be interrupted from user space.
+	ch := make(chan result, 1)
+	go func() {
+		v, err := r.readFile(file)
+		ch <- result{v, err}
+	}()
+	t := time.NewTimer(r.timeout())
+	defer t.Stop()
+	select {
+	case res := <-ch:
+		return res.v, res.err
+	case <-t.C:
+		return nil, fmt.Errorf("%s: %w", file, ErrTimeout)
+	}
+}
+
+// Names lists the variables of vendor guid.
+func (r Reader) Names(guid string) ([]string, error) {
+	var entries []fs.DirEntry
+	var err error
+	if r.FS != nil {
+		entries, err = fs.ReadDir(r.FS, r.dir())
+	} else {
+		err = fdcap.Default.Do(func() (err error) {
+			entries, err = os.ReadDir(r.dir())
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	suffix := "-" + strings.ToLower(guid)
+	var out []string
+	for _, e := range entries {
+		if n, ok := strings.CutSuffix(e.Name(), suffix); ok {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (r Reader) dir() string {
+	switch {
+	case r.Dir != "":
+		return r.Dir
+	case r.FS != nil:
+		return DefaultDir[1:]
+	}
+	return DefaultDir
+}
+
+func (r Reader) timeout() time.Duration {
+	if r.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return r.Timeout
+}
+
+func (r Reader) readFile(name string) (*Variable, error) {
+	var f fs.File
+	var err error
+	p := filepath.Join(r.dir(), name)
+	if r.FS != nil {
+		p = path.Join(r.dir(), name)
+		f, err = r.FS.Open(p)
+	} else {
+		var of *fdcap.File
+		if of, err = fdcap.Default.Open(p); err == nil {
+			f = of
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("%s: %w", p, ErrShort)
+	}
+	return &Variable{
+		Attributes: binary.LittleEndian.Uint32(buf),
+		Data:       buf[4:],
+		Immutable:  isImmutable(f),
+	}, nil
+}
+
+// isImmutable reports the immutable flag of files backed by the OS.
+func isImmutable(f fs.File) bool {
+	switch f := f.(type) {
+	case *fdcap.File:
+		return immutable(f.File)
+	case *os.File:
+		return immutable(f)
+	}
+	return false
+}
+
+// Read reads a variable with the default Reader.
+func Read(name, guid string) (*Variable, error) {
+	return Reader{}.Read(name, guid)
+}
@@ -0,0 +1,14 @@
+package efivars
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const fsImmutableFL = 0x00000010
+
+func immutable(f *os.File) bool {
+	flags, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	return err == nil && flags&fsImmutableFL != 0
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package efivars
+
+import "os"
+
+func immutable(*os.File) bool { return false }
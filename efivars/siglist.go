@@ -0,0 +1,45 @@
+package efivars
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// CertX509GUID is EFI_CERT_X509_GUID.
+const CertX509GUID = "a5c059a1-94e4-4aa7-87b5-ab155c2bf072"
+
+// Signature is one entry of an EFI_SIGNATURE_LIST.
+type Signature struct {
+	Type  string
+	Owner string
+	Data  []byte
+}
+
+// ParseSignatureLists decodes the concatenated EFI_SIGNATURE_LISTs found in
+// PK, KEK, db and dbx.
+func ParseSignatureLists(b []byte) ([]Signature, error) {
+	var out []Signature
+	for len(b) > 0 {
+		if len(b) < 28 {
+			return out, errors.New("efivars: truncated signature list header")
+		}
+		typ := FormatGUID(b[:16])
+		listSize := int(binary.LittleEndian.Uint32(b[16:]))
+		hdrSize := int(binary.LittleEndian.Uint32(b[20:]))
+		sigSize := int(binary.LittleEndian.Uint32(b[24:]))
+		if listSize < 28+hdrSize || listSize > len(b) || sigSize < 16 {
+			return out, errors.New("efivars: malformed signature list")
+		}
+		sigs := b[28+hdrSize : listSize]
+		for len(sigs) >= sigSize {
+			out = append(out, Signature{
+				Type:  typ,
+				Owner: FormatGUID(sigs[:16]),
+				Data:  sigs[16:sigSize],
+			})
+			sigs = sigs[sigSize:]
+		}
+		b = b[listSize:]
+	}
+	return out, nil
+}
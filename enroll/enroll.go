@@ -0,0 +1,171 @@
+// Package enroll implements a small EST (RFC 7030) style enrollment client
+// that submits a fingerprint-bound CSR and returns the issued certificates.
+//
+// Servers answering with a PEM certificate chain instead of a PKCS#7
+// certs-only structure are accepted as well, which covers simple ACME-like
+// provisioning endpoints.
+package enroll
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/httpclient"
+)
+
+// Client submits CSRs to an enrollment endpoint.
+type Client struct {
+	// URL is the enrollment endpoint. A bare EST base such as
+	// "https://est.example.com/.well-known/est" gets "/simpleenroll" appended.
+	URL string
+	// HTTPClient is used for requests; httpclient.Shared() when nil.
+	HTTPClient *http.Client
+	// Username and Password enable HTTP basic authentication when set.
+	Username string
+	Password string
+}
+
+// Enroll posts the DER encoded CSR and returns the issued certificate chain,
+// leaf first. When the server defers issuance with 202 Accepted, Enroll waits
+// for the advertised Retry-After interval and resubmits until ctx is done.
+func (c *Client) Enroll(ctx context.Context, csrDER []byte) ([]*x509.Certificate, error) {
+	for {
+		certs, retry, err := c.submit(ctx, csrDER)
+		if err != nil || retry == 0 {
+			return certs, err
+		}
+		t := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) endpoint() string {
+	u := strings.TrimRight(c.URL, "/")
+	if strings.HasSuffix(u, "/.well-known/est") {
+		u += "/simpleenroll"
+	}
+	return u
+}
+
+func (c *Client) submit(ctx context.Context, csrDER []byte) ([]*x509.Certificate, time.Duration, error) {
+	body := base64.StdEncoding.EncodeToString(csrDER)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), strings.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/pkcs10")
+	req.Header.Set("Content-Transfer-Encoding", "base64")
+	req.Header.Set("Accept", "application/pkcs7-mime, application/pem-certificate-chain")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = httpclient.Shared()
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusAccepted:
+		secs, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if secs <= 0 {
+			secs = 60
+		}
+		return nil, time.Duration(secs) * time.Second, nil
+	default:
+		return nil, 0, fmt.Errorf("enroll: server returned %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	certs, err := ParseCertificates(b)
+	return certs, 0, err
+}
+
+// ParseCertificates decodes an enrollment response: either a PEM chain or a
+// (possibly base64 encoded) PKCS#7 certs-only structure.
+func ParseCertificates(b []byte) ([]*x509.Certificate, error) {
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("-----BEGIN")) {
+		var certs []*x509.Certificate
+		for {
+			var blk *pem.Block
+			blk, b = pem.Decode(b)
+			if blk == nil {
+				break
+			}
+			if blk.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(blk.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("enroll: no certificates in PEM response")
+		}
+		return certs, nil
+	}
+	der := b
+	if dec, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(b), nil))); err == nil {
+		der = dec
+	}
+	return parsePKCS7(der)
+}
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+func parsePKCS7(der []byte) ([]*x509.Certificate, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("enroll: parse pkcs7: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("enroll: pkcs7 content is not signed-data")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("enroll: parse signed-data: %w", err)
+	}
+	if len(sd.Certificates.Bytes) == 0 {
+		return nil, errors.New("enroll: pkcs7 response carries no certificates")
+	}
+	return x509.ParseCertificates(sd.Certificates.Bytes)
+}
@@ -0,0 +1,138 @@
+package enroll
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, k.Public(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// certsOnly encodes a PKCS#7 certs-only structure, as openssl crl2pkcs7
+// -nocrl writes it.
+func certsOnly(t *testing.T, contentType asn1.ObjectIdentifier, certs ...*x509.Certificate) []byte {
+	t.Helper()
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+	data, _ := asn1.Marshal(struct{ Type asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      asn1.RawValue{FullBytes: data},
+		SignerInfos:      asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+	}
+	if len(raw) > 0 {
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw}
+	}
+	sdDER, err := asn1.Marshal(sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestParseCertificates(t *testing.T) {
+	leaf, ca := testCert(t, "leaf"), testCert(t, "ca")
+	p7 := certsOnly(t, oidSignedData, leaf, ca)
+	b64 := base64.StdEncoding.EncodeToString(p7)
+	wrapped := b64[:40] + "\r\n" + b64[40:] + "\n"
+	chain := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	for _, tc := range []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"PEM chain", "\n" + chain, []string{"leaf", "ca"}},
+		{"PKCS#7 DER", string(p7), []string{"leaf", "ca"}},
+		{"PKCS#7 base64", wrapped, []string{"leaf", "ca"}},
+		{"PEM without certificates", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}})), nil},
+		{"PKCS#7 without certificates", string(certsOnly(t, oidSignedData)), nil},
+		{"PKCS#7 enveloped-data", string(certsOnly(t, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}, leaf)), nil},
+		{"garbage", "not a certificate", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			certs, err := ParseCertificates([]byte(tc.body))
+			if tc.want == nil {
+				if err == nil {
+					t.Errorf("ParseCertificates = %d certificates, want an error", len(certs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range certs {
+				got = append(got, c.Subject.CommonName)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("ParseCertificates = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnroll(t *testing.T) {
+	leaf := testCert(t, "leaf")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/est/simpleenroll" || r.Header.Get("Content-Type") != "application/pkcs10" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if u, p, _ := r.BasicAuth(); u != "dev" || p != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkcs7-mime")
+		w.Write([]byte(base64.StdEncoding.EncodeToString(certsOnly(t, oidSignedData, leaf))))
+	}))
+	defer srv.Close()
+	c := &Client{URL: srv.URL + "/.well-known/est/", HTTPClient: srv.Client(), Username: "dev", Password: "secret"}
+	certs, err := c.Enroll(context.Background(), []byte("csr"))
+	if err != nil || len(certs) != 1 || !certs[0].Equal(leaf) {
+		t.Errorf("Enroll = %v, %v", certs, err)
+	}
+	c.Password = "wrong"
+	if _, err := c.Enroll(context.Background(), []byte("csr")); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Enroll with a wrong password = %v, want a 401 error", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Exit codes. They are part of the CLI contract so wrappers can branch on
+// the outcome without parsing messages; 2 is used by the flag package for
+// usage errors.
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitPartial     = 3
+	exitPermission  = 4
+	exitTimeout     = 5
+	exitPushFailure = 6
+	exitIdentity    = 7
+)
+
+var exitKinds = map[int]string{
+	exitFailure:     "failure",
+	exitPartial:     "partial",
+	exitPermission:  "permission",
+	exitTimeout:     "timeout",
+	exitPushFailure: "push_failure",
+	exitIdentity:    "identity_mismatch",
+}
+
+// codedError pins the exit code of err.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// partial reports a snapshot that some collectors did not contribute to,
+// e.g. for lack of budget, as exitPartial. Failed reads within a collector
+// do not count; they are listed in Snapshot.Errors.
+func partial(snap fingerprint.Snapshot) error {
+	if snap.Meta == nil || len(snap.Meta.Skipped) == 0 {
+		return nil
+	}
+	var names []string
+	for _, s := range snap.Meta.Skipped {
+		names = append(names, s.Collector)
+	}
+	return withExitCode(exitPartial, fmt.Errorf("partial snapshot, skipped %s", strings.Join(names, ", ")))
+}
+
+// exitCode classifies err.
+func exitCode(err error) int {
+	var ce *codedError
+	var ne net.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, fs.ErrPermission):
+		return exitPermission
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return exitTimeout
+	}
+	return exitFailure
+}
+
+// cutErrorFormat removes -error-format (or --error-format) from args so it
+// can be given before or after any subcommand.
+func cutErrorFormat(args []string) (format string, rest []string) {
+	format = "text"
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || name != "error-format" {
+			rest = append(rest, a)
+			continue
+		}
+		if !hasVal && i+1 < len(args) {
+			i++
+			val = args[i]
+		}
+		format = val
+	}
+	return format, rest
+}
+
+// exit reports err on stderr in the requested format and terminates with
+// its exit code.
+func exit(format, command string, err error) {
+	code := exitCode(err)
+	if format == "json" {
+		json.NewEncoder(os.Stderr).Encode(struct {
+			Command string `json:"command"`
+			Code    int    `json:"code"`
+			Kind    string `json:"kind"`
+			Error   string `json:"error"`
+		}{command, code, exitKinds[code], err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", command, err)
+	}
+	os.Exit(code)
+}
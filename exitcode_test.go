@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func TestExitCode(t *testing.T) {
+	skipped := fingerprint.Snapshot{Meta: &fingerprint.Meta{Skipped: []fingerprint.Skipped{{Collector: "docker", Reason: "budget"}}}}
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("boom"), exitFailure},
+		{partial(fingerprint.Snapshot{}), exitOK},
+		{partial(skipped), exitPartial},
+		{fmt.Errorf("read key: %w", fs.ErrPermission), exitPermission},
+		{fmt.Errorf("collect: %w", context.DeadlineExceeded), exitTimeout},
+		{withExitCode(exitPushFailure, fs.ErrPermission), exitPushFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
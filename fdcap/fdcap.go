@@ -0,0 +1,177 @@
+// Package fdcap caps the file descriptors the agent holds open at once.
+//
+// Collectors that overrun their budget are abandoned rather than killed,
+// and some reads, such as UEFI variables on broken firmware, never return.
+// In a long-running agent such stragglers, or a burst of socket clients,
+// could otherwise exhaust RLIMIT_NOFILE. Every file and socket opened
+// through a Manager takes a slot that is returned exactly once on Close;
+// when all slots are taken, opens wait briefly and then fail with
+// ErrExhausted instead of starving the rest of the process.
+//
+// Everything the agent opens while collecting, pushing or spooling goes
+// through Default. The exceptions open a handful of files once, on the
+// caller's goroutine, and close them before anything else runs: the input
+// files of one-shot commands, keys, redaction profiles and reconcile
+// policies loaded at startup, the self-update install, and the fleet
+// server's store, which runs in its own process.
+package fdcap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrExhausted is returned when no slot frees up within the wait time.
+var ErrExhausted = errors.New("fdcap: descriptor cap reached")
+
+// DefaultWait is how long Default waits for a free slot.
+const DefaultWait = time.Second
+
+// Manager hands out a fixed number of descriptor slots.
+type Manager struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// Default caps the descriptors of the whole agent at a share of
+// RLIMIT_NOFILE, leaving headroom for the Go runtime and the standard
+// streams.
+var Default = New(defaultCap(), DefaultWait)
+
+// New returns a Manager with max slots whose opens wait up to wait for a
+// free one.
+func New(max int, wait time.Duration) *Manager {
+	return &Manager{slots: make(chan struct{}, max), wait: wait}
+}
+
+// Cap returns the number of slots.
+func (m *Manager) Cap() int { return cap(m.slots) }
+
+// InUse returns the number of slots taken.
+func (m *Manager) InUse() int { return len(m.slots) }
+
+// Acquire takes a slot, waiting until ctx is done or the wait time
+// elapses. The returned release function is safe to call more than once.
+func (m *Manager) Acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		t := time.NewTimer(m.wait)
+		defer t.Stop()
+		select {
+		case m.slots <- struct{}{}:
+		case <-t.C:
+			return nil, ErrExhausted
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-m.slots }) }, nil
+}
+
+// File is an *os.File holding a slot until closed.
+type File struct {
+	*os.File
+	release func()
+}
+
+// Close closes the file and returns its slot.
+func (f *File) Close() error {
+	defer f.release()
+	return f.File.Close()
+}
+
+// Open is os.Open under the cap.
+func (m *Manager) Open(name string) (*File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile is os.OpenFile under the cap. The slot is returned when the
+// open fails.
+func (m *Manager) OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
+	release, err := m.Acquire(context.Background())
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &File{f, release}, nil
+}
+
+// Do runs fn, which opens and closes its own descriptor, under the cap.
+func (m *Manager) Do(fn func() error) error {
+	release, err := m.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// DialContext dials with d under the cap; the slot is held until the
+// connection is closed.
+func (m *Manager) DialContext(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+	release, err := m.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &conn{c, release}, nil
+}
+
+// Listener wraps l so that every accepted connection holds a slot. Accept
+// waits for a free slot first, applying back-pressure to clients instead
+// of failing once the cap is reached.
+func (m *Manager) Listener(l net.Listener) net.Listener {
+	return &listener{Listener: l, m: m, done: make(chan struct{})}
+}
+
+type listener struct {
+	net.Listener
+	m         *Manager
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case l.m.slots <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { <-l.m.slots }) }
+	c, err := l.Listener.Accept()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &conn{c, release}, nil
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+type conn struct {
+	net.Conn
+	release func()
+}
+
+func (c *conn) Close() error {
+	defer c.release()
+	return c.Conn.Close()
+}
@@ -0,0 +1,5 @@
+//go:build !unix
+
+package fdcap
+
+func defaultCap() int { return 256 }
@@ -0,0 +1,14 @@
+//go:build unix
+
+package fdcap
+
+import "syscall"
+
+// defaultCap is half the soft RLIMIT_NOFILE, within [32, 1024].
+func defaultCap() int {
+	var rl syscall.Rlimit
+	if syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl) != nil {
+		return 256
+	}
+	return int(min(max(rl.Cur/2, 32), 1024))
+}
@@ -0,0 +1,59 @@
+package fingerprint
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
+)
+
+// activationBits is the number of HMAC bits kept in an activation code;
+// 50 bits encode to exactly ten base32 characters.
+const activationBits = 50
+
+// ActivationCode derives a short human-typeable code from a fingerprint hash
+// using HOTP-style dynamic truncation of HMAC-SHA256 keyed with secret. The
+// code is formatted as two groups of five base32 characters, e.g.
+// "K3Q7M-XD2PA".
+func ActivationCode(secret []byte, hash string) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(strings.ToLower(hash)))
+	sum := m.Sum(nil)
+	off := int(sum[len(sum)-1] & 0x0f)
+	v := binary.BigEndian.Uint64(sum[off:off+8]) & (1<<63 - 1)
+	v >>= 63 - activationBits
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v<<(64-activationBits))
+	s := base32.StdEncoding.EncodeToString(b[:])[:activationBits/5]
+	return s[:5] + "-" + s[5:]
+}
+
+// VerifyActivationCode reports whether code matches the activation code for
+// hash. Separators, case and the common 0/O, 1/I and 8/B confusions made
+// when codes are read over the phone are tolerated.
+func VerifyActivationCode(secret []byte, hash, code string) bool {
+	want := normalizeActivationCode(ActivationCode(secret, hash))
+	got := normalizeActivationCode(code)
+	return subtle.ConstantTimeCompare([]byte(want), []byte(got)) == 1
+}
+
+func normalizeActivationCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '\t':
+			return -1
+		case '0':
+			return 'O'
+		case '1':
+			return 'I'
+		case '8':
+			return 'B'
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, code)
+}
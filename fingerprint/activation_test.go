@@ -0,0 +1,51 @@
+package fingerprint
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestActivationCode(t *testing.T) {
+	secret := []byte("activation secret")
+	snap := Snapshot{SchemaVersion: SchemaVersion, Hostname: "app-01", MachineID: "0123456789abcdef0123456789abcdef"}
+	other := snap
+	other.MachineID = "fedcba9876543210fedcba9876543210"
+	hash := snap.Hash()
+	code := ActivationCode(secret, hash)
+
+	if !regexp.MustCompile(`^[A-Z2-7]{5}-[A-Z2-7]{5}$`).MatchString(code) {
+		t.Fatalf("ActivationCode = %q, want two groups of five base32 characters", code)
+	}
+	if again := ActivationCode(secret, strings.ToUpper(hash)); again != code {
+		t.Errorf("ActivationCode of the upper-case hash = %q, want %q", again, code)
+	}
+
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	tampered := func(i int) string {
+		c := alphabet[(strings.IndexByte(alphabet, code[i])+1)%len(alphabet)]
+		return code[:i] + string(c) + code[i+1:]
+	}
+	for _, tc := range []struct {
+		name   string
+		secret []byte
+		hash   string
+		code   string
+		want   bool
+	}{
+		{"round trip", secret, hash, code, true},
+		{"lower case without separator", secret, hash, strings.ToLower(strings.ReplaceAll(code, "-", "")), true},
+		{"spaced", secret, hash, code[:5] + " " + code[6:], true},
+		{"misread digits", secret, hash, strings.NewReplacer("O", "0", "I", "1", "B", "8").Replace(code), true},
+		{"first character tampered", secret, hash, tampered(0), false},
+		{"last character tampered", secret, hash, tampered(len(code) - 1), false},
+		{"truncated", secret, hash, code[:len(code)-1], false},
+		{"empty", secret, hash, "", false},
+		{"wrong snapshot", secret, other.Hash(), code, false},
+		{"wrong secret", []byte("another secret"), hash, code, false},
+	} {
+		if got := VerifyActivationCode(tc.secret, tc.hash, tc.code); got != tc.want {
+			t.Errorf("%s: VerifyActivationCode(%q) = %v, want %v", tc.name, tc.code, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package fingerprint
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// AgentInfo is the context the snapshot was collected in, which explains
+// gaps such as DMI serials missing for an unprivileged agent.
+type AgentInfo struct {
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	PID    int    `json:"pid,omitempty"`
+	UID    int    `json:"uid"`
+	EUID   int    `json:"euid"`
+	// Capabilities are the effective Linux capabilities, e.g.
+	// "CAP_SYS_ADMIN".
+	Capabilities []string  `json:"capabilities,omitempty"`
+	StartTime    time.Time `json:"start_time,omitzero"`
+	WorkingDir   string    `json:"working_dir,omitempty"`
+	// SystemdUnit is the unit the agent runs in when started by systemd.
+	SystemdUnit string `json:"systemd_unit,omitempty"`
+	// Container is the container runtime the agent runs under.
+	Container string `json:"container,omitempty"`
+	// SSH reports a run from an SSH session.
+	SSH bool `json:"ssh,omitempty"`
+	// HostNamespaces are the namespaces, e.g. "mnt" or "pid", the agent
+	// shares with PID 1, IsolatedNamespaces those it does not. Both are
+	// empty when /proc/1/ns cannot be read.
+	HostNamespaces     []string `json:"host_namespaces,omitempty"`
+	IsolatedNamespaces []string `json:"isolated_namespaces,omitempty"`
+}
+
+// GoRuntimeInfo is the former name of AgentInfo.
+//
+// Deprecated: use AgentInfo.
+type GoRuntimeInfo = AgentInfo
+
+// processStart approximates the start of the agent process.
+var processStart = time.Now()
+
+func agentInfo() AgentInfo {
+	a := AgentInfo{
+		GOOS:         runtime.GOOS,
+		GOARCH:       runtime.GOARCH,
+		PID:          os.Getpid(),
+		UID:          os.Getuid(),
+		EUID:         os.Geteuid(),
+		Capabilities: effectiveCaps(),
+		StartTime:    processStart.UTC().Truncate(time.Second),
+		SSH:          os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_CLIENT") != "",
+	}
+	a.WorkingDir, _ = os.Getwd()
+	a.HostNamespaces, a.IsolatedNamespaces = sharedNamespaces()
+	cgroup := selfCgroup()
+	a.Container = containerRuntime(cgroup)
+	// systemd sets INVOCATION_ID for the processes of a unit.
+	if os.Getenv("INVOCATION_ID") != "" {
+		if u := path.Base(cgroup); strings.HasSuffix(u, ".service") || strings.HasSuffix(u, ".scope") {
+			a.SystemdUnit = u
+		}
+	}
+	return a
+}
+
+// nsTypes are the namespace kinds of /proc/<pid>/ns.
+var nsTypes = []string{"cgroup", "ipc", "mnt", "net", "pid", "time", "user", "uts"}
+
+// sharedNamespaces compares the namespaces of the agent with those of PID
+// 1, which takes CAP_SYS_PTRACE unless the agent runs as the same user.
+func sharedNamespaces() (shared, isolated []string) {
+	for _, t := range nsTypes {
+		self, err := os.Readlink("/proc/self/ns/" + t)
+		if err != nil {
+			continue
+		}
+		pid1, err := os.Readlink("/proc/1/ns/" + t)
+		if err != nil {
+			continue
+		}
+		if self == pid1 {
+			shared = append(shared, t)
+		} else {
+			isolated = append(isolated, t)
+		}
+	}
+	return shared, isolated
+}
+
+// capNames are the Linux capabilities by bit number.
+var capNames = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER",
+	"CAP_FSETID", "CAP_KILL", "CAP_SETGID", "CAP_SETUID", "CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE", "CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK", "CAP_IPC_OWNER",
+	"CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE", "CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD",
+	"CAP_LEASE", "CAP_AUDIT_WRITE", "CAP_AUDIT_CONTROL", "CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG", "CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// effectiveCaps decodes CapEff of the agent's /proc/self/status; nil
+// where procfs is unavailable.
+func effectiveCaps() []string {
+	f, err := fdcap.Default.Open("/proc/self/status")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		v, ok := strings.CutPrefix(sc.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+		if err != nil {
+			return nil
+		}
+		return decodeCaps(mask)
+	}
+	return nil
+}
+
+func decodeCaps(mask uint64) []string {
+	caps := []string{}
+	for i := range 64 {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		if i < len(capNames) {
+			caps = append(caps, capNames[i])
+		} else {
+			caps = append(caps, "CAP_"+strconv.Itoa(i))
+		}
+	}
+	return caps
+}
@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// BlockDevice is a disk, partition or stacked device of /sys/block, with
+// the links to the devices built on it.
+type BlockDevice struct {
+	Name string `json:"name"`
+	// Kind is "disk", "partition", "dm" (device mapper, e.g. LVM or
+	// dm-crypt), "md" (software RAID) or "loop".
+	Kind string `json:"kind"`
+	// Parent is the disk of a partition.
+	Parent string `json:"parent,omitempty"`
+	// DMName is the device mapper name, e.g. "rhel-root".
+	DMName    string `json:"dm_name,omitempty"`
+	SizeBytes uint64 `json:"size_bytes"`
+	Model     string `json:"model,omitempty"`
+	// Holders are the devices built on this one, e.g. the dm-0 of an LVM
+	// physical volume or the md0 of a RAID member.
+	Holders []string `json:"holders,omitempty"`
+	// Mountpoint and FSType are the file system mounted from the device.
+	Mountpoint string `json:"mountpoint,omitempty"`
+	FSType     string `json:"fstype,omitempty"`
+	// PartTable is the partition table type of a disk, "gpt" or "dos", and
+	// PTUUID its GPT disk GUID or MBR disk signature. PartUUID is the
+	// GPT partition GUID of a partition, or the signature with the
+	// partition number for MBR. GPT GUIDs are written when the disk is
+	// partitioned and survive reinstalling the operating system.
+	PartTable string `json:"part_table,omitempty"`
+	PTUUID    string `json:"ptuuid,omitempty"`
+	PartUUID  string `json:"partuuid,omitempty"`
+}
+
+// blockDevices lists /sys/block and the partitions of each device, disks
+// in kernel order followed by their partitions. RAM disks and empty
+// virtual devices are left out. With raw set, partition tables udev has
+// not probed are read off the disks.
+func (h host) blockDevices(raw bool) []BlockDevice {
+	const base = "/sys/block"
+	entries, err := h.readDir(base)
+	if err != nil {
+		return nil
+	}
+	mounts := map[string]mountedFS{}
+	if f, err := h.open(h.procSelf() + "/mountinfo"); err == nil {
+		mounts = parseMountinfoDevices(f)
+		f.Close()
+	}
+	var out []BlockDevice
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "ram") {
+			continue
+		}
+		dir := path.Join(base, name)
+		d := h.blockDevice(dir, name, mounts)
+		dmName := h.readTrim(path.Join(dir, "dm/name"))
+		switch {
+		case d.SizeBytes == 0 && !h.readable(path.Join(dir, "device")):
+			// Unattached loop devices, unconfigured zram.
+			continue
+		case strings.HasPrefix(name, "loop"):
+			d.Kind = "loop"
+		case dmName != "":
+			d.Kind, d.DMName = "dm", dmName
+		case h.readable(path.Join(dir, "md")):
+			d.Kind = "md"
+		default:
+			d.Kind = "disk"
+			d.Model = h.readTrim(path.Join(dir, "device/model"))
+		}
+		var pt *partitionTable
+		if d.Kind == "disk" {
+			if pt = h.diskPartitionTable(dir, name, raw); pt != nil {
+				d.PartTable, d.PTUUID = pt.Type, pt.UUID
+			}
+		}
+		out = append(out, d)
+		parts, _ := h.readDir(dir)
+		for _, p := range parts {
+			if !strings.HasPrefix(p.Name(), name) {
+				continue
+			}
+			pdir := path.Join(dir, p.Name())
+			num, err := strconv.Atoi(h.readTrim(path.Join(pdir, "partition")))
+			if err != nil {
+				continue
+			}
+			pd := h.blockDevice(pdir, p.Name(), mounts)
+			pd.Kind, pd.Parent = "partition", name
+			pd.PartUUID = h.partUUID(pdir, num, pt)
+			out = append(out, pd)
+		}
+	}
+	return out
+}
+
+func (h host) blockDevice(dir, name string, mounts map[string]mountedFS) BlockDevice {
+	d := BlockDevice{Name: name}
+	// size is in 512-byte sectors whatever the logical block size.
+	if n, err := strconv.ParseUint(h.readTrim(path.Join(dir, "size")), 10, 64); err == nil {
+		d.SizeBytes = n * 512
+	}
+	holders, _ := h.readDir(path.Join(dir, "holders"))
+	for _, e := range holders {
+		d.Holders = append(d.Holders, e.Name())
+	}
+	if m, ok := mounts[h.readTrim(path.Join(dir, "dev"))]; ok {
+		d.Mountpoint, d.FSType = m.mountpoint, m.fstype
+	}
+	return d
+}
@@ -0,0 +1,115 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// BootInfo summarizes PID 1 and the boot chain for triage: an unexpected
+// init binary or a kernel that was not installed under /boot are common
+// signs of persistence.
+type BootInfo struct {
+	PID1Exe       string `json:"pid1_exe,omitempty"`
+	PID1SHA256    string `json:"pid1_sha256,omitempty"`
+	DefaultTarget string `json:"default_target,omitempty"`
+	BootImage     string `json:"boot_image,omitempty"`
+	KernelImage   string `json:"kernel_image,omitempty"`
+	// ExpectedKernel reports whether the running kernel release has a
+	// matching image in /boot that the bootloader actually loaded.
+	ExpectedKernel bool   `json:"expected_kernel"`
+	KernelReason   string `json:"kernel_reason,omitempty"`
+}
+
+func (h host) bootInfo(kernelRelease string) *BootInfo {
+	b := &BootInfo{
+		DefaultTarget: h.defaultTarget(),
+		BootImage:     cmdlineValue(h.readTrim("/proc/cmdline"), "BOOT_IMAGE"),
+	}
+	if exe, err := h.readlink("/proc/1/exe"); err == nil {
+		b.PID1Exe = strings.TrimSuffix(exe, " (deleted)")
+		b.PID1SHA256 = h.fileSHA256("/proc/1/exe")
+	}
+	b.KernelImage, b.ExpectedKernel, b.KernelReason = h.checkKernel(kernelRelease, b.BootImage)
+	if b.PID1Exe == "" && b.DefaultTarget == "" && b.BootImage == "" && b.KernelImage == "" {
+		return nil
+	}
+	return b
+}
+
+// defaultTarget resolves the systemd default.target symlink, preferring the
+// administrator's override in /etc.
+func (h host) defaultTarget() string {
+	for _, p := range []string{
+		"/etc/systemd/system/default.target",
+		"/usr/lib/systemd/system/default.target",
+		"/lib/systemd/system/default.target",
+	} {
+		if t, err := h.readlink(p); err == nil {
+			return filepath.Base(t)
+		}
+		if h.readable(p) {
+			return "default.target"
+		}
+	}
+	return ""
+}
+
+func cmdlineValue(cmdline, key string) string {
+	for _, f := range strings.Fields(cmdline) {
+		if v, ok := strings.CutPrefix(f, key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// checkKernel locates the image for the running release in /boot and
+// compares it with the path the bootloader passed as BOOT_IMAGE. The path
+// may be relative to a separate /boot partition or carry a GRUB device
+// prefix such as "(hd0,gpt2)".
+func (h host) checkKernel(release, bootImage string) (image string, ok bool, reason string) {
+	if release == "" {
+		return "", false, "unknown kernel release"
+	}
+	for _, name := range []string{"vmlinuz-" + release, "vmlinux-" + release, "Image-" + release} {
+		if p := filepath.Join("/boot", name); h.readable(p) {
+			image = p
+			break
+		}
+	}
+	if image == "" {
+		return "", false, "no image for running release " + release + " in /boot"
+	}
+	if bootImage == "" {
+		return image, true, ""
+	}
+	if i := strings.IndexByte(bootImage, ')'); strings.HasPrefix(bootImage, "(") && i > 0 {
+		bootImage = bootImage[i+1:]
+	}
+	base := filepath.Base(image)
+	switch bootImage {
+	case image, "/" + base:
+		return image, true, ""
+	}
+	// Distribution symlinks such as /boot/vmlinuz -> vmlinuz-<release>.
+	if filepath.Base(h.resolveLink(filepath.Join("/boot", filepath.Base(bootImage)))) == base {
+		return image, true, ""
+	}
+	return image, false, "booted " + bootImage + ", expected " + image
+}
+
+func (h host) fileSHA256(path string) string {
+	f, err := h.open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,68 @@
+package fingerprint
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// BuildInfo is the agent's own software bill of materials as recorded by
+// the Go toolchain in the binary.
+type BuildInfo struct {
+	GoVersion string            `json:"go_version,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Main      Module            `json:"main"`
+	Deps      []Module          `json:"deps,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+// Module is a module compiled into the agent.
+type Module struct {
+	Path    string  `json:"path"`
+	Version string  `json:"version,omitempty"`
+	Sum     string  `json:"sum,omitempty"`
+	Replace *Module `json:"replace,omitempty"`
+}
+
+// AgentBuild returns the build information embedded in the running binary,
+// or nil when it was built without module support.
+var AgentBuild = sync.OnceValue(func() *BuildInfo {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	b := &BuildInfo{
+		GoVersion: bi.GoVersion,
+		Path:      bi.Path,
+		Main:      module(&bi.Main),
+		Settings:  map[string]string{},
+	}
+	for _, d := range bi.Deps {
+		b.Deps = append(b.Deps, module(d))
+	}
+	for _, s := range bi.Settings {
+		b.Settings[s.Key] = s.Value
+	}
+	return b
+})
+
+// Summary keeps the agent version and VCS revision of b, all a snapshot
+// needs to name the build; the full list is what the sbom command prints.
+func (b *BuildInfo) Summary() *BuildInfo {
+	if b == nil {
+		return nil
+	}
+	s := &BuildInfo{Main: Module{Path: b.Main.Path, Version: b.Main.Version}}
+	if rev := b.Settings["vcs.revision"]; rev != "" {
+		s.Settings = map[string]string{"vcs.revision": rev}
+	}
+	return s
+}
+
+func module(m *debug.Module) Module {
+	out := Module{Path: m.Path, Version: m.Version, Sum: m.Sum}
+	if m.Replace != nil {
+		r := module(m.Replace)
+		out.Replace = &r
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package fingerprint
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CachedProvider serves a snapshot to repeated callers, such as HTTP
+// handlers embedding the fingerprint, collecting it at most once per TTL so
+// that Docker, blkid and the other slow probes do not run per call. The
+// volatile network sections can be refreshed on their own, on a shorter
+// schedule or on demand. It is safe for concurrent use; callers arriving
+// during a collection wait for it and share the result.
+//
+// Returned snapshots share memory with the cache and must not be modified.
+type CachedProvider struct {
+	// VolatileTTL, when positive, re-collects the volatile sections once
+	// they are older, without re-running the other collectors. Set it
+	// before first use.
+	VolatileTTL time.Duration
+
+	ttl  time.Duration
+	opts []Option
+
+	mu       sync.Mutex
+	snap     *Snapshot
+	full     time.Time
+	volatile time.Time
+	stale    map[string]bool
+}
+
+// NewCachedProvider returns a provider collecting with opts and keeping the
+// snapshot for ttl.
+func NewCachedProvider(ttl time.Duration, opts ...Option) *CachedProvider {
+	return &CachedProvider{ttl: ttl, opts: opts}
+}
+
+// volatileSections are the sections whose contents change while the
+// system runs, which CachedProvider can refresh individually.
+var volatileSections = map[string]bool{
+	"network": true, "network_config": true, "netns": true, "routing": true,
+	"neighbors": true, "dhcp": true, "ipv6": true,
+}
+
+// Snapshot returns the cached snapshot, collecting what is out of date.
+func (p *CachedProvider) Snapshot() Snapshot {
+	return p.SnapshotContext(context.Background())
+}
+
+// SnapshotContext is Snapshot with any collection bounded by ctx. A
+// collection cut short by ctx is returned but not cached.
+func (p *CachedProvider) SnapshotContext(ctx context.Context) Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.snap == nil || now.Sub(p.full) >= p.ttl {
+		s := GetSnapshotContext(ctx, p.opts...)
+		if ctx.Err() == nil {
+			p.snap, p.full, p.volatile, p.stale = &s, now, now, nil
+		}
+		return s
+	}
+	stale := p.stale
+	expired := p.VolatileTTL > 0 && now.Sub(p.volatile) >= p.VolatileTTL
+	if expired {
+		stale = map[string]bool{}
+		for n := range volatileSections {
+			stale[n] = true
+		}
+	}
+	if len(stale) == 0 {
+		return *p.snap
+	}
+	s := p.refresh(ctx, stale)
+	if ctx.Err() == nil {
+		p.snap, p.stale = &s, nil
+		if expired {
+			p.volatile = now
+		}
+	}
+	return s
+}
+
+// Invalidate marks the sections of the named volatile collectors stale, so
+// the next call re-collects just them. Without names, or when a name is
+// not one of "network", "network_config", "netns", "routing", "neighbors",
+// "dhcp" or "ipv6", the whole snapshot is dropped.
+func (p *CachedProvider) Invalidate(collectors ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, n := range collectors {
+		if !volatileSections[n] {
+			collectors = nil
+			break
+		}
+	}
+	if len(collectors) == 0 {
+		p.snap, p.stale = nil, nil
+		return
+	}
+	if p.stale == nil {
+		p.stale = map[string]bool{}
+	}
+	for _, n := range collectors {
+		p.stale[n] = true
+	}
+}
+
+// refresh re-runs the named volatile collectors against a copy of the
+// cached snapshot.
+func (p *CachedProvider) refresh(ctx context.Context, names map[string]bool) Snapshot {
+	s := *p.snap
+	var secs []Section
+	for n := range names {
+		secs = append(secs, Section(n))
+	}
+	// All names are known sections.
+	_ = s.Refresh(ctx, secs...)
+	return s
+}
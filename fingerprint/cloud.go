@@ -0,0 +1,140 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/retry"
+)
+
+// CloudInfo describes the cloud instance as reported by cloud-init.
+type CloudInfo struct {
+	CloudName        string `json:"cloud_name,omitempty"`
+	Platform         string `json:"platform,omitempty"`
+	Region           string `json:"region,omitempty"`
+	AvailabilityZone string `json:"availability_zone,omitempty"`
+	InstanceID       string `json:"instance_id,omitempty"`
+	LocalHostname    string `json:"local_hostname,omitempty"`
+	// CachedInstanceID is the instance ID cloud-init last processed.
+	CachedInstanceID string `json:"cached_instance_id,omitempty"`
+	// MachineIDMismatch flags a machine ID that does not belong to this
+	// instance, typically an image cloned without re-running cloud-init.
+	MachineIDMismatch bool   `json:"machine_id_mismatch,omitempty"`
+	MismatchReason    string `json:"mismatch_reason,omitempty"`
+}
+
+const cloudDataDir = "/var/lib/cloud"
+
+func (h host) cloudInitInfo(ctx context.Context) *CloudInfo {
+	v1, ok := h.cloudInitQuery(ctx)
+	if !ok {
+		return nil
+	}
+	ci := &CloudInfo{
+		CloudName:        v1.CloudName,
+		Platform:         v1.Platform,
+		Region:           v1.Region,
+		AvailabilityZone: v1.AvailabilityZone,
+		InstanceID:       v1.InstanceID,
+		LocalHostname:    v1.LocalHostname,
+		CachedInstanceID: h.readTrim(filepath.Join(cloudDataDir, "data/instance-id")),
+	}
+	ci.MismatchReason = h.reconcileInstance(ci.InstanceID, ci.CachedInstanceID)
+	ci.MachineIDMismatch = ci.MismatchReason != ""
+	return ci
+}
+
+type cloudV1 struct {
+	CloudName        string `json:"cloud_name"`
+	Platform         string `json:"platform"`
+	Region           string `json:"region"`
+	AvailabilityZone string `json:"availability_zone"`
+	InstanceID       string `json:"instance_id"`
+	LocalHostname    string `json:"local_hostname"`
+}
+
+// errCloudInitPending reports instance data cloud-init has not written.
+var errCloudInitPending = errors.New("cloud-init instance data not available")
+
+// cloudInitQuery runs "cloud-init query --all" and falls back to the
+// world-readable instance data cache when the command is unavailable.
+// While cloud-init is still running, as early in boot, it retries.
+func (h host) cloudInitQuery(ctx context.Context) (cloudV1, bool) {
+	ctx, cancel := defaultTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var v1 cloudV1
+	err := h.retry("cloud-init").Do(ctx, func(ctx context.Context) error {
+		var ok bool
+		if v1, ok = h.cloudInitQueryOnce(ctx); ok {
+			return nil
+		}
+		if h.cloudInitRunning() {
+			h.debug("cloud-init still running; retrying")
+			return errCloudInitPending
+		}
+		return retry.Permanent(errCloudInitPending)
+	})
+	return v1, err == nil
+}
+
+func (h host) cloudInitQueryOnce(ctx context.Context) (cloudV1, bool) {
+	var doc struct {
+		V1 cloudV1 `json:"v1"`
+	}
+	var b []byte
+	err := errors.ErrUnsupported
+	if h.native() {
+		b, err = h.output(ctx, "cloud-init", "query", "--all")
+	}
+	if err != nil || json.Unmarshal(b, &doc) != nil {
+		b, err = h.readFile("/run/cloud-init/instance-data.json")
+		if err != nil || json.Unmarshal(b, &doc) != nil {
+			return cloudV1{}, false
+		}
+	}
+	return doc.V1, doc.V1.InstanceID != ""
+}
+
+// cloudInitRunning reports whether cloud-init has started on this boot
+// but not yet written its final result.
+func (h host) cloudInitRunning() bool {
+	if _, err := h.stat("/run/cloud-init"); err != nil {
+		return false
+	}
+	_, err := h.stat("/run/cloud-init/result.json")
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// reconcileInstance explains why the machine ID does not belong to the
+// current instance, or returns "" when it appears consistent.
+func (h host) reconcileInstance(live, cached string) string {
+	if live == "" {
+		return ""
+	}
+	if cached != "" && cached != live {
+		return "cloud-init has not processed instance " + live + " (cached " + cached + ")"
+	}
+	prev := h.readTrim(filepath.Join(cloudDataDir, "data/previous-instance-id"))
+	if prev == "" || prev == live {
+		return ""
+	}
+	// The instance changed on this boot. A machine ID older than the new
+	// instance's cloud-init state survived the change and was cloned.
+	inst, err := h.stat(filepath.Join(cloudDataDir, "instances", live))
+	if err != nil {
+		return ""
+	}
+	mid, err := h.stat("/etc/machine-id")
+	if err != nil {
+		return ""
+	}
+	if mid.ModTime().Before(inst.ModTime()) {
+		return "machine-id predates instance " + live + " (previous instance " + strings.TrimSpace(prev) + ")"
+	}
+	return ""
+}
@@ -0,0 +1,512 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// builtin is a collector shipped with the package. Opt-in collectors have
+// an enabled predicate over the options.
+type builtin struct {
+	name string
+	// weight is the step's share of the budget relative to other steps.
+	weight int
+	// hashed steps collect fields of Snapshot.Components.
+	hashed bool
+	// dependent steps read what earlier steps stored in prev.
+	dependent bool
+	enabled   func(o *options) bool
+	run       func(ctx context.Context, o *options, prev *Snapshot) func(*Snapshot)
+}
+
+func (b builtin) Name() string { return b.name }
+func (b builtin) Weight() int  { return b.weight }
+
+// Collect runs b with its own error log; failed reads are stored in
+// Snapshot.Errors along with the result.
+func (b builtin) Collect(ctx context.Context, env *Env, prev *Snapshot) (func(*Snapshot), error) {
+	o := *env.opts
+	o.errs = &readErrors{}
+	if b.hashed {
+		o.timeouts.FileRead = 0
+	}
+	if o.provenance {
+		o.sources = &sourceLog{}
+	}
+	if o.logger != nil {
+		o.logger = o.logger.With("collector", b.name)
+	}
+	apply := b.run(ctx, &o, prev)
+	errs := o.errs.take(b.name)
+	var src []string
+	if o.sources != nil {
+		src = o.sources.take()
+	}
+	if len(errs) == 0 && src == nil {
+		return apply, nil
+	}
+	return func(s *Snapshot) {
+		apply(s)
+		s.Errors = append(s.Errors, errs...)
+		if src != nil {
+			s.setSources(b.name, src)
+		}
+	}, nil
+}
+
+// builtins are run in order; later steps may depend on earlier ones.
+var builtins = []builtin{
+	{name: "hostname", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		name, _ := os.Hostname()
+		if h := o.host(); !h.native() {
+			// /proc/sys reflects the reader's UTS namespace, so the
+			// host's own configuration comes first.
+			if name = h.readTrim("/etc/hostname"); name == "" {
+				name = h.readTrim("/proc/sys/kernel/hostname")
+			}
+		} else if h.recording() {
+			// Replay has no UTS namespace to ask.
+			h.rec.file("/proc/sys/kernel/hostname", []byte(name+"\n"))
+		}
+		return func(s *Snapshot) { s.Hostname = name }
+	}},
+	{name: "os", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		h := o.host().expect()
+		name, ver := h.readOSEtc()
+		info := OSInfo{
+			Name:       name,
+			Version:    ver,
+			KernelType: h.readTrim("/proc/sys/kernel/ostype"),
+			KernelRel:  h.readTrim("/proc/sys/kernel/osrelease"),
+		}
+		return func(s *Snapshot) { s.OS = info }
+	}},
+	{name: "machine_id", weight: 1, hashed: true, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		id := o.host().expect().readTrim("/etc/machine-id")
+		return func(s *Snapshot) { s.MachineID = id }
+	}},
+	{name: "dmi", weight: 1, hashed: true, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		h := o.host().expect()
+		d := DMIInfo{
+			ProductUUID:     h.readTrim("/sys/class/dmi/id/product_uuid"),
+			BoardSerial:     h.readTrim("/sys/class/dmi/id/board_serial"),
+			ChassisAssetTag: h.readTrim("/sys/class/dmi/id/chassis_asset_tag"),
+		}
+		// Older kernels and some firmware lack the descriptive files.
+		o.host().dmiDescription(&d)
+		o.host().dmiTables(&d)
+		d.Invalid = d.placeholderFields()
+		return func(s *Snapshot) { s.DMI = d }
+	}},
+	{name: "cpu", weight: 1, hashed: true, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		c := o.host().cpuInfo()
+		return func(s *Snapshot) { s.CPU = c }
+	}},
+	{name: "memory", weight: 1, hashed: true, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		h := o.host()
+		m := MemoryInfo{MemTotalKB: h.expect().memTotalKB(), NUMANodes: h.numaNodes(), EDAC: h.edacInfo()}
+		return func(s *Snapshot) { s.Memory = m }
+	}},
+	{name: "memory_modules", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		m := o.host().memoryModules()
+		return func(s *Snapshot) { s.MemoryModules = m }
+	}},
+	{name: "network", weight: 1, hashed: true, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		n, ex := excludeInterfaces(o.host().netIfaces(), o.ifExclude)
+		return func(s *Snapshot) { s.Network, s.NetworkExcluded = n, ex }
+	}},
+	{name: "network_config", weight: 1, dependent: true, run: func(_ context.Context, o *options, prev *Snapshot) func(*Snapshot) {
+		nc := o.host().netConfig(prev.Network)
+		return func(s *Snapshot) { s.NetConfig = nc }
+	}},
+	{name: "netns", weight: 1, enabled: func(o *options) bool { return o.netns },
+		run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			n := o.host().netNamespaces()
+			return func(s *Snapshot) { s.NetNamespaces = n }
+		}},
+	{name: "routing", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		r := o.host().routing()
+		return func(s *Snapshot) { s.Routing = r }
+	}},
+	{name: "neighbors", weight: 1, enabled: func(o *options) bool { return o.neighbors },
+		run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			n := o.host().neighbors()
+			return func(s *Snapshot) { s.Neighbors = n }
+		}},
+	{name: "dhcp", weight: 1, dependent: true, run: func(_ context.Context, o *options, prev *Snapshot) func(*Snapshot) {
+		l := o.host().dhcpLeases(prev.Network)
+		return func(s *Snapshot) { s.DHCP = l }
+	}},
+	{name: "ipv6", weight: 1, dependent: true, run: func(_ context.Context, o *options, prev *Snapshot) func(*Snapshot) {
+		v := o.host().ipv6(prev.Network)
+		return func(s *Snapshot) { s.IPv6 = v }
+	}},
+	{name: "rootfs", weight: 2, hashed: true, run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		h := o.host()
+		src, fstype := h.expect().rootfsFromMountinfo()
+		devnum := h.rootDevnum()
+		r := RootFSInfo{Source: src, Fstype: fstype, UUID: h.rootfsUUID(ctx, src)}
+		if r.UUID == "" && o.udevRootUUID {
+			r.UUID = h.udevProperties(devnum)["ID_FS_UUID"]
+		}
+		r.Device, r.LVUUID = h.rootDM(devnum)
+		return func(s *Snapshot) { s.RootFS = r }
+	}},
+	{name: "block_devices", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		b := o.host().blockDevices(o.partTables)
+		return func(s *Snapshot) { s.BlockDevices = b }
+	}},
+	{name: "nvme", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		n := o.host().nvmeControllers()
+		return func(s *Snapshot) { s.NVMe = n }
+	}},
+	{name: "lvm", weight: 1, run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		v := o.host().volumeGroups(ctx)
+		return func(s *Snapshot) { s.LVM = v }
+	}},
+	{name: "drive_identity", weight: 2, enabled: func(o *options) bool { return o.driveIdentity },
+		run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			d := o.host().driveIdentities(ctx)
+			return func(s *Snapshot) { s.Drives = d }
+		}},
+	{name: "storage_health", weight: 4, enabled: func(o *options) bool { return o.storageHealth },
+		run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			d := o.host().storageHealth(ctx)
+			return func(s *Snapshot) { s.Storage = d }
+		}},
+	{name: "docker", weight: 4, run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		d := DockerInfo{DaemonID: o.host().dockerID(ctx)}
+		return func(s *Snapshot) { s.Docker = d }
+	}},
+	{name: "firmware", weight: 2, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		fw := o.host().firmwareInfo()
+		return func(s *Snapshot) { s.Firmware = fw }
+	}},
+	{name: "boot", weight: 2, dependent: true, run: func(_ context.Context, o *options, prev *Snapshot) func(*Snapshot) {
+		b := o.host().bootInfo(prev.OS.KernelRel)
+		return func(s *Snapshot) { s.Boot = b }
+	}},
+	{name: "security", weight: 1, run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		sec := o.host().securityInfo()
+		return func(s *Snapshot) { s.Security = sec }
+	}},
+	{name: "cloud", weight: 4, enabled: func(o *options) bool { return o.cloudInit },
+		run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			c := o.host().cloudInitInfo(ctx)
+			return func(s *Snapshot) { s.Cloud = c }
+		}},
+	{name: "agent_runtime", weight: 1, run: func(context.Context, *options, *Snapshot) func(*Snapshot) {
+		a := agentInfo()
+		return func(s *Snapshot) { s.Agent = a }
+	}},
+	{name: "labels", weight: 1, enabled: func(o *options) bool { return o.labelsFile != "" || len(o.labels) > 0 },
+		run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			l := o.host().labels(o.labelsFile, o.labels)
+			return func(s *Snapshot) { s.Labels = l }
+		}},
+	{name: "meta", weight: 1, run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+		m := &Meta{Build: AgentBuild().Summary()}
+		if !o.noDocker {
+			m.Container = o.host().agentContainer(ctx)
+		}
+		if o.selfCheck != nil {
+			m.SelfCheck = runSelfCheck(o.selfCheck)
+		}
+		return func(s *Snapshot) { s.Meta = m }
+	}},
+	{name: "plugins", weight: 4, enabled: func(o *options) bool { return o.pluginDir != "" },
+		run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			c := o.host().plugins(ctx, o.pluginDir)
+			return func(s *Snapshot) { s.Custom = c }
+		}},
+	{name: "packages", weight: 2, enabled: func(o *options) bool { return o.large.packages },
+		run: func(ctx context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			p := slices.Collect(o.host().packages(ctx))
+			return func(s *Snapshot) { s.Packages = p }
+		}},
+	{name: "pci", weight: 1, enabled: func(o *options) bool { return o.large.pci },
+		run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			d := slices.Collect(o.host().pciDevices())
+			return func(s *Snapshot) { s.PCI = d }
+		}},
+	{name: "usb", weight: 1, enabled: func(o *options) bool { return o.large.usb },
+		run: func(_ context.Context, o *options, _ *Snapshot) func(*Snapshot) {
+			d := slices.Collect(o.host().usbDevices())
+			return func(s *Snapshot) { s.USB = d }
+		}},
+}
+
+// Skipped records a collector that did not contribute to the snapshot.
+type Skipped struct {
+	Collector string `json:"collector"`
+	Reason    string `json:"reason"`
+}
+
+// ProgressEvent tells whether a collector started or finished.
+type ProgressEvent int
+
+const (
+	CollectorStarted ProgressEvent = iota
+	CollectorFinished
+)
+
+// Progress reports a collector transition during CollectAsync.
+type Progress struct {
+	Event     ProgressEvent
+	Collector string
+	// Index counts from 1 to Total in collection order.
+	Index, Total int
+	// Elapsed and Skipped are set on CollectorFinished. A skipped
+	// collector did not contribute to the snapshot.
+	Elapsed time.Duration
+	Skipped bool
+}
+
+// CollectAsync collects a snapshot in the background and reports each
+// collector's start and finish to progress, e.g. to drive a progress bar.
+// progress is called from the collecting goroutine and may be nil. When ctx
+// is cancelled the remaining collectors are skipped. The returned channel
+// yields the snapshot once and is then closed.
+func CollectAsync(ctx context.Context, progress func(Progress), opts ...Option) <-chan Snapshot {
+	o := buildOptions(opts)
+	ch := make(chan Snapshot, 1)
+	go func() {
+		defer close(ch)
+		ch <- collect(ctx, o, progress)
+	}()
+	return ch
+}
+
+// collect runs the enabled collectors. Without a budget they run inline.
+// With one, every step gets a slice of the remaining time proportional to
+// its weight; a step overrunning its slice is abandoned and its result
+// discarded, and steps are skipped outright once the budget is spent.
+// Abandoned steps keep running in the background until their own I/O
+// returns, but they observe the cancelled context where they can.
+func collect(ctx context.Context, o options, progress func(Progress)) Snapshot {
+	var ready *Readiness
+	if o.waitReady > 0 {
+		ready = o.host().waitReady(ctx, o.waitReady)
+	}
+	snap := collectSome(ctx, o, nil, Snapshot{}, progress)
+	if ready != nil {
+		if snap.Meta == nil {
+			snap.Meta = &Meta{}
+		}
+		snap.Meta.Readiness = ready
+	}
+	snap.opts = &o
+	return snap
+}
+
+// collectSome runs the enabled collectors in names, or all of them when
+// names is nil, starting from seed, which stands in for the results of
+// collectors that do not run.
+func collectSome(ctx context.Context, o options, names map[string]bool, seed Snapshot, progress func(Progress)) Snapshot {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	active := activeCollectors(&o)
+	if names != nil {
+		active = slices.DeleteFunc(active, func(c Collector) bool { return !names[c.Name()] })
+	}
+	if progress == nil {
+		progress = func(Progress) {}
+	}
+	env := &Env{opts: &o}
+	snap := seed
+	snap.SchemaVersion = SchemaVersion
+	var skipped []Skipped
+	start := time.Now()
+	// finished stores the result of active[i] in snap.
+	finished := func(i int, apply func(*Snapshot), err error, p Progress) {
+		c := active[i]
+		if err != nil {
+			skipped = append(skipped, Skipped{c.Name(), err.Error()})
+			snap.Errors = append(snap.Errors, CollectorError{Collector: c.Name(), Kind: errorKind(err), Error: err.Error()})
+			o.host().debug("collector skipped", "collector", c.Name(), "elapsed", p.Elapsed, "err", err)
+		} else {
+			apply(&snap)
+			o.host().debug("collector finished", "collector", c.Name(), "elapsed", p.Elapsed)
+		}
+	}
+	order := make([]int, len(active))
+	for i := range order {
+		order[i] = i
+	}
+	if o.observe != nil {
+		order = collectConcurrently(ctx, env, active, start, progress, finished)
+	}
+	for k, i := range order {
+		c := active[i]
+		rest := make([]Collector, 0, len(order)-k)
+		for _, j := range order[k:] {
+			rest = append(rest, active[j])
+		}
+		p := Progress{Event: CollectorStarted, Collector: c.Name(), Index: i + 1, Total: len(active)}
+		progress(p)
+		began := time.Now()
+		apply, err := runBounded(ctx, env, c, snap, rest, start)
+		p.Event, p.Elapsed, p.Skipped = CollectorFinished, time.Since(began), err != nil
+		progress(p)
+		if err == nil && o.observe != nil {
+			var part Snapshot
+			apply(&part)
+			o.observe(c.Name(), &part)
+		}
+		finished(i, apply, err, p)
+	}
+	snap.Confidence = o.host().fingerprintConfidence(snap)
+	truncated := o.host().applyLimits(&snap, o.limits)
+	if len(skipped) > 0 || len(truncated) > 0 {
+		if snap.Meta == nil {
+			snap.Meta = &Meta{}
+		}
+		snap.Meta.Skipped = skipped
+		snap.Meta.Truncated = truncated
+	}
+	if o.provenance {
+		if snap.Provenance == nil {
+			snap.Provenance = &Provenance{}
+		}
+		snap.Provenance.Fields = fieldProvenance(snap)
+	}
+	return snap
+}
+
+// collectConcurrently runs the independent collectors of active, the
+// built-in ones that do not read prev, each on its own goroutine with the
+// whole remaining budget, observes each as it finishes and then passes the
+// results to finished in order. It returns the indexes of the collectors
+// left to run one after another; they see the results of all independent
+// ones in prev.
+func collectConcurrently(ctx context.Context, env *Env, active []Collector, start time.Time, progress func(Progress), finished func(int, func(*Snapshot), error, Progress)) (later []int) {
+	var now []int
+	for i, c := range active {
+		if b, ok := c.(builtin); ok && !b.dependent {
+			now = append(now, i)
+		} else {
+			later = append(later, i)
+		}
+	}
+	type result struct {
+		i     int
+		apply func(*Snapshot)
+		err   error
+		p     Progress
+	}
+	results := make(chan result)
+	for _, i := range now {
+		c := active[i]
+		p := Progress{Event: CollectorStarted, Collector: c.Name(), Index: i + 1, Total: len(active)}
+		progress(p)
+		go func() {
+			began := time.Now()
+			apply, err := runBounded(ctx, env, c, Snapshot{}, active[i:i+1], start)
+			p.Event, p.Elapsed, p.Skipped = CollectorFinished, time.Since(began), err != nil
+			results <- result{i, apply, err, p}
+		}()
+	}
+	done := make(map[int]result, len(now))
+	for range now {
+		r := <-results
+		progress(r.p)
+		if r.err == nil {
+			var part Snapshot
+			r.apply(&part)
+			env.opts.observe(active[r.i].Name(), &part)
+		}
+		done[r.i] = r
+	}
+	for _, i := range now {
+		r := done[i]
+		finished(i, r.apply, r.err, r.p)
+	}
+	return later
+}
+
+// hashed reports whether c collects fields of Snapshot.Components.
+func hashed(c Collector) bool {
+	b, ok := c.(builtin)
+	return ok && b.hashed
+}
+
+// runBounded runs c on prev within the budget and its timeout and returns
+// its result, or why it was skipped. rest are c and the collectors after
+// it, sharing the remaining budget.
+func runBounded(ctx context.Context, env *Env, c Collector, prev Snapshot, rest []Collector, start time.Time) (func(*Snapshot), error) {
+	if ctx.Err() != nil {
+		return nil, &skipError{"cancelled", ctx.Err()}
+	}
+	o := env.opts
+	limit := o.timeouts.Collectors[c.Name()]
+	budget := o.budget
+	if hashed(c) {
+		budget = 0
+	}
+	if budget <= 0 && limit <= 0 && ctx.Done() == nil {
+		return safeCollect(ctx, env, c, &prev)
+	}
+	var slice time.Duration
+	if budget > 0 {
+		remaining := budget - time.Since(start)
+		if remaining <= 0 {
+			return nil, &skipError{"budget exhausted", context.DeadlineExceeded}
+		}
+		weights := 0
+		for _, r := range rest {
+			if !hashed(r) {
+				weights += weight(r)
+			}
+		}
+		slice = remaining * time.Duration(weight(c)) / time.Duration(weights)
+	}
+	bound, what := slice, "time slice"
+	if limit > 0 && (bound <= 0 || limit < bound) {
+		bound, what = limit, "timeout"
+	}
+	if bound > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bound)
+		defer cancel()
+	}
+	type result struct {
+		apply func(*Snapshot)
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		apply, err := safeCollect(ctx, env, c, &prev)
+		done <- result{apply, err}
+	}()
+	select {
+	case r := <-done:
+		return r.apply, r.err
+	case <-ctx.Done():
+		if bound > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &skipError{fmt.Sprintf("exceeded %s %s", bound.Round(time.Microsecond), what), ctx.Err()}
+		}
+		return nil, &skipError{"cancelled", ctx.Err()}
+	}
+}
+
+// safeCollect runs c, turning a panic in a third-party collector into an
+// error so it cannot take down the embedding program.
+func safeCollect(ctx context.Context, env *Env, c Collector, prev *Snapshot) (apply func(*Snapshot), err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			apply, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	apply, err = c.Collect(ctx, env, prev)
+	if err == nil && apply == nil {
+		apply = func(*Snapshot) {}
+	}
+	return apply, err
+}
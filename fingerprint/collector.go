@@ -0,0 +1,187 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Collector gathers one part of a snapshot. The built-in collectors cover
+// the standard sections; third parties can add their own with Register and
+// store results in Snapshot.Extensions.
+type Collector interface {
+	// Name identifies the collector, e.g. "dmi".
+	Name() string
+	// Collect reads the system. prev holds what the collectors before it
+	// stored and must not be modified. The returned function stores the
+	// result; it is not called when the collector is abandoned, so a
+	// collector overrunning its budget never touches the snapshot.
+	Collect(ctx context.Context, env *Env, prev *Snapshot) (apply func(*Snapshot), err error)
+}
+
+// Weighted is implemented by collectors that need more than an equal share
+// of a WithBudget budget, such as those running external commands.
+type Weighted interface {
+	Weight() int
+}
+
+// Env is the environment a collection runs in, shared by all collectors.
+type Env struct {
+	opts *options
+}
+
+// Path maps an absolute system path to the file a collector should open,
+// honoring WithRootPrefix. Collectors that should also honor WithFS read
+// through FS instead.
+func (e *Env) Path(p string) string { return e.opts.host().path(p) }
+
+// FS returns the system's files with its root as the file system root:
+// the WithFS file system, or the root directory on the OS.
+func (e *Env) FS() fs.FS {
+	if e.opts.fsys != nil {
+		return e.opts.fsys
+	}
+	return os.DirFS(e.opts.host().path("/"))
+}
+
+// Logger returns the WithLogger logger, or one discarding all records.
+// Collectors should log the files, commands and sockets they use at debug
+// level.
+func (e *Env) Logger() *slog.Logger {
+	if e.opts.logger != nil {
+		return e.opts.logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// NewCollector adapts fn to the Collector interface.
+func NewCollector(name string, fn func(ctx context.Context, env *Env, prev *Snapshot) (func(*Snapshot), error)) Collector {
+	return funcCollector{name, fn}
+}
+
+type funcCollector struct {
+	name string
+	fn   func(context.Context, *Env, *Snapshot) (func(*Snapshot), error)
+}
+
+func (f funcCollector) Name() string { return f.name }
+
+func (f funcCollector) Collect(ctx context.Context, env *Env, prev *Snapshot) (func(*Snapshot), error) {
+	return f.fn(ctx, env, prev)
+}
+
+var registry struct {
+	sync.RWMutex
+	list []Collector
+}
+
+func init() {
+	for _, c := range builtins {
+		registry.list = append(registry.list, c)
+	}
+}
+
+// Register adds c to the collectors every snapshot runs, after the
+// built-in ones. It panics if a collector of the same name exists; use
+// Replace to swap out a built-in.
+func Register(c Collector) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, r := range registry.list {
+		if r.Name() == c.Name() {
+			panic(fmt.Sprintf("fingerprint: collector %q registered twice", c.Name()))
+		}
+	}
+	registry.list = append(registry.list, c)
+}
+
+// Replace substitutes c for the registered collector of the same name,
+// keeping its position. It panics if there is none.
+func Replace(c Collector) {
+	registry.Lock()
+	defer registry.Unlock()
+	for i, r := range registry.list {
+		if r.Name() == c.Name() {
+			registry.list[i] = c
+			return
+		}
+	}
+	panic(fmt.Sprintf("fingerprint: no collector %q to replace", c.Name()))
+}
+
+// WithoutCollectors disables the named collectors for one collection.
+// Collectors named after a renamed field are found by the old name too.
+func WithoutCollectors(names ...string) Option {
+	return func(o *options) {
+		if o.disabled == nil {
+			o.disabled = map[string]bool{}
+		}
+		for _, n := range names {
+			o.disabled[collectorName(n)] = true
+		}
+	}
+}
+
+// WithCollector runs c in one collection, replacing the registered
+// collector of the same name or running after all others.
+func WithCollector(c Collector) Option {
+	return func(o *options) { o.extra = append(o.extra, c) }
+}
+
+// WithCollectors restricts one collection to the named collectors, in
+// registry order. Opt-in collectors named here run without their own
+// option.
+func WithCollectors(names ...string) Option {
+	return func(o *options) {
+		o.only = map[string]bool{}
+		for _, n := range names {
+			o.only[collectorName(n)] = true
+		}
+	}
+}
+
+// activeCollectors resolves the registry against o.
+func activeCollectors(o *options) []Collector {
+	registry.RLock()
+	list := append([]Collector(nil), registry.list...)
+	registry.RUnlock()
+	for _, c := range o.extra {
+		i := 0
+		for ; i < len(list) && list[i].Name() != c.Name(); i++ {
+		}
+		if i < len(list) {
+			list[i] = c
+		} else {
+			list = append(list, c)
+		}
+	}
+	var out []Collector
+	for _, c := range list {
+		if o.disabled[c.Name()] || (o.only != nil && !o.only[c.Name()]) {
+			continue
+		}
+		if b, ok := c.(builtin); ok && b.enabled != nil && !b.enabled(o) && !o.only[c.Name()] {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func weight(c Collector) int {
+	if w, ok := c.(Weighted); ok && w.Weight() > 0 {
+		return w.Weight()
+	}
+	return 1
+}
+
+// SetExtension stores the result of a third-party collector under name.
+func (s *Snapshot) SetExtension(name string, v any) {
+	if s.Extensions == nil {
+		s.Extensions = map[string]any{}
+	}
+	s.Extensions[name] = v
+}
@@ -0,0 +1,118 @@
+package fingerprint
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// Confidence rates how strongly the fingerprint is rooted in hardware.
+// License servers can apply stricter policies to low-confidence machines,
+// such as VMs without a TPM whose identifiers are all software-assigned.
+type Confidence struct {
+	// Score is between 0 and 1.
+	Score float64 `json:"score"`
+	Level string  `json:"level"`
+	// Sources lists the contributing hardware-rooted components.
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Confidence levels.
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// confidenceWeights are the contributions of independent hardware roots.
+var confidenceWeights = map[string]float64{
+	"tpm":           0.35,
+	"dmi_uuid":      0.25,
+	"disk_serial":   0.2,
+	"permanent_mac": 0.2,
+}
+
+func (h host) fingerprintConfidence(s Snapshot) *Confidence {
+	c := &Confidence{}
+	add := func(src string, ok bool) {
+		if ok {
+			c.Sources = append(c.Sources, src)
+			c.Score += confidenceWeights[src]
+		}
+	}
+	add("tpm", h.readable("/sys/class/tpm/tpm0"))
+	add("dmi_uuid", s.DMI.ProductUUID != "" && !IsPlaceholder(s.DMI.ProductUUID))
+	add("disk_serial", h.diskSerial(s.RootFS.Source) != "")
+	add("permanent_mac", h.hasPermanentMAC(s.Network))
+	// Round away float noise from the sum of weights.
+	c.Score = float64(int(c.Score*100+0.5)) / 100
+	switch {
+	case c.Score >= 0.75:
+		c.Level = ConfidenceHigh
+	case c.Score >= 0.45:
+		c.Level = ConfidenceMedium
+	default:
+		c.Level = ConfidenceLow
+	}
+	return c
+}
+
+// hasPermanentMAC reports whether a physical NIC carries its burned-in,
+// globally administered address.
+func (h host) hasPermanentMAC(ifs []NetIf) bool {
+	for _, n := range ifs {
+		if !h.isPhysicalNIC(n.Name) {
+			continue
+		}
+		if h.readTrim(filepath.Join("/sys/class/net", n.Name, "addr_assign_type")) != "0" {
+			continue
+		}
+		if hw, err := net.ParseMAC(n.MAC); err == nil && len(hw) > 0 && hw[0]&0x02 == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// diskSerial returns the serial number of the disk backing dev, following
+// partitions to their disk and device-mapper volumes to their first slave.
+func (h host) diskSerial(dev string) string {
+	if dev == "" {
+		return ""
+	}
+	if r := h.resolveLink(dev); r != "" {
+		dev = r
+	}
+	name := filepath.Base(dev)
+	for range 4 {
+		sys := filepath.Join("/sys/class/block", name)
+		if slaves, err := h.readDir(filepath.Join(sys, "slaves")); err == nil && len(slaves) > 0 {
+			name = slaves[0].Name()
+			continue
+		}
+		if h.readable(filepath.Join(sys, "partition")) {
+			name = filepath.Base(filepath.Dir(h.resolveLink(sys)))
+			continue
+		}
+		break
+	}
+	for _, p := range []string{"serial", "device/serial"} {
+		if v := h.readTrim(filepath.Join("/sys/block", name, p)); v != "" && !IsPlaceholder(v) {
+			return v
+		}
+	}
+	// SATA disks expose the serial only through udev's by-id names.
+	entries, _ := h.readDir("/dev/disk/by-id")
+	for _, e := range entries {
+		id := e.Name()
+		if !strings.HasPrefix(id, "ata-") || strings.Contains(id, "-part") {
+			continue
+		}
+		if filepath.Base(h.resolveLink(filepath.Join("/dev/disk/by-id", id))) == name {
+			if i := strings.LastIndexByte(id, '_'); i > 0 {
+				return id[i+1:]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package fingerprint
+
+import (
+	"slices"
+	"strings"
+)
+
+// CPUCapabilities summarizes the CPU features schedulers look for when
+// placing cryptographic and confidential workloads. Hypervisors commonly
+// hide virtualization and enclave features from their guests, so a guest
+// reporting none of them may still run on hardware that has them.
+type CPUCapabilities struct {
+	// AESNI is the AES instruction set, "aes" on both x86 and Arm.
+	AESNI  bool `json:"aes_ni"`
+	AVX2   bool `json:"avx2"`
+	AVX512 bool `json:"avx512"`
+	// Virtualization is "VT-x" or "AMD-V" when the CPU exposes hardware
+	// virtualization.
+	Virtualization string `json:"virtualization,omitempty"`
+	// SEV, SEVES and SEVSNP report AMD Secure Encrypted Virtualization
+	// and its encrypted-state and secure-nested-paging extensions;
+	// SEVEnabled that kvm_amd has enabled it for guests.
+	SEV        bool `json:"sev"`
+	SEVES      bool `json:"sev_es"`
+	SEVSNP     bool `json:"sev_snp"`
+	SEVEnabled bool `json:"sev_enabled"`
+	// TDX reports an Intel Trust Domain Extensions host.
+	TDX bool `json:"tdx"`
+	// SGX reports Intel Software Guard Extensions, SGXDevice the enclave
+	// device of the in-kernel or out-of-tree driver that makes them
+	// usable.
+	SGX       bool `json:"sgx"`
+	SGXDevice bool `json:"sgx_device"`
+	// ConfidentialGuest is "sev-snp" or "tdx" when the system itself runs
+	// as a confidential VM.
+	ConfidentialGuest string `json:"confidential_guest,omitempty"`
+}
+
+// sgxDevices are the enclave devices of the in-kernel driver (5.11+) and
+// of Intel's older out-of-tree drivers.
+var sgxDevices = []string{"/dev/sgx_enclave", "/dev/sgx/enclave", "/dev/isgx"}
+
+func (h host) cpuCapabilities(flags []string) *CPUCapabilities {
+	has := func(f string) bool { return slices.Contains(flags, f) }
+	c := &CPUCapabilities{
+		AESNI:  has("aes"),
+		AVX2:   has("avx2"),
+		AVX512: has("avx512f"),
+		SEV:    has("sev"),
+		SEVES:  has("sev_es"),
+		SEVSNP: has("sev_snp"),
+		TDX:    has("tdx_host_platform") || kernelParamOn(h.readTrim("/sys/module/kvm_intel/parameters/tdx")),
+		SGX:    has("sgx"),
+	}
+	switch {
+	case has("vmx"):
+		c.Virtualization = "VT-x"
+	case has("svm"):
+		c.Virtualization = "AMD-V"
+	}
+	c.SEVEnabled = kernelParamOn(h.readTrim("/sys/module/kvm_amd/parameters/sev"))
+	c.SGXDevice = slices.ContainsFunc(sgxDevices, h.readable)
+	switch {
+	case has("tdx_guest") || h.readable("/dev/tdx_guest"):
+		c.ConfidentialGuest = "tdx"
+	case h.readable("/dev/sev-guest"):
+		c.ConfidentialGuest = "sev-snp"
+	}
+	if *c == (CPUCapabilities{}) {
+		return nil
+	}
+	return c
+}
+
+// kernelParamOn reads a boolean module parameter, which kernels print as
+// "Y"/"N" or "1"/"0" depending on its type.
+func kernelParamOn(v string) bool {
+	return strings.EqualFold(v, "Y") || v == "1"
+}
@@ -0,0 +1,178 @@
+package fingerprint
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DHCPLease is the most recent DHCPv4 lease of an interface found in the
+// dhclient, systemd-networkd or NetworkManager lease files.
+type DHCPLease struct {
+	Interface string `json:"interface,omitempty"`
+	Source    string `json:"source"`
+	// ClientID is the DHCP client identifier (option 61) as colon hex.
+	ClientID string `json:"client_id,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Server   string `json:"server,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	// ClientIDMAC is the hardware address embedded in an Ethernet
+	// (type 1) client identifier.
+	ClientIDMAC string `json:"client_id_mac,omitempty"`
+	// MACMismatch flags a client identifier carrying another MAC than the
+	// interface's, e.g. an identifier cloned with a disk image.
+	MACMismatch bool `json:"mac_mismatch,omitempty"`
+}
+
+// dhcpLeases reads the lease files of all known clients and reconciles the
+// client identifiers with the live interfaces.
+func (h host) dhcpLeases(live []NetIf) []DHCPLease {
+	var out []DHCPLease
+	for _, pattern := range []string{
+		"/var/lib/dhcp/dhclient*.leases",
+		"/var/lib/dhclient/*.lease*",
+		"/var/lib/NetworkManager/dhclient-*.lease",
+	} {
+		for _, f := range h.glob(pattern) {
+			out = append(out, h.dhclientLeases(f)...)
+		}
+	}
+	byIndex := map[string]string{}
+	if entries, err := h.readDir("/sys/class/net"); err == nil {
+		for _, e := range entries {
+			byIndex[h.readTrim(filepath.Join("/sys/class/net", e.Name(), "ifindex"))] = e.Name()
+		}
+	}
+	for _, f := range h.glob("/run/systemd/netif/leases/*") {
+		if l, ok := h.sdLease(f); ok {
+			l.Interface = byIndex[filepath.Base(f)]
+			out = append(out, l)
+		}
+	}
+	for _, f := range h.glob("/var/lib/NetworkManager/internal-*.lease") {
+		if l, ok := h.sdLease(f); ok {
+			// internal-<connection uuid>-<interface>.lease
+			name := strings.TrimSuffix(filepath.Base(f), ".lease")
+			if i := strings.LastIndexByte(name, '-'); i > 0 {
+				l.Interface = name[i+1:]
+			}
+			out = append(out, l)
+		}
+	}
+	macs := map[string]string{}
+	for _, n := range live {
+		macs[n.Name] = strings.ToLower(n.MAC)
+	}
+	for i := range out {
+		l := &out[i]
+		l.ClientIDMAC = clientIDMAC(l.ClientID)
+		if mac, ok := macs[l.Interface]; ok && l.ClientIDMAC != "" {
+			l.MACMismatch = mac != l.ClientIDMAC
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Interface < out[j].Interface })
+	return out
+}
+
+// dhclientLeases parses an ISC dhclient lease file, keeping the last lease
+// of every interface.
+func (h host) dhclientLeases(path string) []DHCPLease {
+	b, err := h.readFile(path)
+	if err != nil {
+		return nil
+	}
+	var out []DHCPLease
+	index := map[string]int{}
+	var cur *DHCPLease
+	for _, ln := range strings.Split(string(b), "\n") {
+		ln = strings.TrimSpace(ln)
+		switch {
+		case strings.HasPrefix(ln, "lease {"):
+			cur = &DHCPLease{Source: path}
+			continue
+		case ln == "}" && cur != nil:
+			if i, ok := index[cur.Interface]; ok {
+				out[i] = *cur
+			} else {
+				index[cur.Interface] = len(out)
+				out = append(out, *cur)
+			}
+			cur = nil
+			continue
+		case cur == nil:
+			continue
+		}
+		ln = strings.TrimSuffix(ln, ";")
+		key, val, _ := strings.Cut(ln, " ")
+		if key == "option" {
+			key, val, _ = strings.Cut(val, " ")
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "interface":
+			cur.Interface = strings.Trim(val, `"`)
+		case "fixed-address":
+			cur.Address = val
+		case "dhcp-server-identifier":
+			cur.Server = val
+		case "dhcp-client-identifier":
+			cur.ClientID = dhclientID(val)
+		case "expire":
+			// "expire 4 2024/01/04 12:00:00" with a weekday first.
+			if _, t, ok := strings.Cut(val, " "); ok {
+				cur.Expires = t
+			}
+		}
+	}
+	return out
+}
+
+// sdLease parses a systemd-networkd lease, also written by NetworkManager's
+// internal client.
+func (h host) sdLease(path string) (DHCPLease, bool) {
+	kv := h.readShellVars(path)
+	if kv["ADDRESS"] == "" && kv["CLIENTID"] == "" {
+		return DHCPLease{}, false
+	}
+	l := DHCPLease{Source: path, Address: kv["ADDRESS"], Server: kv["SERVER_ADDRESS"]}
+	if b, err := hex.DecodeString(kv["CLIENTID"]); err == nil {
+		l.ClientID = colonHex(b)
+	}
+	return l, true
+}
+
+// dhclientID normalizes dhclient's "1:52:54:0:12:34:56" or quoted string
+// client identifiers to two-digit colon hex.
+func dhclientID(v string) string {
+	if s, err := strconv.Unquote(v); err == nil {
+		return colonHex([]byte(s))
+	}
+	var b []byte
+	for _, p := range strings.Split(v, ":") {
+		n, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return v
+		}
+		b = append(b, byte(n))
+	}
+	return colonHex(b)
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = hex.EncodeToString([]byte{c})
+	}
+	return strings.Join(parts, ":")
+}
+
+// clientIDMAC returns the MAC of an Ethernet client identifier: type 1
+// followed by six address bytes.
+func clientIDMAC(id string) string {
+	if len(id) == 20 && strings.HasPrefix(id, "01:") {
+		return id[3:]
+	}
+	return ""
+}
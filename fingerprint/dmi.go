@@ -0,0 +1,162 @@
+package fingerprint
+
+import (
+	"strconv"
+
+	"AurFingerprintAgent/smbios"
+)
+
+// chassisTypes are the SMBIOS 3.x system enclosure types by number.
+var chassisTypes = []string{
+	1: "Other", 2: "Unknown", 3: "Desktop", 4: "Low Profile Desktop", 5: "Pizza Box",
+	6: "Mini Tower", 7: "Tower", 8: "Portable", 9: "Laptop", 10: "Notebook",
+	11: "Hand Held", 12: "Docking Station", 13: "All in One", 14: "Sub Notebook",
+	15: "Space-saving", 16: "Lunch Box", 17: "Main Server Chassis", 18: "Expansion Chassis",
+	19: "SubChassis", 20: "Bus Expansion Chassis", 21: "Peripheral Chassis", 22: "RAID Chassis",
+	23: "Rack Mount Chassis", 24: "Sealed-case PC", 25: "Multi-system chassis",
+	26: "Compact PCI", 27: "Advanced TCA", 28: "Blade", 29: "Blade Enclosure", 30: "Tablet",
+	31: "Convertible", 32: "Detachable", 33: "IoT Gateway", 34: "Embedded PC", 35: "Mini PC",
+	36: "Stick PC",
+}
+
+// chassisTypeName names an SMBIOS chassis type; the number is kept when it
+// is not in the table.
+func chassisTypeName(v string) string {
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n >= len(chassisTypes) {
+		return v
+	}
+	return chassisTypes[n]
+}
+
+// dmiDescription fills the descriptive DMI fields.
+func (h host) dmiDescription(d *DMIInfo) {
+	const dir = "/sys/class/dmi/id/"
+	for _, f := range []struct {
+		name string
+		v    *string
+	}{
+		{"sys_vendor", &d.SysVendor},
+		{"product_name", &d.ProductName},
+		{"product_serial", &d.ProductSerial},
+		{"product_family", &d.ProductFamily},
+		{"board_name", &d.BoardName},
+		{"board_vendor", &d.BoardVendor},
+		{"bios_vendor", &d.BIOSVendor},
+		{"bios_version", &d.BIOSVersion},
+		{"bios_date", &d.BIOSDate},
+	} {
+		*f.v = h.readTrim(dir + f.name)
+	}
+	d.ChassisType = chassisTypeName(h.readTrim(dir + "chassis_type"))
+}
+
+// smbiosTable reads the raw SMBIOS table; nil when it is unavailable.
+func (h host) smbiosTable() *smbios.Table {
+	entry, err := h.readFile(smbios.DefaultDir + "/smbios_entry_point")
+	if err != nil {
+		return nil
+	}
+	raw, err := h.readFile(smbios.DefaultDir + "/DMI")
+	if err != nil {
+		return nil
+	}
+	// A truncated table still yields the structures before the damage.
+	t, err := smbios.Parse(entry, raw)
+	h.note(smbios.DefaultDir+"/DMI", err)
+	return t
+}
+
+// dmiTables takes the OEM strings from the raw SMBIOS table and fills in
+// the descriptive fields /sys/class/dmi/id lacks, e.g. with kernels built
+// without CONFIG_DMIID or sysfs files masked in containers. The hashed
+// fields are left alone: filling them in would change the hash of a
+// machine whenever the table became readable or unreadable.
+func (h host) dmiTables(d *DMIInfo) {
+	t := h.smbiosTable()
+	if t == nil {
+		return
+	}
+	fill := func(name string, v *string, from string) {
+		if *v == "" && from != "" {
+			*v = from
+			d.FromSMBIOS = append(d.FromSMBIOS, name)
+		}
+	}
+	if sys := t.System(); sys != nil {
+		fill("sys_vendor", &d.SysVendor, sys.Manufacturer)
+		fill("product_name", &d.ProductName, sys.ProductName)
+		fill("product_serial", &d.ProductSerial, sys.SerialNumber)
+		fill("product_family", &d.ProductFamily, sys.Family)
+	}
+	if b := t.Baseboards(); len(b) > 0 {
+		fill("board_name", &d.BoardName, b[0].Product)
+		fill("board_vendor", &d.BoardVendor, b[0].Manufacturer)
+	}
+	if c := t.Chassis(); len(c) > 0 {
+		if c[0].Type != 0 {
+			fill("chassis_type", &d.ChassisType, chassisTypeName(strconv.Itoa(c[0].Type)))
+		}
+	}
+	if b := t.BIOS(); b != nil {
+		fill("bios_vendor", &d.BIOSVendor, b.Vendor)
+		fill("bios_version", &d.BIOSVersion, b.Version)
+		fill("bios_date", &d.BIOSDate, b.ReleaseDate)
+	}
+	d.OEMStrings = t.OEMStrings()
+}
+
+// memoryModules lists the populated SMBIOS memory devices (type 17).
+func (h host) memoryModules() []MemoryModule {
+	t := h.smbiosTable()
+	if t == nil {
+		return nil
+	}
+	var out []MemoryModule
+	for _, m := range t.MemoryDevices() {
+		out = append(out, MemoryModule{
+			Slot:         identifying(m.Locator),
+			Bank:         identifying(m.BankLocator),
+			SizeMB:       m.SizeMB,
+			Type:         m.Type,
+			SpeedMTs:     m.SpeedMTs,
+			Manufacturer: identifying(m.Manufacturer),
+			Serial:       identifying(m.SerialNumber),
+			PartNumber:   identifying(m.PartNumber),
+			AssetTag:     identifying(m.AssetTag),
+		})
+	}
+	return out
+}
+
+// cpuPackages lists the processor sockets of the SMBIOS table (type 4).
+func (h host) cpuPackages() []CPUPackage {
+	t := h.smbiosTable()
+	if t == nil {
+		return nil
+	}
+	var out []CPUPackage
+	for _, p := range t.Processors() {
+		out = append(out, CPUPackage{
+			Socket:       identifying(p.Socket),
+			Populated:    p.Populated,
+			Manufacturer: identifying(p.Manufacturer),
+			Version:      identifying(p.Version),
+			MaxSpeedMHz:  p.MaxSpeedMHz,
+			Cores:        p.Cores,
+			Threads:      p.Threads,
+			Serial:       identifying(p.SerialNumber),
+			PartNumber:   identifying(p.PartNumber),
+		})
+	}
+	return out
+}
+
+// identifying drops the placeholders firmware fills memory device strings
+// with, such as "Unknown" or "00000000".
+func identifying(v string) string {
+	if IsPlaceholder(v) {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,168 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"AurFingerprintAgent/smart"
+)
+
+// DriveIdentity identifies a SCSI or ATA disk, including those behind
+// USB-SATA bridges, whose sysfs attributes describe the bridge rather than
+// the drive. NVMe drives are listed in Snapshot.NVMe.
+type DriveIdentity struct {
+	Name string `json:"name"`
+	// Transport is "ata", "usb" or "scsi", from the device's sysfs path.
+	Transport string `json:"transport,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Serial    string `json:"serial,omitempty"`
+	Firmware  string `json:"firmware,omitempty"`
+	// WWN is the World Wide Name as 16 or, for NAA 6 names, 32 hex digits.
+	WWN string `json:"wwn,omitempty"`
+	// Source is where the serial came from: "sysfs", "ata" (IDENTIFY
+	// DEVICE through SG_IO) or "smartctl".
+	Source string `json:"source,omitempty"`
+}
+
+// driveIdentities identifies every sd* disk from sysfs and, when sysfs
+// lacks the serial or the disk sits behind a USB bridge, whose vpd_pg80
+// may carry the bridge's own serial, from ATA IDENTIFY DEVICE, falling
+// back to smartctl, which knows the pass-through quirks of more bridges.
+// The queries need read access to the raw devices; disks that cannot be
+// queried keep what sysfs offers.
+func (h host) driveIdentities(ctx context.Context) []DriveIdentity {
+	entries, err := h.readDir("/sys/block")
+	if err != nil {
+		return nil
+	}
+	var out []DriveIdentity
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "sd") || ctx.Err() != nil {
+			continue
+		}
+		sys := path.Join("/sys/block", name, "device")
+		vpd, _ := h.readFile(path.Join(sys, "vpd_pg80"))
+		model := h.readTrim(path.Join(sys, "model"))
+		// libata reports "ATA" as the vendor of every disk.
+		if v := h.readTrim(path.Join(sys, "vendor")); v != "" && v != "ATA" {
+			model = v + " " + model
+		}
+		d := DriveIdentity{
+			Name:      name,
+			Transport: driveTransport(h.resolveLink(sys)),
+			Model:     model,
+			Serial:    vpdSerial(vpd),
+			Firmware:  h.readTrim(path.Join(sys, "rev")),
+			WWN:       strings.TrimPrefix(h.readTrim(path.Join(sys, "wwid")), "naa."),
+		}
+		if !isWWN(d.WWN) {
+			d.WWN = ""
+		}
+		if d.Serial != "" {
+			d.Source = "sysfs"
+		}
+		if (d.Serial == "" || d.Transport == "usb") && h.live() {
+			dev := h.path("/dev/" + name)
+			if id := h.ataIdentity(dev); id != nil {
+				d.adopt(id, "ata")
+			} else if id := h.smartctlIdentity(ctx, dev); id != nil {
+				d.adopt(id, "smartctl")
+			}
+		}
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// adopt takes the fields the drive reported over those of sysfs, which
+// may describe a bridge.
+func (d *DriveIdentity) adopt(id *smart.Identity, source string) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&d.Model, id.Model)
+	set(&d.Serial, id.Serial)
+	set(&d.Firmware, id.Firmware)
+	set(&d.WWN, id.WWN)
+	d.Source = source
+}
+
+func (h host) ataIdentity(dev string) *smart.Identity {
+	id, err := smart.ReadATAIdentity(dev)
+	h.debug("ioctl", "device", dev, "command", "ata identify", "err", err)
+	if err != nil {
+		h.notePermission(h.rel(dev), err)
+		return nil
+	}
+	h.source("ioctl", dev)
+	return id
+}
+
+// smartctlIdentity runs smartctl -i, whose exit status flags problems
+// unrelated to identification and is ignored when the output decodes.
+func (h host) smartctlIdentity(ctx context.Context, dev string) *smart.Identity {
+	out, _ := h.output(ctx, "smartctl", "-i", "-j", dev)
+	var v struct {
+		Model    string `json:"model_name"`
+		Serial   string `json:"serial_number"`
+		Firmware string `json:"firmware_version"`
+		WWN      *struct {
+			NAA uint64 `json:"naa"`
+			OUI uint64 `json:"oui"`
+			ID  uint64 `json:"id"`
+		} `json:"wwn"`
+	}
+	if json.Unmarshal(out, &v) != nil || v.Serial == "" {
+		return nil
+	}
+	id := &smart.Identity{Model: v.Model, Serial: v.Serial, Firmware: v.Firmware}
+	if w := v.WWN; w != nil && w.NAA != 0 {
+		id.WWN = fmt.Sprintf("%016x", w.NAA<<60|w.OUI<<36|w.ID)
+	}
+	return id
+}
+
+// driveTransport classifies a resolved /sys/block/*/device path.
+func driveTransport(dev string) string {
+	switch {
+	case dev == "":
+		return ""
+	case strings.Contains(dev, "/usb"):
+		return "usb"
+	case strings.Contains(dev, "/ata"):
+		return "ata"
+	}
+	return "scsi"
+}
+
+// vpdSerial decodes the Unit Serial Number VPD page (0x80).
+func vpdSerial(b []byte) string {
+	if len(b) < 4 || b[1] != 0x80 {
+		return ""
+	}
+	n := int(b[3])
+	if 4+n > len(b) {
+		n = len(b) - 4
+	}
+	return strings.TrimSpace(strings.TrimRight(string(b[4:4+n]), "\x00"))
+}
+
+func isWWN(s string) bool {
+	if len(s) != 16 && len(s) != 32 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
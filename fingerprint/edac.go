@@ -0,0 +1,63 @@
+package fingerprint
+
+import (
+	"path"
+	"strconv"
+)
+
+// EDACInfo reports the memory controllers of the kernel's EDAC subsystem,
+// which registers them only with a driver for the platform, so its absence
+// leaves ECC unknown.
+type EDACInfo struct {
+	// ECC reports a controller running its DIMMs with error correction.
+	ECC         bool             `json:"ecc"`
+	Controllers []EDACController `json:"controllers"`
+}
+
+// EDACController is one /sys/devices/system/edac/mc/mc* directory.
+type EDACController struct {
+	Name string `json:"name"`
+	// Type is the driver's name for the controller, e.g. "Skylake
+	// Socket#0 IMC#0".
+	Type   string `json:"type,omitempty"`
+	SizeMB uint64 `json:"size_mb,omitempty"`
+	// Mode is the error correction of its DIMMs, e.g. "SECDED" or
+	// "None".
+	Mode  string `json:"mode,omitempty"`
+	DIMMs int    `json:"dimms"`
+	// CorrectedErrors and UncorrectedErrors count the errors since the
+	// driver loaded.
+	CorrectedErrors   uint64 `json:"corrected_errors"`
+	UncorrectedErrors uint64 `json:"uncorrected_errors"`
+}
+
+// noECCModes are the edac_mode values of memory without error correction.
+var noECCModes = map[string]bool{"": true, "Unknown": true, "None": true, "Reserved": true}
+
+func (h host) edacInfo() *EDACInfo {
+	mcs := h.glob("/sys/devices/system/edac/mc/mc[0-9]*")
+	if len(mcs) == 0 {
+		return nil
+	}
+	info := &EDACInfo{Controllers: []EDACController{}}
+	for _, d := range mcs {
+		c := EDACController{Name: path.Base(d), Type: h.readTrim(path.Join(d, "mc_name"))}
+		c.SizeMB, _ = strconv.ParseUint(h.readTrim(path.Join(d, "size_mb")), 10, 64)
+		c.CorrectedErrors, _ = strconv.ParseUint(h.readTrim(path.Join(d, "ce_count")), 10, 64)
+		c.UncorrectedErrors, _ = strconv.ParseUint(h.readTrim(path.Join(d, "ue_count")), 10, 64)
+		// Kernels before 3.6 only have the csrow view.
+		modes := h.glob(path.Join(d, "dimm[0-9]*/dimm_edac_mode"))
+		if len(modes) == 0 {
+			modes = h.glob(path.Join(d, "csrow[0-9]*/edac_mode"))
+		}
+		c.DIMMs = len(modes)
+		for _, m := range modes {
+			if v := h.readTrim(m); c.Mode == "" || noECCModes[c.Mode] {
+				c.Mode = v
+			}
+		}
+		info.ECC = info.ECC || !noECCModes[c.Mode]
+		info.Controllers = append(info.Controllers, c)
+	}
+	return info
+}
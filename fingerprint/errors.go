@@ -0,0 +1,75 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+)
+
+// CollectorError records why a collector, or one of its reads, failed, so
+// consumers can tell an absent value from one that could not be read.
+type CollectorError struct {
+	Collector string `json:"collector"`
+	Kind      string `json:"kind"`
+	// Path is the system file that could not be read, if any.
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error"`
+}
+
+// Kinds of CollectorError.
+const (
+	ErrorPermission = "permission"
+	ErrorMissing    = "missing"
+	ErrorTimeout    = "timeout"
+	ErrorCancelled  = "cancelled"
+	ErrorFailed     = "error"
+)
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorMissing
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCancelled
+	}
+	return ErrorFailed
+}
+
+// readErrors collects the failed reads of one collector run.
+type readErrors struct {
+	mu   sync.Mutex
+	list []CollectorError
+}
+
+func (r *readErrors) add(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.list = append(r.list, CollectorError{Kind: errorKind(err), Path: path, Error: err.Error()})
+}
+
+// take returns the recorded errors attributed to collector.
+func (r *readErrors) take(collector string) []CollectorError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.list
+	r.list = nil
+	for i := range out {
+		out[i].Collector = collector
+	}
+	return out
+}
+
+// skipError explains why a collector was left out; it wraps the context
+// error behind it.
+type skipError struct {
+	reason string
+	cause  error
+}
+
+func (e *skipError) Error() string { return e.reason }
+func (e *skipError) Unwrap() error { return e.cause }
@@ -1,33 +1,82 @@
 package fingerprint
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
-	"net"
-	"net/http"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
-	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"AurFingerprintAgent/dockerid"
 )
 
 // Snapshot contains collected system fingerprint information.
 type Snapshot struct {
-	Hostname  string        `json:"hostname,omitempty"`
-	OS        OSInfo        `json:"os"`
-	MachineID string        `json:"machine_id,omitempty"`
-	DMI       DMIInfo       `json:"dmi"`
-	CPU       CPUInfo       `json:"cpu"`
-	Memory    MemoryInfo    `json:"memory"`
-	Network   []NetIf       `json:"network"`
-	RootFS    RootFSInfo    `json:"rootfs"`
-	Docker    DockerInfo    `json:"docker"`
-	Runtime   GoRuntimeInfo `json:"go_runtime"`
+	// SchemaVersion is the layout the snapshot was written with; see
+	// Unmarshal.
+	SchemaVersion int        `json:"schema_version"`
+	Hostname      string     `json:"hostname,omitempty"`
+	OS            OSInfo     `json:"os"`
+	MachineID     string     `json:"machine_id,omitempty"`
+	DMI           DMIInfo    `json:"dmi"`
+	CPU           CPUInfo    `json:"cpu"`
+	Memory        MemoryInfo `json:"memory"`
+	// MemoryModules are the installed DIMMs by slot.
+	MemoryModules []MemoryModule `json:"memory_modules,omitempty"`
+	Network       []NetIf        `json:"network"`
+	// NetworkExcluded summarizes interfaces dropped by
+	// WithInterfaceExclude.
+	NetworkExcluded *ExcludedInterfaces `json:"network_excluded,omitempty"`
+	NetConfig       *NetConfigInfo      `json:"network_config,omitempty"`
+	NetNamespaces   []NetNamespace      `json:"network_namespaces,omitempty"`
+	Routing         *RoutingInfo        `json:"routing,omitempty"`
+	Neighbors       *NeighborInfo       `json:"neighbors,omitempty"`
+	DHCP            []DHCPLease         `json:"dhcp_leases,omitempty"`
+	IPv6            *IPv6Info           `json:"ipv6,omitempty"`
+	RootFS          RootFSInfo          `json:"rootfs"`
+	BlockDevices    []BlockDevice       `json:"block_devices,omitempty"`
+	NVMe            []NVMeController    `json:"nvme,omitempty"`
+	LVM             []VolumeGroup       `json:"lvm,omitempty"`
+	Drives          []DriveIdentity     `json:"drives,omitempty"`
+	Storage         []DiskHealth        `json:"storage_health,omitempty"`
+	Docker          DockerInfo          `json:"docker"`
+	Firmware        *FirmwareInfo       `json:"firmware,omitempty"`
+	Boot            *BootInfo           `json:"boot,omitempty"`
+	Security        *SecurityInfo       `json:"security,omitempty"`
+	Cloud           *CloudInfo          `json:"cloud,omitempty"`
+	Agent           AgentInfo           `json:"agent_runtime"`
+	// Labels are the operator's static labels; see WithLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+	Meta   *Meta             `json:"meta,omitempty"`
+	// Errors lists the collectors that failed or were skipped and the
+	// reads that failed, e.g. for lack of permission.
+	Errors []CollectorError `json:"errors,omitempty"`
+	// Confidence is derived from the collected data and is not part of
+	// the hash.
+	Confidence *Confidence `json:"fingerprint_confidence,omitempty"`
+	// Provenance is set by WithProvenance.
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Extensions holds the results of third-party collectors by name.
+	Extensions map[string]any `json:"extensions,omitempty"`
+	// Custom holds the output of WithPlugins executables by name.
+	Custom map[string]json.RawMessage `json:"custom,omitempty"`
+
+	// Large opt-in sections; see EncodeStream.
+	Packages []Package   `json:"packages,omitempty"`
+	PCI      []PCIDevice `json:"pci,omitempty"`
+	USB      []USBDevice `json:"usb,omitempty"`
+
+	// opts are the options the snapshot was collected with, for Refresh.
+	opts *options
 }
 
 // OSInfo represents operating system details.
@@ -43,22 +92,147 @@ type DMIInfo struct {
 	ProductUUID     string `json:"product_uuid,omitempty"`
 	BoardSerial     string `json:"board_serial,omitempty"`
 	ChassisAssetTag string `json:"chassis_asset_tag,omitempty"`
+	// The descriptive fields below are not hashed; they help match assets
+	// when the identifiers above are unreadable.
+	SysVendor     string `json:"sys_vendor,omitempty"`
+	ProductName   string `json:"product_name,omitempty"`
+	ProductSerial string `json:"product_serial,omitempty"`
+	ProductFamily string `json:"product_family,omitempty"`
+	BoardName     string `json:"board_name,omitempty"`
+	BoardVendor   string `json:"board_vendor,omitempty"`
+	BIOSVendor    string `json:"bios_vendor,omitempty"`
+	BIOSVersion   string `json:"bios_version,omitempty"`
+	BIOSDate      string `json:"bios_date,omitempty"`
+	// ChassisType is the SMBIOS enclosure type by name, e.g. "Rack Mount
+	// Chassis", or its number when unknown.
+	ChassisType string `json:"chassis_type,omitempty"`
+	// OEMStrings are the SMBIOS type 11 strings, e.g. Dell service tags
+	// or cloud instance metadata, which sysfs does not expose.
+	OEMStrings []string `json:"oem_strings,omitempty"`
+	// FromSMBIOS names the fields recovered from the raw SMBIOS table
+	// because /sys/class/dmi/id lacked them.
+	FromSMBIOS []string `json:"from_smbios,omitempty"`
+	// Invalid names the fields holding OEM placeholders; they are kept for
+	// reference but excluded from the hash.
+	Invalid []string `json:"invalid,omitempty"`
 }
 
-// CPUInfo describes CPU model information.
+// CPUInfo describes the CPU model and topology.
 type CPUInfo struct {
 	Model string `json:"model,omitempty"`
+	// Vendor is vendor_id on x86, e.g. "GenuineIntel", and the
+	// implementer code on Arm, e.g. "0x41".
+	Vendor string `json:"vendor,omitempty"`
+	// Family, ModelNumber and Stepping are the decimal "cpu family",
+	// "model" and "stepping" on x86, and the architecture, part and
+	// variant/revision on Arm, as the kernel prints them.
+	Family      string `json:"family,omitempty"`
+	ModelNumber string `json:"model_number,omitempty"`
+	Stepping    string `json:"stepping,omitempty"`
+	// Microcode is the revision loaded into the first CPU, e.g.
+	// "0x5003604"; x86 only.
+	Microcode string `json:"microcode,omitempty"`
+	// MaxMHz is the highest frequency cpufreq allows, including turbo;
+	// 0 where the hypervisor hides cpufreq.
+	MaxMHz  int `json:"cpu_mhz_max,omitempty"`
+	Sockets int `json:"sockets,omitempty"`
+	// Cores counts physical cores, LogicalCPUs the online hardware
+	// threads.
+	Cores       int `json:"cores,omitempty"`
+	LogicalCPUs int `json:"logical_cpus,omitempty"`
+	// Flags are the feature flags of the first CPU, "flags" on x86 and
+	// "Features" on Arm.
+	Flags []string `json:"flags,omitempty"`
+	// Capabilities summarizes Flags and the related devices.
+	Capabilities *CPUCapabilities `json:"capabilities,omitempty"`
+	// Caches are the distinct caches, ordered by level and type.
+	Caches []CPUCache `json:"caches,omitempty"`
+	// Packages are the processor sockets of the SMBIOS table, empty ones
+	// included.
+	Packages []CPUPackage `json:"packages,omitempty"`
+}
+
+// CPUPackage is a processor socket of the SMBIOS table (type 4).
+type CPUPackage struct {
+	// Socket is the board's designation, e.g. "CPU0" or "P1".
+	Socket       string `json:"socket,omitempty"`
+	Populated    bool   `json:"populated"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// Version is the model the firmware reports, which may differ from
+	// the kernel's model name.
+	Version     string `json:"version,omitempty"`
+	MaxSpeedMHz int    `json:"max_speed_mhz,omitempty"`
+	Cores       int    `json:"cores,omitempty"`
+	Threads     int    `json:"threads,omitempty"`
+	Serial      string `json:"serial,omitempty"`
+	PartNumber  string `json:"part_number,omitempty"`
+}
+
+// CPUCache describes one kind of cache of
+// /sys/devices/system/cpu/cpu*/cache.
+type CPUCache struct {
+	Level int `json:"level"`
+	// Type is "Data", "Instruction" or "Unified".
+	Type     string `json:"type"`
+	SizeKB   uint64 `json:"size_kb"`
+	Ways     int    `json:"ways,omitempty"`
+	LineSize int    `json:"line_size,omitempty"`
+	// SharedCPUs is how many logical CPUs share one instance, Instances
+	// how many instances there are, e.g. one L3 per socket.
+	SharedCPUs int `json:"shared_cpus,omitempty"`
+	Instances  int `json:"instances"`
 }
 
 // MemoryInfo reports total memory in kilobytes.
 type MemoryInfo struct {
 	MemTotalKB uint64 `json:"mem_total_kb,omitempty"`
+	// NUMANodes lists the online NUMA nodes; empty on kernels without
+	// NUMA support.
+	NUMANodes []NUMANode `json:"numa_nodes,omitempty"`
+	// EDAC is nil without an EDAC driver for the memory controllers.
+	EDAC *EDACInfo `json:"edac,omitempty"`
+}
+
+// NUMANode is one node of /sys/devices/system/node.
+type NUMANode struct {
+	ID         int    `json:"id"`
+	MemTotalKB uint64 `json:"mem_total_kb"`
+	// CPUs is the kernel's CPU list, e.g. "0-7,16-23".
+	CPUs     string `json:"cpus,omitempty"`
+	CPUCount int    `json:"cpu_count"`
+	// Distances are the relative access costs to every node by ID, 10
+	// being local.
+	Distances []int `json:"distances,omitempty"`
+}
+
+// MemoryModule is an installed memory module of the SMBIOS table.
+type MemoryModule struct {
+	// Slot and Bank are the board's locators, e.g. "DIMM_A1" and
+	// "BANK 0".
+	Slot   string `json:"slot,omitempty"`
+	Bank   string `json:"bank,omitempty"`
+	SizeMB uint64 `json:"size_mb"`
+	// Type is the memory technology, e.g. "DDR4".
+	Type         string `json:"type,omitempty"`
+	SpeedMTs     int    `json:"speed_mts,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	PartNumber   string `json:"part_number,omitempty"`
+	AssetTag     string `json:"asset_tag,omitempty"`
 }
 
 // NetIf contains network interface name and MAC address.
 type NetIf struct {
 	Name string `json:"name"`
 	MAC  string `json:"mac"`
+	// Kind is the kernel's device type of virtual interfaces, e.g.
+	// "bond", "bridge" or "vlan"; empty for plain NICs.
+	Kind string `json:"kind,omitempty"`
+	// Master is the bond or bridge the interface is enslaved to.
+	Master string `json:"master,omitempty"`
+	// Physical is set for interfaces backed by a device, as opposed to
+	// bonds, bridges, tunnels and veths.
+	Physical bool `json:"physical,omitempty"`
 }
 
 // RootFSInfo describes root filesystem source, type and UUID.
@@ -66,6 +240,10 @@ type RootFSInfo struct {
 	Source string `json:"source,omitempty"`
 	Fstype string `json:"fstype,omitempty"`
 	UUID   string `json:"uuid,omitempty"`
+	// Device is the device mapper node, e.g. "dm-0", of a root file system
+	// on LVM or dm-crypt, and LVUUID the UUID of its logical volume.
+	Device string `json:"device,omitempty"`
+	LVUUID string `json:"lv_uuid,omitempty"`
 }
 
 // DockerInfo holds Docker daemon ID if available.
@@ -73,143 +251,217 @@ type DockerInfo struct {
 	DaemonID string `json:"daemon_id,omitempty"`
 }
 
-// GoRuntimeInfo exposes GOOS and GOARCH.
-type GoRuntimeInfo struct {
-	GOOS   string `json:"goos"`
-	GOARCH string `json:"goarch"`
-}
-
-func readTrim(path string) string {
-	b, err := os.ReadFile(path)
+func (h host) readOSEtc() (name, ver string) {
+	f, err := h.open("/etc/os-release")
 	if err != nil {
-		return ""
+		return "", ""
 	}
-	return strings.TrimSpace(string(b))
+	defer f.Close()
+	return parseOSRelease(f)
 }
 
-func readOSEtc() (name, ver string) {
-	b, err := os.ReadFile("/etc/os-release")
-	if err != nil {
-		return "", ""
+func (h host) cpuInfo() CPUInfo {
+	var c CPUInfo
+	if f, err := h.expect().open("/proc/cpuinfo"); err == nil {
+		c = parseCPUInfo(f)
+		f.Close()
 	}
-	for _, ln := range strings.Split(string(b), "\n") {
-		if strings.HasPrefix(ln, "NAME=") {
-			name = strings.Trim(strings.TrimPrefix(ln, "NAME="), `"`)
-		} else if strings.HasPrefix(ln, "VERSION=") {
-			ver = strings.Trim(strings.TrimPrefix(ln, "VERSION="), `"`)
+	// The sysfs topology is authoritative where cpuinfo lacks physical
+	// and core ids, as on Arm and in some hypervisors.
+	pkgs, cores := map[string]bool{}, map[string]bool{}
+	for _, p := range h.glob("/sys/devices/system/cpu/cpu[0-9]*/topology/physical_package_id") {
+		pkg := h.readTrim(p)
+		if pkg == "" {
+			continue
+		}
+		if pkg == "-1" { // no package information on some Arm kernels
+			pkg = "0"
+		}
+		pkgs[pkg] = true
+		cores[pkg+"/"+h.readTrim(path.Join(path.Dir(p), "core_id"))] = true
+	}
+	if len(pkgs) > 0 {
+		c.Sockets, c.Cores = len(pkgs), len(cores)
+	}
+	c.Packages = h.cpuPackages()
+	if c.Sockets == 0 {
+		// Without a sysfs topology, count the populated sockets.
+		for _, p := range c.Packages {
+			if p.Populated {
+				c.Sockets++
+			}
 		}
 	}
-	return
+	if n := cpuListLen(h.readTrim("/sys/devices/system/cpu/online")); n > 0 {
+		c.LogicalCPUs = n
+	}
+	if khz, err := strconv.Atoi(h.readTrim("/sys/devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq")); err == nil {
+		c.MaxMHz = khz / 1000
+	}
+	c.Capabilities = h.cpuCapabilities(c.Flags)
+	c.Caches = h.cpuCaches()
+	return c
 }
 
-func firstCPUModel() string {
-	f, err := os.Open("/proc/cpuinfo")
-	if err != nil {
-		return ""
+// cpuCaches groups the caches of all CPUs by level, type and size; each
+// distinct shared_cpu_list of a group is one instance.
+func (h host) cpuCaches() []CPUCache {
+	type key struct {
+		level int
+		typ   string
+		size  uint64
 	}
-	defer f.Close()
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		ln := sc.Text()
-		if strings.HasPrefix(ln, "model name") {
-			parts := strings.SplitN(ln, ":", 2)
-			if len(parts) == 2 {
-				return strings.TrimSpace(parts[1])
+	caches := map[key]*CPUCache{}
+	shared := map[key]map[string]bool{}
+	for _, d := range h.glob("/sys/devices/system/cpu/cpu[0-9]*/cache/index[0-9]*") {
+		level, err := strconv.Atoi(h.readTrim(path.Join(d, "level")))
+		if err != nil {
+			continue
+		}
+		k := key{level, h.readTrim(path.Join(d, "type")), parseCacheSize(h.readTrim(path.Join(d, "size")))}
+		c := caches[k]
+		if c == nil {
+			c = &CPUCache{Level: k.level, Type: k.typ, SizeKB: k.size}
+			c.Ways, _ = strconv.Atoi(h.readTrim(path.Join(d, "ways_of_associativity")))
+			c.LineSize, _ = strconv.Atoi(h.readTrim(path.Join(d, "coherency_line_size")))
+			caches[k], shared[k] = c, map[string]bool{}
+		}
+		list := h.readTrim(path.Join(d, "shared_cpu_list"))
+		if !shared[k][list] {
+			shared[k][list] = true
+			c.Instances++
+			c.SharedCPUs = max(c.SharedCPUs, cpuListLen(list))
+		}
+	}
+	var out []CPUCache
+	for _, c := range caches {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.SizeKB < b.SizeKB
+	})
+	return out
+}
+
+// numaNodes reads the nodes of /sys/devices/system/node in ID order.
+func (h host) numaNodes() []NUMANode {
+	var nodes []NUMANode
+	for _, d := range h.glob("/sys/devices/system/node/node[0-9]*") {
+		id, err := strconv.Atoi(strings.TrimPrefix(path.Base(d), "node"))
+		if err != nil {
+			continue
+		}
+		n := NUMANode{ID: id, CPUs: h.readTrim(path.Join(d, "cpulist"))}
+		n.CPUCount = cpuListLen(n.CPUs)
+		if f, err := h.open(path.Join(d, "meminfo")); err == nil {
+			n.MemTotalKB = parseMemTotalKB(f)
+			f.Close()
+		}
+		for _, v := range strings.Fields(h.readTrim(path.Join(d, "distance"))) {
+			dist, err := strconv.Atoi(v)
+			if err != nil {
+				break
 			}
+			n.Distances = append(n.Distances, dist)
 		}
+		nodes = append(nodes, n)
 	}
-	return ""
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
 }
 
-func memTotalKB() uint64 {
-	f, err := os.Open("/proc/meminfo")
+func (h host) memTotalKB() uint64 {
+	f, err := h.open("/proc/meminfo")
 	if err != nil {
 		return 0
 	}
 	defer f.Close()
-	var total uint64
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		ln := sc.Text()
-		if strings.HasPrefix(ln, "MemTotal:") {
-			var val uint64
-			var unit string
-			fmt.Sscanf(ln, "MemTotal: %d %s", &val, &unit)
-			total = val
-			break
-		}
-	}
-	return total
+	return parseMemTotalKB(f)
 }
 
-func netIfaces() []NetIf {
-	ifaces, err := net.Interfaces()
+// netIfaces lists interfaces with a hardware address from sysfs rather than
+// net.Interfaces: the latter always reflects the agent's own network
+// namespace, so inside a pod it reports the pod's veth, while sysfs mounted
+// from the host shows the host's NICs. Interfaces are ordered by index.
+func (h host) netIfaces() []NetIf {
+	const base = "/sys/class/net"
+	entries, err := h.readDir(base)
 	if err != nil {
 		return nil
 	}
-	var out []NetIf
-	for _, it := range ifaces {
-		if it.Name == "lo" {
+	type indexed struct {
+		NetIf
+		index int
+	}
+	var found []indexed
+	for _, e := range entries {
+		name := e.Name()
+		if name == "lo" {
 			continue
 		}
-		mac := it.HardwareAddr.String()
+		mac := h.readTrim(filepath.Join(base, name, "address"))
 		if mac == "" || mac == "00:00:00:00:00:00" {
 			continue
 		}
-		out = append(out, NetIf{Name: it.Name, MAC: mac})
+		idx, _ := strconv.Atoi(h.readTrim(filepath.Join(base, name, "ifindex")))
+		n := NetIf{Name: name, MAC: mac, Physical: h.isPhysicalNIC(name)}
+		if m, err := h.readlink(filepath.Join(base, name, "master")); err == nil {
+			n.Master = path.Base(m)
+		}
+		for _, l := range strings.Split(h.readTrim(filepath.Join(base, name, "uevent")), "\n") {
+			if v, ok := strings.CutPrefix(l, "DEVTYPE="); ok {
+				n.Kind = v
+			}
+		}
+		found = append(found, indexed{n, idx})
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].index < found[j].index })
+	out := make([]NetIf, 0, len(found))
+	for _, f := range found {
+		out = append(out, f.NetIf)
+	}
+	if len(out) == 0 {
+		return nil
 	}
 	return out
 }
 
-func rootfsFromMountinfo() (source, fstype string) {
-	f, err := os.Open("/proc/self/mountinfo")
-	if err != nil {
-		return "", ""
+// rootfsFromMountinfo falls back to /etc/mtab, which a captured file tree
+// may hold in place of procfs.
+func (h host) rootfsFromMountinfo() (source, fstype string) {
+	p := h.procSelf() + "/mountinfo"
+	lax := h
+	lax.strict = false
+	f, err := lax.open(p)
+	if err == nil {
+		defer f.Close()
+		return parseMountinfoRoot(f)
 	}
-	defer f.Close()
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		ln := sc.Text()
-		if !strings.Contains(ln, " - ") {
-			continue
-		}
-		parts := strings.Split(ln, " - ")
-		if len(parts) != 2 {
-			continue
-		}
-		left := parts[0]
-		right := parts[1]
-		leftFields := strings.Fields(left)
-		if len(leftFields) < 5 {
-			continue
-		}
-		mountPoint := leftFields[4]
-		if mountPoint != "/" {
-			continue
-		}
-		rightFields := strings.Fields(right)
-		if len(rightFields) >= 2 {
-			fstype = rightFields[0]
-			source = rightFields[1]
-		}
-		break
+	if mf, err := lax.open("/etc/mtab"); err == nil {
+		defer mf.Close()
+		return parseMountsRoot(mf)
+	}
+	// A missing mountinfo is expected of captured trees; a denied one is
+	// worth reporting.
+	if !errors.Is(err, fs.ErrNotExist) {
+		h.note(p, err)
 	}
-	return
+	return "", ""
 }
 
-func dockerID() string {
+func (h host) dockerID(ctx context.Context) string {
 	type daemonCfg struct {
 		DataRoot string `json:"data-root"`
 	}
-	readFile := func(p string) string {
-		b, err := os.ReadFile(p)
-		if err != nil {
-			return ""
-		}
-		return strings.TrimSpace(string(b))
-	}
 	var roots []string
-	if b, err := os.ReadFile("/etc/docker/daemon.json"); err == nil {
+	if b, err := h.readFile("/etc/docker/daemon.json"); err == nil {
 		var cfg daemonCfg
 		if json.Unmarshal(b, &cfg) == nil && strings.TrimSpace(cfg.DataRoot) != "" {
 			roots = append(roots, strings.TrimSpace(cfg.DataRoot))
@@ -229,83 +481,114 @@ func dockerID() string {
 			continue
 		}
 		seen[r] = struct{}{}
-		if id := readFile(filepath.Join(r, "engine-id")); id != "" {
+		if id := h.readTrim(filepath.Join(r, "engine-id")); id != "" {
 			return id
 		}
 	}
 	for _, p := range []string{"/var/lib/docker/.docker_id", "/var/lib/docker/.docker_uuid"} {
-		if id := readFile(p); id != "" {
+		if id := h.readTrim(p); id != "" {
 			return id
 		}
 	}
-	if id := dockerIDViaUnixSocket(); id != "" {
+	if !h.live() {
+		return ""
+	}
+	if id := h.dockerIDViaUnixSocket(ctx); id != "" {
 		return id
 	}
-	if id := dockerIDViaCLI(); id != "" {
+	if !h.native() {
+		return ""
+	}
+	if id := h.dockerIDViaCLI(ctx); id != "" {
 		return id
 	}
 	return ""
 }
 
-func dockerIDViaUnixSocket() string {
-	type infoResp struct {
-		ID string `json:"ID"`
-	}
-	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		return net.Dial("unix", "/var/run/docker.sock")
+func (h host) dockerIDViaUnixSocket(ctx context.Context) string {
+	c := h.dockerClient()
+	if c == nil {
+		return ""
 	}
-	tr := &http.Transport{DialContext: dialer}
-	client := &http.Client{Transport: tr, Timeout: 2 * time.Second}
-	req, _ := http.NewRequest("GET", "http://unix/info", nil)
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := defaultTimeout(ctx, 2*time.Second)
 	defer cancel()
-	req = req.WithContext(ctx)
-	resp, err := client.Do(req)
+	id, err := c.DaemonID(ctx)
+	h.debug("docker request", "endpoint", c.Endpoint, "path", "/info", "err", err)
 	if err != nil {
+		h.notePermission(h.rel(strings.TrimPrefix(c.Endpoint, "unix://")), err)
 		return ""
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return ""
+	h.source("socket", c.Endpoint)
+	return id
+}
+
+// dockerEndpointOrDefault returns the daemon address of the system.
+func (h host) dockerEndpointOrDefault() string {
+	if h.dockerEndpoint != "" {
+		return h.dockerEndpoint
 	}
-	var v infoResp
-	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
-		return ""
+	return h.path(dockerid.DefaultSocket)
+}
+
+// dockerClients holds one client per daemon endpoint and retry policy, so
+// that the agent reuses its connections across snapshots.
+var dockerClients sync.Map
+
+// dockerClient returns the client for the daemon of the system, or nil
+// when the system is not live.
+func (h host) dockerClient() *dockerid.Client {
+	if !h.live() {
+		return nil
+	}
+	ep := h.dockerEndpointOrDefault()
+	p := h.retry("docker")
+	key := fmt.Sprint(ep, p)
+	if c, ok := dockerClients.Load(key); ok {
+		return c.(*dockerid.Client)
 	}
-	return strings.TrimSpace(v.ID)
+	c := dockerid.New(ep)
+	c.Retry = p
+	v, _ := dockerClients.LoadOrStore(key, c)
+	return v.(*dockerid.Client)
 }
 
-func dockerIDViaCLI() string {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+func (h host) dockerIDViaCLI(ctx context.Context) string {
+	ctx, cancel := defaultTimeout(ctx, 2*time.Second)
 	defer cancel()
-	out, err := exec.CommandContext(ctx, "docker", "info", "-f", "{{.ID}}").Output()
+	out, err := h.output(ctx, "docker", "info", "-f", "{{.ID}}")
 	if err != nil {
 		return ""
 	}
 	return strings.TrimSpace(string(out))
 }
 
-func ensureReadable(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+// defaultTimeout bounds ctx by d unless the caller already set a deadline,
+// which then governs alone.
+func defaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
 }
 
-func rootfsUUID(dev string) string {
+// rootfsUUID resolves the file system UUID of dev through
+// /dev/disk/by-uuid or blkid.
+func (h host) rootfsUUID(ctx context.Context, dev string) string {
 	if dev == "" {
 		return ""
 	}
-	realDev, err := filepath.EvalSymlinks(dev)
-	if err != nil || realDev == "" {
+	realDev := h.resolveLink(dev)
+	if realDev == "" {
 		realDev = dev
 	}
 	const byUUID = "/dev/disk/by-uuid"
-	if entries, err := os.ReadDir(byUUID); err == nil {
+	if entries, err := h.readDir(byUUID); err == nil {
 		for _, e := range entries {
 			if e.IsDir() {
 				continue
 			}
 			link := filepath.Join(byUUID, e.Name())
-			target, err := os.Readlink(link)
+			target, err := h.readlink(link)
 			if err != nil {
 				continue
 			}
@@ -313,16 +596,15 @@ func rootfsUUID(dev string) string {
 			if !strings.HasPrefix(target, "/") {
 				fullTarget = filepath.Join(byUUID, target)
 			}
-			resolved, err := filepath.EvalSymlinks(fullTarget)
-			if err != nil {
-				continue
-			}
-			if resolved == realDev {
+			if h.resolveLink(fullTarget) == realDev {
 				return e.Name()
 			}
 		}
 	}
-	out, err := exec.Command("blkid", "-s", "UUID", "-o", "value", dev).Output()
+	if !h.live() {
+		return ""
+	}
+	out, err := h.output(ctx, "blkid", "-s", "UUID", "-o", "value", h.path(dev))
 	if err == nil {
 		if uuid := strings.TrimSpace(string(out)); uuid != "" {
 			return uuid
@@ -332,36 +614,15 @@ func rootfsUUID(dev string) string {
 }
 
 // GetSnapshot collects system information without producing any output.
-func GetSnapshot() Snapshot {
-	h, _ := os.Hostname()
-	name, ver := readOSEtc()
-	kType := readTrim("/proc/sys/kernel/ostype")
-	kRel := readTrim("/proc/sys/kernel/osrelease")
-	snap := Snapshot{
-		Hostname: h,
-		OS: OSInfo{
-			Name:       name,
-			Version:    ver,
-			KernelType: kType,
-			KernelRel:  kRel,
-		},
-		MachineID: readTrim("/etc/machine-id"),
-		DMI: DMIInfo{
-			ProductUUID:     readTrim("/sys/class/dmi/id/product_uuid"),
-			BoardSerial:     readTrim("/sys/class/dmi/id/board_serial"),
-			ChassisAssetTag: readTrim("/sys/class/dmi/id/chassis_asset_tag"),
-		},
-		CPU:     CPUInfo{Model: firstCPUModel()},
-		Memory:  MemoryInfo{MemTotalKB: memTotalKB()},
-		Network: netIfaces(),
-		Runtime: GoRuntimeInfo{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH},
-		Docker:  DockerInfo{DaemonID: dockerID()},
-	}
-	src, fstype := rootfsFromMountinfo()
-	uuid := rootfsUUID(src)
-	snap.RootFS = RootFSInfo{Source: src, Fstype: fstype, UUID: uuid}
-	_ = filepath.WalkDir("/sys/class/dmi/id", func(path string, d fs.DirEntry, err error) error {
-		return nil
-	})
-	return snap
+// Optional sections are enabled through opts.
+func GetSnapshot(opts ...Option) Snapshot {
+	return GetSnapshotContext(context.Background(), opts...)
+}
+
+// GetSnapshotContext is GetSnapshot bounded by ctx. External commands and
+// socket probes run under ctx; when it carries a deadline, that deadline
+// replaces their built-in timeouts. Collectors still running when ctx is
+// done are abandoned and listed in Snapshot.Meta.Skipped.
+func GetSnapshotContext(ctx context.Context, opts ...Option) Snapshot {
+	return collect(ctx, buildOptions(opts), nil)
 }
@@ -0,0 +1,110 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+
+	"AurFingerprintAgent/efivars"
+)
+
+// FirmwareInfo describes UEFI state read from efivarfs. It is nil on
+// systems booted in legacy BIOS mode.
+type FirmwareInfo struct {
+	SecureBoot  bool         `json:"secure_boot"`
+	SetupMode   bool         `json:"setup_mode"`
+	BootCurrent string       `json:"boot_current,omitempty"`
+	BootOrder   []string     `json:"boot_order,omitempty"`
+	BootEntries []BootEntry  `json:"boot_entries,omitempty"`
+	PlatformKey *PlatformKey `json:"platform_key,omitempty"`
+}
+
+// BootEntry is a decoded Boot#### variable.
+type BootEntry struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Path          string `json:"path,omitempty"`
+	PartitionGUID string `json:"partition_guid,omitempty"`
+	Active        bool   `json:"active"`
+}
+
+// PlatformKey identifies the enrolled Secure Boot platform key.
+type PlatformKey struct {
+	Owner   string `json:"owner,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	SHA256  string `json:"sha256"`
+}
+
+func (h host) firmwareInfo() *FirmwareInfo {
+	r := efivars.Reader{Dir: h.path(efivars.DefaultDir)}
+	if h.fsys != nil {
+		r = efivars.Reader{FS: h.fsys, Dir: fsName(efivars.DefaultDir)}
+	} else if h.recording() {
+		r = efivars.Reader{FS: recFS{os.DirFS(h.path("/")), h.rec}, Dir: fsName(efivars.DefaultDir)}
+	}
+	if !r.Available() {
+		h.debug("efivars unavailable", "dir", r.Dir)
+		return nil
+	}
+	fw := &FirmwareInfo{
+		SecureBoot: efiFlag(r, "SecureBoot"),
+		SetupMode:  efiFlag(r, "SetupMode"),
+	}
+	if v, err := r.Read("BootCurrent", efivars.GlobalGUID); err == nil && len(v.Data) >= 2 {
+		fw.BootCurrent = efivars.BootName(binary.LittleEndian.Uint16(v.Data))
+	}
+	if v, err := r.Read("BootOrder", efivars.GlobalGUID); err == nil {
+		fw.BootOrder = efivars.BootOrder(v.Data)
+	}
+	fw.BootEntries = bootEntries(r, fw.BootOrder)
+	fw.PlatformKey = platformKey(r)
+	return fw
+}
+
+func efiFlag(r efivars.Reader, name string) bool {
+	v, err := r.Read(name, efivars.GlobalGUID)
+	return err == nil && len(v.Data) > 0 && v.Data[0] == 1
+}
+
+// bootEntries decodes the entries listed in BootOrder, in that order.
+func bootEntries(r efivars.Reader, order []string) []BootEntry {
+	var out []BootEntry
+	for _, name := range order {
+		v, err := r.Read(name, efivars.GlobalGUID)
+		if err != nil {
+			continue
+		}
+		lo, err := efivars.ParseLoadOption(v.Data)
+		if lo == nil {
+			continue
+		}
+		e := BootEntry{Name: name, Description: lo.Description, Active: lo.Active()}
+		if err == nil {
+			e.Path, e.PartitionGUID = lo.Path, lo.PartitionGUID
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func platformKey(r efivars.Reader) *PlatformKey {
+	v, err := r.Read("PK", efivars.GlobalGUID)
+	if err != nil {
+		return nil
+	}
+	sigs, _ := efivars.ParseSignatureLists(v.Data)
+	if len(sigs) == 0 {
+		return nil
+	}
+	s := sigs[0]
+	sum := sha256.Sum256(s.Data)
+	pk := &PlatformKey{Owner: s.Owner, SHA256: hex.EncodeToString(sum[:])}
+	if s.Type == efivars.CertX509GUID {
+		if c, err := x509.ParseCertificate(s.Data); err == nil {
+			pk.Subject = c.Subject.String()
+		}
+	}
+	return pk
+}
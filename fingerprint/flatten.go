@@ -0,0 +1,48 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Flatten returns the snapshot as a flat map of dotted JSON paths to string
+// values, e.g. "dmi.product_uuid" or "network.0.mac". Empty sections are
+// omitted. The fingerprint hash is included under "hash".
+func (s Snapshot) Flatten() map[string]string {
+	out := map[string]string{"hash": s.Hash()}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return out
+	}
+	var v any
+	if json.Unmarshal(b, &v) != nil {
+		return out
+	}
+	flatten("", v, out)
+	return out
+}
+
+func flatten(prefix string, v any, out map[string]string) {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for k, c := range t {
+			flatten(join(k), c, out)
+		}
+	case []any:
+		for i, c := range t {
+			flatten(join(strconv.Itoa(i)), c, out)
+		}
+	case string:
+		out[prefix] = t
+	case float64:
+		out[prefix] = strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		out[prefix] = strconv.FormatBool(t)
+	}
+}
@@ -0,0 +1,244 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden snapshots in testdata/corpus")
+
+// TestCorpus runs the full collection, with every opt-in collector, against
+// the captured file trees in testdata/corpus/<system>/root and compares the
+// result with testdata/corpus/<system>/golden.json. Fields describing the
+// agent rather than the system are cleared. Run with -update after an
+// intended change and review the diff.
+func TestCorpus(t *testing.T) {
+	dirs, err := filepath.Glob("testdata/corpus/*")
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("no corpus: %v", err)
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			got := corpusSnapshot(t, filepath.Join(dir, "root"))
+			golden := filepath.Join(dir, "golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("snapshot differs from %s (rerun with -update to accept):\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// TestRecordReplay records a collection over each corpus tree and checks
+// that replaying the recording yields the same snapshot, and that secrets
+// in the network configuration are not recorded.
+func TestRecordReplay(t *testing.T) {
+	dirs, _ := filepath.Glob("testdata/corpus/*")
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			rec := NewRecorder()
+			want := corpusJSON(t, GetSnapshot(append(corpusOptions(), WithRootPrefix(filepath.Join(dir, "root")), WithRecorder(rec))...))
+			var buf bytes.Buffer
+			if _, err := rec.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			raw := buf.Bytes()
+			snap, err := Replay(bytes.NewReader(raw), corpusOptions()...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := corpusJSON(t, snap); !bytes.Equal(withoutErrors(got), withoutErrors(want)) {
+				t.Errorf("replayed snapshot differs:\n%s\nrecorded:\n%s", got, want)
+			}
+			fsys, err := ReadBundle(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err == nil && d.Type().IsRegular() {
+					if b, _ := fs.ReadFile(fsys, p); bytes.Contains(b, []byte("corpus-secret")) {
+						t.Errorf("recording keeps a secret in %s", p)
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
+
+// withoutErrors drops the error messages of a snapshot, which name the
+// paths opened and so differ between a live and a replayed read.
+func withoutErrors(b []byte) []byte {
+	var out [][]byte
+	for _, ln := range bytes.Split(b, []byte("\n")) {
+		if !bytes.Contains(ln, []byte(`"error": `)) {
+			out = append(out, ln)
+		}
+	}
+	return bytes.Join(out, []byte("\n"))
+}
+
+func corpusOptions() []Option {
+	return []Option{
+		WithCloudInit(), WithStorageHealth(), WithDriveIdentity(), WithNetNamespaces(), WithNeighbors(),
+		WithPackages(), WithPCI(), WithUSB(),
+	}
+}
+
+func corpusSnapshot(t *testing.T, root string) []byte {
+	t.Helper()
+	return corpusJSON(t, GetSnapshot(append(corpusOptions(), WithFS(os.DirFS(root)))...))
+}
+
+// corpusJSON clears the fields describing the agent and marshals snap with
+// its hash.
+func corpusJSON(t *testing.T, snap Snapshot) []byte {
+	t.Helper()
+	snap.Agent = AgentInfo{}
+	if m := snap.Meta; m != nil {
+		m.Build, m.Container, m.SelfCheck = nil, nil, nil
+		if len(m.Skipped) == 0 && len(m.Truncated) == 0 {
+			snap.Meta = nil
+		}
+	}
+	b, err := json.MarshalIndent(struct {
+		Hash     string   `json:"hash"`
+		Snapshot Snapshot `json:"snapshot"`
+	}{snap.Hash(), snap}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(b, '\n')
+}
+
+// TestUdevRootFSUUID checks that the udev fallback, which changes Hash, is
+// opt-in.
+func TestUdevRootFSUUID(t *testing.T) {
+	root := os.DirFS(filepath.Join("testdata", "corpus", "rhel-9", "root"))
+	if got := GetSnapshot(WithFS(root), WithCollectors("rootfs")).RootFS.UUID; got != "" {
+		t.Errorf("RootFS.UUID = %q without WithUdevRootFSUUID", got)
+	}
+	const want = "6f1c2a9e-5b3d-4e87-9a0f-d2c4b6e8a1f3"
+	if got := GetSnapshot(WithFS(root), WithCollectors("rootfs"), WithUdevRootFSUUID()).RootFS.UUID; got != want {
+		t.Errorf("RootFS.UUID = %q, want %q", got, want)
+	}
+}
+
+// TestCollectStream checks that the sections CollectStream sends, the last
+// value of each winning, add up to the snapshot GetSnapshot collects.
+func TestCollectStream(t *testing.T) {
+	dirs, _ := filepath.Glob("testdata/corpus/*")
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			opts := append(corpusOptions(), WithFS(os.DirFS(filepath.Join(dir, "root"))))
+			m := map[string]json.RawMessage{}
+			for f := range CollectStream(context.Background(), opts...) {
+				m[f.Name] = f.Value
+			}
+			b, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Snapshot
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			want := corpusJSON(t, GetSnapshot(opts...))
+			if g := corpusJSON(t, got); !bytes.Equal(g, want) {
+				t.Errorf("streamed snapshot differs:\n%s\nwant:\n%s", g, want)
+			}
+		})
+	}
+}
+
+// TestNetplanOrder checks that interfaces of one netplan file come out in
+// a stable order although the file is decoded into a map.
+func TestNetplanOrder(t *testing.T) {
+	fsys := fstest.MapFS{"etc/netplan/50-cloud-init.yaml": {Data: []byte(`network:
+  ethernets:
+    eth3: {match: {macaddress: "52:54:00:00:00:03"}, set-name: lan3}
+    eth1: {}
+    eth2: {match: {name: "en*"}, macaddress: "52:54:00:00:00:02"}
+    eth0: {}
+  wifis:
+    wlan0: {}
+`)}}
+	var names []string
+	for _, c := range (host{fsys: fsys}).netplanIfaces() {
+		names = append(names, c.Name+"/"+c.MAC)
+	}
+	want := []string{"eth0/", "eth1/", "/52:54:00:00:00:02", "lan3/52:54:00:00:00:03", "wlan0/"}
+	if !slices.Equal(names, want) {
+		t.Errorf("netplan interfaces %q, want %q", names, want)
+	}
+}
+
+// shortWriter accepts n bytes and fails every write after that.
+type shortWriter struct{ n int }
+
+var errShortWrite = errors.New("short write")
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		k := w.n
+		w.n = 0
+		return k, errShortWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+// TestEncodeStreamWriteError checks that EncodeStream reports a writer
+// failing at any point, including inside the streamed sections and with
+// those sections cut by limits.
+func TestEncodeStreamWriteError(t *testing.T) {
+	for _, l := range []Limits{DefaultLimits, {MaxPackages: 1, MaxStringLength: 4}} {
+		opts := append(corpusOptions(), WithLimits(l), WithFS(os.DirFS(filepath.Join("testdata", "corpus", "debian-12", "root"))))
+		var full bytes.Buffer
+		if err := EncodeStream(&full, opts...); err != nil {
+			t.Fatal(err)
+		}
+		head := bytes.Index(full.Bytes(), []byte(`,"packages":[`))
+		if head < 0 {
+			t.Fatalf("%+v: no packages section streamed", l)
+		}
+		for _, n := range []int{0, head, head + 20, full.Len() - 1} {
+			if err := EncodeStream(&shortWriter{n}, opts...); !errors.Is(err, errShortWrite) {
+				t.Errorf("%+v: writer failing after %d of %d bytes: EncodeStream = %v", l, n, full.Len(), err)
+			}
+		}
+	}
+}
+
+// TestRenamedCollector checks that a collector is still found by the name
+// of the field it was named after before the rename.
+func TestRenamedCollector(t *testing.T) {
+	if s := GetSnapshot(WithCollectors("go_runtime")); s.Agent.PID == 0 {
+		t.Error("WithCollectors(\"go_runtime\") did not run agent_runtime")
+	}
+	if s := GetSnapshot(WithCollectors("hostname", "agent_runtime"), WithoutCollectors("go_runtime")); s.Agent.PID != 0 {
+		t.Error("WithoutCollectors(\"go_runtime\") did not disable agent_runtime")
+	}
+	s := GetSnapshot(WithCollectors("hostname"))
+	if err := s.Refresh(context.Background(), "go_runtime"); err != nil || s.Agent.PID == 0 {
+		t.Errorf("Refresh(\"go_runtime\") = %v, PID %d", err, s.Agent.PID)
+	}
+}
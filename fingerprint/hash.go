@@ -0,0 +1,74 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Components returns the identity-relevant values of the snapshot keyed by
+// their JSON path. Volatile data such as the hostname or Docker daemon ID is
+// left out so that the derived hash survives renames and reinstalls, and DMI
+// placeholders shared by many boards are skipped.
+func (s Snapshot) Components() map[string]string {
+	c := map[string]string{}
+	add := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			c[k] = v
+		}
+	}
+	add("machine_id", s.MachineID)
+	addDMI := func(k, v string) {
+		if !IsPlaceholder(v) {
+			add(k, v)
+		}
+	}
+	addDMI("dmi.product_uuid", strings.ToLower(s.DMI.ProductUUID))
+	addDMI("dmi.board_serial", s.DMI.BoardSerial)
+	addDMI("dmi.chassis_asset_tag", s.DMI.ChassisAssetTag)
+	add("cpu.model", s.CPU.Model)
+	if s.Memory.MemTotalKB > 0 {
+		add("memory.mem_total_kb", strconv.FormatUint(s.Memory.MemTotalKB, 10))
+	}
+	var macs []string
+	for _, n := range s.Network {
+		macs = append(macs, strings.ToLower(n.MAC))
+	}
+	sort.Strings(macs)
+	add("network.mac", strings.Join(macs, ","))
+	add("rootfs.uuid", strings.ToLower(s.RootFS.UUID))
+	return c
+}
+
+// Hash returns the hex-encoded SHA-256 digest of the canonical component list.
+func (s Snapshot) Hash() string {
+	return hashComponents(s.Components())
+}
+
+// ID returns a stable device identifier derived from Hash, formatted as an
+// RFC 9562 version 8 UUID so that it fits UUID columns of inventories.
+func (s Snapshot) ID() string {
+	b, _ := hex.DecodeString(s.Hash())
+	b[6] = b[6]&0x0f | 0x80
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func hashComponents(c map[string]string) string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{'='})
+		h.Write([]byte(c[k]))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
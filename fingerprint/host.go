@@ -0,0 +1,336 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"AurFingerprintAgent/fdcap"
+	"AurFingerprintAgent/retry"
+)
+
+// host resolves the absolute system paths collectors read against the
+// system being fingerprinted: the live system, a directory standing for /
+// (WithRootPrefix) or an fs.FS (WithFS). The zero value reads the live
+// system.
+type host struct {
+	root string
+	fsys fs.FS
+	// hostPID marks a root holding the procfs of the host's PID
+	// namespace, whose /proc/self is the agent rather than the host.
+	hostPID bool
+	// errs, when set, records failed reads for Snapshot.Errors. Missing
+	// files are only recorded in strict mode, since most reads probe
+	// paths that may legitimately be absent.
+	errs   *readErrors
+	strict bool
+	// sources, when set, records what was read for WithProvenance.
+	sources *sourceLog
+	// rec, when set, keeps the raw files read for WithRecorder.
+	rec *Recorder
+	// log, when set, receives a debug record per access.
+	log      *slog.Logger
+	timeouts Timeouts
+	retries  map[string]retry.Policy
+	// dockerEndpoint overrides the daemon socket under the root.
+	dockerEndpoint string
+}
+
+// ReadLinkFS is implemented by file systems passed to WithFS that can read
+// symbolic links, which some collectors follow in /sys and /dev. It matches
+// fs.ReadLinkFS of newer Go releases.
+type ReadLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// expect returns h in strict mode, for reads of files every system should
+// have.
+func (h host) expect() host {
+	h.strict = true
+	return h
+}
+
+// retry returns the retry policy of probe.
+func (h host) retry(probe string) retry.Policy {
+	if p, ok := h.retries[probe]; ok {
+		return p
+	}
+	return retry.Once
+}
+
+// debug logs an access to the system.
+func (h host) debug(msg string, args ...any) {
+	if h.log != nil {
+		h.log.Debug(msg, args...)
+	}
+}
+
+// trace logs a file operation on p.
+func (h host) trace(op, p string, err error) {
+	if err == nil && op != "stat" {
+		h.source("file", p)
+	}
+	if h.log == nil {
+		return
+	}
+	args := []any{"path", p}
+	if h.fsys == nil && h.root != "" {
+		args = append(args, "os_path", h.path(p))
+	}
+	if err != nil {
+		args = append(args, "err", err)
+	}
+	h.log.Debug(op, args...)
+}
+
+func (h host) note(p string, err error) {
+	if err == nil || h.errs == nil || (!h.strict && errors.Is(err, fs.ErrNotExist)) {
+		return
+	}
+	h.errs.add(p, err)
+}
+
+// notePermission records err only when it denies access, for probes whose
+// other failures are expected, such as ioctls a device does not support.
+func (h host) notePermission(p string, err error) {
+	if errors.Is(err, fs.ErrPermission) {
+		h.note(p, err)
+	}
+}
+
+// live reports whether the system is reachable beyond its files, so that
+// external commands, ioctls, sockets and namespaces can be used.
+func (h host) live() bool { return h.fsys == nil }
+
+// procSelf returns the procfs directory of a process in the host's mount
+// and network namespaces: init for WithHostRoot, else the agent.
+func (h host) procSelf() string {
+	if h.hostPID {
+		return "/proc/1"
+	}
+	return "/proc/self"
+}
+
+// native reports whether h is the system the agent runs on, which tools
+// that cannot be pointed at another root, such as cloud-init or rtnetlink,
+// require.
+func (h host) native() bool { return h.fsys == nil && h.root == "" }
+
+// path maps an absolute system path to the OS path to open. It is only
+// meaningful on a live host.
+func (h host) path(p string) string {
+	if h.root == "" {
+		return p
+	}
+	return filepath.Join(h.root, p)
+}
+
+// name maps an absolute system path to an fs.FS name.
+func fsName(p string) string {
+	if n := strings.TrimPrefix(path.Clean(p), "/"); n != "" {
+		return n
+	}
+	return "."
+}
+
+// rel maps a path opened under the root back to the system path.
+func (h host) rel(p string) string {
+	if h.root == "" {
+		return p
+	}
+	if r, ok := strings.CutPrefix(p, filepath.Clean(h.root)); ok && strings.HasPrefix(r, "/") {
+		return r
+	}
+	return p
+}
+
+func (h host) readFile(p string) ([]byte, error) {
+	var b []byte
+	var err error
+	if h.fsys != nil {
+		b, err = fs.ReadFile(h.fsys, fsName(p))
+	} else {
+		b, err = h.osReadFile(h.path(p))
+		if err == nil && h.recording() {
+			h.rec.file(p, b)
+		}
+	}
+	h.trace("read", p, err)
+	h.note(p, err)
+	return b, err
+}
+
+func (h host) open(p string) (fs.File, error) {
+	var f fs.File
+	var err error
+	if h.fsys != nil {
+		f, err = h.fsys.Open(fsName(p))
+	} else {
+		var of *fdcap.File
+		if of, err = h.osOpen(h.path(p)); err == nil {
+			f = of
+			if h.recording() {
+				f = &recFile{File: of, p: p, r: h.rec}
+			}
+		}
+	}
+	h.trace("open", p, err)
+	h.note(p, err)
+	return f, err
+}
+
+func (h host) readDir(p string) ([]fs.DirEntry, error) {
+	var e []fs.DirEntry
+	var err error
+	if h.fsys != nil {
+		e, err = fs.ReadDir(h.fsys, fsName(p))
+	} else {
+		e, err = h.osReadDir(h.path(p))
+		if err == nil && h.recording() {
+			h.rec.dir(p, e, func(c string) (string, error) { return os.Readlink(h.path(c)) })
+		}
+	}
+	h.trace("readdir", p, err)
+	h.note(p, err)
+	return e, err
+}
+
+func (h host) readlink(p string) (string, error) {
+	l, err := h.rawReadlink(p)
+	h.note(p, err)
+	return l, err
+}
+
+func (h host) rawReadlink(p string) (l string, err error) {
+	defer func() { h.trace("readlink", p, err) }()
+	switch fsys := h.fsys.(type) {
+	case nil:
+		if l, err = os.Readlink(h.path(p)); err == nil && h.recording() {
+			h.rec.link(p, l)
+		}
+		return l, err
+	case ReadLinkFS:
+		return fsys.ReadLink(fsName(p))
+	}
+	return "", &fs.PathError{Op: "readlink", Path: p, Err: errors.ErrUnsupported}
+}
+
+// stat is a probe and records nothing.
+func (h host) stat(p string) (fi fs.FileInfo, err error) {
+	defer func() { h.trace("stat", p, err) }()
+	if h.fsys != nil {
+		return fs.Stat(h.fsys, fsName(p))
+	}
+	if fi, err = os.Stat(h.path(p)); err == nil && h.recording() {
+		h.rec.exists(p, fi.IsDir())
+	}
+	return fi, err
+}
+
+// command prepares an external command, logging it.
+func (h host) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	h.debug("exec", "cmd", cmd.String())
+	h.source("command", name)
+	return cmd
+}
+
+// output runs an external command and returns its standard output.
+func (h host) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if d := h.timeouts.Commands[name]; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	out, err := h.command(ctx, name, args...).Output()
+	if err != nil {
+		h.debug("exec failed", "cmd", name, "err", err)
+	}
+	return out, err
+}
+
+// glob returns the system paths matching pattern.
+func (h host) glob(pattern string) []string {
+	if h.fsys != nil {
+		m, _ := fs.Glob(h.fsys, fsName(pattern))
+		for i := range m {
+			m[i] = "/" + m[i]
+		}
+		return m
+	}
+	m, _ := filepath.Glob(h.path(pattern))
+	for i := range m {
+		m[i] = h.rel(m[i])
+		if h.recording() {
+			if fi, err := os.Stat(h.path(m[i])); err == nil {
+				h.rec.exists(m[i], fi.IsDir())
+			}
+		}
+	}
+	return m
+}
+
+func (h host) readTrim(p string) string {
+	b, err := h.readFile(p)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func (h host) readable(p string) bool {
+	_, err := h.stat(p)
+	return err == nil
+}
+
+// resolveLink follows the symlinks of p, returning "" when it does not
+// resolve. On a live host, absolute links are followed on the live system.
+func (h host) resolveLink(p string) string {
+	if h.fsys == nil {
+		r, err := filepath.EvalSymlinks(h.path(p))
+		if err != nil {
+			return ""
+		}
+		h.recordLink(p, h.rel(r))
+		return h.rel(r)
+	}
+	// Resolve component by component within the file system.
+	todo := strings.Split(fsName(p), "/")
+	resolved := "/"
+	for hops := 0; len(todo) > 0; {
+		c := todo[0]
+		todo = todo[1:]
+		switch c {
+		case ".", "":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, c)
+		l, err := h.rawReadlink(next)
+		if err != nil {
+			// Not a link, or the file system cannot tell.
+			if !h.readable(next) {
+				return ""
+			}
+			resolved = next
+			continue
+		}
+		if hops++; hops > 40 {
+			return ""
+		}
+		if path.IsAbs(l) {
+			resolved = "/"
+		}
+		todo = append(strings.Split(l, "/"), todo...)
+	}
+	return resolved
+}
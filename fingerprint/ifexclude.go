@@ -0,0 +1,61 @@
+package fingerprint
+
+import "path"
+
+// DefaultInterfaceExclude matches the per-pod and overlay interfaces that
+// container networking plugins create by the hundred on Kubernetes nodes.
+// Their MACs are ephemeral, so they would only bloat the snapshot and
+// destabilize the hash.
+var DefaultInterfaceExclude = []string{
+	"veth*",
+	"cali*",
+	"flannel*",
+	"cni*",
+	"cilium_*",
+	"lxc*",
+	"vxlan.calico",
+	"tunl*",
+	"weave*",
+	"kube-ipvs*",
+	"nodelocaldns",
+	"genev_sys_*",
+}
+
+// ExcludedInterfaces summarizes the interfaces left out of Network.
+type ExcludedInterfaces struct {
+	Count int `json:"count"`
+	// ByPattern counts the exclusions per matching pattern.
+	ByPattern map[string]int `json:"by_pattern,omitempty"`
+}
+
+// WithInterfaceExclude replaces DefaultInterfaceExclude with patterns in
+// path.Match syntax. Call it without arguments to keep every interface.
+func WithInterfaceExclude(patterns ...string) Option {
+	return func(o *options) { o.ifExclude = patterns }
+}
+
+// excludeInterfaces splits ifs into kept interfaces and a summary of those
+// matching patterns.
+func excludeInterfaces(ifs []NetIf, patterns []string) ([]NetIf, *ExcludedInterfaces) {
+	var kept []NetIf
+	var ex *ExcludedInterfaces
+	for _, n := range ifs {
+		pat := ""
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, n.Name); ok {
+				pat = p
+				break
+			}
+		}
+		if pat == "" {
+			kept = append(kept, n)
+			continue
+		}
+		if ex == nil {
+			ex = &ExcludedInterfaces{ByPattern: map[string]int{}}
+		}
+		ex.Count++
+		ex.ByPattern[pat]++
+	}
+	return kept, ex
+}
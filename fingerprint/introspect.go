@@ -0,0 +1,225 @@
+package fingerprint
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// CollectorInfo describes what a built-in collector reads, for allow-lists
+// and security reviews. Paths are absolute system paths before
+// WithRootPrefix or WithHostRoot and may hold glob patterns.
+type CollectorInfo struct {
+	Name string `json:"name"`
+	// Fields lists the top-level snapshot fields the collector sets.
+	Fields []string `json:"fields,omitempty"`
+	// Option is the option enabling an opt-in collector; empty when the
+	// collector runs by default.
+	Option   string   `json:"option,omitempty"`
+	Paths    []string `json:"paths,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	// Sockets lists the sockets and kernel interfaces beyond plain files,
+	// e.g. "netlink:RTM_GETRULE", in the notation of Provenance.Sources.
+	Sockets []string `json:"sockets,omitempty"`
+	// RequiresRoot reports that some values are readable only with root or
+	// the capabilities in Privileges; without them the collector still runs
+	// and records permission errors.
+	RequiresRoot bool     `json:"requires_root"`
+	Privileges   []string `json:"privileges,omitempty"`
+	// Network reports that the collector opens sockets, such as the Docker
+	// API, which may reach another host with WithDockerEndpoint.
+	Network bool `json:"network"`
+	// Requirements lists, per field, what reading it takes beyond an
+	// unprivileged user, for least-privilege deployment manifests.
+	Requirements []FieldRequirement `json:"field_requirements,omitempty"`
+}
+
+// FieldRequirement is what one source of a field needs. Root satisfies
+// every requirement but network egress.
+type FieldRequirement struct {
+	Field string `json:"field"`
+	// Path is the file, device or socket the field is read from; empty for
+	// network egress.
+	Path string `json:"path,omitempty"`
+	// Capabilities lists the capabilities any of which grants access.
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Access lists other grants: "root-read" for files only root may read,
+	// "docker-group" for membership in the group owning the Docker socket.
+	Access []string `json:"access,omitempty"`
+	// NetworkEgress reports that the field may be fetched from another
+	// host, e.g. a remote Docker daemon with WithDockerEndpoint.
+	NetworkEgress bool `json:"network_egress,omitempty"`
+}
+
+var dockerSockets = []string{"socket:/var/run/docker.sock", "socket:/run/docker.sock"}
+
+// egressFields are the fields of a collector that may need the network.
+var egressFields = map[string][]string{
+	"docker": {"docker.daemon_id"},
+	"meta":   {"meta.container"},
+}
+
+var collectorInfo = map[string]CollectorInfo{
+	"hostname":   {Paths: []string{"/etc/hostname", "/proc/sys/kernel/hostname"}},
+	"os":         {Paths: []string{"/etc/os-release", "/proc/sys/kernel/ostype", "/proc/sys/kernel/osrelease"}},
+	"machine_id": {Paths: []string{"/etc/machine-id"}},
+	"dmi": {Paths: []string{"/sys/class/dmi/id/product_uuid", "/sys/class/dmi/id/board_serial",
+		"/sys/class/dmi/id/chassis_asset_tag", "/sys/class/dmi/id/sys_vendor", "/sys/class/dmi/id/product_name",
+		"/sys/class/dmi/id/product_serial", "/sys/class/dmi/id/product_family", "/sys/class/dmi/id/board_name",
+		"/sys/class/dmi/id/board_vendor", "/sys/class/dmi/id/bios_vendor", "/sys/class/dmi/id/bios_version",
+		"/sys/class/dmi/id/bios_date", "/sys/class/dmi/id/chassis_type",
+		"/sys/firmware/dmi/tables/smbios_entry_point", "/sys/firmware/dmi/tables/DMI"}},
+	"cpu": {Paths: []string{"/proc/cpuinfo", "/sys/devices/system/cpu/online", "/sys/devices/system/cpu/cpu*/topology/*", "/sys/devices/system/cpu/cpu*/cache/index*/*",
+		"/sys/devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq", "/sys/module/kvm_amd/parameters/sev",
+		"/sys/module/kvm_intel/parameters/tdx", "/dev/sgx_enclave", "/dev/sgx/enclave", "/dev/isgx",
+		"/dev/tdx_guest", "/dev/sev-guest",
+		"/sys/firmware/dmi/tables/smbios_entry_point", "/sys/firmware/dmi/tables/DMI"}},
+	"memory": {Paths: []string{"/proc/meminfo", "/sys/devices/system/node/node*/*",
+		"/sys/devices/system/edac/mc/mc*/*", "/sys/devices/system/edac/mc/mc*/dimm*/*", "/sys/devices/system/edac/mc/mc*/csrow*/*"}},
+	"memory_modules": {Paths: []string{"/sys/firmware/dmi/tables/smbios_entry_point", "/sys/firmware/dmi/tables/DMI"}},
+	"network": {Paths: []string{"/sys/class/net/*/address", "/sys/class/net/*/ifindex",
+		"/sys/class/net/*/device", "/sys/class/net/*/master", "/sys/class/net/*/uevent"}},
+	"network_config": {Paths: []string{"/lib/netplan/*", "/etc/netplan/*", "/run/netplan/*",
+		"/etc/NetworkManager/system-connections/*", "/run/NetworkManager/system-connections/*",
+		"/etc/sysconfig/network-scripts/*", "/etc/sysconfig/network/*"}},
+	"netns": {Option: "WithNetNamespaces", Paths: []string{"/run/netns/*", "/proc/*/ns/net", "/proc/*/net/dev"},
+		Sockets: []string{"namespace:/run/netns/*"}},
+	"routing": {Paths: []string{"/proc/self/net/route", "/proc/self/net/ipv6_route"},
+		Sockets: []string{"netlink:RTM_GETROUTE", "netlink:RTM_GETRULE"}},
+	"neighbors": {Option: "WithNeighbors", Paths: []string{"/proc/self/net/arp", "/proc/self/net/route"}},
+	"dhcp": {Paths: []string{"/var/lib/dhcp/dhclient*.leases", "/var/lib/dhclient/*.lease*",
+		"/var/lib/NetworkManager/dhclient-*.lease", "/var/lib/NetworkManager/internal-*.lease",
+		"/run/systemd/netif/leases/*", "/sys/class/net/*/ifindex"}},
+	"ipv6": {Paths: []string{"/proc/sys/net/ipv6/conf/*", "/etc/NetworkManager/system-connections/*.nmconnection",
+		"/var/lib/dhcp/dhclient6*.leases", "/var/lib/dhclient/dhclient6*.lease*",
+		"/var/lib/NetworkManager/dhclient6-*.lease", "/var/lib/dhcpcd/duid", "/etc/dhcpcd.duid",
+		"/var/db/dhcpcd/duid", "/var/lib/dhcpv6/dhcp6c_duid"}},
+	"rootfs": {Paths: []string{"/proc/self/mountinfo", "/etc/mtab", "/dev/disk/by-uuid/*", "/run/udev/data/b*",
+		"/sys/block/dm-*/dm/uuid"},
+		Commands: []string{"blkid"}},
+	"block_devices": {Paths: []string{"/sys/block/*", "/sys/block/*/*/partition", "/sys/block/*/holders/*",
+		"/sys/block/*/*/holders/*", "/proc/self/mountinfo", "/run/udev/data/b*"}},
+	"nvme": {Paths: []string{"/sys/class/nvme/*/*", "/sys/class/nvme/*/nvme*n*/nsid", "/sys/class/nvme/*/nvme*n*/eui",
+		"/sys/class/nvme/*/nvme*n*/nguid", "/sys/class/nvme/*/nvme*n*/uuid"}},
+	"lvm": {Paths: []string{"/sys/block/dm-*/dm/*", "/sys/block/dm-*/slaves/*", "/run/udev/data/b*",
+		"/etc/lvm/backup/*", "/proc/self/mountinfo"}, Commands: []string{"pvs"}},
+	"drive_identity": {Option: "WithDriveIdentity", Paths: []string{"/sys/block/*/device/*", "/dev/sd*"},
+		Commands: []string{"smartctl"}, Sockets: []string{"ioctl:/dev/sd*"}},
+	"storage_health": {Option: "WithStorageHealth", Paths: []string{"/sys/block/*", "/dev/nvme*", "/dev/sd*"},
+		Sockets: []string{"ioctl:/dev/nvme*", "ioctl:/dev/sd*"}},
+	"docker": {Paths: []string{"/etc/docker/daemon.json", "/var/lib/docker/.docker_id",
+		"/var/lib/docker/.docker_uuid", "/var/snap/docker/common/var-lib-docker"},
+		Commands: []string{"docker"}, Sockets: dockerSockets, Network: true},
+	"firmware": {Paths: []string{"/sys/firmware/efi/efivars/*"}},
+	"boot": {Paths: []string{"/proc/cmdline", "/proc/1/exe", "/etc/systemd/system/default.target",
+		"/usr/lib/systemd/system/default.target", "/lib/systemd/system/default.target", "/boot/*"}},
+	"security": {Paths: []string{vulnerabilitiesDir + "/*"}},
+	"cloud": {Option: "WithCloudInit", Paths: []string{"/run/cloud-init/instance-data.json",
+		"/run/cloud-init/result.json", "/var/lib/cloud/data/instance-id",
+		"/var/lib/cloud/data/previous-instance-id", "/var/lib/cloud/instances/*", "/etc/machine-id"},
+		Commands: []string{"cloud-init"}},
+	"agent_runtime": {Paths: []string{"/proc/self/status", "/proc/self/cgroup", "/run/.containerenv", "/.dockerenv"}},
+	"labels":        {Option: "WithLabelsFile", Paths: []string{DefaultLabelsFile}},
+	"meta": {Paths: []string{"/run/.containerenv", "/proc/self/cgroup", "/proc/self/mountinfo", "/proc/self/exe"},
+		Sockets: dockerSockets, Network: true},
+	"plugins": {Option: "WithPlugins", Paths: []string{DefaultPluginDir + "/*"},
+		Commands: []string{DefaultPluginDir + "/*"}},
+	"packages": {Option: "WithPackages", Paths: []string{"/var/lib/dpkg/status", "/lib/apk/db/installed", "/var/lib/rpm"},
+		Commands: []string{"rpm"}},
+	"pci": {Option: "WithPCI", Paths: []string{"/sys/bus/pci/devices/*"}},
+	"usb": {Option: "WithUSB", Paths: []string{"/sys/bus/usb/devices/*"}},
+}
+
+// Collectors describes the built-in collectors in the order they run,
+// including opt-in ones. Collectors added with Register are not listed.
+func Collectors() []CollectorInfo {
+	out := make([]CollectorInfo, 0, len(builtins))
+	for _, b := range builtins {
+		c := collectorInfo[b.name]
+		c.Name = b.name
+		c.Fields = slices.Clone(sections[Section(b.name)].fields)
+		c.Paths, c.Commands, c.Sockets = slices.Clone(c.Paths), slices.Clone(c.Commands), slices.Clone(c.Sockets)
+		if b.name == "meta" {
+			c.Fields = []string{"meta.build", "meta.container", "meta.self_check"}
+		}
+		for _, p := range slices.Concat(c.Paths, socketPaths(c.Sockets)) {
+			for _, r := range privileges {
+				if ok, _ := path.Match(r.pattern, p); ok {
+					c.RequiresRoot = true
+					if !slices.Contains(c.Privileges, r.requires) {
+						c.Privileges = append(c.Privileges, r.requires)
+					}
+					c.Requirements = append(c.Requirements, fieldRequirements(c.Fields, p, r)...)
+					break
+				}
+			}
+		}
+		for _, f := range egressFields[b.name] {
+			c.Requirements = append(c.Requirements, FieldRequirement{Field: f, NetworkEgress: true})
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// ActiveCollectors describes the built-in collectors a collection with
+// opts runs, e.g. to derive a sandbox for one deployment of the agent.
+func ActiveCollectors(opts ...Option) []CollectorInfo {
+	o := buildOptions(opts)
+	active := map[string]bool{}
+	for _, c := range activeCollectors(&o) {
+		if _, ok := c.(builtin); ok {
+			active[c.Name()] = true
+		}
+	}
+	list := slices.DeleteFunc(Collectors(), func(c CollectorInfo) bool { return !active[c.Name] })
+	if o.partTables {
+		// WithPartitionTables widens block_devices to the raw disks.
+		for i := range list {
+			if list[i].Name == "block_devices" {
+				list[i].Paths = append(list[i].Paths, partitionTableDevices...)
+			}
+		}
+	}
+	return list
+}
+
+// partitionTableDevices are the disks WithPartitionTables reads.
+var partitionTableDevices = []string{"/dev/sd*", "/dev/nvme*n*", "/dev/vd*"}
+
+// fieldRequirements returns the requirements r places on the fields of a
+// collector setting fields when it reads p.
+func fieldRequirements(fields []string, p string, r privilege) []FieldRequirement {
+	var out []FieldRequirement
+	add := func(f string) {
+		out = append(out, FieldRequirement{Field: f, Path: p,
+			Capabilities: slices.Clone(r.caps), Access: slices.Clone(r.access)})
+	}
+	if r.fields == nil {
+		for _, f := range fields {
+			add(f)
+		}
+		return out
+	}
+	for _, f := range r.fields {
+		// The table names fields of several collectors, e.g. those read
+		// from the Docker socket.
+		top, _, _ := strings.Cut(f, ".")
+		if slices.ContainsFunc(fields, func(cf string) bool { return cf == f || cf == top }) {
+			add(f)
+		}
+	}
+	return out
+}
+
+// socketPaths returns the paths of the unix sockets in sockets, which the
+// privileges table lists without the kind prefix.
+func socketPaths(sockets []string) []string {
+	var out []string
+	for _, s := range sockets {
+		if p, ok := strings.CutPrefix(s, "socket:"); ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package fingerprint
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"iter"
+	"path/filepath"
+	"strings"
+)
+
+// Package is an installed software package.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+	Manager string `json:"manager"`
+}
+
+// PCIDevice is a device on the PCI bus. IDs are hexadecimal as in sysfs.
+type PCIDevice struct {
+	Address         string `json:"address"`
+	Vendor          string `json:"vendor"`
+	Device          string `json:"device"`
+	SubsystemVendor string `json:"subsystem_vendor,omitempty"`
+	SubsystemDevice string `json:"subsystem_device,omitempty"`
+	Class           string `json:"class,omitempty"`
+	Revision        string `json:"revision,omitempty"`
+	Driver          string `json:"driver,omitempty"`
+}
+
+// USBDevice is a device on the USB bus; interfaces are not listed.
+type USBDevice struct {
+	Path         string `json:"path"`
+	Vendor       string `json:"vendor"`
+	Product      string `json:"product"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	SpeedMbps    string `json:"speed_mbps,omitempty"`
+}
+
+// packages yields the packages known to dpkg, apk or rpm, reading the
+// databases incrementally so the full list is never held in memory.
+func (h host) packages(ctx context.Context) iter.Seq[Package] {
+	return func(yield func(Package) bool) {
+		if f, err := h.open("/var/lib/dpkg/status"); err == nil {
+			defer f.Close()
+			stanzas(f, func(m map[string]string) bool {
+				if !strings.HasSuffix(m["Status"], " installed") {
+					return true
+				}
+				return yield(Package{Name: m["Package"], Version: m["Version"], Arch: m["Architecture"], Manager: "dpkg"})
+			})
+			return
+		}
+		if f, err := h.open("/lib/apk/db/installed"); err == nil {
+			defer f.Close()
+			stanzas(f, func(m map[string]string) bool {
+				return yield(Package{Name: m["P"], Version: m["V"], Arch: m["A"], Manager: "apk"})
+			})
+			return
+		}
+		if !h.live() || !h.readable("/var/lib/rpm") {
+			return
+		}
+		args := []string{"-qa", "--qf", `%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\n`}
+		if h.root != "" {
+			args = append(args, "--root", h.root)
+		}
+		cmd := h.command(ctx, "rpm", args...)
+		out, err := cmd.StdoutPipe()
+		if err != nil || cmd.Start() != nil {
+			return
+		}
+		defer cmd.Wait()
+		sc := bufio.NewScanner(out)
+		for sc.Scan() {
+			f := strings.Split(sc.Text(), "\t")
+			if len(f) == 3 && !yield(Package{Name: f[0], Version: f[1], Arch: f[2], Manager: "rpm"}) {
+				cmd.Process.Kill()
+				return
+			}
+		}
+	}
+}
+
+// stanzas parses blank-line separated "Key: value" (dpkg) or "K:value"
+// (apk) records, ignoring continuation lines.
+func stanzas(r io.Reader, fn func(map[string]string) bool) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	m := map[string]string{}
+	for sc.Scan() {
+		ln := sc.Text()
+		if ln == "" {
+			if len(m) > 0 && !fn(m) {
+				return
+			}
+			m = map[string]string{}
+			continue
+		}
+		if ln[0] == ' ' || ln[0] == '\t' {
+			continue
+		}
+		if k, v, ok := strings.Cut(ln, ":"); ok {
+			m[k] = strings.TrimSpace(v)
+		}
+	}
+	if len(m) > 0 {
+		fn(m)
+	}
+}
+
+func (h host) pciDevices() iter.Seq[PCIDevice] {
+	return func(yield func(PCIDevice) bool) {
+		const base = "/sys/bus/pci/devices"
+		entries, _ := h.readDir(base)
+		for _, e := range entries {
+			dir := filepath.Join(base, e.Name())
+			id := func(f string) string { return strings.TrimPrefix(h.readTrim(filepath.Join(dir, f)), "0x") }
+			d := PCIDevice{
+				Address:         e.Name(),
+				Vendor:          id("vendor"),
+				Device:          id("device"),
+				SubsystemVendor: id("subsystem_vendor"),
+				SubsystemDevice: id("subsystem_device"),
+				Class:           id("class"),
+				Revision:        id("revision"),
+				Driver:          filepath.Base(h.resolveLink(filepath.Join(dir, "driver"))),
+			}
+			if d.Driver == "." {
+				d.Driver = ""
+			}
+			if !yield(d) {
+				return
+			}
+		}
+	}
+}
+
+func (h host) usbDevices() iter.Seq[USBDevice] {
+	return func(yield func(USBDevice) bool) {
+		const base = "/sys/bus/usb/devices"
+		entries, _ := h.readDir(base)
+		for _, e := range entries {
+			dir := filepath.Join(base, e.Name())
+			vendor := h.readTrim(filepath.Join(dir, "idVendor"))
+			if vendor == "" {
+				continue // interface, not a device
+			}
+			d := USBDevice{
+				Path:         e.Name(),
+				Vendor:       vendor,
+				Product:      h.readTrim(filepath.Join(dir, "idProduct")),
+				Manufacturer: h.readTrim(filepath.Join(dir, "manufacturer")),
+				Name:         h.readTrim(filepath.Join(dir, "product")),
+				Serial:       h.readTrim(filepath.Join(dir, "serial")),
+				SpeedMbps:    h.readTrim(filepath.Join(dir, "speed")),
+			}
+			if !yield(d) {
+				return
+			}
+		}
+	}
+}
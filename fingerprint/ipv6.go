@@ -0,0 +1,133 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IPv6Info holds the DHCPv6 client DUIDs and the interface identifier
+// generation mode of each interface.
+type IPv6Info struct {
+	DUIDs      []DUID   `json:"duids,omitempty"`
+	Interfaces []IPv6If `json:"interfaces,omitempty"`
+}
+
+// DUID is a DHCPv6 DUID persisted by a client.
+type DUID struct {
+	Source string `json:"source"`
+	// Type is LLT, EN, LL or UUID (RFC 8415, RFC 6355).
+	Type string `json:"type,omitempty"`
+	DUID string `json:"duid"`
+}
+
+// IPv6If describes how an interface derives its IPv6 addresses.
+type IPv6If struct {
+	Name string `json:"name"`
+	// AddrGenMode is the kernel's addr_gen_mode: eui64, none,
+	// stable-privacy or random.
+	AddrGenMode string `json:"addr_gen_mode,omitempty"`
+	// StablePrivacy reports RFC 7217 addresses generated by the kernel or
+	// by NetworkManager, which sets the kernel mode to none.
+	StablePrivacy bool `json:"stable_privacy"`
+	// TempAddr is use_tempaddr: 0 off, 1 generated, 2 preferred.
+	TempAddr int `json:"temp_addr"`
+}
+
+var addrGenModes = []string{"eui64", "none", "stable-privacy", "random"}
+
+var duidTypes = map[uint16]string{1: "LLT", 2: "EN", 3: "LL", 4: "UUID"}
+
+func (h host) ipv6(live []NetIf) *IPv6Info {
+	info := &IPv6Info{DUIDs: h.duids()}
+	nm := map[string]bool{}
+	for _, f := range h.glob("/etc/NetworkManager/system-connections/*.nmconnection") {
+		ini := h.readINI(f)
+		if ini["ipv6.addr-gen-mode"] == "stable-privacy" || ini["ipv6.addr-gen-mode"] == "1" {
+			nm[ini["connection.interface-name"]] = true
+		}
+	}
+	for _, n := range live {
+		dir := filepath.Join("/proc/sys/net/ipv6/conf", n.Name)
+		mode := h.readTrim(filepath.Join(dir, "addr_gen_mode"))
+		if mode == "" {
+			continue
+		}
+		it := IPv6If{Name: n.Name}
+		if m, err := strconv.Atoi(mode); err == nil && m >= 0 && m < len(addrGenModes) {
+			it.AddrGenMode = addrGenModes[m]
+		}
+		it.StablePrivacy = mode == "2" || mode == "3" || nm[n.Name]
+		it.TempAddr, _ = strconv.Atoi(h.readTrim(filepath.Join(dir, "use_tempaddr")))
+		info.Interfaces = append(info.Interfaces, it)
+	}
+	if len(info.DUIDs) == 0 && len(info.Interfaces) == 0 {
+		return nil
+	}
+	return info
+}
+
+// duids reads the DUIDs stored by dhclient, dhcpcd and wide-dhcpv6.
+// systemd-networkd derives its DUID from the machine ID and stores none.
+func (h host) duids() []DUID {
+	var out []DUID
+	add := func(src string, b []byte) {
+		if len(b) < 4 {
+			return
+		}
+		out = append(out, DUID{Source: src, Type: duidTypes[binary.BigEndian.Uint16(b)], DUID: colonHex(b)})
+	}
+	for _, pattern := range []string{
+		"/var/lib/dhcp/dhclient6*.leases",
+		"/var/lib/dhclient/dhclient6*.lease*",
+		"/var/lib/NetworkManager/dhclient6-*.lease",
+	} {
+		for _, f := range h.glob(pattern) {
+			add(f, h.dhclientDUID(f))
+		}
+	}
+	for _, f := range []string{"/var/lib/dhcpcd/duid", "/etc/dhcpcd.duid", "/var/db/dhcpcd/duid"} {
+		if v := h.readTrim(f); v != "" {
+			add(f, parseColonHex(v))
+		}
+	}
+	// wide-dhcpv6 stores a little-endian length followed by the DUID.
+	if b, err := h.readFile("/var/lib/dhcpv6/dhcp6c_duid"); err == nil && len(b) > 2 {
+		if n := int(binary.LittleEndian.Uint16(b)); n <= len(b)-2 {
+			add("/var/lib/dhcpv6/dhcp6c_duid", b[2:2+n])
+		}
+	}
+	return out
+}
+
+// dhclientDUID returns the default-duid statement of a dhclient6 lease
+// file, an octal-escaped string.
+func (h host) dhclientDUID(path string) []byte {
+	b, err := h.readFile(path)
+	if err != nil {
+		return nil
+	}
+	for _, ln := range strings.Split(string(b), "\n") {
+		v, ok := strings.CutPrefix(strings.TrimSpace(ln), "default-duid ")
+		if !ok {
+			continue
+		}
+		if s, err := strconv.Unquote(strings.TrimSuffix(v, ";")); err == nil {
+			return []byte(s)
+		}
+	}
+	return nil
+}
+
+func parseColonHex(s string) []byte {
+	var b []byte
+	for _, p := range strings.Split(s, ":") {
+		n, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return nil
+		}
+		b = append(b, byte(n))
+	}
+	return b
+}
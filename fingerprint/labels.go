@@ -0,0 +1,102 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// DefaultLabelsFile is where operators keep the labels of a machine.
+const DefaultLabelsFile = "/etc/linux-fingerprint/labels"
+
+// WithLabels attaches static labels, such as site, rack, owner or cost
+// center, to the snapshot's labels section, so that downstream systems need
+// no separate table to join them. Later labels override earlier ones and
+// those of WithLabelsFile. Labels are not hashed.
+func WithLabels(labels map[string]string) Option {
+	return func(o *options) {
+		o.labels = maps.Clone(o.labels)
+		if o.labels == nil {
+			o.labels = map[string]string{}
+		}
+		maps.Copy(o.labels, labels)
+	}
+}
+
+// WithLabelsFile reads labels from the agent's file at path, one key=value
+// per line in os-release syntax: values may be quoted, and blank lines and
+// lines starting with # are skipped. A missing file contributes nothing.
+func WithLabelsFile(path string) Option {
+	return func(o *options) { o.labelsFile = path }
+}
+
+func (h host) labels(path string, set map[string]string) map[string]string {
+	out := map[string]string{}
+	if path != "" {
+		var b []byte
+		err := fdcap.Default.Do(func() (err error) {
+			b, err = os.ReadFile(path)
+			return err
+		})
+		h.trace("read", path, err)
+		if err == nil {
+			err = parseLabels(b, out)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			h.note(path, err)
+		}
+	}
+	maps.Copy(out, set)
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// parseLabels adds the labels of b to m, stopping at the first malformed
+// line.
+func parseLabels(b []byte, m map[string]string) error {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if err := ValidLabelKey(k); !ok || err != nil {
+			return fmt.Errorf("line %d: %q is not key=value", n, line)
+		}
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			if u, err := strconv.Unquote(`"` + v[1:len(v)-1] + `"`); err == nil {
+				v = u
+			} else {
+				v = v[1 : len(v)-1]
+			}
+		}
+		m[k] = v
+	}
+	return sc.Err()
+}
+
+// ValidLabelKey reports why k cannot be a label key: keys are non-empty
+// and consist of letters, digits and the characters ._-/
+func ValidLabelKey(k string) error {
+	if k == "" {
+		return errors.New("empty label key")
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-/", r)) {
+			return fmt.Errorf("label key %q contains %q", k, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package fingerprint
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits caps the size of a snapshot so a pathological host, such as a busy
+// Kubernetes node with thousands of veth interfaces, cannot produce a
+// multi-megabyte document. A zero field disables that cap. Every cut is
+// recorded in Snapshot.Meta.Truncated.
+type Limits struct {
+	MaxInterfaces   int
+	MaxPackages     int
+	MaxStringLength int
+}
+
+// DefaultLimits apply unless overridden with WithLimits.
+var DefaultLimits = Limits{
+	MaxInterfaces:   256,
+	MaxPackages:     20000,
+	MaxStringLength: 4096,
+}
+
+// Truncation marks a list or string that was cut to its limit.
+type Truncation struct {
+	Field    string `json:"field"`
+	Limit    int    `json:"limit"`
+	Original int    `json:"original"`
+}
+
+// truncatedSuffix ends every shortened string.
+const truncatedSuffix = "…[truncated]"
+
+// WithLimits replaces DefaultLimits.
+func WithLimits(l Limits) Option {
+	return func(o *options) { o.limits = l }
+}
+
+// applyLimits enforces l on s and returns the cuts made.
+func (h host) applyLimits(s *Snapshot, l Limits) []Truncation {
+	var out []Truncation
+	if l.MaxInterfaces > 0 && len(s.Network) > l.MaxInterfaces {
+		// Keep physical NICs, which carry identity, ahead of virtual ones
+		// so the cut is deterministic and spares the hashed MACs.
+		sort.SliceStable(s.Network, func(i, j int) bool {
+			pi, pj := h.isPhysicalNIC(s.Network[i].Name), h.isPhysicalNIC(s.Network[j].Name)
+			if pi != pj {
+				return pi
+			}
+			return s.Network[i].Name < s.Network[j].Name
+		})
+		out = append(out, Truncation{"network", l.MaxInterfaces, len(s.Network)})
+		s.Network = s.Network[:l.MaxInterfaces]
+	}
+	if l.MaxPackages > 0 && len(s.Packages) > l.MaxPackages {
+		out = append(out, Truncation{"packages", l.MaxPackages, len(s.Packages)})
+		s.Packages = s.Packages[:l.MaxPackages]
+	}
+	if l.MaxStringLength > 0 {
+		truncateStrings(reflect.ValueOf(s).Elem(), "", l.MaxStringLength, &out)
+	}
+	return out
+}
+
+// truncateStrings shortens every string reachable from v, which must be
+// addressable, naming fields by their JSON path.
+func truncateStrings(v reflect.Value, path string, max int, out *[]Truncation) {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if s := v.String(); len(s) > max {
+			*out = append(*out, Truncation{path, max, len(s)})
+			v.SetString(truncateString(s, max))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			truncateStrings(v.Elem(), path, max, out)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			truncateStrings(v.Field(i), join(name), max, out)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			truncateStrings(v.Index(i), join(strconv.Itoa(i)), max, out)
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			if s := v.MapIndex(k).String(); len(s) > max {
+				*out = append(*out, Truncation{join(k.String()), max, len(s)})
+				v.SetMapIndex(k, reflect.ValueOf(truncateString(s, max)))
+			}
+		}
+	}
+}
+
+// truncateString cuts s to max bytes on a rune boundary and appends the
+// truncation marker.
+func truncateString(s string, max int) string {
+	for max > 0 && max < len(s) && s[max]&0xc0 == 0x80 {
+		max--
+	}
+	return s[:max] + truncatedSuffix
+}
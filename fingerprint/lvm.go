@@ -0,0 +1,217 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// VolumeGroup is an LVM volume group with active logical volumes. The
+// UUIDs are written when the volumes are created and, unlike the
+// /dev/mapper names and dm-N numbers, tell volume groups of the same name
+// apart.
+type VolumeGroup struct {
+	Name string           `json:"name"`
+	UUID string           `json:"uuid,omitempty"`
+	PVs  []PhysicalVolume `json:"pvs,omitempty"`
+	LVs  []LogicalVolume  `json:"lvs"`
+}
+
+// PhysicalVolume is a device of a volume group.
+type PhysicalVolume struct {
+	// Device is the kernel name, e.g. "nvme0n1p2".
+	Device string `json:"device"`
+	UUID   string `json:"uuid,omitempty"`
+}
+
+// LogicalVolume is an active logical volume.
+type LogicalVolume struct {
+	Name string `json:"name"`
+	UUID string `json:"uuid,omitempty"`
+	// Device is the device mapper node, e.g. "dm-0".
+	Device     string `json:"device"`
+	Mountpoint string `json:"mountpoint,omitempty"`
+}
+
+// volumeGroups lists the volume groups of the active logical volumes in
+// /sys/block/dm-*, whose device mapper UUIDs carry the VG and LV UUIDs.
+// PV UUIDs come from the udev database, else from the metadata backups in
+// /etc/lvm/backup (readable by root only), else from pvs.
+func (h host) volumeGroups(ctx context.Context) []VolumeGroup {
+	const base = "/sys/block"
+	entries, err := h.readDir(base)
+	if err != nil {
+		return nil
+	}
+	mounts := map[string]mountedFS{}
+	if f, err := h.open(h.procSelf() + "/mountinfo"); err == nil {
+		mounts = parseMountinfoDevices(f)
+		f.Close()
+	}
+	groups := map[string]*VolumeGroup{}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "dm-") {
+			continue
+		}
+		dir := path.Join(base, name)
+		vgUUID, lvUUID, ok := lvmDMUUID(h.readTrim(path.Join(dir, "dm/uuid")))
+		if !ok {
+			continue
+		}
+		vgName, lvName := splitDMName(h.readTrim(path.Join(dir, "dm/name")))
+		g := groups[vgName]
+		if g == nil {
+			g = &VolumeGroup{Name: vgName, UUID: vgUUID}
+			groups[vgName] = g
+		}
+		lv := LogicalVolume{Name: lvName, UUID: lvUUID, Device: name}
+		if m, ok := mounts[h.readTrim(path.Join(dir, "dev"))]; ok {
+			lv.Mountpoint = m.mountpoint
+		}
+		g.LVs = append(g.LVs, lv)
+		for _, pv := range h.lvmPVs(dir, vgUUID, 0) {
+			if !slices.ContainsFunc(g.PVs, func(p PhysicalVolume) bool { return p.Device == pv.Device }) {
+				g.PVs = append(g.PVs, pv)
+			}
+		}
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var pvs map[string]string
+	out := make([]VolumeGroup, 0, len(groups))
+	for _, g := range groups {
+		var backup *lvmBackup
+		for i := range g.PVs {
+			pv := &g.PVs[i]
+			if pv.UUID != "" {
+				continue
+			}
+			if backup == nil {
+				if backup = h.lvmBackup(g.Name); backup == nil {
+					backup = &lvmBackup{}
+				}
+			}
+			if backup.UUID == g.UUID {
+				pv.UUID = backup.PVs["/dev/"+pv.Device]
+			}
+			if pv.UUID == "" && h.live() && h.native() {
+				if pvs == nil {
+					pvs = h.pvsUUIDs(ctx)
+				}
+				pv.UUID = pvs[pv.Device]
+			}
+		}
+		sort.Slice(g.PVs, func(i, j int) bool { return g.PVs[i].Device < g.PVs[j].Device })
+		sort.Slice(g.LVs, func(i, j int) bool { return g.LVs[i].Name < g.LVs[j].Name })
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// lvmPVs follows the slaves of the device mapper node at dir down to the
+// physical volumes, through the internal layers of thin pools, caches and
+// RAID volumes of the same volume group.
+func (h host) lvmPVs(dir, vgUUID string, depth int) []PhysicalVolume {
+	slaves, _ := h.readDir(path.Join(dir, "slaves"))
+	var out []PhysicalVolume
+	for _, s := range slaves {
+		sdir := path.Join(dir, "slaves", s.Name())
+		raw, _ := strings.CutPrefix(h.readTrim(path.Join(sdir, "dm/uuid")), "LVM-")
+		if depth < 8 && len(raw) >= 32 && lvmUUID(raw[:32]) == vgUUID {
+			out = append(out, h.lvmPVs(path.Join("/sys/block", s.Name()), vgUUID, depth+1)...)
+			continue
+		}
+		pv := PhysicalVolume{Device: s.Name()}
+		if p := h.udevProperties(h.readTrim(path.Join(sdir, "dev"))); p["ID_FS_TYPE"] == "LVM2_member" {
+			pv.UUID = p["ID_FS_UUID"]
+		}
+		out = append(out, pv)
+	}
+	return out
+}
+
+func (h host) lvmBackup(vg string) *lvmBackup {
+	if vg == "" || strings.Contains(vg, "/") {
+		return nil
+	}
+	f, err := h.open("/etc/lvm/backup/" + vg)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return parseLVMBackup(f)
+}
+
+// pvsUUIDs asks LVM for the UUIDs of all physical volumes by kernel name.
+func (h host) pvsUUIDs(ctx context.Context) map[string]string {
+	out, err := h.output(ctx, "pvs", "--reportformat", "json", "-o", "pv_name,pv_uuid")
+	if err != nil {
+		return map[string]string{}
+	}
+	var v struct {
+		Report []struct {
+			PV []struct {
+				Name string `json:"pv_name"`
+				UUID string `json:"pv_uuid"`
+			} `json:"pv"`
+		} `json:"report"`
+	}
+	m := map[string]string{}
+	if json.Unmarshal(out, &v) == nil {
+		for _, r := range v.Report {
+			for _, pv := range r.PV {
+				if dev := h.resolveLink(pv.Name); dev != "" {
+					m[path.Base(dev)] = pv.UUID
+				} else {
+					m[strings.TrimPrefix(pv.Name, "/dev/")] = pv.UUID
+				}
+			}
+		}
+	}
+	return m
+}
+
+// rootDM returns the device mapper node the root file system is mounted
+// from and, for an LVM logical volume, its LV UUID.
+func (h host) rootDM(devnum string) (name, lvUUID string) {
+	if devnum == "" {
+		return "", ""
+	}
+	entries, err := h.readDir("/sys/block")
+	if err != nil {
+		return "", ""
+	}
+	for _, e := range entries {
+		dir := path.Join("/sys/block", e.Name())
+		if !strings.HasPrefix(e.Name(), "dm-") || h.readTrim(path.Join(dir, "dev")) != devnum {
+			continue
+		}
+		_, lv, _ := lvmDMUUID(h.readTrim(path.Join(dir, "dm/uuid")))
+		return e.Name(), lv
+	}
+	return "", ""
+}
+
+// rootDevnum returns the "major:minor" of the root file system.
+func (h host) rootDevnum() string {
+	lax := h
+	lax.strict = false
+	f, err := lax.open(h.procSelf() + "/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	for dev, m := range parseMountinfoDevices(f) {
+		if m.mountpoint == "/" {
+			return dev
+		}
+	}
+	return ""
+}
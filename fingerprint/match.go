@@ -0,0 +1,99 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// Tolerance describes how strictly two fingerprints must agree. Hardware
+// changes over a machine's life (a replaced NIC, a new disk), so callers may
+// allow a number of components to differ while pinning the ones they trust.
+type Tolerance struct {
+	// MaxMismatches is the number of components allowed to differ.
+	MaxMismatches int `json:"max_mismatches"`
+	// Required lists components that must always match, e.g. "machine_id".
+	Required []string `json:"required,omitempty"`
+}
+
+// ComponentDigests returns the SHA-256 digest of every identity component so
+// fingerprints can be stored and compared without retaining raw serials.
+func (s Snapshot) ComponentDigests() map[string]string {
+	out := map[string]string{}
+	for k, v := range s.Components() {
+		sum := sha256.Sum256([]byte(v))
+		out[k] = hex.EncodeToString(sum[:])
+	}
+	return out
+}
+
+// MatchDigests compares reference component digests with the current ones
+// and returns the sorted names of components that differ. A component is
+// considered different when it is missing on either side. ok reports whether
+// the difference stays within tol.
+func MatchDigests(want, got map[string]string, tol Tolerance) (mismatched []string, ok bool) {
+	seen := map[string]struct{}{}
+	for k, v := range want {
+		seen[k] = struct{}{}
+		if got[k] != v {
+			mismatched = append(mismatched, k)
+		}
+	}
+	for k := range got {
+		if _, dup := seen[k]; !dup {
+			mismatched = append(mismatched, k)
+		}
+	}
+	sort.Strings(mismatched)
+	if len(mismatched) > tol.MaxMismatches {
+		return mismatched, false
+	}
+	for _, r := range tol.Required {
+		if i := sort.SearchStrings(mismatched, r); i < len(mismatched) && mismatched[i] == r {
+			return mismatched, false
+		}
+	}
+	return mismatched, true
+}
+
+// Severity grades how far a fingerprint has drifted from a stored one.
+type Severity string
+
+const (
+	// SeverityNone means all components match.
+	SeverityNone Severity = "none"
+	// SeverityMinor means some components differ, within the tolerance.
+	SeverityMinor Severity = "minor"
+	// SeverityMajor means more components differ than tolerated.
+	SeverityMajor Severity = "major"
+	// SeverityCritical means a required component differs.
+	SeverityCritical Severity = "critical"
+)
+
+// Divergence is the outcome of comparing a stored fingerprint with the
+// live one.
+type Divergence struct {
+	Severity   Severity `json:"severity"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	// Within reports whether the difference stays within the tolerance.
+	Within bool `json:"within_tolerance"`
+}
+
+// Diverge compares reference component digests with the current ones like
+// MatchDigests and grades the difference.
+func Diverge(want, got map[string]string, tol Tolerance) Divergence {
+	mismatched, ok := MatchDigests(want, got, tol)
+	d := Divergence{Severity: SeverityNone, Mismatched: mismatched, Within: ok}
+	switch {
+	case ok && len(mismatched) > 0:
+		d.Severity = SeverityMinor
+	case !ok:
+		d.Severity = SeverityMajor
+		for _, r := range tol.Required {
+			if i := sort.SearchStrings(mismatched, r); i < len(mismatched) && mismatched[i] == r {
+				d.Severity = SeverityCritical
+			}
+		}
+	}
+	return d
+}
@@ -0,0 +1,156 @@
+package fingerprint
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+	"AurFingerprintAgent/tlog"
+)
+
+// Meta describes the agent that produced the snapshot rather than the host.
+type Meta struct {
+	Build     *BuildInfo      `json:"build,omitempty"`
+	Container *AgentContainer `json:"container,omitempty"`
+	SelfCheck *SelfCheck      `json:"self_check,omitempty"`
+	// Skipped lists collectors that did not contribute: left out to honor
+	// WithBudget, cancelled, or failed.
+	Skipped []Skipped `json:"skipped,omitempty"`
+	// Truncated lists data cut to honor Limits.
+	Truncated []Truncation `json:"truncated,omitempty"`
+	// Readiness is the wait of WithWaitForReady.
+	Readiness *Readiness `json:"readiness,omitempty"`
+	// Transparency is the entry recording the snapshot in a transparency
+	// log, set by the agent that submitted it.
+	Transparency *tlog.Entry `json:"transparency,omitempty"`
+}
+
+// AgentContainer is the provenance of the agent when it runs inside a
+// container, letting servers check they talk to a blessed build.
+type AgentContainer struct {
+	Runtime     string `json:"runtime,omitempty"`
+	ID          string `json:"id,omitempty"`
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+	CgroupPath  string `json:"cgroup_path,omitempty"`
+}
+
+// Environment variables a deployment can set when the runtime does not
+// expose the image to the container, e.g. from a Kubernetes manifest.
+const (
+	envImage       = "LSF_IMAGE"
+	envImageDigest = "LSF_IMAGE_DIGEST"
+)
+
+var containerIDRe = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// agentContainer returns nil when the agent does not run in a container.
+// The agent's own files are read from the live system; h locates the
+// container runtime.
+func (h host) agentContainer(ctx context.Context) *AgentContainer {
+	c := &AgentContainer{CgroupPath: selfCgroup()}
+	if c.Runtime = containerRuntime(c.CgroupPath); c.Runtime == "" {
+		return nil
+	}
+	if c.Runtime == "podman" {
+		env := host{}.readShellVars("/run/.containerenv")
+		c.ID, c.Image, c.ImageDigest = env["id"], env["image"], env["imageid"]
+	}
+	if c.ID == "" {
+		c.ID = containerIDRe.FindString(c.CgroupPath)
+	}
+	if c.ID == "" {
+		c.ID = mountinfoContainerID()
+	}
+	if c.Runtime == "docker" && c.ID != "" {
+		c.Image, c.ImageDigest = h.containerImage(ctx, c.ID)
+	}
+	if v := os.Getenv(envImage); v != "" {
+		c.Image = v
+	}
+	if v := os.Getenv(envImageDigest); v != "" {
+		c.ImageDigest = v
+	}
+	return c
+}
+
+// containerRuntime names the container runtime the agent runs under, or
+// returns "" outside a container. cgroup is the agent's cgroup path.
+func containerRuntime(cgroup string) string {
+	var self host
+	switch {
+	case self.readable("/run/.containerenv"):
+		return "podman"
+	case self.readable("/.dockerenv"):
+		return "docker"
+	case strings.Contains(cgroup, "kubepods"):
+		return "kubernetes"
+	}
+	return os.Getenv("container")
+}
+
+// containerImage asks the Docker daemon for the image reference and digest
+// of container id.
+func (h host) containerImage(ctx context.Context, id string) (ref, digest string) {
+	dc := h.dockerClient()
+	if dc == nil {
+		return "", ""
+	}
+	ctx, cancel := defaultTimeout(ctx, 2*time.Second)
+	defer cancel()
+	v, err := dc.Container(ctx, id)
+	h.debug("docker request", "endpoint", dc.Endpoint, "path", "/containers/"+id+"/json", "err", err)
+	if err != nil {
+		return "", ""
+	}
+	h.source("socket", dc.Endpoint)
+	return v.Config.Image, v.Image
+}
+
+// selfCgroup returns the unified (v2) cgroup path of the agent, or the
+// first non-root v1 path.
+func selfCgroup() string {
+	f, err := fdcap.Default.Open("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var v1 string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), ":", 3)
+		if len(parts) != 3 || parts[2] == "/" {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if v1 == "" {
+			v1 = parts[2]
+		}
+	}
+	return v1
+}
+
+// mountinfoContainerID finds the container ID in the bind mounts Docker and
+// containerd set up for /etc/hostname and friends; it works when a private
+// cgroup namespace hides the path.
+func mountinfoContainerID() string {
+	b, err := host{}.readFile("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	for _, ln := range strings.Split(string(b), "\n") {
+		if !strings.Contains(ln, "/containers/") && !strings.Contains(ln, "/sandboxes/") {
+			continue
+		}
+		if id := containerIDRe.FindString(ln); id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package fingerprint
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NeighborInfo samples the IPv4 neighbor (ARP) table to help locate a
+// machine on a flat network. It is volatile and never part of the hash.
+type NeighborInfo struct {
+	// Gateways are the default gateways with their resolved MACs.
+	Gateways []Neighbor `json:"gateways,omitempty"`
+	// Count is the number of resolved neighbors.
+	Count       int            `json:"count"`
+	ByInterface map[string]int `json:"by_interface,omitempty"`
+}
+
+// Neighbor is an entry of the neighbor table.
+type Neighbor struct {
+	Interface string `json:"interface"`
+	IP        string `json:"ip"`
+	MAC       string `json:"mac,omitempty"`
+}
+
+// ARP entry flags.
+const (
+	atfComplete = 0x2
+	rtfGateway  = 0x2
+)
+
+func (h host) neighbors() *NeighborInfo {
+	f, err := h.open(h.procSelf() + "/net/arp")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	macs := map[string]string{}
+	n := &NeighborInfo{ByInterface: map[string]int{}}
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		c := strings.Fields(sc.Text())
+		if len(c) < 6 {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimPrefix(c[2], "0x"), 16, 32)
+		if err != nil || flags&atfComplete == 0 {
+			continue
+		}
+		macs[c[5]+" "+c[0]] = c[3]
+		n.Count++
+		n.ByInterface[c[5]]++
+	}
+	for _, gw := range h.defaultGateways() {
+		gw.MAC = macs[gw.Interface+" "+gw.IP]
+		n.Gateways = append(n.Gateways, gw)
+	}
+	if len(n.ByInterface) == 0 {
+		n.ByInterface = nil
+	}
+	return n
+}
+
+// defaultGateways returns the IPv4 default routes of the main table.
+func (h host) defaultGateways() []Neighbor {
+	f, err := h.open(h.procSelf() + "/net/route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []Neighbor
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		// Iface Destination Gateway Flags ...
+		c := strings.Fields(sc.Text())
+		if len(c) < 4 || c[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(c[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		b, err := hex.DecodeString(c[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		// The kernel prints the address as a host byte order integer.
+		ip := make(net.IP, 4)
+		binary.NativeEndian.PutUint32(ip, binary.BigEndian.Uint32(b))
+		out = append(out, Neighbor{Interface: c[0], IP: ip.String()})
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package fingerprint
+
+import (
+	"bufio"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NetConfigInfo reconciles interfaces declared in netplan, NetworkManager
+// and ifcfg configuration with the interfaces present on the host.
+type NetConfigInfo struct {
+	Configured []ConfiguredIf `json:"configured,omitempty"`
+	// Missing lists configured interfaces with no matching live NIC.
+	Missing []string `json:"missing,omitempty"`
+	// Unknown lists physical NICs not covered by any configuration.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// ConfiguredIf is an interface declared in a network configuration file.
+type ConfiguredIf struct {
+	Name   string `json:"name,omitempty"`
+	MAC    string `json:"mac,omitempty"`
+	Source string `json:"source"`
+}
+
+func (h host) netConfig(live []NetIf) *NetConfigInfo {
+	var cfg []ConfiguredIf
+	cfg = append(cfg, h.netplanIfaces()...)
+	cfg = append(cfg, h.nmIfaces()...)
+	cfg = append(cfg, h.ifcfgIfaces()...)
+	if len(cfg) == 0 {
+		return nil
+	}
+	info := &NetConfigInfo{Configured: cfg}
+	matched := map[string]bool{}
+	for _, c := range cfg {
+		found := false
+		for _, l := range live {
+			if (c.Name != "" && c.Name == l.Name) || (c.MAC != "" && strings.EqualFold(c.MAC, l.MAC)) {
+				matched[l.Name] = true
+				found = true
+			}
+		}
+		if !found {
+			id := c.Name
+			if id == "" {
+				id = c.MAC
+			}
+			info.Missing = append(info.Missing, id)
+		}
+	}
+	for _, l := range live {
+		if !matched[l.Name] && h.isPhysicalNIC(l.Name) {
+			info.Unknown = append(info.Unknown, l.Name)
+		}
+	}
+	sort.Strings(info.Missing)
+	sort.Strings(info.Unknown)
+	return info
+}
+
+func (h host) isPhysicalNIC(name string) bool {
+	return h.readable(filepath.Join("/sys/class/net", name, "device"))
+}
+
+func (h host) netplanIfaces() []ConfiguredIf {
+	type match struct {
+		Name       string `yaml:"name"`
+		MACAddress string `yaml:"macaddress"`
+	}
+	type iface struct {
+		Match      match  `yaml:"match"`
+		SetName    string `yaml:"set-name"`
+		MACAddress string `yaml:"macaddress"`
+	}
+	var doc struct {
+		Network struct {
+			Ethernets map[string]iface `yaml:"ethernets"`
+			Wifis     map[string]iface `yaml:"wifis"`
+		} `yaml:"network"`
+	}
+	var out []ConfiguredIf
+	for _, dir := range []string{"/lib/netplan", "/etc/netplan", "/run/netplan"} {
+		for _, f := range h.glob(filepath.Join(dir, "*.yaml")) {
+			b, err := h.readFile(f)
+			if err != nil {
+				continue
+			}
+			doc.Network.Ethernets, doc.Network.Wifis = nil, nil
+			if yaml.Unmarshal(b, &doc) != nil {
+				continue
+			}
+			for _, set := range []map[string]iface{doc.Network.Ethernets, doc.Network.Wifis} {
+				// Map order is random; keep the output stable.
+				for _, id := range slices.Sorted(maps.Keys(set)) {
+					it := set[id]
+					c := ConfiguredIf{Name: id, MAC: it.Match.MACAddress, Source: f}
+					switch {
+					case it.SetName != "":
+						c.Name = it.SetName
+					case it.Match.Name != "" && !strings.ContainsAny(it.Match.Name, "*?["):
+						c.Name = it.Match.Name
+					case it.Match.Name != "" || it.Match.MACAddress != "":
+						c.Name = ""
+					}
+					if c.MAC == "" {
+						c.MAC = it.MACAddress
+					}
+					if c.Name != "" || c.MAC != "" {
+						out = append(out, c)
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (h host) nmIfaces() []ConfiguredIf {
+	var out []ConfiguredIf
+	for _, dir := range []string{"/etc/NetworkManager/system-connections", "/run/NetworkManager/system-connections"} {
+		for _, f := range h.glob(filepath.Join(dir, "*.nmconnection")) {
+			ini := h.readINI(f)
+			switch ini["connection.type"] {
+			case "ethernet", "802-3-ethernet", "wifi", "802-11-wireless":
+			default:
+				continue
+			}
+			c := ConfiguredIf{Name: ini["connection.interface-name"], Source: f}
+			for _, k := range []string{"ethernet.mac-address", "802-3-ethernet.mac-address", "wifi.mac-address", "802-11-wireless.mac-address"} {
+				if v := ini[k]; v != "" {
+					c.MAC = strings.ToLower(v)
+				}
+			}
+			if c.Name != "" || c.MAC != "" {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+func (h host) ifcfgIfaces() []ConfiguredIf {
+	var out []ConfiguredIf
+	for _, dir := range []string{"/etc/sysconfig/network-scripts", "/etc/sysconfig/network"} {
+		for _, f := range h.glob(filepath.Join(dir, "ifcfg-*")) {
+			kv := h.readShellVars(f)
+			if t := strings.ToLower(kv["TYPE"]); t != "" && t != "ethernet" && t != "wireless" {
+				continue
+			}
+			name := kv["DEVICE"]
+			if name == "" {
+				name = strings.TrimPrefix(filepath.Base(f), "ifcfg-")
+			}
+			if name == "lo" {
+				continue
+			}
+			mac := kv["HWADDR"]
+			if mac == "" {
+				mac = kv["MACADDR"]
+			}
+			out = append(out, ConfiguredIf{Name: name, MAC: strings.ToLower(mac), Source: f})
+		}
+	}
+	return out
+}
+
+// readINI returns "section.key" pairs of a keyfile.
+func (h host) readINI(path string) map[string]string {
+	out := map[string]string{}
+	f, err := h.open(path)
+	if err != nil {
+		return out
+	}
+	defer f.Close()
+	section := ""
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ln := strings.TrimSpace(sc.Text())
+		if ln == "" || ln[0] == '#' || ln[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(ln, "[") && strings.HasSuffix(ln, "]") {
+			section = ln[1 : len(ln)-1]
+			continue
+		}
+		if k, v, ok := strings.Cut(ln, "="); ok {
+			out[section+"."+strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return out
+}
+
+// readShellVars parses KEY=value lines of a shell-style config file.
+func (h host) readShellVars(path string) map[string]string {
+	out := map[string]string{}
+	b, err := h.readFile(path)
+	if err != nil {
+		return out
+	}
+	for _, ln := range strings.Split(string(b), "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" || ln[0] == '#' {
+			continue
+		}
+		if k, v, ok := strings.Cut(ln, "="); ok {
+			out[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package fingerprint
+
+import (
+	"bufio"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NetNamespace is a network namespace found through /run/netns or the
+// namespace links of running processes.
+type NetNamespace struct {
+	Inode uint64 `json:"inode"`
+	// Names lists the /run/netns entries bound to the namespace.
+	Names []string `json:"names,omitempty"`
+	// Current marks the namespace the agent runs in.
+	Current bool `json:"current,omitempty"`
+	// Processes counts the processes living in the namespace.
+	Processes int `json:"processes"`
+	// Interfaces counts the interfaces other than lo; it is absent when
+	// the namespace could not be entered.
+	Interfaces *int `json:"interfaces,omitempty"`
+}
+
+// netNamespaces lists the distinct network namespaces ordered by inode.
+// Interfaces are counted from /proc/<pid>/net/dev of a member process;
+// named namespaces without processes are entered with setns, which needs
+// CAP_SYS_ADMIN.
+func (h host) netNamespaces() []NetNamespace {
+	byIno := map[uint64]*NetNamespace{}
+	pidOf := map[uint64]string{}
+	get := func(ino uint64) *NetNamespace {
+		ns := byIno[ino]
+		if ns == nil {
+			ns = &NetNamespace{Inode: ino}
+			byIno[ino] = ns
+		}
+		return ns
+	}
+	named, _ := h.readDir("/run/netns")
+	for _, e := range named {
+		if ino, ok := h.nsInode(filepath.Join("/run/netns", e.Name())); ok {
+			ns := get(ino)
+			ns.Names = append(ns.Names, e.Name())
+		}
+	}
+	procs, _ := h.readDir("/proc")
+	for _, e := range procs {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		ino, ok := h.nsLinkInode(filepath.Join("/proc", e.Name(), "ns/net"))
+		if !ok {
+			continue
+		}
+		get(ino).Processes++
+		if _, ok := pidOf[ino]; !ok {
+			pidOf[ino] = e.Name()
+		}
+	}
+	if len(byIno) == 0 {
+		return nil
+	}
+	self, _ := host{}.nsLinkInode("/proc/self/ns/net")
+	out := make([]NetNamespace, 0, len(byIno))
+	for ino, ns := range byIno {
+		ns.Current = ino == self
+		if pid, ok := pidOf[ino]; ok {
+			if n, ok := h.countNetDev(filepath.Join("/proc", pid, "net/dev")); ok {
+				ns.Interfaces = &n
+			}
+		} else if len(ns.Names) > 0 && h.live() {
+			p := h.path(filepath.Join("/run/netns", ns.Names[0]))
+			n, ok := countInNetns(p)
+			h.debug("setns", "path", p, "ok", ok)
+			if ok {
+				h.source("namespace", p)
+				ns.Interfaces = &n
+			}
+		}
+		sort.Strings(ns.Names)
+		out = append(out, *ns)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Inode < out[j].Inode })
+	return out
+}
+
+// nsLinkInode parses the inode out of a "net:[4026531840]" link.
+func (h host) nsLinkInode(path string) (uint64, bool) {
+	l, err := h.rawReadlink(path)
+	if err != nil {
+		return 0, false
+	}
+	l, ok := strings.CutPrefix(l, "net:[")
+	if !ok {
+		return 0, false
+	}
+	ino, err := strconv.ParseUint(strings.TrimSuffix(l, "]"), 10, 64)
+	return ino, err == nil
+}
+
+// countNetDev counts the interfaces other than lo listed in a net/dev
+// table.
+func (h host) countNetDev(path string) (int, bool) {
+	f, err := h.open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	n := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, _, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		if name = strings.TrimSpace(name); name != "lo" {
+			n++
+		}
+	}
+	return n, sc.Err() == nil
+}
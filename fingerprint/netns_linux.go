@@ -0,0 +1,51 @@
+package fingerprint
+
+import (
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// nsInode returns the nsfs inode a /run/netns entry is bound to.
+func (h host) nsInode(path string) (uint64, bool) {
+	fi, err := h.stat(path)
+	if err != nil {
+		return 0, false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return st.Ino, true
+}
+
+// countInNetns counts the interfaces of the namespace bound at path from a
+// dedicated thread that joins it. The thread stays locked so the runtime
+// discards it instead of reusing it in the foreign namespace.
+func countInNetns(path string) (int, bool) {
+	type result struct {
+		n  int
+		ok bool
+	}
+	ch := make(chan result, 1)
+	go func() {
+		runtime.LockOSThread()
+		f, err := fdcap.Default.Open(path)
+		if err != nil {
+			ch <- result{}
+			return
+		}
+		defer f.Close()
+		if unix.Setns(int(f.Fd()), unix.CLONE_NEWNET) != nil {
+			ch <- result{}
+			return
+		}
+		n, ok := host{}.countNetDev("/proc/thread-self/net/dev")
+		ch <- result{n, ok}
+	}()
+	r := <-ch
+	return r.n, r.ok
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package fingerprint
+
+func (host) nsInode(string) (uint64, bool) { return 0, false }
+
+func countInNetns(string) (int, bool) { return 0, false }
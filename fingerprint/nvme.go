@@ -0,0 +1,88 @@
+package fingerprint
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// NVMeController is one /sys/class/nvme/nvme* controller. Its serial and
+// the namespace IDs below are assigned by the manufacturer and survive
+// repartitioning and reformatting, unlike file system UUIDs.
+type NVMeController struct {
+	Name     string `json:"name"`
+	Model    string `json:"model,omitempty"`
+	Serial   string `json:"serial,omitempty"`
+	Firmware string `json:"firmware,omitempty"`
+	// SubsystemNQN is the NVMe Qualified Name of the subsystem, shared by
+	// the controllers of a multipath device.
+	SubsystemNQN string `json:"subsystem_nqn,omitempty"`
+	// Transport is "pcie", "tcp", "rdma", "fc" or "loop"; Address is the
+	// PCI address or the fabric target address.
+	Transport  string          `json:"transport,omitempty"`
+	Address    string          `json:"address,omitempty"`
+	Namespaces []NVMeNamespace `json:"namespaces,omitempty"`
+}
+
+// NVMeNamespace is a namespace of a controller with its globally unique
+// identifiers, those the device reports; all-zero values are left out.
+type NVMeNamespace struct {
+	Name  string `json:"name"`
+	NSID  int    `json:"nsid"`
+	EUI64 string `json:"eui64,omitempty"`
+	NGUID string `json:"nguid,omitempty"`
+	UUID  string `json:"uuid,omitempty"`
+}
+
+func (h host) nvmeControllers() []NVMeController {
+	const base = "/sys/class/nvme"
+	entries, err := h.readDir(base)
+	if err != nil {
+		return nil
+	}
+	var out []NVMeController
+	for _, e := range entries {
+		name := e.Name()
+		dir := path.Join(base, name)
+		c := NVMeController{
+			Name:         name,
+			Model:        h.readTrim(path.Join(dir, "model")),
+			Serial:       h.readTrim(path.Join(dir, "serial")),
+			Firmware:     h.readTrim(path.Join(dir, "firmware_rev")),
+			SubsystemNQN: h.readTrim(path.Join(dir, "subsysnqn")),
+			Transport:    h.readTrim(path.Join(dir, "transport")),
+			Address:      h.readTrim(path.Join(dir, "address")),
+		}
+		// nvme0n1, or nvme0c0n1 for a path of a multipath namespace.
+		children, _ := h.readDir(dir)
+		for _, ch := range children {
+			ns := ch.Name()
+			if !strings.HasPrefix(ns, name) || !strings.Contains(ns[len(name):], "n") {
+				continue
+			}
+			nsDir := path.Join(dir, ns)
+			id, err := strconv.Atoi(h.readTrim(path.Join(nsDir, "nsid")))
+			if err != nil {
+				continue
+			}
+			c.Namespaces = append(c.Namespaces, NVMeNamespace{
+				Name:  ns,
+				NSID:  id,
+				EUI64: nonZeroID(h.readTrim(path.Join(nsDir, "eui"))),
+				NGUID: nonZeroID(h.readTrim(path.Join(nsDir, "nguid"))),
+				UUID:  nonZeroID(h.readTrim(path.Join(nsDir, "uuid"))),
+			})
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// nonZeroID drops identifiers the device left unset, which the kernel
+// prints as zeros.
+func nonZeroID(v string) string {
+	if strings.Trim(v, "0-: ") == "" {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,229 @@
+package fingerprint
+
+import (
+	"io/fs"
+	"log/slog"
+	"maps"
+	"time"
+
+	"AurFingerprintAgent/dockerid"
+	"AurFingerprintAgent/retry"
+)
+
+// Option tunes what GetSnapshot collects.
+type Option func(*options)
+
+type options struct {
+	cloudInit     bool
+	storageHealth bool
+	driveIdentity bool
+	netns         bool
+	neighbors     bool
+	udevRootUUID  bool
+	partTables    bool
+	selfCheck     *selfCheckConfig
+	budget        time.Duration
+	timeout       time.Duration
+	root          string
+	hostPID       bool
+	fsys          fs.FS
+	noDocker      bool
+	dockerEP      string
+	large         largeSections
+	limits        Limits
+	timeouts      Timeouts
+	waitReady     time.Duration
+	retries       map[string]retry.Policy
+	ifExclude     []string
+	disabled      map[string]bool
+	only          map[string]bool
+	extra         []Collector
+	logger        *slog.Logger
+	pluginDir     string
+	labels        map[string]string
+	labelsFile    string
+	provenance    bool
+	recorder      *Recorder
+	aliasVersions int
+	// observe, when set, sees what each collector stored, on its own in
+	// an empty snapshot, and the independent collectors run concurrently.
+	observe func(collector string, part *Snapshot)
+	// errs receives the failed reads of the running builtin.
+	errs *readErrors
+	// sources receives the sources the running builtin read.
+	sources *sourceLog
+}
+
+func (o *options) host() host {
+	return host{root: o.root, fsys: o.fsys, hostPID: o.hostPID, errs: o.errs, sources: o.sources, rec: o.recorder, log: o.logger, dockerEndpoint: o.dockerEP, timeouts: o.timeouts, retries: o.retries}
+}
+
+// largeSections are the opt-in inventories that EncodeStream can write
+// without holding them in memory.
+type largeSections struct {
+	packages, pci, usb bool
+}
+
+func buildOptions(opts []Option) options {
+	o := options{limits: DefaultLimits, timeouts: DefaultTimeouts, retries: DefaultRetries, ifExclude: DefaultInterfaceExclude, aliasVersions: DefaultAliasVersions}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCloudInit merges cloud-init instance data into Snapshot.Cloud and
+// reconciles the instance ID with the machine ID.
+func WithCloudInit() Option {
+	return func(o *options) { o.cloudInit = true }
+}
+
+// WithStorageHealth queries NVMe and ATA SMART data into Snapshot.Storage.
+// It needs read access to the raw block devices, usually root.
+func WithStorageHealth() Option {
+	return func(o *options) { o.storageHealth = true }
+}
+
+// WithDriveIdentity lists the model, serial, firmware and WWN of SCSI and
+// ATA disks in Snapshot.Drives, asking the drive itself through ATA
+// IDENTIFY DEVICE or smartctl when sysfs lacks the serial, as it does
+// behind most USB-SATA bridges. The queries need read access to the raw
+// block devices, usually root.
+func WithDriveIdentity() Option {
+	return func(o *options) { o.driveIdentity = true }
+}
+
+// WithPartitionTables reads the partition table type, disk GUID or MBR
+// signature and PARTUUIDs of disks the udev database has not probed from
+// the disks' first sectors. It opens the raw block devices, which needs
+// root; without it block_devices uses sysfs and udev only.
+func WithPartitionTables() Option {
+	return func(o *options) { o.partTables = true }
+}
+
+// WithNetNamespaces lists the host's network namespaces with their
+// interface counts in Snapshot.NetNamespaces. Seeing namespaces of other
+// processes needs root.
+func WithNetNamespaces() Option {
+	return func(o *options) { o.netns = true }
+}
+
+// WithNeighbors samples the ARP table into Snapshot.Neighbors: the default
+// gateways' MACs and neighbor counts. The data is volatile and not hashed.
+func WithNeighbors() Option {
+	return func(o *options) { o.neighbors = true }
+}
+
+// WithUdevRootFSUUID falls back to the udev database for RootFS.UUID when
+// /dev/disk/by-uuid and blkid do not resolve it, as for the /dev/mapper
+// root of a captured tree or a -host-root container. rootfs.uuid is part
+// of Hash, so turning this on changes the hash, and the ID, of the hosts
+// where the UUID was empty before; switch a fleet over together with the
+// matching policy that reconciles them (see the README).
+func WithUdevRootFSUUID() Option {
+	return func(o *options) { o.udevRootUUID = true }
+}
+
+// WithBudget bounds the wall-clock time of a collection. The budget is
+// shared among the collectors by weight; collectors that overrun their share
+// or start after the budget is spent are left out and listed in
+// Snapshot.Meta.Skipped. The collectors of the hashed fields always run to
+// completion, so that a tight budget cannot change the hash; their time
+// still counts against the budget of the others.
+func WithBudget(d time.Duration) Option {
+	return func(o *options) { o.budget = d }
+}
+
+// WithPackages lists installed dpkg, apk or rpm packages in
+// Snapshot.Packages.
+func WithPackages() Option {
+	return func(o *options) { o.large.packages = true }
+}
+
+// WithPCI lists PCI devices in Snapshot.PCI.
+func WithPCI() Option {
+	return func(o *options) { o.large.pci = true }
+}
+
+// WithUSB lists USB devices in Snapshot.USB.
+func WithUSB() Option {
+	return func(o *options) { o.large.usb = true }
+}
+
+// WithTimeout cancels the whole collection after d, like
+// GetSnapshotContext with a deadline. Collectors still running are
+// abandoned and listed in Snapshot.Meta.Skipped.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithRootPrefix reads the system files collectors look at, such as
+// /etc/machine-id or /sys/class/dmi, under dir instead of /, e.g. to
+// fingerprint a mounted image or a prepared tree. Files describing the
+// agent process itself are still read from the live system.
+func WithRootPrefix(dir string) Option {
+	return func(o *options) { o.root, o.hostPID = dir, false }
+}
+
+// WithHostRoot fingerprints the host from inside a container that has the
+// host's root file system mounted at dir, e.g. "/host". Mount and network
+// tables are read from the host's init process under dir/proc, which
+// needs the host PID namespace (docker run --pid=host) so that dir/proc
+// is the host's procfs. The host's Docker socket is used when mounted
+// under dir; tools that only see the container, such as cloud-init, are
+// skipped.
+func WithHostRoot(dir string) Option {
+	return func(o *options) { o.root, o.hostPID = dir, true }
+}
+
+// WithFS reads the system files from fsys, whose root stands for /, e.g.
+// an fstest.MapFS with a fake /proc and /sys for tests, or os.DirFS of a
+// bind-mounted host root. Symbolic links are followed when fsys implements
+// ReadLinkFS. Probes that need the live system, such as external commands,
+// SMART ioctls, the Docker socket and namespace entry, are skipped. It
+// takes precedence over WithRootPrefix.
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) { o.fsys = fsys }
+}
+
+// WithoutDocker skips all Docker daemon probing: the docker collector and
+// the container image lookup in Snapshot.Meta.
+func WithoutDocker() Option {
+	return func(o *options) {
+		o.noDocker = true
+		WithoutCollectors("docker")(o)
+	}
+}
+
+// WithDockerEndpoint queries the Docker daemon at ep, a socket path or a
+// unix://, tcp:// or http:// URL, instead of /var/run/docker.sock under
+// the root. The path is not resolved against WithRootPrefix.
+func WithDockerEndpoint(ep string) Option {
+	return func(o *options) { o.dockerEP = ep }
+}
+
+// DefaultRetries are the retry policies of the probes that may fail while
+// the system boots: "docker", the daemon socket, and "cloud-init", whose
+// instance data is written late in boot.
+var DefaultRetries = map[string]retry.Policy{
+	"docker":     dockerid.DefaultRetry,
+	"cloud-init": {Attempts: 4, Base: 250 * time.Millisecond, Max: time.Second, Jitter: 0.2},
+}
+
+// WithRetry sets the retry policy of probe, one of the DefaultRetries;
+// retry.Once disables retries.
+func WithRetry(probe string, p retry.Policy) Option {
+	return func(o *options) {
+		o.retries = maps.Clone(o.retries)
+		if o.retries == nil {
+			o.retries = map[string]retry.Policy{}
+		}
+		o.retries[probe] = p
+	}
+}
+
+// WithLogger logs every file the collectors read, command they run and
+// socket they contact to l at debug level, tagged with the collector name.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
@@ -0,0 +1,186 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+)
+
+// partitionTable is the partition table of a disk as blkid reports it:
+// Type "gpt" or "dos", the GPT disk GUID or the MBR disk signature, and the
+// PARTUUIDs by partition number.
+type partitionTable struct {
+	Type      string
+	UUID      string
+	PartUUIDs map[int]string
+}
+
+// diskPartitionTable reads the partition table of the disk at dir, e.g.
+// /sys/block/sda, from the udev database and, with raw set, where udev has
+// not probed it, from the disk's first sectors. Reading the disk needs
+// root; without it the table is left out.
+func (h host) diskPartitionTable(dir, name string, raw bool) *partitionTable {
+	if p := h.udevProperties(h.readTrim(path.Join(dir, "dev"))); p["ID_PART_TABLE_TYPE"] != "" {
+		return &partitionTable{Type: p["ID_PART_TABLE_TYPE"], UUID: p["ID_PART_TABLE_UUID"]}
+	}
+	if !raw || !h.live() {
+		return nil
+	}
+	dev := h.path("/dev/" + name)
+	f, err := h.osOpen(dev)
+	h.debug("read partition table", "device", dev, "err", err)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	h.source("file", "/dev/"+name)
+	ss, err := strconv.ParseInt(h.readTrim(path.Join(dir, "queue/logical_block_size")), 10, 64)
+	if err != nil || ss < 512 {
+		ss = 512
+	}
+	return parsePartitionTable(f, ss)
+}
+
+// partUUID returns the PARTUUID of partition number n of a disk with table
+// pt, preferring the udev database entry of the partition at dir.
+func (h host) partUUID(dir string, n int, pt *partitionTable) string {
+	if u := h.udevProperties(h.readTrim(path.Join(dir, "dev")))["ID_PART_ENTRY_UUID"]; u != "" {
+		return u
+	}
+	if pt == nil {
+		return ""
+	}
+	return pt.PartUUIDs[n]
+}
+
+// udevProperties reads the udev database entry of the block device with
+// the given "major:minor".
+func (h host) udevProperties(devnum string) map[string]string {
+	if devnum == "" {
+		return nil
+	}
+	f, err := h.open("/run/udev/data/b" + devnum)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return parseUdevProperties(f)
+}
+
+// GPT limits: the specification reserves at least 16 KiB for 128 entries;
+// tables beyond a few thousand entries or 1 MiB are not read. Chains of
+// more extended boot records than mbrMaxLogical are cut off.
+const (
+	gptMaxEntries   = 4096
+	gptMaxTableSize = 1 << 20
+	mbrMaxLogical   = 256
+)
+
+// parsePartitionTable decodes the GPT or, failing that, the MBR at the
+// start of a disk with sectors of ss bytes. It returns nil for disks
+// without a partition table and must not panic on any input.
+func parsePartitionTable(r io.ReaderAt, ss int64) *partitionTable {
+	mbr := make([]byte, 512)
+	if _, err := r.ReadAt(mbr, 0); err != nil || mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return nil
+	}
+	protective := false
+	for i := range 4 {
+		e := mbr[446+16*i:]
+		if e[0] != 0 && e[0] != 0x80 {
+			// Not a partition table, e.g. a file system on the whole disk.
+			return nil
+		}
+		protective = protective || e[4] == 0xee
+	}
+	if protective {
+		return parseGPT(r, ss)
+	}
+	pt := &partitionTable{Type: "dos", PartUUIDs: map[int]string{}}
+	sig := binary.LittleEndian.Uint32(mbr[440:])
+	if sig == 0 {
+		return pt
+	}
+	pt.UUID = fmt.Sprintf("%08x", sig)
+	logical := 0
+	for i := range 4 {
+		e := mbr[446+16*i:]
+		if e[4] == 0 {
+			continue
+		}
+		pt.PartUUIDs[i+1] = fmt.Sprintf("%s-%02x", pt.UUID, i+1)
+		if isExtended(e[4]) && logical == 0 {
+			logical = countLogical(r, ss, binary.LittleEndian.Uint32(e[8:]))
+		}
+	}
+	// Logical partitions are numbered from 5, as the kernel and blkid do.
+	for n := 5; n < 5+logical; n++ {
+		pt.PartUUIDs[n] = fmt.Sprintf("%s-%02x", pt.UUID, n)
+	}
+	return pt
+}
+
+// isExtended reports whether an MBR partition type is an extended
+// partition holding a chain of extended boot records.
+func isExtended(typ byte) bool {
+	return typ == 0x05 || typ == 0x0f || typ == 0x85
+}
+
+// countLogical follows the chain of extended boot records of the extended
+// partition starting at sector base and counts its logical partitions.
+// Each record's first entry is a logical partition and its second links
+// to the next record, relative to base.
+func countLogical(r io.ReaderAt, ss int64, base uint32) int {
+	ebr := make([]byte, 512)
+	seen := map[uint32]bool{}
+	n := 0
+	for next := uint32(0); n < mbrMaxLogical && !seen[next]; {
+		seen[next] = true
+		off := (int64(base) + int64(next)) * ss
+		if _, err := r.ReadAt(ebr, off); err != nil || ebr[510] != 0x55 || ebr[511] != 0xaa {
+			break
+		}
+		if ebr[446+4] != 0 {
+			n++
+		}
+		link := ebr[462:]
+		if !isExtended(link[4]) {
+			break
+		}
+		next = binary.LittleEndian.Uint32(link[8:])
+	}
+	return n
+}
+
+func parseGPT(r io.ReaderAt, ss int64) *partitionTable {
+	hdr := make([]byte, 92)
+	if _, err := r.ReadAt(hdr, ss); err != nil || !bytes.Equal(hdr[:8], []byte("EFI PART")) {
+		return nil
+	}
+	le := binary.LittleEndian
+	pt := &partitionTable{Type: "gpt", UUID: guidString(hdr[56:72]), PartUUIDs: map[int]string{}}
+	lba, n, size := le.Uint64(hdr[72:]), int64(le.Uint32(hdr[80:])), int64(le.Uint32(hdr[84:]))
+	if n == 0 || n > gptMaxEntries || size < 128 || size%8 != 0 || n*size > gptMaxTableSize || lba > 1<<40 {
+		return pt
+	}
+	table := make([]byte, n*size)
+	if _, err := r.ReadAt(table, int64(lba)*ss); err != nil {
+		return pt
+	}
+	for i := range n {
+		e := table[i*size:]
+		if !bytes.Equal(e[:16], make([]byte, 16)) {
+			pt.PartUUIDs[int(i)+1] = guidString(e[16:32])
+		}
+	}
+	return pt
+}
+
+// guidString formats a GUID stored in the mixed-endian GPT layout.
+func guidString(b []byte) string {
+	le := binary.LittleEndian
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", le.Uint32(b), le.Uint16(b[4:]), le.Uint16(b[6:]), b[8:10], b[10:16])
+}
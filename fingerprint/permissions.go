@@ -0,0 +1,103 @@
+package fingerprint
+
+import (
+	"path"
+	"slices"
+)
+
+// PermissionIssue is a value missing from a snapshot because the agent
+// lacked a privilege.
+type PermissionIssue struct {
+	Collector string `json:"collector"`
+	// Path is the file, device or socket that could not be read.
+	Path string `json:"path"`
+	// Fields lists the snapshot fields left empty, where known.
+	Fields []string `json:"fields,omitempty"`
+	// Requires is the privilege that grants access, e.g. a capability.
+	Requires string `json:"requires"`
+	Error    string `json:"error"`
+	// Missing are the capabilities granting access that the agent ran
+	// without, per Snapshot.Agent.
+	Missing []string `json:"missing,omitempty"`
+	// Namespaces are the namespaces the agent did not share with PID 1
+	// that hide the path, e.g. "pid" for /proc/1 of a container.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// privilege maps a path pattern to the fields read from it and what grants
+// access: the capabilities any of which suffices, other grants in the
+// notation of FieldRequirement.Access, and the namespaces the agent must
+// share with PID 1 to see the host's object. The first matching entry
+// applies.
+type privilege struct {
+	pattern  string
+	fields   []string
+	requires string
+	caps     []string
+	access   []string
+	ns       []string
+}
+
+var privileges = []privilege{
+	{"/sys/class/dmi/id/product_uuid", []string{"dmi.product_uuid"}, "root or CAP_DAC_READ_SEARCH (file is mode 0400)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, nil},
+	{"/sys/class/dmi/id/board_serial", []string{"dmi.board_serial"}, "root or CAP_DAC_READ_SEARCH (file is mode 0400)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, nil},
+	{"/sys/class/dmi/id/product_serial", []string{"dmi.product_serial"}, "root or CAP_DAC_READ_SEARCH (file is mode 0400)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, nil},
+	{"/sys/class/dmi/id/chassis_asset_tag", []string{"dmi.chassis_asset_tag"}, "root or CAP_DAC_READ_SEARCH (file is mode 0400)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, nil},
+	{"/sys/firmware/dmi/tables/*", []string{"dmi.oem_strings", "memory_modules"}, "root or CAP_DAC_READ_SEARCH (file is mode 0400)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, nil},
+	{"/sys/class/dmi/id/*", nil, "root or CAP_DAC_READ_SEARCH (file is mode 0400)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, nil},
+	{"/proc/1/exe", []string{"boot.pid1_exe", "boot.pid1_sha256"}, "CAP_SYS_PTRACE", []string{"CAP_SYS_PTRACE"}, nil, []string{"pid"}},
+	{"/proc/*/ns/*", []string{"network_namespaces"}, "CAP_SYS_PTRACE", []string{"CAP_SYS_PTRACE"}, nil, []string{"pid"}},
+	{"/run/netns/*", []string{"network_namespaces.*.interfaces"}, "CAP_SYS_ADMIN", []string{"CAP_SYS_ADMIN"}, nil, []string{"mnt"}},
+	{"/dev/nvme*", []string{"storage_health"}, "CAP_SYS_ADMIN (NVMe admin commands)", []string{"CAP_SYS_ADMIN"}, nil, nil},
+	{"/dev/sd*", []string{"storage_health", "drives"}, "CAP_SYS_RAWIO (ATA pass-through)", []string{"CAP_SYS_RAWIO"}, nil, nil},
+	{"/etc/lvm/backup/*", []string{"lvm"}, "root (LVM metadata backups are private)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, []string{"mnt"}},
+	{"/run/cloud-init/*", []string{"cloud"}, "root (cloud-init keeps sensitive instance data private)", []string{"CAP_DAC_READ_SEARCH", "CAP_DAC_OVERRIDE"}, []string{"root-read"}, []string{"mnt"}},
+	{"/var/run/docker.sock", []string{"docker.daemon_id", "meta.container"}, "root or membership in the docker group", nil, []string{"docker-group"}, []string{"mnt"}},
+	{"/run/docker.sock", []string{"docker.daemon_id", "meta.container"}, "root or membership in the docker group", nil, []string{"docker-group"}, []string{"mnt"}},
+}
+
+// Permissions lists the values of s that could not be collected for lack
+// of privileges, with the path and the privilege needed, so that sparse
+// output of an unprivileged run can be told from a system lacking the data.
+// It is derived from the errors of kind ErrorPermission.
+func (s Snapshot) Permissions() []PermissionIssue {
+	var out []PermissionIssue
+	for _, e := range s.Errors {
+		if e.Kind != ErrorPermission || e.Path == "" {
+			continue
+		}
+		p := PermissionIssue{Collector: e.Collector, Path: e.Path, Error: e.Error,
+			Requires: "read permission, e.g. root or CAP_DAC_READ_SEARCH"}
+		if sec, ok := sections[Section(e.Collector)]; ok {
+			p.Fields = sec.fields
+		}
+		for _, r := range privileges {
+			if ok, _ := path.Match(r.pattern, e.Path); ok {
+				p.Requires = r.requires
+				if r.fields != nil {
+					p.Fields = r.fields
+				}
+				p.Missing, p.Namespaces = s.Agent.lacking(r)
+				break
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// lacking returns the capabilities of r the agent ran without and the
+// namespaces of r it did not share with PID 1. Capabilities are only
+// judged for snapshots that describe their agent; an empty set does not
+// survive JSON, so PID tells it from an unknown one.
+func (a AgentInfo) lacking(r privilege) (caps, ns []string) {
+	if a.PID != 0 && !slices.ContainsFunc(r.caps, func(c string) bool { return slices.Contains(a.Capabilities, c) }) {
+		caps = r.caps
+	}
+	for _, n := range r.ns {
+		if slices.Contains(a.IsolatedNamespaces, n) {
+			ns = append(ns, n)
+		}
+	}
+	return caps, ns
+}
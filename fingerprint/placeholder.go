@@ -0,0 +1,126 @@
+package fingerprint
+
+import (
+	"strings"
+	"unicode"
+)
+
+// placeholders are values firmware vendors leave in DMI fields instead of
+// real identifiers, compared after normalization.
+var placeholders = map[string]bool{
+	"to be filled by o.e.m.":               true,
+	"to be filled by oem":                  true,
+	"default string":                       true,
+	"default":                              true,
+	"system serial number":                 true,
+	"system product name":                  true,
+	"chassis serial number":                true,
+	"base board serial number":             true,
+	"type2 - board serial number":          true,
+	"type1productconfigid":                 true,
+	"not specified":                        true,
+	"not applicable":                       true,
+	"not available":                        true,
+	"not settable":                         true,
+	"no asset tag":                         true,
+	"no asset information":                 true,
+	"asset-1234567890":                     true,
+	"asset tag":                            true,
+	"none":                                 true,
+	"n/a":                                  true,
+	"na":                                   true,
+	"unknown":                              true,
+	"invalid":                              true,
+	"empty":                                true,
+	"oem":                                  true,
+	"o.e.m.":                               true,
+	"0123456789":                           true,
+	"123456789":                            true,
+	"1234567890":                           true,
+	"0123456789abcdef":                     true,
+	"03000200-0400-0500-0006-000700080009": true,
+	"00020003-0004-0005-0006-000700080009": true,
+}
+
+// descriptivePlaceholders are further filler values of the descriptive
+// fields. They are kept apart from placeholders, which decide what enters
+// the hash, so that recognizing them does not change any machine's hash.
+var descriptivePlaceholders = map[string]bool{
+	"system manufacturer": true,
+	"system version":      true,
+}
+
+// normalizeDMI folds a DMI string for comparison: Unicode whitespace and
+// control characters are dropped at the ends and collapsed inside, full-width
+// forms are mapped to ASCII and letters are lowercased.
+func normalizeDMI(v string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range v {
+		if r >= 0xff01 && r <= 0xff5e {
+			r -= 0xfee0
+		}
+		switch {
+		case unicode.IsSpace(r):
+			space = sb.Len() > 0
+			continue
+		case !unicode.IsPrint(r):
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// IsPlaceholder reports whether a DMI value is a known OEM placeholder or
+// otherwise carries no identifying information: empty strings, values made
+// of a single repeated character such as all-zero UUIDs, and well-known
+// filler text.
+func IsPlaceholder(v string) bool {
+	n := normalizeDMI(v)
+	if n == "" || placeholders[n] {
+		return true
+	}
+	var first rune
+	same := true
+	for _, r := range n {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		if first == 0 {
+			first = r
+		} else if r != first {
+			same = false
+			break
+		}
+	}
+	return same
+}
+
+// placeholderFields lists the DMI fields holding placeholder values.
+func (d DMIInfo) placeholderFields() []string {
+	var out []string
+	for _, f := range []struct {
+		name, v string
+		hashed  bool
+	}{
+		{"product_uuid", d.ProductUUID, true},
+		{"board_serial", d.BoardSerial, true},
+		{"chassis_asset_tag", d.ChassisAssetTag, true},
+		{"sys_vendor", d.SysVendor, false},
+		{"product_name", d.ProductName, false},
+		{"product_serial", d.ProductSerial, false},
+		{"product_family", d.ProductFamily, false},
+		{"board_name", d.BoardName, false},
+		{"board_vendor", d.BoardVendor, false},
+	} {
+		if f.v != "" && (IsPlaceholder(f.v) || !f.hashed && descriptivePlaceholders[normalizeDMI(f.v)]) {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
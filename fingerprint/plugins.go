@@ -0,0 +1,150 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// DefaultPluginDir is where sites install collector plugins.
+const DefaultPluginDir = "/etc/linux-fingerprint/collectors.d"
+
+const (
+	// pluginTimeout bounds one plugin unless the context sets a deadline.
+	pluginTimeout = 10 * time.Second
+	// maxPluginOutput bounds the JSON a plugin may print.
+	maxPluginOutput = 1 << 20
+)
+
+// WithPlugins runs the executables in dir, in name order, and stores the
+// JSON each prints on stdout in Snapshot.Custom under its file name without
+// extension. Plugins run on the live system only; for WithRootPrefix and
+// WithHostRoot the root is passed in the LSF_ROOT environment variable.
+// The directory and the plugins must not be writable by group or others,
+// and must belong to root or the agent's user. Custom data is not hashed.
+func WithPlugins(dir string) Option {
+	return func(o *options) { o.pluginDir = dir }
+}
+
+func (h host) plugins(ctx context.Context, dir string) map[string]json.RawMessage {
+	if !h.live() {
+		return nil
+	}
+	var entries []fs.DirEntry
+	err := fdcap.Default.Do(func() (err error) {
+		entries, err = os.ReadDir(dir)
+		return err
+	})
+	h.trace("readdir", dir, err)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			h.note(dir, err)
+		}
+		return nil
+	}
+	if err := checkPluginPerm(dir); err != nil {
+		h.note(dir, err)
+		return nil
+	}
+	out := map[string]json.RawMessage{}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if strings.HasPrefix(e.Name(), ".") || e.IsDir() {
+			continue
+		}
+		fi, err := os.Stat(p)
+		if err != nil || !fi.Mode().IsRegular() || fi.Mode()&0o111 == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		if _, dup := out[name]; dup {
+			h.note(p, fmt.Errorf("plugin %q already ran", name))
+			continue
+		}
+		v, err := h.runPlugin(ctx, p)
+		if err != nil {
+			h.note(p, err)
+			continue
+		}
+		out[name] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (h host) runPlugin(ctx context.Context, p string) (json.RawMessage, error) {
+	if err := checkPluginPerm(p); err != nil {
+		return nil, err
+	}
+	ctx, cancel := defaultTimeout(ctx, pluginTimeout)
+	defer cancel()
+	cmd := h.command(ctx, p)
+	cmd.Env = append(os.Environ(), "LSF_ROOT="+h.root)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitedWriter{w: &stdout, n: maxPluginOutput}
+	cmd.Stderr = &limitedWriter{w: &stderr, n: 4096}
+	// Children left holding the pipes must not stall the collection.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	switch {
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	case cmd.Stdout.(*limitedWriter).over:
+		err = fmt.Errorf("output exceeds %d bytes", maxPluginOutput)
+	}
+	if err != nil {
+		h.debug("exec failed", "cmd", p, "err", err)
+		return nil, err
+	}
+	b := bytes.TrimSpace(stdout.Bytes())
+	if !json.Valid(b) {
+		return nil, errors.New("output is not valid JSON")
+	}
+	return b, nil
+}
+
+// checkPluginPerm refuses files others could have replaced.
+func checkPluginPerm(p string) error {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&0o022 != 0 {
+		return &fs.PathError{Op: "plugin", Path: p, Err: fmt.Errorf("writable by group or others (%v)", fi.Mode().Perm())}
+	}
+	if uid, ok := fileOwner(fi); ok && uid != 0 && uid != os.Geteuid() {
+		return &fs.PathError{Op: "plugin", Path: p, Err: fmt.Errorf("owned by uid %d", uid)}
+	}
+	return nil
+}
+
+// limitedWriter keeps the first n bytes written and drops the rest.
+type limitedWriter struct {
+	w    io.Writer
+	n    int
+	over bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	k := min(len(p), l.n)
+	l.w.Write(p[:k])
+	l.n -= k
+	l.over = l.over || k < len(p)
+	return len(p), nil
+}
@@ -0,0 +1,439 @@
+package fingerprint
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Parsers for the /proc, /sys and /etc formats. They take a reader rather
+// than a path so that content from a WithFS capture, which may have been
+// edited or corrupted, goes through the same code as the live system; they
+// must not panic or stall on any input.
+
+const (
+	// maxProcInput bounds how much of a file a parser reads; the real
+	// files are a few kilobytes even on large machines.
+	maxProcInput = 8 << 20
+	// maxProcLine bounds one line. Longer lines end the scan.
+	maxProcLine = 1 << 20
+)
+
+func lineScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(io.LimitReader(r, maxProcInput))
+	sc.Buffer(nil, maxProcLine)
+	return sc
+}
+
+// parseOSRelease returns NAME and VERSION of an os-release(5) file.
+func parseOSRelease(r io.Reader) (name, ver string) {
+	sc := lineScanner(r)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "NAME":
+			name = unquoteShell(v)
+		case "VERSION":
+			ver = unquoteShell(v)
+		}
+	}
+	return
+}
+
+// unquoteShell undoes the shell quoting os-release(5) allows: a single- or
+// double-quoted value, the latter with backslash escapes. Anything else is
+// returned as written.
+func unquoteShell(v string) string {
+	if len(v) < 2 || v[0] != v[len(v)-1] {
+		return strings.Trim(v, `"'`)
+	}
+	switch v[0] {
+	case '\'':
+		return v[1 : len(v)-1]
+	case '"':
+		v = v[1 : len(v)-1]
+	default:
+		return v
+	}
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+1 < len(v) && strings.IndexByte("\"\\$`", v[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(v[i])
+	}
+	return b.String()
+}
+
+// parseCPUInfo parses /proc/cpuinfo. Identification and flags come from
+// the first processor; Sockets and Cores only where the kernel prints
+// physical and core ids.
+func parseCPUInfo(r io.Reader) CPUInfo {
+	var c CPUInfo
+	pkgs, cores := map[string]bool{}, map[string]bool{}
+	var pkg, revision string
+	sc := lineScanner(r)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		first := c.LogicalCPUs <= 1
+		switch k {
+		case "processor":
+			c.LogicalCPUs++
+		case "physical id":
+			pkg = v
+			pkgs[v] = true
+		case "core id":
+			cores[pkg+"/"+v] = true
+		case "model name":
+			if c.Model == "" {
+				c.Model = v
+			}
+		case "vendor_id", "CPU implementer":
+			if first && c.Vendor == "" {
+				c.Vendor = v
+			}
+		case "cpu family", "CPU architecture":
+			if first && c.Family == "" {
+				c.Family = v
+			}
+		case "model", "CPU part":
+			if first && c.ModelNumber == "" {
+				c.ModelNumber = v
+			}
+		case "stepping", "CPU variant":
+			if first && c.Stepping == "" {
+				c.Stepping = v
+			}
+		case "microcode":
+			if first && c.Microcode == "" {
+				c.Microcode = v
+			}
+		case "CPU revision":
+			if first && revision == "" {
+				revision = v
+			}
+		case "flags", "Features":
+			if first && c.Flags == nil {
+				c.Flags = strings.Fields(v)
+			}
+		}
+	}
+	// Arm identifies a stepping as variant and revision, "r0p3".
+	if revision != "" && strings.HasPrefix(c.Stepping, "0x") {
+		if n, err := strconv.ParseUint(c.Stepping[2:], 16, 8); err == nil {
+			c.Stepping = "r" + strconv.FormatUint(n, 10) + "p" + revision
+		}
+	}
+	if len(pkgs) > 0 {
+		c.Sockets = len(pkgs)
+	}
+	if len(cores) > 0 {
+		c.Cores = len(cores)
+	}
+	return c
+}
+
+// cpuListLen counts the CPUs of a kernel CPU list such as "0-3,8-11" as in
+// /sys/devices/system/cpu/online; 0 if malformed.
+func cpuListLen(s string) int {
+	n := 0
+	for _, r := range strings.Split(s, ",") {
+		lo, hi, ok := strings.Cut(strings.TrimSpace(r), "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil || a < 0 {
+			return 0
+		}
+		b := a
+		if ok {
+			if b, err = strconv.Atoi(hi); err != nil || b < a {
+				return 0
+			}
+		}
+		if b-a >= 1<<20 {
+			return 0
+		}
+		if n += b - a + 1; n > 1<<20 {
+			return 0
+		}
+	}
+	return n
+}
+
+// parseCacheSize converts a sysfs cache size such as "32K" or "30M" to kB;
+// 0 if malformed.
+func parseCacheSize(s string) uint64 {
+	mul := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		s, mul = s[:len(s)-1], 1<<10
+	case strings.HasSuffix(s, "G"):
+		s, mul = s[:len(s)-1], 1<<20
+	default:
+		// Plain bytes.
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return n >> 10
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return n * mul
+}
+
+// parseMemTotalKB returns MemTotal of /proc/meminfo, or of a NUMA node's
+// meminfo, in kB.
+func parseMemTotalKB(r io.Reader) uint64 {
+	sc := lineScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		// Per-node meminfo prefixes "Node <n> ".
+		if f := strings.Fields(line); len(f) > 2 && f[0] == "Node" {
+			line = strings.Join(f[2:], " ")
+		}
+		v, ok := strings.CutPrefix(line, "MemTotal:")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(v)
+		if len(f) == 0 {
+			return 0
+		}
+		n, err := strconv.ParseUint(f[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+// parseMountinfoRoot returns the source and type of the file system
+// mounted on / in proc_pid_mountinfo(5) format: ID, parent ID, major:minor,
+// root, mount point and options, optional fields ended by "-", then type,
+// source and super options.
+func parseMountinfoRoot(r io.Reader) (source, fstype string) {
+	sc := lineScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 6 || unescapeMount(f[4]) != "/" {
+			continue
+		}
+		sep := 6
+		for sep < len(f) && f[sep] != "-" {
+			sep++
+		}
+		if sep+2 >= len(f) || f[sep+1] == "rootfs" {
+			continue
+		}
+		return unescapeMount(f[sep+2]), f[sep+1]
+	}
+	return "", ""
+}
+
+// mountedFS is a file system of mountinfo.
+type mountedFS struct {
+	mountpoint, fstype string
+}
+
+// parseMountinfoDevices maps the "major:minor" of block devices to their
+// first mount; later ones are bind mounts or remounts of the same file
+// system.
+func parseMountinfoDevices(r io.Reader) map[string]mountedFS {
+	out := map[string]mountedFS{}
+	sc := lineScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 6 || strings.HasPrefix(f[2], "0:") {
+			continue
+		}
+		sep := 6
+		for sep < len(f) && f[sep] != "-" {
+			sep++
+		}
+		if sep+1 >= len(f) {
+			continue
+		}
+		if _, ok := out[f[2]]; !ok {
+			out[f[2]] = mountedFS{unescapeMount(f[4]), f[sep+1]}
+		}
+	}
+	return out
+}
+
+// parseMountsRoot does the same for the fstab-like format of /proc/mounts
+// and /etc/mtab: source, mount point, type, options, dump and pass.
+func parseMountsRoot(r io.Reader) (source, fstype string) {
+	sc := lineScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 3 || strings.HasPrefix(f[0], "#") || unescapeMount(f[1]) != "/" || f[2] == "rootfs" {
+			continue
+		}
+		return unescapeMount(f[0]), f[2]
+	}
+	return "", ""
+}
+
+// unescapeMount decodes the \ooo octal escapes the kernel writes for
+// spaces, tabs, newlines and backslashes in mount paths. Malformed escapes
+// are kept as written.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) && s[i+1] <= '3' {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool { return c >= '0' && c <= '7' }
+
+// parseUdevProperties returns the properties of a udev database entry
+// (/run/udev/data/b8:0), the lines "E:KEY=value".
+func parseUdevProperties(r io.Reader) map[string]string {
+	props := map[string]string{}
+	sc := lineScanner(r)
+	for sc.Scan() {
+		if kv, ok := strings.CutPrefix(sc.Text(), "E:"); ok {
+			if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+				props[k] = v
+			}
+		}
+	}
+	return props
+}
+
+// lvmBackup is what the LVM metadata backup of a volume group
+// (/etc/lvm/backup/<vg>) says about its identity.
+type lvmBackup struct {
+	VG, UUID string
+	// PVs maps the device hint of each physical volume to its UUID and
+	// LVs the logical volume names to theirs.
+	PVs, LVs map[string]string
+}
+
+// parseLVMBackup reads the LVM text metadata format: nested "name {"
+// sections with "key = value" lines, the volume group being the only
+// top-level section.
+func parseLVMBackup(r io.Reader) *lvmBackup {
+	var b *lvmBackup
+	var stack []string
+	var pvDevice, pvUUID string
+	sc := lineScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(stripLVMComment(sc.Text()))
+		switch {
+		case strings.HasSuffix(line, "{"):
+			stack = append(stack, strings.TrimSpace(strings.TrimSuffix(line, "{")))
+			if len(stack) == 1 && b == nil {
+				b = &lvmBackup{VG: stack[0], PVs: map[string]string{}, LVs: map[string]string{}}
+			}
+			pvDevice, pvUUID = "", ""
+			continue
+		case line == "}":
+			if len(stack) == 3 && stack[1] == "physical_volumes" && pvDevice != "" && pvUUID != "" {
+				b.PVs[pvDevice] = pvUUID
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || b == nil || len(stack) == 0 || stack[0] != b.VG {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.Trim(strings.TrimSpace(v), `"`)
+		switch {
+		case len(stack) == 1 && k == "id":
+			b.UUID = v
+		case len(stack) == 3 && stack[1] == "physical_volumes" && k == "id":
+			pvUUID = v
+		case len(stack) == 3 && stack[1] == "physical_volumes" && k == "device":
+			pvDevice = v
+		case len(stack) == 3 && stack[1] == "logical_volumes" && k == "id":
+			b.LVs[stack[2]] = v
+		}
+	}
+	return b
+}
+
+// stripLVMComment cuts a "#" comment that is not inside a string.
+func stripLVMComment(s string) string {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// splitDMName splits the device mapper name of a logical volume, e.g.
+// "rhel-root", into volume group and volume; LVM doubles the dashes of
+// the names themselves.
+func splitDMName(s string) (vg, lv string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '-' {
+			i++
+			continue
+		}
+		return strings.ReplaceAll(s[:i], "--", "-"), strings.ReplaceAll(s[i+1:], "--", "-")
+	}
+	return strings.ReplaceAll(s, "--", "-"), ""
+}
+
+// lvmDMUUID decodes the device mapper UUID "LVM-<vg uuid><lv uuid>" of a
+// logical volume into the dashed UUIDs LVM prints. Internal layers, whose
+// UUIDs carry a suffix such as "-tpool", are rejected.
+func lvmDMUUID(s string) (vg, lv string, ok bool) {
+	raw, ok := strings.CutPrefix(s, "LVM-")
+	if !ok || len(raw) != 64 {
+		return "", "", false
+	}
+	return lvmUUID(raw[:32]), lvmUUID(raw[32:]), true
+}
+
+// lvmUUID formats 32 characters in LVM's 6-4-4-4-4-4-6 groups.
+func lvmUUID(s string) string {
+	var b strings.Builder
+	for i, n := range []int{6, 4, 4, 4, 4, 4, 6} {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(s[:n])
+		s = s[n:]
+	}
+	return b.String()
+}
@@ -0,0 +1,293 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzParseMountinfo(f *testing.F) {
+	f.Add("22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw\n")
+	f.Add("1 0 0:1 / / rw - rootfs rootfs rw\n29 1 259:2 / / rw shared:1 master:2 - xfs /dev/nvme0n1p2 rw,attr2\n")
+	f.Add("25 1 0:22 / / rw - overlay overlay rw,lowerdir=/a\\040b\n")
+	f.Add("1 2 3 4 / 6\n1 2 3 4 / 6 -\n1 2 3 4 / 6 - ext4\n")
+	f.Add("36 35 98:0 /mnt1 /mnt\\0 rw - ext3 /dev/root\\1 rw\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		src, typ := parseMountinfoRoot(strings.NewReader(s))
+		if typ == "" && src != "" {
+			t.Fatalf("source %q without type", src)
+		}
+		if strings.ContainsAny(typ, " \t\n") {
+			t.Fatalf("type %q contains a separator", typ)
+		}
+		for dev, m := range parseMountinfoDevices(strings.NewReader(s)) {
+			if strings.HasPrefix(dev, "0:") || m.fstype == "" {
+				t.Fatalf("device %s mounted as %+v", dev, m)
+			}
+		}
+	})
+}
+
+func FuzzParseMounts(f *testing.F) {
+	f.Add("rootfs / rootfs rw 0 0\n/dev/sda1 / ext4 rw,relatime 0 0\n")
+	f.Add("# static\n/dev/mapper/vg-root / btrfs rw 0 0\n")
+	f.Add("/dev/disk\\040a / ext4 rw 0 0\n")
+	f.Add("a / \n/ /\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		src, typ := parseMountsRoot(strings.NewReader(s))
+		if typ == "" && src != "" {
+			t.Fatalf("source %q without type", src)
+		}
+	})
+}
+
+func FuzzUnescapeMount(f *testing.F) {
+	for _, s := range []string{`/a\040b`, `\134`, `\`, `\0`, `\04`, `\777`, `\400`, `x\011\012`} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		u := unescapeMount(s)
+		if len(u) > len(s) {
+			t.Fatalf("unescapeMount(%q) = %q grew", s, u)
+		}
+		if !strings.Contains(s, `\`) && u != s {
+			t.Fatalf("unescapeMount(%q) = %q changed text without escapes", s, u)
+		}
+	})
+}
+
+func FuzzParseCPUInfo(f *testing.F) {
+	f.Add("processor\t: 0\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R) CPU @ 2.20GHz\n")
+	f.Add("model name:\nmodel name")
+	f.Add("processor : 0\nHardware : BCM2835\n")
+	f.Add(strings.Repeat("x", 70000) + "\nmodel name : late\n")
+	f.Add("processor : 0\nphysical id : 0\ncore id : 1\nflags : fpu sse2\n\nprocessor : 1\nphysical id : 1\ncore id : 1\n")
+	f.Add("processor : 0\nCPU implementer : 0x41\nCPU variant : 0x\nCPU revision : 3\n")
+	f.Add("processor : 0\nmicrocode : 0xf0\n\nprocessor : 1\nmicrocode : 0xea\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		c := parseCPUInfo(strings.NewReader(s))
+		if m := c.Model; m != strings.TrimSpace(m) || strings.Contains(m, "\n") {
+			t.Fatalf("model %q not trimmed to one line", m)
+		}
+		if c.Sockets < 0 || c.Cores < 0 || c.LogicalCPUs < 0 {
+			t.Fatalf("bad topology %+v", c)
+		}
+		for _, fl := range c.Flags {
+			if fl == "" || strings.ContainsAny(fl, " \t\n") {
+				t.Fatalf("bad flag %q", fl)
+			}
+		}
+	})
+}
+
+func FuzzCPUListLen(f *testing.F) {
+	f.Add("0-3,8-11")
+	f.Add("0")
+	f.Add("3-1")
+	f.Add("0-2147483647")
+	f.Add("0-9223372036854775807")
+	f.Fuzz(func(t *testing.T, s string) {
+		if n := cpuListLen(s); n < 0 || n > 1<<20 {
+			t.Fatalf("cpuListLen(%q) = %d", s, n)
+		}
+	})
+}
+
+func FuzzParseMemTotal(f *testing.F) {
+	f.Add("MemTotal:       16318412 kB\nMemFree:         1234 kB\n")
+	f.Add("MemTotal:\n")
+	f.Add("MemTotal: 99999999999999999999999 kB\n")
+	f.Add("MemTotal: -1 kB\n")
+	f.Add("Node 0 MemTotal:        6158152 kB\nNode 0 MemFree: 1 kB\n")
+	f.Add("Node\nNode 1\nNode 1 MemTotal:\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		parseMemTotalKB(strings.NewReader(s))
+	})
+}
+
+func FuzzParseOSRelease(f *testing.F) {
+	f.Add("NAME=\"Ubuntu\"\nVERSION=\"22.04.4 LTS (Jammy Jellyfish)\"\nID=ubuntu\n")
+	f.Add("NAME='Alpine Linux'\nVERSION_ID=3.19\n")
+	f.Add("NAME=\"Say \\\"hi\\\" \\$HOME\"\nVERSION=\"\n")
+	f.Add("NAME=\"\nVERSION='\n=\n\"")
+	f.Fuzz(func(t *testing.T, s string) {
+		name, ver := parseOSRelease(strings.NewReader(s))
+		if utf8.ValidString(s) && (!utf8.ValidString(name) || !utf8.ValidString(ver)) {
+			t.Fatalf("valid input produced invalid UTF-8: %q %q", name, ver)
+		}
+	})
+}
+
+func FuzzParseUdevProperties(f *testing.F) {
+	f.Add("S:disk/by-id/nvme-foo\nE:ID_PART_TABLE_TYPE=gpt\nE:ID_PART_TABLE_UUID=3b0e1a5c-9d1e-4b8a-8f3c-2f1d6e0a7b11\n")
+	f.Add("E:=x\nE:A\nE:B==\nI:123\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		for k := range parseUdevProperties(strings.NewReader(s)) {
+			if k == "" || strings.ContainsAny(k, "=\n") {
+				t.Fatalf("bad key %q", k)
+			}
+		}
+	})
+}
+
+const lvmBackupSample = `contents = "Text Format Volume Group"
+description = "Created *after* executing 'vgcreate -y my-vg /dev/sda2'" # comment
+my-vg {
+	id = "Xq3Tz9-aB1c-D2eF-g3H4-i5J6-k7L8-m9N0pQ"
+	physical_volumes {
+		pv0 {
+			id = "hL2m9Q-x4Rt-7vWs-K1pZ-c8Dn-3FgB-Yj6uE0"
+			device = "/dev/sda2"	# Hint only
+		}
+	}
+	logical_volumes {
+		root {
+			id = "Rt7Kp2-Lm4N-q8Vs-1Wx5-Yz0A-b3Cd-6Ef9Gh"
+			segment1 {
+				stripes = [
+					"pv0", 0
+				]
+			}
+		}
+	}
+}
+`
+
+func FuzzParseLVMBackup(f *testing.F) {
+	f.Add(lvmBackupSample)
+	f.Add("}\n}\nvg {\n{\nid = \"#\"\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		b := parseLVMBackup(strings.NewReader(s))
+		if b == nil {
+			return
+		}
+		for dev := range b.PVs {
+			if dev == "" {
+				t.Fatal("physical volume without device")
+			}
+		}
+	})
+}
+
+func FuzzSplitDMName(f *testing.F) {
+	f.Add("rhel-root")
+	f.Add("my--vg-my--lv")
+	f.Add("-")
+	f.Fuzz(func(t *testing.T, s string) {
+		vg, lv := splitDMName(s)
+		if len(vg)+len(lv) > len(s) {
+			t.Fatalf("splitDMName(%q) = %q, %q", s, vg, lv)
+		}
+		lvmDMUUID(s)
+	})
+}
+
+// diskImage returns the first sectors of a disk with a protective MBR and
+// a GPT of two partitions, or with an MBR of two partitions.
+func diskImage(gpt bool) []byte {
+	b := make([]byte, 34*512)
+	b[510], b[511] = 0x55, 0xaa
+	if !gpt {
+		binary.LittleEndian.PutUint32(b[440:], 0x1a2b3c4d)
+		b[446+4], b[462+4] = 0x83, 0x8e
+		return b
+	}
+	b[446+4] = 0xee
+	h := b[512:]
+	copy(h, "EFI PART")
+	copy(h[56:], []byte{0x5c, 0x1a, 0x0e, 0x3b, 0x1e, 0x9d, 0x8a, 0x4b, 0x8f, 0x3c, 0x2f, 0x1d, 0x6e, 0x0a, 0x7b, 0x11})
+	binary.LittleEndian.PutUint64(h[72:], 2)
+	binary.LittleEndian.PutUint32(h[80:], 128)
+	binary.LittleEndian.PutUint32(h[84:], 128)
+	for i, id := range []byte{0x01, 0x02} {
+		e := b[1024+128*i:]
+		e[0], e[16] = 0xaf, id
+	}
+	return b
+}
+
+// extendedImage is an MBR disk with a primary partition and an extended
+// partition at sector 2048 holding two logical partitions.
+func extendedImage() []byte {
+	b := make([]byte, 4100*512)
+	b[510], b[511] = 0x55, 0xaa
+	binary.LittleEndian.PutUint32(b[440:], 0x1a2b3c4d)
+	b[446+4] = 0x83
+	b[462+4] = 0x0f
+	binary.LittleEndian.PutUint32(b[462+8:], 2048)
+	for i, next := range []uint32{2050, 0} {
+		ebr := b[(2048+2*i)*512:]
+		ebr[510], ebr[511] = 0x55, 0xaa
+		ebr[446+4] = 0x83
+		if next != 0 {
+			ebr[462+4] = 0x05
+			binary.LittleEndian.PutUint32(ebr[462+8:], next-2048)
+		}
+	}
+	return b
+}
+
+func FuzzParsePartitionTable(f *testing.F) {
+	f.Add(diskImage(true))
+	f.Add(diskImage(false))
+	f.Add(extendedImage())
+	f.Add(diskImage(true)[:600])
+	f.Fuzz(func(t *testing.T, b []byte) {
+		pt := parsePartitionTable(bytes.NewReader(b), 512)
+		if pt == nil {
+			return
+		}
+		for n := range pt.PartUUIDs {
+			if n < 1 || n > gptMaxEntries {
+				t.Fatalf("partition number %d", n)
+			}
+		}
+	})
+}
+
+func TestParseExamples(t *testing.T) {
+	src, typ := parseMountinfoRoot(strings.NewReader("1 0 0:1 / / rw - rootfs rootfs rw\n29 1 259:2 / / rw shared:1 master:2 - xfs /dev/disk\\040a rw\n"))
+	if src != "/dev/disk a" || typ != "xfs" {
+		t.Errorf("parseMountinfoRoot = %q, %q", src, typ)
+	}
+	src, typ = parseMountsRoot(strings.NewReader("rootfs / rootfs rw 0 0\n/dev/sda1 / ext4 rw 0 0\n"))
+	if src != "/dev/sda1" || typ != "ext4" {
+		t.Errorf("parseMountsRoot = %q, %q", src, typ)
+	}
+	name, ver := parseOSRelease(strings.NewReader("NAME=\"A \\\"B\\\"\"\nVERSION='1 2'\n"))
+	if name != `A "B"` || ver != "1 2" {
+		t.Errorf("parseOSRelease = %q, %q", name, ver)
+	}
+	if got := parseMemTotalKB(strings.NewReader("MemFree: 1 kB\nMemTotal:  2048 kB\n")); got != 2048 {
+		t.Errorf("parseMemTotalKB = %d", got)
+	}
+	if pt := parsePartitionTable(bytes.NewReader(diskImage(true)), 512); pt == nil || pt.Type != "gpt" ||
+		pt.UUID != "3b0e1a5c-9d1e-4b8a-8f3c-2f1d6e0a7b11" || pt.PartUUIDs[2] != "00000002-0000-0000-0000-000000000000" {
+		t.Errorf("parsePartitionTable(GPT) = %+v", pt)
+	}
+	if b := parseLVMBackup(strings.NewReader(lvmBackupSample)); b == nil || b.VG != "my-vg" ||
+		b.UUID != "Xq3Tz9-aB1c-D2eF-g3H4-i5J6-k7L8-m9N0pQ" || b.PVs["/dev/sda2"] != "hL2m9Q-x4Rt-7vWs-K1pZ-c8Dn-3FgB-Yj6uE0" ||
+		b.LVs["root"] != "Rt7Kp2-Lm4N-q8Vs-1Wx5-Yz0A-b3Cd-6Ef9Gh" || len(b.LVs) != 1 {
+		t.Errorf("parseLVMBackup = %+v", b)
+	}
+	if vg, lv := splitDMName("my--vg-my--lv"); vg != "my-vg" || lv != "my-lv" {
+		t.Errorf("splitDMName = %q, %q", vg, lv)
+	}
+	if vg, lv, ok := lvmDMUUID("LVM-Xq3Tz9aB1cD2eFg3H4i5J6k7L8m9N0pQRt7Kp2Lm4Nq8Vs1Wx5Yz0Ab3Cd6Ef9Gh"); !ok ||
+		vg != "Xq3Tz9-aB1c-D2eF-g3H4-i5J6-k7L8-m9N0pQ" || lv != "Rt7Kp2-Lm4N-q8Vs-1Wx5-Yz0A-b3Cd-6Ef9Gh" {
+		t.Errorf("lvmDMUUID = %q, %q, %v", vg, lv, ok)
+	}
+	if _, _, ok := lvmDMUUID("LVM-Xq3Tz9aB1cD2eFg3H4i5J6k7L8m9N0pQRt7Kp2Lm4Nq8Vs1Wx5Yz0Ab3Cd6Ef9Gh-tpool"); ok {
+		t.Error("lvmDMUUID accepted an internal layer")
+	}
+	if pt := parsePartitionTable(bytes.NewReader(diskImage(false)), 512); pt == nil || pt.Type != "dos" ||
+		pt.UUID != "1a2b3c4d" || pt.PartUUIDs[2] != "1a2b3c4d-02" || len(pt.PartUUIDs) != 2 {
+		t.Errorf("parsePartitionTable(MBR) = %+v", pt)
+	}
+	if pt := parsePartitionTable(bytes.NewReader(extendedImage()), 512); pt == nil || len(pt.PartUUIDs) != 4 ||
+		pt.PartUUIDs[2] != "1a2b3c4d-02" || pt.PartUUIDs[5] != "1a2b3c4d-05" || pt.PartUUIDs[6] != "1a2b3c4d-06" {
+		t.Errorf("parsePartitionTable(extended MBR) = %+v", pt)
+	}
+}
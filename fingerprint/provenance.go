@@ -0,0 +1,220 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Stability classifies how often a field's value changes.
+type Stability string
+
+const (
+	// Immutable values are fixed for the hardware or installation, such
+	// as serial numbers, the machine ID and burned-in MAC addresses.
+	Immutable Stability = "immutable"
+	// Stable values change with administration: upgrades, renames or
+	// replaced parts.
+	Stable Stability = "stable"
+	// Volatile values change while the system runs, such as routes,
+	// leases and wear counters.
+	Volatile Stability = "volatile"
+)
+
+// Provenance tells where the values of a snapshot come from.
+type Provenance struct {
+	// Sources lists per collector the files ("file:/etc/machine-id"),
+	// commands ("command:blkid"), sockets ("socket:/var/run/docker.sock")
+	// and kernel interfaces ("netlink:RTM_GETRULE", "ioctl:/dev/nvme0")
+	// it read successfully.
+	Sources map[string][]string `json:"sources,omitempty"`
+	// Fields maps the JSON path of every field, with "*" for array
+	// indices, to its origin.
+	Fields map[string]FieldProvenance `json:"fields,omitempty"`
+}
+
+// FieldProvenance is the origin of one field.
+type FieldProvenance struct {
+	Collector string    `json:"collector"`
+	Stability Stability `json:"stability"`
+}
+
+// WithProvenance records in Snapshot.Provenance which collector produced
+// each field, what it read, and how stable the field is, for matching
+// logic that weighs fields and for audits. Provenance is not hashed.
+func WithProvenance() Option {
+	return func(o *options) { o.provenance = true }
+}
+
+// stabilities classifies fields by the longest matching path prefix;
+// fields matching none are Stable.
+var stabilities = map[string]Stability{
+	"machine_id":                 Immutable,
+	"dmi.product_uuid":           Immutable,
+	"dmi.board_serial":           Immutable,
+	"dmi.chassis_asset_tag":      Immutable,
+	"dmi.product_serial":         Immutable,
+	"dmi.bios_version":           Volatile,
+	"dmi.bios_date":              Volatile,
+	"cpu.model":                  Immutable,
+	"cpu.vendor":                 Immutable,
+	"cpu.family":                 Immutable,
+	"cpu.model_number":           Immutable,
+	"cpu.stepping":               Immutable,
+	"cpu.packages.*.serial":      Immutable,
+	"memory_modules.*.serial":    Immutable,
+	"network.*.mac":              Immutable,
+	"rootfs.uuid":                Immutable,
+	"rootfs.device":              Volatile,
+	"rootfs.lv_uuid":             Immutable,
+	"storage_health":             Volatile,
+	"storage_health.*.model":     Stable,
+	"storage_health.*.name":      Stable,
+	"storage_health.*.transport": Stable,
+	"storage_health.*.serial":    Immutable,
+	"network_excluded":           Volatile,
+	"network_config":             Volatile,
+	"network_namespaces":         Volatile,
+	"routing":                    Volatile,
+	"neighbors":                  Volatile,
+	"dhcp_leases":                Volatile,
+	"ipv6":                       Volatile,
+	"packages":                   Volatile,
+	"meta":                       Volatile,
+	"meta.build":                 Stable,
+	"agent_runtime":              Volatile,
+	"agent_runtime.goos":         Stable,
+	"agent_runtime.goarch":       Stable,
+
+	"memory.edac.controllers.*.corrected_errors":   Volatile,
+	"memory.edac.controllers.*.uncorrected_errors": Volatile,
+
+	"nvme.*.serial":             Immutable,
+	"nvme.*.namespaces.*.eui64": Immutable,
+	"nvme.*.namespaces.*.nguid": Immutable,
+	"nvme.*.namespaces.*.uuid":  Immutable,
+
+	"lvm.*.uuid":         Immutable,
+	"lvm.*.pvs.*.uuid":   Immutable,
+	"lvm.*.lvs.*.uuid":   Immutable,
+	"lvm.*.lvs.*.device": Volatile,
+
+	"drives.*.serial": Immutable,
+	"drives.*.wwn":    Immutable,
+	"drives.*.source": Volatile,
+}
+
+func stabilityOf(path string) Stability {
+	for p := path; ; {
+		if s, ok := stabilities[p]; ok {
+			return s
+		}
+		i := strings.LastIndexByte(p, '.')
+		if i < 0 {
+			return Stable
+		}
+		p = p[:i]
+	}
+}
+
+// StabilityOf classifies a key of Snapshot.Flatten, e.g. "network.0.mac",
+// including the deprecated aliases of renamed fields.
+func StabilityOf(key string) Stability {
+	segs := strings.Split(key, ".")
+	for i, seg := range segs {
+		if _, err := strconv.Atoi(seg); err == nil {
+			segs[i] = "*"
+		}
+	}
+	for _, r := range renames {
+		if segs[0] == r.old {
+			segs[0] = r.new
+		}
+	}
+	return stabilityOf(strings.Join(segs, "."))
+}
+
+// sourceLog collects the sources one collector run read.
+type sourceLog struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (l *sourceLog) add(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !slices.Contains(l.list, s) {
+		l.list = append(l.list, s)
+	}
+}
+
+func (l *sourceLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := l.list
+	l.list = nil
+	return out
+}
+
+// source records that the running collector read from kind:p.
+func (h host) source(kind, p string) {
+	if h.sources != nil {
+		h.sources.add(kind + ":" + p)
+	}
+}
+
+// setSources stores the sources of collector in s.
+func (s *Snapshot) setSources(collector string, src []string) {
+	if s.Provenance == nil {
+		s.Provenance = &Provenance{}
+	}
+	if s.Provenance.Sources == nil {
+		s.Provenance.Sources = map[string][]string{}
+	}
+	s.Provenance.Sources[collector] = src
+}
+
+// fieldProvenance maps the fields of s to the collectors owning them.
+func fieldProvenance(s Snapshot) map[string]FieldProvenance {
+	owner := map[string]string{}
+	for sec, d := range sections {
+		for _, f := range d.fields {
+			owner[f] = string(sec)
+		}
+	}
+	owner["meta.build"], owner["meta.container"], owner["meta.self_check"] = "meta", "meta", "meta"
+	s.Provenance = nil
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	var v any
+	if json.Unmarshal(b, &v) != nil {
+		return nil
+	}
+	leaves := map[string]string{}
+	flatten("", v, leaves)
+	out := map[string]FieldProvenance{}
+	for k := range leaves {
+		segs := strings.Split(k, ".")
+		for i, seg := range segs {
+			if _, err := strconv.Atoi(seg); err == nil {
+				segs[i] = "*"
+			}
+		}
+		path := strings.Join(segs, ".")
+		c, ok := owner[segs[0]]
+		if !ok && len(segs) > 1 {
+			c, ok = owner[segs[0]+"."+segs[1]]
+		}
+		if !ok && segs[0] == "extensions" && len(segs) > 1 {
+			c, ok = segs[1], true
+		}
+		if ok {
+			out[path] = FieldProvenance{Collector: c, Stability: stabilityOf(path)}
+		}
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+package fingerprint
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/dockerid"
+	"AurFingerprintAgent/retry"
+)
+
+// readyPoll is how often WithWaitForReady rechecks the sources.
+const readyPoll = 250 * time.Millisecond
+
+// Readiness reports the wait of WithWaitForReady.
+type Readiness struct {
+	// WaitedMS is how long the collection was delayed.
+	WaitedMS int64 `json:"waited_ms"`
+	// Pending lists the sources still unavailable when the wait ended;
+	// the snapshot is likely incomplete for them.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// WithWaitForReady delays the collection until the sources that come up
+// late in boot are available, for at most max: udev has processed its
+// event queue, the network has a default route, and the Docker daemon,
+// when installed, answers. Sources that do not apply, such as udev in a
+// container, are not waited for. The outcome is recorded in
+// Snapshot.Meta.Readiness. Only the live system is waited for.
+func WithWaitForReady(max time.Duration) Option {
+	return func(o *options) { o.waitReady = max }
+}
+
+// readySource is a source WithWaitForReady waits for.
+type readySource struct {
+	name string
+	// applies reports whether the system has the source at all.
+	applies func(h host) bool
+	ready   func(ctx context.Context, h host) bool
+}
+
+var readySources = []readySource{
+	{"udev", func(h host) bool { return h.exists("/run/udev/control") },
+		func(_ context.Context, h host) bool { return !h.exists("/run/udev/queue") }},
+	{"network", func(host) bool { return true }, func(_ context.Context, h host) bool { return h.hasDefaultRoute() }},
+	{"docker", func(h host) bool { return h.dockerEndpoint != "" || h.exists("/var/lib/docker") },
+		func(ctx context.Context, h host) bool {
+			// Each poll is a single try; the poll loop retries.
+			c := dockerid.New(h.dockerEndpointOrDefault())
+			c.Retry = retry.Once
+			ctx, cancel := context.WithTimeout(ctx, readyPoll)
+			defer cancel()
+			_, err := c.DaemonID(ctx)
+			return err == nil
+		}},
+}
+
+// waitReady blocks until the applicable sources are ready, max has passed
+// or ctx is done, and reports the outcome.
+func (h host) waitReady(ctx context.Context, max time.Duration) *Readiness {
+	if !h.live() {
+		return nil
+	}
+	start := time.Now()
+	var pending []readySource
+	for _, s := range readySources {
+		if s.applies(h) {
+			pending = append(pending, s)
+		}
+	}
+	deadline := time.NewTimer(max)
+	defer deadline.Stop()
+wait:
+	for {
+		still := pending[:0]
+		for _, s := range pending {
+			if !s.ready(ctx, h) {
+				still = append(still, s)
+			}
+		}
+		pending = still
+		if len(pending) == 0 {
+			break
+		}
+		t := time.NewTimer(readyPoll)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			break wait
+		case <-deadline.C:
+			t.Stop()
+			break wait
+		case <-t.C:
+		}
+	}
+	r := &Readiness{WaitedMS: time.Since(start).Milliseconds()}
+	for _, s := range pending {
+		r.Pending = append(r.Pending, s.name)
+	}
+	h.debug("wait for ready", "waited", time.Since(start), "pending", strings.Join(r.Pending, ","))
+	return r
+}
+
+// exists is a probe and records nothing.
+func (h host) exists(p string) bool {
+	_, err := h.stat(p)
+	return err == nil
+}
+
+// hasDefaultRoute reports whether an IPv4 or IPv6 default route exists.
+func (h host) hasDefaultRoute() bool {
+	if len(h.defaultGateways()) > 0 {
+		return true
+	}
+	for _, r := range h.ipv6Routes() {
+		// dest dlen ...
+		if strings.HasPrefix(r, strings.Repeat("0", 32)+" 00 ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,497 @@
+package fingerprint
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Recorder keeps the raw system files a collection reads, such as
+// /proc/cpuinfo, /etc/os-release, the DMI files and mountinfo, together
+// with the directories listed, the symbolic links followed and the paths
+// probed, so that a snapshot can be reproduced offline with Replay. Live
+// probes that are not files, i.e. external commands, sockets, ioctls and
+// namespaces, are not recorded and are skipped on replay. Configuration
+// files that hold secrets are reduced to the keys the collectors read; see
+// scrub.
+type Recorder struct {
+	mu      sync.Mutex
+	entries map[string]*recEntry
+}
+
+type recEntry struct {
+	mode fs.FileMode // 0, fs.ModeDir or fs.ModeSymlink
+	data []byte
+	// target of a symbolic link, as read from the system.
+	target string
+	// read tells recorded content from an entry only seen to exist.
+	read bool
+}
+
+// NewRecorder returns an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{entries: map[string]*recEntry{}}
+}
+
+// WithRecorder records the files the collection reads in r. It has no
+// effect with WithFS.
+func WithRecorder(r *Recorder) Option {
+	return func(o *options) { o.recorder = r }
+}
+
+func (r *Recorder) entry(p string) *recEntry {
+	p = path.Clean(p)
+	e := r.entries[p]
+	if e == nil {
+		e = &recEntry{}
+		r.entries[p] = e
+	}
+	return e
+}
+
+// file records the content of file p.
+func (r *Recorder) file(p string, b []byte) {
+	b = scrub(p, b)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e := r.entry(p); e.mode != fs.ModeSymlink {
+		e.mode, e.data, e.read = 0, b, true
+	}
+}
+
+// scrub reduces the network and cloud configuration files that may hold
+// Wi-Fi PSKs, VPN and 802.1X secrets or cloud metadata to what the
+// collectors read from them. Other files are kept verbatim.
+func scrub(p string, b []byte) []byte {
+	name := path.Base(p)
+	switch {
+	case strings.HasSuffix(name, ".nmconnection"):
+		return scrubINI(b)
+	case strings.HasPrefix(name, "ifcfg-"):
+		return scrubShellVars(b)
+	case strings.Contains(p, "/netplan/") && strings.HasSuffix(name, ".yaml"):
+		return scrubNetplan(b)
+	case p == "/run/cloud-init/instance-data.json":
+		var doc struct {
+			V1 cloudV1 `json:"v1"`
+		}
+		if json.Unmarshal(b, &doc) != nil {
+			return nil
+		}
+		out, _ := json.Marshal(doc)
+		return out
+	}
+	return b
+}
+
+// nmKeys are the keyfile settings readINI callers use.
+var nmKeys = map[string]bool{
+	"connection.type": true, "connection.interface-name": true, "ipv6.addr-gen-mode": true,
+	"ethernet.mac-address": true, "802-3-ethernet.mac-address": true,
+	"wifi.mac-address": true, "802-11-wireless.mac-address": true,
+}
+
+func scrubINI(b []byte) []byte {
+	var out bytes.Buffer
+	section := ""
+	for _, ln := range strings.Split(string(b), "\n") {
+		t := strings.TrimSpace(ln)
+		if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") {
+			section = t[1 : len(t)-1]
+			fmt.Fprintln(&out, t)
+			continue
+		}
+		if k, _, ok := strings.Cut(t, "="); ok && nmKeys[section+"."+strings.TrimSpace(k)] {
+			fmt.Fprintln(&out, t)
+		}
+	}
+	return out.Bytes()
+}
+
+// ifcfgKeys are the variables ifcfgIfaces uses.
+var ifcfgKeys = map[string]bool{"TYPE": true, "DEVICE": true, "HWADDR": true, "MACADDR": true}
+
+func scrubShellVars(b []byte) []byte {
+	var out bytes.Buffer
+	for _, ln := range strings.Split(string(b), "\n") {
+		t := strings.TrimSpace(ln)
+		if k, _, ok := strings.Cut(t, "="); ok && ifcfgKeys[strings.TrimSpace(k)] {
+			fmt.Fprintln(&out, t)
+		}
+	}
+	return out.Bytes()
+}
+
+// scrubNetplan keeps the interface matching keys netplanIfaces uses and
+// drops the rest, e.g. the access point passwords of wifis.
+func scrubNetplan(b []byte) []byte {
+	type match struct {
+		Name       string `yaml:"name,omitempty"`
+		MACAddress string `yaml:"macaddress,omitempty"`
+	}
+	type iface struct {
+		Match      match  `yaml:"match,omitempty"`
+		SetName    string `yaml:"set-name,omitempty"`
+		MACAddress string `yaml:"macaddress,omitempty"`
+	}
+	var doc struct {
+		Network struct {
+			Ethernets map[string]iface `yaml:"ethernets,omitempty"`
+			Wifis     map[string]iface `yaml:"wifis,omitempty"`
+		} `yaml:"network"`
+	}
+	if yaml.Unmarshal(b, &doc) != nil {
+		return nil
+	}
+	out, _ := yaml.Marshal(doc)
+	return out
+}
+
+// exists records that p exists, e.g. after a successful stat.
+func (r *Recorder) exists(p string, dir bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e := r.entry(p); dir && e.mode == 0 && !e.read {
+		e.mode = fs.ModeDir
+	}
+}
+
+func (r *Recorder) link(p, target string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(p)
+	e.mode, e.target = fs.ModeSymlink, target
+}
+
+// dir records the listing of directory p; readlink reads the targets of
+// the symbolic links in it.
+func (r *Recorder) dir(p string, entries []fs.DirEntry, readlink func(string) (string, error)) {
+	r.exists(p, true)
+	for _, e := range entries {
+		c := path.Join(p, e.Name())
+		switch {
+		case e.Type()&fs.ModeSymlink != 0:
+			if t, err := readlink(c); err == nil {
+				r.link(c, t)
+			}
+		default:
+			r.exists(c, e.IsDir())
+		}
+	}
+}
+
+// recFile records what is read from an opened file when it is closed.
+type recFile struct {
+	fs.File
+	buf bytes.Buffer
+	p   string
+	r   *Recorder
+}
+
+func (f *recFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.buf.Write(b[:n])
+	return n, err
+}
+
+func (f *recFile) Close() error {
+	f.r.file(f.p, f.buf.Bytes())
+	return f.File.Close()
+}
+
+// recFS records the reads of collectors that take an fs.FS, such as the
+// efivars reader. Names are relative to the system root.
+type recFS struct {
+	fsys fs.FS
+	r    *Recorder
+}
+
+func (f recFS) Open(name string) (fs.File, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &recFile{File: file, p: "/" + name, r: f.r}, nil
+}
+
+func (f recFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := fs.ReadDir(f.fsys, name)
+	if err == nil {
+		f.r.dir("/"+name, e, func(string) (string, error) { return "", errors.ErrUnsupported })
+	}
+	return e, err
+}
+
+func (f recFS) Stat(name string) (fs.FileInfo, error) {
+	fi, err := fs.Stat(f.fsys, name)
+	if err == nil {
+		f.r.exists("/"+name, fi.IsDir())
+	}
+	return fi, err
+}
+
+// recording reports whether h records what it reads.
+func (h host) recording() bool { return h.rec != nil && h.fsys == nil }
+
+// recordLink records the link p resolved to r by following it on the live
+// system, with the target's existence.
+func (h host) recordLink(p, r string) {
+	if !h.recording() || p == r {
+		return
+	}
+	h.rec.link(p, r)
+	if fi, err := os.Stat(h.path(r)); err == nil {
+		h.rec.exists(r, fi.IsDir())
+	}
+}
+
+// WriteTo writes the recording as a gzip-compressed tar archive laid out
+// like the system root, which can also be unpacked and used with
+// WithRootPrefix or as a test corpus.
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cw := &countWriter{w: w}
+	zw := gzip.NewWriter(cw)
+	tw := tar.NewWriter(zw)
+	err := r.write(tw)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	return cw.n, err
+}
+
+func (r *Recorder) write(tw *tar.Writer) error {
+	// Entries below a symbolic link belong below its target, as on the
+	// system; parents of recorded entries are directories.
+	tree := map[string]*recEntry{}
+	for p, e := range r.entries {
+		if e.mode == fs.ModeSymlink {
+			p = path.Join(r.resolve(path.Dir(p)), path.Base(p))
+		} else {
+			p = r.resolve(p)
+		}
+		if p == "" || p == "/" {
+			continue
+		}
+		if old := tree[p]; old == nil || old.mode != fs.ModeSymlink && !old.read {
+			tree[p] = e
+		}
+	}
+	for p := range tree {
+		for d := path.Dir(p); d != "/"; d = path.Dir(d) {
+			if e := tree[d]; e == nil || e.mode == 0 && !e.read {
+				tree[d] = &recEntry{mode: fs.ModeDir}
+			}
+		}
+	}
+	names := slices.Sorted(func(yield func(string) bool) {
+		for p := range tree {
+			if !yield(p) {
+				return
+			}
+		}
+	})
+	now := time.Now()
+	for _, p := range names {
+		e := tree[p]
+		hdr := &tar.Header{Name: strings.TrimPrefix(p, "/"), ModTime: now, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.data))}
+		switch e.mode {
+		case fs.ModeDir:
+			hdr.Name += "/"
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0o755, 0
+		case fs.ModeSymlink:
+			hdr.Typeflag, hdr.Linkname, hdr.Mode, hdr.Size = tar.TypeSymlink, e.target, 0o777, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(e.data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve maps p to its location once the recorded links among its
+// components are followed.
+func (r *Recorder) resolve(p string) string {
+	for hops := 0; hops < 40; hops++ {
+		moved := false
+		for d := p; d != "/" && d != "."; d = path.Dir(d) {
+			e := r.entries[d]
+			if e == nil || e.mode != fs.ModeSymlink {
+				continue
+			}
+			t := e.target
+			if !path.IsAbs(t) {
+				t = path.Join(path.Dir(d), t)
+			}
+			p, moved = path.Join(t, strings.TrimPrefix(p, d)), true
+			break
+		}
+		if !moved {
+			return p
+		}
+	}
+	return ""
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadBundle loads a recording written by Recorder.WriteTo, or any
+// gzip-compressed tar archive of a system root, as a file system for
+// WithFS that follows its symbolic links.
+func ReadBundle(r io.Reader) (ReadLinkFS, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	b := bundleFS{files: fstest.MapFS{}, links: map[string]string{}}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		name := fsName("/" + hdr.Name)
+		if name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			b.files[name] = &fstest.MapFile{Mode: fs.ModeDir | 0o755, ModTime: hdr.ModTime}
+		case tar.TypeSymlink:
+			b.files[name] = &fstest.MapFile{Mode: fs.ModeSymlink | 0o777, ModTime: hdr.ModTime}
+			b.links[name] = hdr.Linkname
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("bundle: %w", err)
+			}
+			b.files[name] = &fstest.MapFile{Data: data, Mode: 0o644, ModTime: hdr.ModTime}
+		}
+	}
+	return b, nil
+}
+
+// Replay reconstructs a snapshot from a bundle read with ReadBundle, for
+// offline debugging of parsing problems on other machines. Opt-in
+// collectors recorded must be enabled again through opts.
+func Replay(r io.Reader, opts ...Option) (Snapshot, error) {
+	fsys, err := ReadBundle(r)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return GetSnapshot(append(opts, WithFS(fsys))...), nil
+}
+
+// bundleFS is an in-memory file system with symbolic links.
+type bundleFS struct {
+	files fstest.MapFS
+	links map[string]string
+}
+
+// resolve follows the links among the components of name, and the last
+// one when final is set.
+func (b bundleFS) resolve(op, name string, final bool) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	todo := strings.Split(name, "/")
+	resolved := "."
+	for hops := 0; len(todo) > 0; {
+		c := todo[0]
+		todo = todo[1:]
+		if c == "." || c == "" {
+			continue
+		}
+		if c == ".." {
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, c)
+		t, ok := b.links[next]
+		if !ok || (!final && len(todo) == 0) {
+			resolved = next
+			continue
+		}
+		if hops++; hops > 40 {
+			return "", &fs.PathError{Op: op, Path: name, Err: errors.New("too many links")}
+		}
+		if path.IsAbs(t) {
+			resolved = "."
+		}
+		todo = append(strings.Split(t, "/"), todo...)
+	}
+	return resolved, nil
+}
+
+func (b bundleFS) Open(name string) (fs.File, error) {
+	n, err := b.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return b.files.Open(n)
+}
+
+func (b bundleFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := b.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return b.files.ReadDir(n)
+}
+
+func (b bundleFS) Stat(name string) (fs.FileInfo, error) {
+	n, err := b.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return b.files.Stat(n)
+}
+
+func (b bundleFS) ReadLink(name string) (string, error) {
+	n, err := b.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if t, ok := b.links[n]; ok {
+		return t, nil
+	}
+	if _, err := b.files.Stat(n); err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
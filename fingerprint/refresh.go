@@ -0,0 +1,214 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Section names the part of a snapshot one collector produces. Sections of
+// collectors added with Register or WithCollector are named after them and
+// live in Snapshot.Extensions.
+type Section string
+
+const (
+	SectionHostname      Section = "hostname"
+	SectionOS            Section = "os"
+	SectionMachineID     Section = "machine_id"
+	SectionDMI           Section = "dmi"
+	SectionCPU           Section = "cpu"
+	SectionMemory        Section = "memory"
+	SectionMemoryModules Section = "memory_modules"
+	SectionNetwork       Section = "network"
+	SectionNetworkConfig Section = "network_config"
+	SectionNetNamespaces Section = "netns"
+	SectionRouting       Section = "routing"
+	SectionNeighbors     Section = "neighbors"
+	SectionDHCP          Section = "dhcp"
+	SectionIPv6          Section = "ipv6"
+	SectionRootFS        Section = "rootfs"
+	SectionBlockDevices  Section = "block_devices"
+	SectionNVMe          Section = "nvme"
+	SectionLVM           Section = "lvm"
+	SectionDriveIdentity Section = "drive_identity"
+	SectionStorageHealth Section = "storage_health"
+	SectionDocker        Section = "docker"
+	SectionFirmware      Section = "firmware"
+	SectionBoot          Section = "boot"
+	SectionSecurity      Section = "security"
+	SectionCloud         Section = "cloud"
+	SectionAgentRuntime  Section = "agent_runtime"
+	SectionLabels        Section = "labels"
+	SectionMeta          Section = "meta"
+	SectionPlugins       Section = "plugins"
+	SectionPackages      Section = "packages"
+	SectionPCI           Section = "pci"
+	SectionUSB           Section = "usb"
+)
+
+// SectionGoRuntime is the former name of SectionAgentRuntime.
+//
+// Deprecated: use SectionAgentRuntime.
+const SectionGoRuntime = SectionAgentRuntime
+
+// section describes the fields a built-in collector sets, by JSON name,
+// and how to copy them.
+type section struct {
+	fields []string
+	copy   func(dst, src *Snapshot)
+}
+
+var sections = map[Section]section{
+	SectionHostname:  {[]string{"hostname"}, func(d, s *Snapshot) { d.Hostname = s.Hostname }},
+	SectionOS:        {[]string{"os"}, func(d, s *Snapshot) { d.OS = s.OS }},
+	SectionMachineID: {[]string{"machine_id"}, func(d, s *Snapshot) { d.MachineID = s.MachineID }},
+	SectionDMI:       {[]string{"dmi"}, func(d, s *Snapshot) { d.DMI = s.DMI }},
+	SectionCPU:       {[]string{"cpu"}, func(d, s *Snapshot) { d.CPU = s.CPU }},
+	SectionMemory:    {[]string{"memory"}, func(d, s *Snapshot) { d.Memory = s.Memory }},
+	SectionNetwork: {[]string{"network", "network_excluded"}, func(d, s *Snapshot) {
+		d.Network, d.NetworkExcluded = s.Network, s.NetworkExcluded
+	}},
+	SectionMemoryModules: {[]string{"memory_modules"}, func(d, s *Snapshot) { d.MemoryModules = s.MemoryModules }},
+	SectionNetworkConfig: {[]string{"network_config"}, func(d, s *Snapshot) { d.NetConfig = s.NetConfig }},
+	SectionNetNamespaces: {[]string{"network_namespaces"}, func(d, s *Snapshot) { d.NetNamespaces = s.NetNamespaces }},
+	SectionRouting:       {[]string{"routing"}, func(d, s *Snapshot) { d.Routing = s.Routing }},
+	SectionNeighbors:     {[]string{"neighbors"}, func(d, s *Snapshot) { d.Neighbors = s.Neighbors }},
+	SectionDHCP:          {[]string{"dhcp_leases"}, func(d, s *Snapshot) { d.DHCP = s.DHCP }},
+	SectionIPv6:          {[]string{"ipv6"}, func(d, s *Snapshot) { d.IPv6 = s.IPv6 }},
+	SectionRootFS:        {[]string{"rootfs"}, func(d, s *Snapshot) { d.RootFS = s.RootFS }},
+	SectionBlockDevices:  {[]string{"block_devices"}, func(d, s *Snapshot) { d.BlockDevices = s.BlockDevices }},
+	SectionNVMe:          {[]string{"nvme"}, func(d, s *Snapshot) { d.NVMe = s.NVMe }},
+	SectionLVM:           {[]string{"lvm"}, func(d, s *Snapshot) { d.LVM = s.LVM }},
+	SectionDriveIdentity: {[]string{"drives"}, func(d, s *Snapshot) { d.Drives = s.Drives }},
+	SectionStorageHealth: {[]string{"storage_health"}, func(d, s *Snapshot) { d.Storage = s.Storage }},
+	SectionDocker:        {[]string{"docker"}, func(d, s *Snapshot) { d.Docker = s.Docker }},
+	SectionFirmware:      {[]string{"firmware"}, func(d, s *Snapshot) { d.Firmware = s.Firmware }},
+	SectionBoot:          {[]string{"boot"}, func(d, s *Snapshot) { d.Boot = s.Boot }},
+	SectionSecurity:      {[]string{"security"}, func(d, s *Snapshot) { d.Security = s.Security }},
+	SectionCloud:         {[]string{"cloud"}, func(d, s *Snapshot) { d.Cloud = s.Cloud }},
+	SectionAgentRuntime:  {[]string{"agent_runtime"}, func(d, s *Snapshot) { d.Agent = s.Agent }},
+	SectionLabels:        {[]string{"labels"}, func(d, s *Snapshot) { d.Labels = s.Labels }},
+	// The meta collector owns only part of Meta; the rest is merged by
+	// Refresh.
+	SectionMeta: {nil, func(d, s *Snapshot) {
+		var m Meta
+		if d.Meta != nil {
+			m = *d.Meta
+		}
+		m.Build, m.Container, m.SelfCheck = nil, nil, nil
+		if s.Meta != nil {
+			m.Build, m.Container, m.SelfCheck = s.Meta.Build, s.Meta.Container, s.Meta.SelfCheck
+		}
+		d.Meta = &m
+	}},
+	SectionPlugins:  {[]string{"custom"}, func(d, s *Snapshot) { d.Custom = s.Custom }},
+	SectionPackages: {[]string{"packages"}, func(d, s *Snapshot) { d.Packages = s.Packages }},
+	SectionPCI:      {[]string{"pci"}, func(d, s *Snapshot) { d.PCI = s.PCI }},
+	SectionUSB:      {[]string{"usb"}, func(d, s *Snapshot) { d.USB = s.USB }},
+}
+
+// Refresh re-collects the given sections, e.g. SectionNetwork after a
+// hotplug, and keeps the rest of s, which is far cheaper than a new
+// snapshot. Collectors run with the options s was collected with, or the
+// defaults for a decoded snapshot; opt-in sections are collected when
+// named. Collectors that build on other sections see those of s. The
+// errors, skips and truncations of the refreshed sections are replaced.
+// Refresh does not modify memory s shares with copies of it.
+func (s *Snapshot) Refresh(ctx context.Context, secs ...Section) error {
+	o := buildOptions(nil)
+	if s.opts != nil {
+		o = *s.opts
+	}
+	names := map[string]bool{}
+	for _, sec := range secs {
+		sec = Section(collectorName(string(sec)))
+		if _, ok := sections[sec]; !ok && !registered(&o, string(sec)) {
+			return fmt.Errorf("fingerprint: unknown section %q", sec)
+		}
+		names[string(sec)] = true
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	o.only = names
+	seed := *s
+	seed.Errors, seed.Meta, seed.Confidence, seed.Provenance = nil, nil, nil, nil
+	seed.Extensions = maps.Clone(s.Extensions)
+	part := collectSome(ctx, o, nil, seed, nil)
+
+	fields := map[string]bool{}
+	for n := range names {
+		if sec, ok := sections[Section(n)]; ok {
+			sec.copy(s, &part)
+			for _, f := range sec.fields {
+				fields[f] = true
+			}
+			continue
+		}
+		s.Extensions = maps.Clone(s.Extensions)
+		if v, ok := part.Extensions[n]; ok {
+			s.SetExtension(n, v)
+		} else {
+			delete(s.Extensions, n)
+		}
+	}
+	ours := func(field string) bool {
+		top, _, _ := strings.Cut(field, ".")
+		return fields[top]
+	}
+	s.Errors = slices.DeleteFunc(slices.Clone(s.Errors), func(e CollectorError) bool { return names[e.Collector] })
+	s.Errors = append(s.Errors, part.Errors...)
+	if s.Meta != nil || part.Meta != nil {
+		var m Meta
+		if s.Meta != nil {
+			m = *s.Meta
+		}
+		m.Skipped = slices.DeleteFunc(slices.Clone(m.Skipped), func(k Skipped) bool { return names[k.Collector] })
+		m.Truncated = slices.DeleteFunc(slices.Clone(m.Truncated), func(t Truncation) bool { return ours(t.Field) })
+		if part.Meta != nil {
+			m.Skipped = append(m.Skipped, part.Meta.Skipped...)
+			for _, t := range part.Meta.Truncated {
+				if ours(t.Field) {
+					m.Truncated = append(m.Truncated, t)
+				}
+			}
+		}
+		s.Meta = &m
+		if m.Build == nil && m.Container == nil && m.SelfCheck == nil && m.Readiness == nil && len(m.Skipped) == 0 && len(m.Truncated) == 0 {
+			s.Meta = nil
+		}
+	}
+	if part.Provenance != nil {
+		p := &Provenance{}
+		if s.Provenance != nil {
+			p.Sources = maps.Clone(s.Provenance.Sources)
+			maps.DeleteFunc(p.Sources, func(c string, _ []string) bool { return names[c] })
+		}
+		for c, src := range part.Provenance.Sources {
+			if p.Sources == nil {
+				p.Sources = map[string][]string{}
+			}
+			p.Sources[c] = src
+		}
+		s.Provenance = p
+		p.Fields = fieldProvenance(*s)
+	}
+	s.SchemaVersion = SchemaVersion
+	s.Confidence = o.host().fingerprintConfidence(*s)
+	return nil
+}
+
+// registered reports whether a collector outside the built-ins is named
+// name in collections with o.
+func registered(o *options, name string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, c := range slices.Concat(registry.list, o.extra) {
+		if _, ok := c.(builtin); !ok && c.Name() == name {
+			return true
+		}
+	}
+	return false
+}
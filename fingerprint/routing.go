@@ -0,0 +1,105 @@
+package fingerprint
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RoutingInfo fingerprints the main routing table and the policy rules so
+// configuration drift shows up without storing the tables. Volatile data
+// such as reference counts, use counters and cached routes is left out.
+type RoutingInfo struct {
+	Routes     int    `json:"routes"`
+	RoutesHash string `json:"routes_hash,omitempty"`
+	Rules      int    `json:"rules"`
+	RulesHash  string `json:"rules_hash,omitempty"`
+}
+
+// rtfCache flags IPv6 routes cloned into the route cache.
+const rtfCache = 0x01000000
+
+func (h host) routing() *RoutingInfo {
+	routes := append(h.ipv4Routes(), h.ipv6Routes()...)
+	var rules []string
+	if h.native() {
+		rules = policyRules()
+		h.debug("netlink", "request", "RTM_GETRULE", "rules", len(rules))
+		h.source("netlink", "RTM_GETRULE")
+	}
+	if len(routes) == 0 && len(rules) == 0 {
+		return nil
+	}
+	r := &RoutingInfo{Routes: len(routes), Rules: len(rules)}
+	r.RoutesHash = linesHash(routes)
+	r.RulesHash = linesHash(rules)
+	return r
+}
+
+// ipv4Routes returns the main table from /proc/net/route without the
+// RefCnt and Use counters.
+func (h host) ipv4Routes() []string {
+	f, err := h.open(h.procSelf() + "/net/route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		c := strings.Fields(sc.Text())
+		if len(c) < 11 {
+			continue
+		}
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		out = append(out, strings.Join(append(c[:4:4], c[6:11]...), " "))
+	}
+	return out
+}
+
+// ipv6Routes returns the IPv6 main table. On a live host it is read over
+// rtnetlink; /proc/net/ipv6_route, the fallback for file trees, lists every
+// table and is kept without loopback, cached routes and the refcnt and use
+// counters.
+func (h host) ipv6Routes() []string {
+	if h.native() {
+		if routes, ok := mainIPv6Routes(); ok {
+			h.debug("netlink", "request", "RTM_GETROUTE", "routes", len(routes))
+			h.source("netlink", "RTM_GETROUTE")
+			return routes
+		}
+	}
+	f, err := h.open(h.procSelf() + "/net/ipv6_route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		c := strings.Fields(sc.Text())
+		if len(c) < 10 || c[9] == "lo" {
+			continue
+		}
+		// dest dlen src slen nexthop metric refcnt use flags dev
+		if flags, err := strconv.ParseUint(c[8], 16, 32); err != nil || flags&rtfCache != 0 {
+			continue
+		}
+		out = append(out, strings.Join(append(c[:6:6], c[8:10]...), " "))
+	}
+	return out
+}
+
+// linesHash returns the hex SHA-256 of the sorted lines, or "" for none.
+func linesHash(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// policyRules dumps the IPv4 and IPv6 policy rules of the agent's network
+// namespace over rtnetlink. Each rule is rendered from its header and its
+// attributes sorted by type, which is stable across dumps.
+func policyRules() []string {
+	var out []string
+	for _, family := range []int{syscall.AF_INET, syscall.AF_INET6} {
+		rib, err := syscall.NetlinkRIB(syscall.RTM_GETRULE, family)
+		if err != nil {
+			continue
+		}
+		msgs, err := syscall.ParseNetlinkMessage(rib)
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			if m.Header.Type != syscall.RTM_NEWRULE || len(m.Data) < 12 {
+				continue
+			}
+			out = append(out, formatRule(m.Data))
+		}
+	}
+	return out
+}
+
+// Route attributes not in package syscall.
+const (
+	rtaTable   = 15
+	rtaExpires = 23
+)
+
+// mainIPv6Routes dumps the IPv6 routes of the main table over rtnetlink,
+// since /proc/net/ipv6_route lists every table. Cached routes, routes via
+// lo and the volatile cache and expiry attributes are left out; the output
+// interface is rendered by name. ok is false when the dump fails.
+func mainIPv6Routes() (routes []string, ok bool) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, syscall.AF_INET6)
+	if err != nil {
+		return nil, false
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, false
+	}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < syscall.SizeofRtMsg {
+			continue
+		}
+		table := uint32(m.Data[4])
+		flags := binary.NativeEndian.Uint32(m.Data[8:])
+		var attrs []string
+		var dev string
+		for a := m.Data[syscall.SizeofRtMsg:]; len(a) >= 4; {
+			n := int(binary.NativeEndian.Uint16(a))
+			if n < 4 || n > len(a) {
+				break
+			}
+			v := a[4:n]
+			switch typ := binary.NativeEndian.Uint16(a[2:]) &^ syscall.NLA_F_NESTED; {
+			case typ == rtaTable && len(v) == 4:
+				table = binary.NativeEndian.Uint32(v)
+			case typ == syscall.RTA_OIF && len(v) == 4:
+				if ifi, err := net.InterfaceByIndex(int(binary.NativeEndian.Uint32(v))); err == nil {
+					dev = ifi.Name
+				}
+				attrs = append(attrs, "dev="+dev)
+			case typ != syscall.RTA_CACHEINFO && typ != rtaExpires:
+				attrs = append(attrs, fmt.Sprintf("%d=%s", typ, hex.EncodeToString(v)))
+			}
+			a = a[min((n+3)&^3, len(a)):]
+		}
+		if table != syscall.RT_TABLE_MAIN || flags&syscall.RTM_F_CLONED != 0 || dev == "lo" {
+			continue
+		}
+		sort.Strings(attrs)
+		hdr := slices.Clone(m.Data[:syscall.SizeofRtMsg])
+		hdr[4] = 0 // table, already filtered on
+		routes = append(routes, hex.EncodeToString(hdr)+" "+strings.Join(attrs, " "))
+	}
+	return routes, true
+}
+
+// formatRule renders a fib_rule_hdr and its attributes.
+func formatRule(b []byte) string {
+	var attrs []string
+	for a := b[12:]; len(a) >= 4; {
+		n := int(binary.NativeEndian.Uint16(a))
+		if n < 4 || n > len(a) {
+			break
+		}
+		typ := binary.NativeEndian.Uint16(a[2:]) &^ syscall.NLA_F_NESTED
+		attrs = append(attrs, fmt.Sprintf("%d=%s", typ, hex.EncodeToString(a[4:n])))
+		a = a[min((n+3)&^3, len(a)):]
+	}
+	sort.Strings(attrs)
+	return hex.EncodeToString(b[:12]) + " " + strings.Join(attrs, " ")
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package fingerprint
+
+func policyRules() []string { return nil }
+
+func mainIPv6Routes() ([]string, bool) { return nil, false }
@@ -0,0 +1,142 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the layout of Snapshot this package writes. Bump it, and
+// add the step from the previous layout to migrations, whenever a field is
+// renamed, moved or changes type; fields that are only added need neither.
+// Top-level renames go to renames instead, which also keeps the old name
+// as an alias for a while.
+const SchemaVersion = 2
+
+// migrations[v] rewrites the top-level JSON object of a version v snapshot
+// into version v+1.
+var migrations = map[int]func(map[string]json.RawMessage) error{}
+
+// rename is a top-level field renamed in schema version since.
+type rename struct {
+	old, new string
+	since    int
+}
+
+var renames = []rename{
+	{"go_runtime", "agent_runtime", 2},
+}
+
+// collectorName maps the old name of a collector, named after a renamed
+// field, to its current one.
+func collectorName(name string) string {
+	for _, r := range renames {
+		if name == r.old {
+			return r.new
+		}
+	}
+	return name
+}
+
+func init() {
+	for _, r := range renames {
+		prev := migrations[r.since-1]
+		migrations[r.since-1] = func(m map[string]json.RawMessage) error {
+			if prev != nil {
+				if err := prev(m); err != nil {
+					return err
+				}
+			}
+			if v, ok := m[r.old]; ok {
+				if _, ok := m[r.new]; !ok {
+					m[r.new] = v
+				}
+				delete(m, r.old)
+			}
+			return nil
+		}
+	}
+}
+
+// DefaultAliasVersions is for how many schema versions a renamed field is
+// still written under its old name as well, so that ingestion pipelines
+// keep working until they move to the new name.
+const DefaultAliasVersions = 2
+
+// WithDeprecatedAliases writes renamed fields under their old names as
+// well for n schema versions after the rename; 0 writes the new names
+// only. Snapshots decoded with Unmarshal use DefaultAliasVersions.
+func WithDeprecatedAliases(n int) Option {
+	return func(o *options) { o.aliasVersions = n }
+}
+
+// MarshalJSON writes s with the deprecated aliases of renamed fields
+// appended.
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	type plain Snapshot
+	b, err := json.Marshal(plain(s))
+	if err != nil {
+		return nil, err
+	}
+	n := DefaultAliasVersions
+	if s.opts != nil {
+		n = s.opts.aliasVersions
+	}
+	var m map[string]json.RawMessage
+	for _, r := range renames {
+		if SchemaVersion >= r.since+n {
+			continue
+		}
+		if m == nil {
+			if err := json.Unmarshal(b, &m); err != nil {
+				return nil, err
+			}
+		}
+		if v, ok := m[r.new]; ok {
+			if _, dup := m[r.old]; !dup {
+				b = append(b[:len(b)-1], `,"`+r.old+`":`...)
+				b = append(append(b, v...), '}')
+			}
+		}
+	}
+	return b, nil
+}
+
+// Unmarshal decodes a stored snapshot, upgrading it from the layout of the
+// schema_version it was written with to the current one. Snapshots without
+// schema_version predate the field and have the layout of version 1.
+// Snapshots written by a newer release are decoded as far as this release
+// understands them and keep their SchemaVersion, so callers can tell.
+func Unmarshal(b []byte) (Snapshot, error) {
+	var s Snapshot
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return s, err
+	}
+	v := 1
+	if raw, ok := m["schema_version"]; ok {
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return s, fmt.Errorf("schema_version: %w", err)
+		}
+	}
+	if v < 1 {
+		return s, fmt.Errorf("schema_version %d: unknown layout", v)
+	}
+	if v < SchemaVersion {
+		for ; v < SchemaVersion; v++ {
+			if mig := migrations[v]; mig != nil {
+				if err := mig(m); err != nil {
+					return s, fmt.Errorf("schema_version %d to %d: %w", v, v+1, err)
+				}
+			}
+		}
+		var err error
+		if b, err = json.Marshal(m); err != nil {
+			return s, err
+		}
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, err
+	}
+	s.SchemaVersion = v
+	return s, nil
+}
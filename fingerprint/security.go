@@ -0,0 +1,59 @@
+package fingerprint
+
+import (
+	"path"
+	"strings"
+)
+
+// SecurityInfo reports the security posture of the host, starting with
+// the kernel's view of the CPU's speculative execution vulnerabilities.
+type SecurityInfo struct {
+	Vulnerabilities []CPUVulnerability `json:"cpu_vulnerabilities,omitempty"`
+}
+
+// CPUVulnerability is one file of /sys/devices/system/cpu/vulnerabilities.
+type CPUVulnerability struct {
+	// Name is the file name, e.g. "spectre_v2" or "mds".
+	Name string `json:"name"`
+	// Status is "not_affected", "mitigated", "partial" (a mitigation with
+	// a part still vulnerable), "vulnerable" or "unknown".
+	Status string `json:"status"`
+	// Detail is the kernel's text, e.g. "Mitigation: PTI".
+	Detail string `json:"detail,omitempty"`
+}
+
+const vulnerabilitiesDir = "/sys/devices/system/cpu/vulnerabilities"
+
+// securityInfo returns nil on kernels before 4.15 and systems without
+// sysfs.
+func (h host) securityInfo() *SecurityInfo {
+	var v []CPUVulnerability
+	for _, p := range h.glob(vulnerabilitiesDir + "/*") {
+		b, err := h.readFile(p)
+		if err != nil {
+			continue
+		}
+		detail := strings.TrimSpace(string(b))
+		v = append(v, CPUVulnerability{Name: path.Base(p), Status: vulnerabilityStatus(detail), Detail: detail})
+	}
+	if v == nil {
+		return nil
+	}
+	return &SecurityInfo{Vulnerabilities: v}
+}
+
+func vulnerabilityStatus(detail string) string {
+	switch {
+	case detail == "Not affected":
+		return "not_affected"
+	case strings.HasPrefix(detail, "Mitigation"):
+		// e.g. "Mitigation: Enhanced IBRS; BHI: Vulnerable"
+		if strings.Contains(detail, "Vulnerable") {
+			return "partial"
+		}
+		return "mitigated"
+	case strings.HasPrefix(detail, "Vulnerable"):
+		return "vulnerable"
+	}
+	return "unknown"
+}
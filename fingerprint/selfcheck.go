@@ -0,0 +1,106 @@
+package fingerprint
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// SelfCheck is the result of hashing the running agent executable.
+//
+// A binary cannot embed its own digest, so verification uses either an
+// expected digest supplied from outside or a detached Ed25519 signature over
+// the SHA-256 digest, stored next to the executable as "<exe>.sig" and
+// checked against a public key embedded at build time.
+type SelfCheck struct {
+	Executable string `json:"executable,omitempty"`
+	SHA256     string `json:"sha256,omitempty"`
+	Method     string `json:"method"`
+	Verified   bool   `json:"verified"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Self-check methods.
+const (
+	SelfCheckNone      = "none"
+	SelfCheckDigest    = "digest"
+	SelfCheckSignature = "signature"
+)
+
+type selfCheckConfig struct {
+	digest string
+	key    ed25519.PublicKey
+}
+
+// WithSelfCheck hashes /proc/self/exe and records the result in
+// Snapshot.Meta.SelfCheck. digest, if non-empty, is the expected hex
+// SHA-256; otherwise key verifies the detached signature. With neither the
+// digest is only reported.
+func WithSelfCheck(digest string, key ed25519.PublicKey) Option {
+	return func(o *options) { o.selfCheck = &selfCheckConfig{digest: digest, key: key} }
+}
+
+func runSelfCheck(cfg *selfCheckConfig) *SelfCheck {
+	sc := &SelfCheck{Method: SelfCheckNone}
+	exe, err := os.Executable()
+	if err != nil {
+		sc.Error = err.Error()
+		return sc
+	}
+	sc.Executable = exe
+	f, err := fdcap.Default.Open("/proc/self/exe")
+	if err != nil {
+		sc.Error = err.Error()
+		return sc
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		sc.Error = err.Error()
+		return sc
+	}
+	sum := h.Sum(nil)
+	sc.SHA256 = hex.EncodeToString(sum)
+	switch {
+	case cfg.digest != "":
+		sc.Method = SelfCheckDigest
+		sc.Verified = strings.EqualFold(strings.TrimSpace(cfg.digest), sc.SHA256)
+		if !sc.Verified {
+			sc.Error = "digest mismatch"
+		}
+	case len(cfg.key) == ed25519.PublicKeySize:
+		sc.Method = SelfCheckSignature
+		sig, err := readDetachedSig(exe + ".sig")
+		if err != nil {
+			sc.Error = err.Error()
+			return sc
+		}
+		sc.Verified = ed25519.Verify(cfg.key, sum, sig)
+		if !sc.Verified {
+			sc.Error = "bad signature"
+		}
+	}
+	return sc
+}
+
+// readDetachedSig accepts a raw 64-byte signature or its base64 encoding.
+func readDetachedSig(path string) ([]byte, error) {
+	var b []byte
+	err := fdcap.Default.Do(func() (err error) {
+		b, err = os.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == ed25519.SignatureSize {
+		return b, nil
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+}
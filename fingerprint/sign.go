@@ -0,0 +1,150 @@
+package fingerprint
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SignedSnapshot wraps the JSON encoding of a snapshot together with a
+// detached signature over exactly those bytes. When a verifier-supplied
+// nonce is present the signature covers the snapshot bytes followed by a
+// newline and the nonce, so a captured document cannot be replayed against
+// a fresh challenge.
+type SignedSnapshot struct {
+	Snapshot  json.RawMessage `json:"snapshot"`
+	Nonce     string          `json:"nonce,omitempty"`
+	Algorithm string          `json:"alg"`
+	KeyID     string          `json:"kid,omitempty"`
+	Signature []byte          `json:"sig"`
+}
+
+// NonceHeader is the HTTP header carrying a verifier nonce in serve and
+// push mode.
+const NonceHeader = "X-LSF-Nonce"
+
+var (
+	// ErrBadSignature is returned when a signed snapshot fails verification.
+	ErrBadSignature = errors.New("fingerprint: bad snapshot signature")
+	// ErrNonceMismatch is returned when a signed snapshot answers a
+	// different challenge than the one expected.
+	ErrNonceMismatch = errors.New("fingerprint: snapshot nonce mismatch")
+)
+
+// Sign encodes snap and signs it with s. Ed25519, ECDSA and RSA signers are
+// supported, including hardware-backed ones.
+func Sign(s crypto.Signer, snap Snapshot) (*SignedSnapshot, error) {
+	return SignNonce(s, snap, "")
+}
+
+// SignNonce is like Sign but binds the signature to a verifier nonce.
+func SignNonce(s crypto.Signer, snap Snapshot, nonce string) (*SignedSnapshot, error) {
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	alg, sig, err := SignBytes(s, signingInput(body, nonce))
+	if err != nil {
+		return nil, err
+	}
+	return &SignedSnapshot{Snapshot: body, Nonce: nonce, Algorithm: alg, KeyID: KeyID(s.Public()), Signature: sig}, nil
+}
+
+// SignBytes signs msg the way snapshots are signed, over its SHA-256 for
+// ECDSA and RSA keys, and returns the JWS name of the algorithm.
+func SignBytes(s crypto.Signer, msg []byte) (alg string, sig []byte, err error) {
+	alg, opts, err := sigAlg(s.Public())
+	if err != nil {
+		return "", nil, err
+	}
+	if opts.HashFunc() != 0 {
+		sum := sha256.Sum256(msg)
+		msg = sum[:]
+	}
+	sig, err = s.Sign(rand.Reader, msg, opts)
+	return alg, sig, err
+}
+
+// VerifyNonce is like Verify but additionally requires the snapshot to
+// answer the given nonce.
+func (ss *SignedSnapshot) VerifyNonce(pub crypto.PublicKey, nonce string) (Snapshot, error) {
+	if ss.Nonce != nonce {
+		return Snapshot{}, ErrNonceMismatch
+	}
+	return ss.Verify(pub)
+}
+
+// Verify checks the signature with pub and decodes the snapshot.
+func (ss *SignedSnapshot) Verify(pub crypto.PublicKey) (Snapshot, error) {
+	if err := VerifyBytes(pub, ss.Algorithm, signingInput(ss.Snapshot, ss.Nonce), ss.Signature); err != nil {
+		return Snapshot{}, err
+	}
+	return Unmarshal(ss.Snapshot)
+}
+
+// VerifyBytes checks a signature made by SignBytes. It returns
+// ErrBadSignature when sig or alg do not match pub.
+func VerifyBytes(pub crypto.PublicKey, alg string, msg, sig []byte) error {
+	want, _, err := sigAlg(pub)
+	if err != nil {
+		return err
+	}
+	if alg != want {
+		return ErrBadSignature
+	}
+	sum := sha256.Sum256(msg)
+	var ok bool
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		// ed25519.Verify panics on a key of the wrong length.
+		ok = len(k) == ed25519.PublicKeySize && ed25519.Verify(k, msg, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, sum[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func signingInput(body []byte, nonce string) []byte {
+	if nonce == "" {
+		return body
+	}
+	msg := make([]byte, 0, len(body)+1+len(nonce))
+	msg = append(msg, body...)
+	msg = append(msg, '\n')
+	return append(msg, nonce...)
+}
+
+// KeyID returns the hex SHA-256 of the DER encoded public key.
+func KeyID(pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func sigAlg(pub crypto.PublicKey) (string, crypto.SignerOpts, error) {
+	switch pub.(type) {
+	case ed25519.PublicKey:
+		return "EdDSA", crypto.Hash(0), nil
+	case *ecdsa.PublicKey:
+		return "ES256", crypto.SHA256, nil
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+	}
+	return "", nil, fmt.Errorf("fingerprint: unsupported key type %T", pub)
+}
@@ -0,0 +1,28 @@
+package fingerprint
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	snap := Snapshot{SchemaVersion: SchemaVersion, MachineID: "4c4c4544004d3110"}
+	ss, err := SignNonce(priv, snap, "n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ss.VerifyNonce(pub, "n1"); err != nil || got.MachineID != snap.MachineID {
+		t.Fatalf("VerifyNonce = %+v, %v", got, err)
+	}
+	if _, err := ss.VerifyNonce(pub, "n2"); err != ErrNonceMismatch {
+		t.Errorf("VerifyNonce with another nonce = %v, want ErrNonceMismatch", err)
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	for _, k := range []ed25519.PublicKey{other, pub[:10], nil} {
+		if _, err := ss.Verify(k); err != ErrBadSignature {
+			t.Errorf("Verify with a %d-byte foreign key = %v, want ErrBadSignature", len(k), err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"AurFingerprintAgent/smart"
+)
+
+// DiskHealth reports wear and health indicators of a block device.
+type DiskHealth struct {
+	Name            string   `json:"name"`
+	Transport       string   `json:"transport"`
+	Model           string   `json:"model,omitempty"`
+	Serial          string   `json:"serial,omitempty"`
+	PercentUsed     *int     `json:"percent_used,omitempty"`
+	MediaErrors     uint64   `json:"media_errors"`
+	PowerOnHours    uint64   `json:"power_on_hours,omitempty"`
+	TemperatureC    int      `json:"temperature_c,omitempty"`
+	Reallocated     uint64   `json:"reallocated_sectors,omitempty"`
+	Pending         uint64   `json:"pending_sectors,omitempty"`
+	CriticalWarning uint8    `json:"critical_warning,omitempty"`
+	NearEndOfLife   bool     `json:"near_end_of_life"`
+	Reasons         []string `json:"reasons,omitempty"`
+}
+
+// endOfLifePercent is the wear level from which a disk is flagged.
+const endOfLifePercent = 90
+
+// storageHealth queries every NVMe controller and SCSI disk. Devices that
+// cannot be queried, typically for lack of privileges, are skipped.
+func (h host) storageHealth(ctx context.Context) []DiskHealth {
+	if !h.live() {
+		return nil
+	}
+	entries, err := h.readDir("/sys/block")
+	if err != nil {
+		return nil
+	}
+	var out []DiskHealth
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		name := e.Name()
+		sys := filepath.Join("/sys/block", name, "device")
+		var d *DiskHealth
+		switch {
+		case strings.HasPrefix(name, "nvme"):
+			// Namespaces share the controller's health log.
+			ctrl := filepath.Base(h.resolveLink(sys))
+			if !strings.HasPrefix(ctrl, "nvme") || seen[ctrl] {
+				continue
+			}
+			seen[ctrl] = true
+			d = h.nvmeHealth(h.path("/dev/" + ctrl))
+		case strings.HasPrefix(name, "sd"):
+			d = h.ataHealth(h.path("/dev/" + name))
+		default:
+			continue
+		}
+		if d == nil {
+			continue
+		}
+		d.Model = h.readTrim(filepath.Join(sys, "model"))
+		d.Serial = h.readTrim(filepath.Join(sys, "serial"))
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (h host) nvmeHealth(dev string) *DiskHealth {
+	l, err := smart.ReadNVMe(dev)
+	h.debug("ioctl", "device", dev, "command", "nvme smart log", "err", err)
+	if err != nil {
+		h.notePermission(h.rel(dev), err)
+		return nil
+	}
+	h.source("ioctl", dev)
+	used := int(l.PercentUsed)
+	d := &DiskHealth{
+		Name:            filepath.Base(dev),
+		Transport:       "nvme",
+		PercentUsed:     &used,
+		MediaErrors:     l.MediaErrors,
+		PowerOnHours:    l.PowerOnHours,
+		CriticalWarning: l.CriticalWarning,
+	}
+	if l.TemperatureK > 0 {
+		d.TemperatureC = int(l.TemperatureK) - 273
+	}
+	if used >= endOfLifePercent {
+		d.flag(fmt.Sprintf("percent used %d%%", used))
+	}
+	if l.CriticalWarning&smart.WarnSpare != 0 || l.AvailableSpare < l.SpareThreshold {
+		d.flag("available spare below threshold")
+	}
+	if l.CriticalWarning&smart.WarnReliability != 0 {
+		d.flag("reliability degraded")
+	}
+	if l.CriticalWarning&smart.WarnReadOnly != 0 {
+		d.flag("media in read-only mode")
+	}
+	return d
+}
+
+func (h host) ataHealth(dev string) *DiskHealth {
+	attrs, err := smart.ReadATA(dev)
+	h.debug("ioctl", "device", dev, "command", "ata smart read", "err", err)
+	h.notePermission(h.rel(dev), err)
+	if err != nil || len(attrs) == 0 {
+		return nil
+	}
+	h.source("ioctl", dev)
+	d := &DiskHealth{Name: filepath.Base(dev), Transport: "ata"}
+	for _, a := range attrs {
+		switch a.ID {
+		case smart.AttrReallocated:
+			d.Reallocated = a.Raw & 0xffffffff
+		case smart.AttrPowerOnHours:
+			d.PowerOnHours = a.Raw & 0xffffffff
+		case smart.AttrTemperature:
+			d.TemperatureC = int(a.Raw & 0xff)
+		case smart.AttrPending:
+			d.Pending = a.Raw & 0xffffffff
+		case smart.AttrUncorrectable:
+			d.MediaErrors = a.Raw & 0xffffffff
+		case smart.AttrWearLeveling, smart.AttrSSDLifeLeft, smart.AttrMediaWearout:
+			// Normalized values count down from 100 on SSDs.
+			if d.PercentUsed == nil && a.Current <= 100 {
+				used := 100 - int(a.Current)
+				d.PercentUsed = &used
+				if used >= endOfLifePercent {
+					d.flag(fmt.Sprintf("percent used %d%%", used))
+				}
+			}
+		}
+		if a.Failing() {
+			d.flag(fmt.Sprintf("attribute %d at threshold", a.ID))
+		}
+	}
+	return d
+}
+
+func (d *DiskHealth) flag(reason string) {
+	d.NearEndOfLife = true
+	d.Reasons = append(d.Reasons, reason)
+}
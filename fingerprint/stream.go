@@ -0,0 +1,202 @@
+package fingerprint
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// EncodeStream collects a snapshot and writes it as JSON to w. Unlike
+// encoding the result of GetSnapshot, the large opt-in sections enabled by
+// WithPackages, WithPCI and WithUSB are written element by element while
+// they are read, so memory use stays flat on small devices regardless of
+// their size. The output is equivalent to marshalling the in-memory
+// snapshot. Limits are honored; the streamed sections are read twice so
+// their cuts can be recorded up front.
+func EncodeStream(w io.Writer, opts ...Option) error {
+	return EncodeStreamContext(context.Background(), w, opts...)
+}
+
+// EncodeStreamContext is EncodeStream bounded by ctx, like
+// GetSnapshotContext.
+func EncodeStreamContext(ctx context.Context, w io.Writer, opts ...Option) error {
+	o := buildOptions(opts)
+	large := o.large
+	o.large = largeSections{}
+	snap := collect(ctx, o, nil)
+	// A first pass over the streamed sections finds the cuts Limits will
+	// make, so they can be recorded in the meta block written before them.
+	var cuts []Truncation
+	if large.packages {
+		cuts = append(cuts, scanLimits("packages", o.host().packages(ctx), o.limits.MaxPackages, o.limits.MaxStringLength)...)
+	}
+	if large.pci {
+		cuts = append(cuts, scanLimits("pci", o.host().pciDevices(), 0, o.limits.MaxStringLength)...)
+	}
+	if large.usb {
+		cuts = append(cuts, scanLimits("usb", o.host().usbDevices(), 0, o.limits.MaxStringLength)...)
+	}
+	if len(cuts) > 0 {
+		if snap.Meta == nil {
+			snap.Meta = &Meta{}
+		}
+		snap.Meta.Truncated = append(snap.Meta.Truncated, cuts...)
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(b[:len(b)-1]); err != nil {
+		return err
+	}
+	if large.packages {
+		if err := streamArray(bw, "packages", o.host().packages(ctx), o.limits.MaxPackages, o.limits.MaxStringLength); err != nil {
+			return err
+		}
+	}
+	if large.pci {
+		if err := streamArray(bw, "pci", o.host().pciDevices(), 0, o.limits.MaxStringLength); err != nil {
+			return err
+		}
+	}
+	if large.usb {
+		if err := streamArray(bw, "usb", o.host().usbDevices(), 0, o.limits.MaxStringLength); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.WriteString("}\n"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Field is a top-level section of a snapshot as emitted by CollectStream.
+type Field struct {
+	// Name is the JSON name of the section, e.g. "dmi" or "network".
+	Name string `json:"name"`
+	// Collector produced the value; empty for the schema version and for
+	// values derived once all collectors ran, such as
+	// fingerprint_confidence.
+	Collector string          `json:"collector,omitempty"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// CollectStream collects a snapshot like GetSnapshotContext but sends each
+// section on the returned channel as soon as the collector producing it
+// finishes, so fast fields such as the machine ID arrive within
+// milliseconds instead of after the slowest collector. The built-in
+// collectors that do not build on others run concurrently, so sections
+// arrive in no fixed order. Sections shared by several collectors, such as
+// errors and extensions, and those a later step changes, such as the
+// network list cut by limits, are sent once the collection ends; a section
+// sent twice takes its last value. Sections left empty are not sent. The
+// channel is closed when the collection ends; cancelling ctx ends it
+// early.
+func CollectStream(ctx context.Context, opts ...Option) <-chan Field {
+	o := buildOptions(opts)
+	ch := make(chan Field)
+	go func() {
+		defer close(ch)
+		// The empty snapshot is the baseline; only what differs is sent.
+		empty, sent := map[string]string{}, map[string]string{}
+		for k, v := range topLevel(&Snapshot{}) {
+			empty[k], sent[k] = string(v), string(v)
+		}
+		// part leaves the sections its collector did not store empty.
+		emit := func(collector string, s *Snapshot, part bool) {
+			m := topLevel(s)
+			for _, k := range slices.Sorted(maps.Keys(m)) {
+				v := string(m[k])
+				if sent[k] == v || part && (v == empty[k] || sharedSections[k]) {
+					continue
+				}
+				sent[k] = v
+				select {
+				case ch <- Field{Name: k, Collector: collector, Value: m[k]}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		emit("", &Snapshot{SchemaVersion: SchemaVersion}, false)
+		o.observe = func(collector string, part *Snapshot) {
+			if ctx.Err() == nil {
+				emit(collector, part, true)
+			}
+		}
+		snap := collect(ctx, o, nil)
+		if ctx.Err() == nil {
+			emit("", &snap, false)
+		}
+	}()
+	return ch
+}
+
+// sharedSections collect the results of several collectors.
+var sharedSections = map[string]bool{"errors": true, "extensions": true, "provenance": true}
+
+// topLevel is the JSON encoding of s split into its top-level sections.
+func topLevel(s *Snapshot) map[string]json.RawMessage {
+	var m map[string]json.RawMessage
+	if b, err := json.Marshal(s); err == nil {
+		json.Unmarshal(b, &m)
+	}
+	return m
+}
+
+// streamArray appends ,"key":[...] to w, omitting empty sequences like
+// omitempty does. At most maxItems elements are written when positive, and
+// strings are cut to maxString.
+func streamArray[T any](w *bufio.Writer, key string, seq iter.Seq[T], maxItems, maxString int) error {
+	n := 0
+	for v := range seq {
+		if maxItems > 0 && n == maxItems {
+			break
+		}
+		if maxString > 0 {
+			truncateStrings(reflect.ValueOf(&v).Elem(), "", maxString, new([]Truncation))
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if n == 0 {
+			sep = `,"` + key + `":[`
+		}
+		if _, err := w.WriteString(sep); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		return w.WriteByte(']')
+	}
+	return nil
+}
+
+// scanLimits reports the cuts streamArray will make without encoding.
+func scanLimits[T any](key string, seq iter.Seq[T], maxItems, maxString int) []Truncation {
+	var out []Truncation
+	n := 0
+	for v := range seq {
+		if maxString > 0 && (maxItems <= 0 || n < maxItems) {
+			truncateStrings(reflect.ValueOf(&v).Elem(), key+"."+strconv.Itoa(n), maxString, &out)
+		}
+		n++
+	}
+	if maxItems > 0 && n > maxItems {
+		out = append([]Truncation{{key, maxItems, n}}, out...)
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// Timeouts bound single collectors and probes, so that a hung NFS mount or
+// a wedged Docker daemon costs at most its own timeout instead of stalling
+// the whole snapshot. A zero or missing entry leaves that step unbounded
+// except by WithTimeout and WithBudget, which still apply.
+type Timeouts struct {
+	// Collectors bounds the named collectors. Collectors that overrun are
+	// abandoned and listed in Snapshot.Meta.Skipped, with an error of kind
+	// "timeout".
+	Collectors map[string]time.Duration
+	// Commands bounds the external commands of the given names, e.g.
+	// "blkid", when run for their output.
+	Commands map[string]time.Duration
+	// FileRead bounds opening or reading one file or directory of the
+	// system. A read that overruns is abandoned and recorded in
+	// Snapshot.Errors. It does not apply to WithFS, nor to the collectors
+	// of the hashed fields, whose slow reads would otherwise blank a field
+	// and change the hash. Each bounded read runs on its own goroutine,
+	// and an abandoned one keeps its goroutine and descriptor until the
+	// kernel answers; once maxStragglers are pending, further reads fail
+	// at once.
+	FileRead time.Duration
+}
+
+// DefaultTimeouts apply unless overridden with WithTimeouts.
+var DefaultTimeouts = Timeouts{
+	Collectors: map[string]time.Duration{"docker": 2 * time.Second},
+	Commands:   map[string]time.Duration{"blkid": 500 * time.Millisecond, "smartctl": 5 * time.Second, "pvs": 2 * time.Second},
+}
+
+// WithTimeouts replaces DefaultTimeouts.
+func WithTimeouts(t Timeouts) Option {
+	return func(o *options) { o.timeouts = t }
+}
+
+// WithCollectorTimeout bounds the named collector by d, keeping the other
+// timeouts.
+func WithCollectorTimeout(name string, d time.Duration) Option {
+	return func(o *options) {
+		o.timeouts.Collectors = maps.Clone(o.timeouts.Collectors)
+		if o.timeouts.Collectors == nil {
+			o.timeouts.Collectors = map[string]time.Duration{}
+		}
+		o.timeouts.Collectors[name] = d
+	}
+}
+
+// readTimeoutError reports a file operation abandoned after d.
+func readTimeoutError(op, p string, d time.Duration) error {
+	return &fs.PathError{Op: op, Path: p, Err: fmt.Errorf("no answer within %s: %w", d, context.DeadlineExceeded)}
+}
+
+// maxStragglers caps the abandoned reads still waiting for the kernel.
+const maxStragglers = 8
+
+// stragglers counts the abandoned reads still running.
+var stragglers atomic.Int32
+
+// bounded runs op, giving up after d with ok false. An abandoned op
+// finishes in the background and its result is passed to discard. While
+// maxStragglers ops are abandoned, bounded gives up without running op.
+func bounded[T any](d time.Duration, op func() (T, error), discard func(T)) (v T, ok bool, err error) {
+	if d <= 0 {
+		v, err = op()
+		return v, true, err
+	}
+	if stragglers.Load() >= maxStragglers {
+		return v, false, nil
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	// Unbuffered, so that a result arriving after the timeout goes to
+	// discard rather than into the channel.
+	done := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		v, err := op()
+		select {
+		case done <- result{v, err}:
+		case <-abandoned:
+			if err == nil && discard != nil {
+				discard(v)
+			}
+			stragglers.Add(-1)
+		}
+	}()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r.v, true, r.err
+	case <-t.C:
+		stragglers.Add(1)
+		close(abandoned)
+		return v, false, nil
+	}
+}
+
+// osReadFile reads the system file at the OS path p within the read
+// timeout.
+func (h host) osReadFile(p string) ([]byte, error) {
+	b, ok, err := bounded(h.timeouts.FileRead, func() (b []byte, err error) {
+		err = fdcap.Default.Do(func() (err error) {
+			b, err = os.ReadFile(p)
+			return err
+		})
+		return b, err
+	}, nil)
+	if !ok {
+		return nil, readTimeoutError("read", p, h.timeouts.FileRead)
+	}
+	return b, err
+}
+
+// osOpen opens the system file at the OS path p within the read timeout.
+func (h host) osOpen(p string) (*fdcap.File, error) {
+	f, ok, err := bounded(h.timeouts.FileRead, func() (*fdcap.File, error) {
+		return fdcap.Default.Open(p)
+	}, func(f *fdcap.File) { f.Close() })
+	if !ok {
+		return nil, readTimeoutError("open", p, h.timeouts.FileRead)
+	}
+	return f, err
+}
+
+// osReadDir lists the system directory at the OS path p within the read
+// timeout.
+func (h host) osReadDir(p string) ([]fs.DirEntry, error) {
+	e, ok, err := bounded(h.timeouts.FileRead, func() (e []fs.DirEntry, err error) {
+		err = fdcap.Default.Do(func() (err error) {
+			e, err = os.ReadDir(p)
+			return err
+		})
+		return e, err
+	}, nil)
+	if !ok {
+		return nil, readTimeoutError("readdir", p, h.timeouts.FileRead)
+	}
+	return e, err
+}
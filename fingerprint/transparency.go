@@ -0,0 +1,45 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// ErrNoTransparency is returned when verifying a snapshot that carries no
+// transparency log entry.
+var ErrNoTransparency = errors.New("fingerprint: snapshot has no transparency log entry")
+
+// TransparencyDigest is the SHA-256 of the JSON encoding of snap without
+// its transparency log entry, the digest a transparency log records.
+func TransparencyDigest(snap Snapshot) ([]byte, error) {
+	if snap.Meta != nil && snap.Meta.Transparency != nil {
+		m := *snap.Meta
+		m.Transparency = nil
+		snap.Meta = &m
+		// A Meta holding nothing but the entry was added for it.
+		if reflect.ValueOf(m).IsZero() {
+			snap.Meta = nil
+		}
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// VerifyTransparency checks that the entry in Meta.Transparency logs this
+// snapshot and is proven included in the log.
+func (s Snapshot) VerifyTransparency() error {
+	if s.Meta == nil || s.Meta.Transparency == nil {
+		return ErrNoTransparency
+	}
+	digest, err := TransparencyDigest(s)
+	if err != nil {
+		return err
+	}
+	return s.Meta.Transparency.Verify(digest)
+}
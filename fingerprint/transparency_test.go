@@ -0,0 +1,49 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"AurFingerprintAgent/tlog"
+)
+
+// logged returns snap with an entry of a one-leaf log recording digest.
+func logged(t *testing.T, snap Snapshot, digest []byte) Snapshot {
+	t.Helper()
+	h := hex.EncodeToString(digest)
+	body := []byte(fmt.Sprintf(`{"kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":%q}}}}`, h))
+	leaf := sha256.Sum256(append([]byte{0}, body...))
+	snap.Meta = &Meta{Transparency: &tlog.Entry{ArtifactHash: h, Body: body,
+		InclusionProof: &tlog.InclusionProof{TreeSize: 1, RootHash: hex.EncodeToString(leaf[:])}}}
+	return snap
+}
+
+func TestTransparency(t *testing.T) {
+	snap := Snapshot{SchemaVersion: SchemaVersion, Hostname: "app-03", MachineID: "4c4c4544004d3110"}
+	if err := snap.VerifyTransparency(); err != ErrNoTransparency {
+		t.Errorf("VerifyTransparency without an entry = %v", err)
+	}
+	digest, err := TransparencyDigest(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := logged(t, snap, digest)
+	if d, _ := TransparencyDigest(s); hex.EncodeToString(d) != hex.EncodeToString(digest) {
+		t.Error("TransparencyDigest depends on the entry")
+	}
+	if err := s.VerifyTransparency(); err != nil {
+		t.Errorf("VerifyTransparency = %v", err)
+	}
+	// The entry of the full snapshot does not cover a changed view.
+	s.Hostname = ""
+	if err := s.VerifyTransparency(); err == nil {
+		t.Error("VerifyTransparency accepted a changed snapshot")
+	}
+	s = logged(t, snap, digest)
+	s.Meta.Transparency.InclusionProof.RootHash = hex.EncodeToString(make([]byte, sha256.Size))
+	if err := s.VerifyTransparency(); err != tlog.ErrBadProof {
+		t.Errorf("VerifyTransparency with a foreign root = %v, want ErrBadProof", err)
+	}
+}
@@ -0,0 +1,157 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ChangeEvent reports a change of the values the fingerprint is derived
+// from.
+type ChangeEvent struct {
+	Time time.Time `json:"time"`
+	// Triggers lists what prompted the check: the changed files, "network"
+	// for interface changes, or "recheck" for the periodic check.
+	Triggers []string `json:"triggers"`
+	// Changed lists the keys of Snapshot.Components whose values changed,
+	// and "os.name" or "os.version" when the distribution changed.
+	Changed []string `json:"changed"`
+	// OldHash and NewHash are the fingerprint hashes before and after the
+	// change; they are equal when only the OS changed.
+	OldHash string `json:"old_hash"`
+	NewHash string `json:"new_hash"`
+	// Snapshot holds the watched sections after the change.
+	Snapshot Snapshot `json:"-"`
+}
+
+// watchCollectors are the collectors Watch re-runs to compare states.
+var watchCollectors = map[string]bool{
+	"os": true, "machine_id": true, "dmi": true, "cpu": true, "memory": true, "network": true, "rootfs": true,
+}
+
+// watchFiles are the files whose changes prompt a check. Their directories
+// are watched, since tools replace such files by renaming.
+var watchFiles = []string{"/etc/machine-id", "/etc/os-release", "/usr/lib/os-release"}
+
+// watchDirs are directories any change in which prompts a check.
+var watchDirs = []string{"/sys/class/dmi/id"}
+
+const (
+	// watchSettle collects the notifications of one change, such as the
+	// several link messages of an interface coming up, into one check.
+	watchSettle = 500 * time.Millisecond
+	// watchRecheck is the period of the check catching changes that send
+	// no notification, e.g. sysfs attributes or a relabelled file system.
+	watchRecheck = 5 * time.Minute
+)
+
+// Watch reports changes of the fingerprint without polling: it watches
+// /etc/machine-id, the os-release files and the DMI sysfs directory with
+// inotify and interface changes over rtnetlink, re-collects the hashed
+// sections when notified, and sends a ChangeEvent when their values
+// differ from the previous state. A periodic recheck catches what sends no
+// notification. Watch needs the live system; WithFS is not supported, and
+// interfaces are only watched in the agent's own network namespace. The
+// channel is closed when ctx is done.
+func Watch(ctx context.Context, opts ...Option) (<-chan ChangeEvent, error) {
+	o := buildOptions(opts)
+	h := o.host()
+	if !h.live() {
+		return nil, fmt.Errorf("watch: %w without the live system", errors.ErrUnsupported)
+	}
+	triggers, err := h.watchSources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("watch: %w", err)
+	}
+	ch := make(chan ChangeEvent)
+	go func() {
+		defer close(ch)
+		prev := watchState(ctx, o)
+		tick := time.NewTicker(watchRecheck)
+		defer tick.Stop()
+		for {
+			var reasons []string
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-triggers:
+				reasons = settle(ctx, triggers, r)
+			case <-tick.C:
+				reasons = []string{"recheck"}
+			}
+			cur := watchState(ctx, o)
+			if ctx.Err() != nil {
+				return
+			}
+			changed := changedKeys(prev.values, cur.values)
+			h.debug("watch check", "triggers", reasons, "changed", changed)
+			if len(changed) == 0 {
+				continue
+			}
+			ev := ChangeEvent{
+				Time:     time.Now(),
+				Triggers: reasons,
+				Changed:  changed,
+				OldHash:  prev.snap.Hash(),
+				NewHash:  cur.snap.Hash(),
+				Snapshot: cur.snap,
+			}
+			prev = cur
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// settle gathers the triggers arriving within watchSettle of first.
+func settle(ctx context.Context, triggers <-chan string, first string) []string {
+	seen := map[string]bool{first: true}
+	t := time.NewTimer(watchSettle)
+	defer t.Stop()
+	for {
+		select {
+		case r := <-triggers:
+			seen[r] = true
+		case <-t.C:
+			return slices.Sorted(maps.Keys(seen))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+type watched struct {
+	snap   Snapshot
+	values map[string]string
+}
+
+func watchState(ctx context.Context, o options) watched {
+	s := collectSome(ctx, o, watchCollectors, Snapshot{}, nil)
+	v := s.Components()
+	v["os.name"], v["os.version"] = s.OS.Name, s.OS.Version
+	return watched{s, v}
+}
+
+// changedKeys returns the keys whose values differ between a and b.
+func changedKeys(a, b map[string]string) []string {
+	var out []string
+	for k, v := range a {
+		if b[k] != v {
+			out = append(out, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
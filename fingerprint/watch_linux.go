@@ -0,0 +1,151 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// rtmgrpLink is the rtnetlink multicast group of link changes.
+const rtmgrpLink = 0x1
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+// watchSources sends the trigger of every notification until ctx is done:
+// the system path of a watched file that changed, or "network".
+func (h host) watchSources(ctx context.Context) (<-chan string, error) {
+	out := make(chan string, 16)
+	in, err := h.inotify()
+	if err != nil {
+		return nil, err
+	}
+	go h.readInotify(ctx, in, out)
+	if h.native() {
+		if nl, release, err := netlinkLinks(); err == nil {
+			go readNetlink(ctx, nl, release, out)
+		} else {
+			h.debug("rtnetlink unavailable", "err", err)
+		}
+	}
+	return out, nil
+}
+
+type inotifyWatch struct {
+	f       *os.File
+	release func()
+	// dirs maps watch descriptors to the system directories they watch.
+	dirs map[int32]string
+}
+
+func (h host) inotify() (*inotifyWatch, error) {
+	release, err := fdcap.Default.Acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		release()
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// Non-blocking, so that reads use the runtime poller and Close
+	// unblocks them.
+	w := &inotifyWatch{f: os.NewFile(uintptr(fd), "inotify"), release: release, dirs: map[int32]string{}}
+	dirs := slices.Clone(watchDirs)
+	for _, p := range watchFiles {
+		dirs = append(dirs, filepath.Dir(p))
+	}
+	slices.Sort(dirs)
+	for _, d := range slices.Compact(dirs) {
+		wd, err := syscall.InotifyAddWatch(fd, h.path(d), inotifyMask)
+		h.debug("inotify", "path", d, "err", err)
+		if err == nil {
+			w.dirs[int32(wd)] = d
+		}
+	}
+	return w, nil
+}
+
+func (h host) readInotify(ctx context.Context, w *inotifyWatch, out chan<- string) {
+	defer w.release()
+	defer w.f.Close()
+	stop := context.AfterFunc(ctx, func() { w.f.Close() })
+	defer stop()
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for b := buf[:n]; len(b) >= syscall.SizeofInotifyEvent; {
+			wd := int32(binary.NativeEndian.Uint32(b))
+			nameLen := int(binary.NativeEndian.Uint32(b[12:]))
+			end := syscall.SizeofInotifyEvent + nameLen
+			if end > len(b) {
+				break
+			}
+			name := string(b[syscall.SizeofInotifyEvent:end])
+			b = b[end:]
+			for i := range len(name) {
+				if name[i] == 0 {
+					name = name[:i]
+					break
+				}
+			}
+			dir, ok := w.dirs[wd]
+			if !ok {
+				continue
+			}
+			p := filepath.Join(dir, name)
+			if !slices.Contains(watchDirs, dir) && !slices.Contains(watchFiles, p) {
+				continue
+			}
+			select {
+			case out <- p:
+			default:
+				// A check is already pending.
+			}
+		}
+	}
+}
+
+// netlinkLinks subscribes to rtnetlink link notifications: interfaces
+// appearing, disappearing or changing their address.
+func netlinkLinks() (*os.File, func(), error) {
+	release, err := fdcap.Default.Acquire(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		release()
+		return nil, nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpLink}); err != nil {
+		syscall.Close(fd)
+		release()
+		return nil, nil, os.NewSyscallError("bind", err)
+	}
+	return os.NewFile(uintptr(fd), "rtnetlink"), release, nil
+}
+
+func readNetlink(ctx context.Context, f *os.File, release func(), out chan<- string) {
+	defer release()
+	defer f.Close()
+	stop := context.AfterFunc(ctx, func() { f.Close() })
+	defer stop()
+	buf := make([]byte, 64<<10)
+	for {
+		if _, err := f.Read(buf); err != nil {
+			return
+		}
+		select {
+		case out <- "network":
+		default:
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fingerprint
+
+import (
+	"context"
+	"errors"
+)
+
+func (h host) watchSources(context.Context) (<-chan string, error) {
+	return nil, errors.ErrUnsupported
+}
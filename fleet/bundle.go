@@ -0,0 +1,59 @@
+package fleet
+
+import (
+	"fmt"
+	"sort"
+
+	"AurFingerprintAgent/bundle"
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Import files the snapshots of an opened bundle, oldest first, as if
+// they had been pushed when they were collected. Snapshots the audit log
+// already records for the bundle are skipped, so an import that failed
+// partway can be retried and carrying the same file across twice does not
+// duplicate records. Import holds the same lock as Handler.
+func Import(st Store, p Policy, c *bundle.Contents) ([]Decision, error) {
+	id := c.Manifest.ID
+	entries := append([]bundle.Entry(nil), c.Snapshots...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	// A malformed snapshot rejects the bundle before anything is filed.
+	snaps := make([]fingerprint.Snapshot, len(entries))
+	for i, e := range entries {
+		var err error
+		if snaps[i], err = fingerprint.Unmarshal(e.Snapshot); err != nil {
+			return nil, fmt.Errorf("fleet: bundle %s: snapshot %d: %w", id, i+1, err)
+		}
+	}
+
+	unlock, err := lockStore(st)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	past, err := st.Decisions()
+	if err != nil {
+		return nil, err
+	}
+	filed := map[int]bool{}
+	for _, d := range past {
+		if d.Bundle == id {
+			filed[d.Entry] = true
+		}
+	}
+	if len(filed) >= len(entries) {
+		return nil, fmt.Errorf("fleet: bundle %s was already imported", id)
+	}
+	var out []Decision
+	for i, e := range entries {
+		if filed[i+1] {
+			continue
+		}
+		d, err := reconcile(st, p, snaps[i], "", Decision{Time: e.Time.UTC(), Bundle: id, Entry: i + 1})
+		if err != nil {
+			return out, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
@@ -0,0 +1,37 @@
+package fleet
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/bundle"
+)
+
+func TestImportResumes(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	t0 := time.Unix(1700000000, 0).UTC()
+	c := &bundle.Contents{
+		Manifest: bundle.Manifest{ID: bundle.NewID()},
+		Snapshots: []bundle.Entry{
+			{Time: t0.Add(time.Hour), Snapshot: json.RawMessage(`{"hostname":"b","machine_id":"bbbb"}`)},
+			{Time: t0, Snapshot: json.RawMessage(`{"hostname":"a","machine_id":"aaaa"}`)},
+		},
+	}
+	// An import that stopped after the oldest snapshot.
+	partial := &bundle.Contents{Manifest: c.Manifest, Snapshots: c.Snapshots[1:]}
+	if ds, err := Import(st, DefaultPolicy, partial); err != nil || len(ds) != 1 || ds[0].Entry != 1 {
+		t.Fatalf("partial Import = %+v, %v", ds, err)
+	}
+	ds, err := Import(st, DefaultPolicy, c)
+	if err != nil || len(ds) != 1 || ds[0].Entry != 2 || !ds[0].Time.Equal(t0.Add(time.Hour)) {
+		t.Fatalf("resumed Import = %+v, %v", ds, err)
+	}
+	if _, err := Import(st, DefaultPolicy, c); err == nil {
+		t.Fatal("Import accepted a bundle twice")
+	}
+	all, err := st.Decisions()
+	if err != nil || len(all) != 2 {
+		t.Fatalf("Decisions = %+v, %v", all, err)
+	}
+}
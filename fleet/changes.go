@@ -0,0 +1,44 @@
+package fleet
+
+import (
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/report"
+)
+
+// FieldChange is a flattened field that differs between two consecutive
+// snapshots of a host.
+type FieldChange struct {
+	// Time is when the snapshot carrying the change was stored.
+	Time  time.Time `json:"time"`
+	Field string    `json:"field"`
+	// Kind is "added", "removed" or "changed".
+	Kind string `json:"kind"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+	// Hash is the fingerprint hash of the snapshot carrying the change.
+	Hash string `json:"hash"`
+}
+
+// Changes returns the field changes of a host history, oldest first,
+// carried by snapshots stored after since. The first snapshot is the
+// baseline and changes nothing. Fields that change while the system runs,
+// such as routes and the agent's PID, are left out unless volatile is set.
+func Changes(history []Record, since time.Time, volatile bool) []FieldChange {
+	out := []FieldChange{}
+	for i := 1; i < len(history); i++ {
+		r := history[i]
+		if !r.Time.After(since) {
+			continue
+		}
+		hash := r.Snapshot.Hash()
+		for _, c := range report.Diff(history[i-1].Snapshot, r.Snapshot) {
+			if !volatile && fingerprint.StabilityOf(c.Key) == fingerprint.Volatile {
+				continue
+			}
+			out = append(out, FieldChange{Time: r.Time, Field: c.Key, Kind: c.Kind(), Old: c.Old, New: c.New, Hash: hash})
+		}
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+// Package fleet computes statistics over snapshots collected from many
+// machines, used to tune which fields the fingerprint should rely on.
+package fleet
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// ValueCount is a field value and the number of machines reporting it.
+type ValueCount struct {
+	Value    string `json:"value"`
+	Machines int    `json:"machines"`
+}
+
+// FieldStats describes how well a field tells machines apart.
+type FieldStats struct {
+	Field string `json:"field"`
+	// Machines is the number of snapshots carrying the field.
+	Machines int `json:"machines"`
+	Distinct int `json:"distinct"`
+	// Shared is the number of machines whose value is also reported by
+	// another machine.
+	Shared int `json:"shared"`
+	// Uniqueness is Distinct/Machines; 1 means every machine differs.
+	Uniqueness float64 `json:"uniqueness"`
+	// EntropyBits is the Shannon entropy of the value distribution.
+	EntropyBits float64      `json:"entropy_bits"`
+	Top         []ValueCount `json:"top,omitempty"`
+	Warning     string       `json:"warning,omitempty"`
+}
+
+// Fields returns the values of a snapshot to analyze.
+type Fields func(fingerprint.Snapshot) map[string]string
+
+// Components analyzes the hash inputs; it is the default.
+func Components(s fingerprint.Snapshot) map[string]string { return s.Components() }
+
+// All analyzes every flattened field.
+func All(s fingerprint.Snapshot) map[string]string { return s.Flatten() }
+
+// expectUnique are fields that must never repeat across machines; any
+// sharing points at cloned images or firmware placeholders.
+var expectUnique = map[string]bool{
+	"machine_id":         true,
+	"dmi.product_uuid":   true,
+	"dmi.board_serial":   true,
+	"dmi.product_serial": true,
+	"rootfs.uuid":        true,
+	"network.mac":        true,
+	"hash":               true,
+}
+
+// dominantShare is the fraction of the fleet sharing one value from which
+// any field is reported as globally duplicated.
+const dominantShare = 0.5
+
+// Stats computes per-field statistics sorted by field name. fields selects
+// the analyzed values; nil means Components.
+func Stats(snaps []fingerprint.Snapshot, fields Fields) []FieldStats {
+	if fields == nil {
+		fields = Components
+	}
+	counts := map[string]map[string]int{}
+	for _, s := range snaps {
+		for k, v := range fields(s) {
+			if counts[k] == nil {
+				counts[k] = map[string]int{}
+			}
+			counts[k][v]++
+		}
+	}
+	var out []FieldStats
+	for field, vals := range counts {
+		st := FieldStats{Field: field, Distinct: len(vals)}
+		for v, n := range vals {
+			st.Machines += n
+			if n > 1 {
+				st.Shared += n
+				st.Top = append(st.Top, ValueCount{v, n})
+			}
+		}
+		for _, n := range vals {
+			p := float64(n) / float64(st.Machines)
+			st.EntropyBits -= p * math.Log2(p)
+		}
+		st.EntropyBits = math.Round(st.EntropyBits*100) / 100
+		st.Uniqueness = math.Round(float64(st.Distinct)/float64(st.Machines)*1000) / 1000
+		sort.Slice(st.Top, func(i, j int) bool {
+			if st.Top[i].Machines != st.Top[j].Machines {
+				return st.Top[i].Machines > st.Top[j].Machines
+			}
+			return st.Top[i].Value < st.Top[j].Value
+		})
+		if len(st.Top) > 5 {
+			st.Top = st.Top[:5]
+		}
+		switch {
+		case len(st.Top) > 0 && expectUnique[field]:
+			st.Warning = fmt.Sprintf("%d machines share a value expected to be unique (cloned image or placeholder?)", st.Shared)
+		case len(st.Top) > 0 && len(snaps) > 2 && float64(st.Top[0].Machines) >= dominantShare*float64(len(snaps)):
+			st.Warning = fmt.Sprintf("one value is shared by %d of %d machines", st.Top[0].Machines, len(snaps))
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
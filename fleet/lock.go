@@ -0,0 +1,33 @@
+package fleet
+
+import "sync"
+
+// Locker is implemented by stores that several processes reconcile into,
+// e.g. fleetserver and import-bundle on the same directory or database.
+type Locker interface {
+	// Lock blocks until the caller holds the store's reconciliation lock
+	// and returns the function releasing it.
+	Lock() (unlock func(), err error)
+}
+
+// reconcileMu serializes reconciliation within the process.
+var reconcileMu sync.Mutex
+
+// lockStore serializes reconciliation into st within the process and,
+// when st implements Locker, across processes.
+func lockStore(st Store) (func(), error) {
+	reconcileMu.Lock()
+	l, ok := st.(Locker)
+	if !ok {
+		return reconcileMu.Unlock, nil
+	}
+	unlock, err := l.Lock()
+	if err != nil {
+		reconcileMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		reconcileMu.Unlock()
+	}, nil
+}
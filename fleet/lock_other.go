@@ -0,0 +1,8 @@
+//go:build !unix
+
+package fleet
+
+// Lock implements Locker. Without flock only the in-process lock applies.
+func (d DirStore) Lock() (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package fleet
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Lock implements Locker with an flock on <Dir>/.lock.
+func (d DirStore) Lock() (func(), error) {
+	if err := os.MkdirAll(d.Dir, 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(d.Dir, ".lock"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
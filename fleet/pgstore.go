@@ -0,0 +1,354 @@
+package fleet
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/pgwire"
+)
+
+// Schema is the SQL creating the tables of PGStore. Dashboards may query
+// lsf_latest, lsf_snapshots, lsf_changes and lsf_decisions directly.
+//
+//go:embed schema.sql
+var Schema string
+
+// pgTimeout bounds every statement of PGStore.
+const pgTimeout = 30 * time.Second
+
+// PGStore stores records in PostgreSQL. It keeps one connection, opened
+// again after a failure.
+type PGStore struct {
+	dsn  string
+	mu   sync.Mutex
+	conn *pgwire.Conn
+}
+
+// OpenPGStore connects to the database at dsn, a pgwire.Connect URL, and
+// creates the schema if needed.
+func OpenPGStore(ctx context.Context, dsn string) (*PGStore, error) {
+	s := &PGStore{dsn: dsn}
+	if err := s.do(func(c *pgwire.Conn) error { return c.Exec(ctx, Schema) }); err != nil {
+		return nil, err
+	}
+	if err := s.index(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// index fills lsf_identities from the latest snapshot of every host that
+// is not an alias, when the table is empty but snapshots are stored.
+func (s *PGStore) index() error {
+	rows, err := s.query(`SELECT EXISTS (SELECT 1 FROM lsf_identities), EXISTS (SELECT 1 FROM lsf_snapshots)`)
+	if err != nil {
+		return err
+	}
+	if len(rows) != 1 || rows[0][0] == "t" || rows[0][1] != "t" {
+		return nil
+	}
+	hosts, err := s.query("SELECT DISTINCT host FROM lsf_snapshots WHERE host NOT IN (SELECT alias FROM lsf_aliases)")
+	if err != nil {
+		return err
+	}
+	for _, h := range hosts {
+		last, err := s.Latest(h[0])
+		if err != nil {
+			continue
+		}
+		if err := s.tx(func(ctx context.Context, c *pgwire.Conn) error {
+			return insertIdentities(ctx, c, h[0], indexKeys(last.Snapshot))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertIdentities replaces the index entries of host with ids.
+func insertIdentities(ctx context.Context, c *pgwire.Conn, host string, ids map[string]string) error {
+	if err := c.Exec(ctx, "DELETE FROM lsf_identities WHERE host = $1", host); err != nil {
+		return err
+	}
+	for k, v := range ids {
+		if err := c.Exec(ctx, "INSERT INTO lsf_identities (component, digest, host) VALUES ($1, $2, $3)", k, v, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (s *PGStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// do runs f on the connection, dropping the connection when f fails other
+// than by a server error.
+func (s *PGStore) do(f func(c *pgwire.Conn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), pgTimeout)
+		c, err := pgwire.Connect(ctx, s.dsn)
+		cancel()
+		if err != nil {
+			return err
+		}
+		s.conn = c
+	}
+	err := f(s.conn)
+	var perr *pgwire.Error
+	if err != nil && !errors.As(err, &perr) {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// tx runs f in a transaction.
+func (s *PGStore) tx(f func(ctx context.Context, c *pgwire.Conn) error) error {
+	return s.do(func(c *pgwire.Conn) error {
+		ctx, cancel := context.WithTimeout(context.Background(), pgTimeout)
+		defer cancel()
+		if err := c.Exec(ctx, "BEGIN"); err != nil {
+			return err
+		}
+		if err := f(ctx, c); err != nil {
+			c.Exec(ctx, "ROLLBACK")
+			return err
+		}
+		return c.Exec(ctx, "COMMIT")
+	})
+}
+
+// query runs a single statement outside a transaction.
+func (s *PGStore) query(sql string, args ...any) ([][]string, error) {
+	var rows [][]string
+	err := s.do(func(c *pgwire.Conn) error {
+		ctx, cancel := context.WithTimeout(context.Background(), pgTimeout)
+		defer cancel()
+		var err error
+		rows, err = c.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+// lockKey is the pg_advisory_lock key serializing reconciliation.
+const lockKey = 0x6c7366
+
+// Lock implements Locker with a session advisory lock. A connection lost
+// while it is held releases it early.
+func (s *PGStore) Lock() (func(), error) {
+	if _, err := s.query("SELECT pg_advisory_lock($1::bigint)", lockKey); err != nil {
+		return nil, err
+	}
+	return func() { s.query("SELECT pg_advisory_unlock($1::bigint)", lockKey) }, nil
+}
+
+// Put implements Store. The changes the record carries are stored with it.
+func (s *PGStore) Put(r Record) error {
+	b, err := json.Marshal(r.Snapshot)
+	if err != nil {
+		return err
+	}
+	prev, err := s.Latest(r.Host)
+	if err != nil && !errors.Is(err, ErrUnknownHost) {
+		return err
+	}
+	first := err != nil
+	return s.tx(func(ctx context.Context, c *pgwire.Conn) error {
+		snap := r.Snapshot
+		if err := c.Exec(ctx, `INSERT INTO lsf_snapshots
+			(host, stored_at, hash, hostname, machine_id, os_name, os_version, snapshot)
+			VALUES ($1, $2::timestamptz, $3, $4, $5, $6, $7, $8::jsonb)`,
+			r.Host, r.Time, snap.Hash(), snap.Hostname, snap.MachineID, snap.OS.Name, snap.OS.Version, b); err != nil {
+			return err
+		}
+		if !first && r.Time.Before(prev.Time) {
+			return nil
+		}
+		if err := insertIdentities(ctx, c, r.Host, indexKeys(snap)); err != nil {
+			return err
+		}
+		if !first && prev.Time.Before(r.Time) {
+			return insertChanges(ctx, c, r.Host, []Record{prev, r})
+		}
+		return nil
+	})
+}
+
+// insertChanges stores the changes of history, volatile ones included.
+func insertChanges(ctx context.Context, c *pgwire.Conn, host string, history []Record) error {
+	for _, fc := range Changes(history, time.Time{}, true) {
+		volatile := fingerprint.StabilityOf(fc.Field) == fingerprint.Volatile
+		if err := c.Exec(ctx, `INSERT INTO lsf_changes
+			(host, changed_at, field, kind, old, new, hash, volatile)
+			VALUES ($1, $2::timestamptz, $3, $4, $5, $6, $7, $8::boolean)`,
+			host, fc.Time, fc.Field, fc.Kind, fc.Old, fc.New, fc.Hash, volatile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hosts implements Store.
+func (s *PGStore) Hosts() ([]string, error) {
+	rows, err := s.query("SELECT DISTINCT host FROM lsf_snapshots ORDER BY host")
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, r := range rows {
+		out = append(out, r[0])
+	}
+	return out, nil
+}
+
+// recordColumns selects what pgRecord decodes; times come back as Unix
+// microseconds to avoid parsing the server's date style.
+const recordColumns = "(extract(epoch FROM stored_at) * 1000000)::bigint, snapshot"
+
+func pgRecord(host string, row []string) (Record, error) {
+	us, err := strconv.ParseInt(row[0], 10, 64)
+	if err != nil {
+		return Record{}, err
+	}
+	snap, err := fingerprint.Unmarshal([]byte(row[1]))
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Host: host, Time: time.UnixMicro(us).UTC(), Snapshot: snap}, nil
+}
+
+// History implements Store. Rows that do not decode are skipped.
+func (s *PGStore) History(host string) ([]Record, error) {
+	rows, err := s.query("SELECT "+recordColumns+" FROM lsf_snapshots WHERE host = $1 ORDER BY stored_at, id", host)
+	if err != nil {
+		return nil, err
+	}
+	var out []Record
+	for _, row := range rows {
+		if r, err := pgRecord(host, row); err == nil {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrUnknownHost
+	}
+	return out, nil
+}
+
+// Latest implements Store.
+func (s *PGStore) Latest(host string) (Record, error) {
+	rows, err := s.query("SELECT "+recordColumns+" FROM lsf_snapshots WHERE host = $1 ORDER BY stored_at DESC, id DESC LIMIT 1", host)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(rows) == 0 {
+		return Record{}, ErrUnknownHost
+	}
+	return pgRecord(host, rows[0])
+}
+
+// Merge implements Store.
+func (s *PGStore) Merge(from, into string) error {
+	return s.tx(func(ctx context.Context, c *pgwire.Conn) error {
+		if err := c.Exec(ctx, `INSERT INTO lsf_aliases (alias, host) VALUES ($1, $2)
+			ON CONFLICT (alias) DO UPDATE SET host = EXCLUDED.host`, from, into); err != nil {
+			return err
+		}
+		if err := c.Exec(ctx, "UPDATE lsf_aliases SET host = $2 WHERE host = $1", from, into); err != nil {
+			return err
+		}
+		return c.Exec(ctx, "DELETE FROM lsf_identities WHERE host = $1", from)
+	})
+}
+
+// Aliases implements Store.
+func (s *PGStore) Aliases() (map[string]string, error) {
+	rows, err := s.query("SELECT alias, host FROM lsf_aliases")
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, r := range rows {
+		out[r[0]] = r[1]
+	}
+	return out, nil
+}
+
+// Candidates implements Store.
+func (s *PGStore) Candidates(ids map[string]string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var where []string
+	var args []any
+	for k, v := range ids {
+		where = append(where, fmt.Sprintf("(component = $%d AND digest = $%d)", len(args)+1, len(args)+2))
+		args = append(args, k, v)
+	}
+	rows, err := s.query("SELECT DISTINCT host FROM lsf_identities WHERE "+strings.Join(where, " OR ")+" ORDER BY host", args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, r := range rows {
+		out = append(out, r[0])
+	}
+	return out, nil
+}
+
+// Prune implements Store. Changes stay in lsf_changes.
+func (s *PGStore) Prune(host string, keep int) error {
+	return s.exec(`DELETE FROM lsf_snapshots WHERE host = $1 AND id NOT IN
+		(SELECT id FROM lsf_snapshots WHERE host = $1 ORDER BY stored_at DESC, id DESC LIMIT $2::bigint)`, host, keep)
+}
+
+func (s *PGStore) exec(sql string, args ...any) error {
+	_, err := s.query(sql, args...)
+	return err
+}
+
+// Audit implements Store.
+func (s *PGStore) Audit(d Decision) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return s.exec(`INSERT INTO lsf_decisions (decided_at, action, host, hash, decision)
+		VALUES ($1::timestamptz, $2, $3, $4, $5::jsonb)`, d.Time, d.Action, d.Host, d.Hash, b)
+}
+
+// Decisions implements Store.
+func (s *PGStore) Decisions() ([]Decision, error) {
+	rows, err := s.query("SELECT decision FROM lsf_decisions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	var out []Decision
+	for _, r := range rows {
+		var d Decision
+		if json.Unmarshal([]byte(r[0]), &d) == nil {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package fleet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Policy decides which host record a pushed snapshot belongs to.
+type Policy struct {
+	// Tolerance is how many components may differ from a host's latest
+	// snapshot, and which must not, for the snapshot to continue that
+	// host.
+	fingerprint.Tolerance
+	// Ambiguous is what to do when the snapshot continues several hosts,
+	// e.g. a disk moved into the chassis of another machine: "merge"
+	// files it under the closest host and makes the others aliases of it,
+	// "fork" starts a new host. Empty means "fork".
+	Ambiguous string `json:"ambiguous,omitempty"`
+}
+
+// DefaultPolicy tolerates two replaced parts and never merges hosts on
+// its own.
+var DefaultPolicy = Policy{Tolerance: fingerprint.Tolerance{MaxMismatches: 2}, Ambiguous: "fork"}
+
+// LoadPolicy reads a policy from a JSON file such as
+// {"max_mismatches": 3, "required": ["machine_id"], "ambiguous": "merge"}.
+func LoadPolicy(path string) (Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Policy{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Ambiguous != "" && p.Ambiguous != "merge" && p.Ambiguous != "fork" {
+		return Policy{}, fmt.Errorf("%s: ambiguous must be merge or fork, not %q", path, p.Ambiguous)
+	}
+	return p, nil
+}
+
+// Decision records how a snapshot was filed, for audit.
+type Decision struct {
+	Time time.Time `json:"time"`
+	// Action is "announced" for snapshots carrying their host ID, "new"
+	// for unknown hardware, "match" when the snapshot continues one host,
+	// "merge" when it joined several and "fork" when it matched some
+	// partly but none or several within the policy.
+	Action string `json:"action"`
+	// Host is the host the snapshot was filed under.
+	Host string `json:"host"`
+	Hash string `json:"hash"`
+	// Candidates are the hosts sharing an identifying component with the
+	// snapshot.
+	Candidates []Candidate `json:"candidates,omitempty"`
+	// Merged lists the hosts made aliases of Host.
+	Merged []string `json:"merged,omitempty"`
+	// Bundle is the ID of the bundle an imported snapshot came from.
+	Bundle string `json:"bundle,omitempty"`
+	// Entry is the snapshot's 1-based position in the bundle, oldest
+	// first.
+	Entry int `json:"entry,omitempty"`
+}
+
+// Candidate is a host a snapshot partly matches.
+type Candidate struct {
+	Host       string   `json:"host"`
+	Matched    []string `json:"matched"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	// Within reports whether the difference stays within the policy.
+	Within bool `json:"within_tolerance"`
+}
+
+// Reconcile decides where snap belongs among the hosts of st and files it
+// there, merging hosts as the policy allows, and records the decision.
+// announced is the host ID the agent sent, if any, which is trusted.
+func Reconcile(st Store, p Policy, snap fingerprint.Snapshot, announced string, now time.Time) (Decision, error) {
+	return reconcile(st, p, snap, announced, Decision{Time: now})
+}
+
+// reconcile is Reconcile for a decision with Time and Bundle filled in.
+func reconcile(st Store, p Policy, snap fingerprint.Snapshot, announced string, d Decision) (Decision, error) {
+	now := d.Time
+	d.Hash = snap.Hash()
+	if announced != "" {
+		aliases, err := st.Aliases()
+		if err != nil {
+			return d, err
+		}
+		if into, ok := aliases[announced]; ok {
+			announced = into
+		}
+		d.Action, d.Host = "announced", announced
+		return d, file(st, d, snap)
+	}
+	hosts, err := st.Candidates(identity(snap))
+	if err != nil {
+		return d, err
+	}
+	got := snap.ComponentDigests()
+	var within []Candidate
+	for _, h := range hosts {
+		last, err := st.Latest(h)
+		if err != nil {
+			continue
+		}
+		want := last.Snapshot.ComponentDigests()
+		var matched []string
+		for k, v := range want {
+			if expectUnique[k] && got[k] == v {
+				matched = append(matched, k)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		sort.Strings(matched)
+		c := Candidate{Host: h, Matched: matched}
+		c.Mismatched, c.Within = fingerprint.MatchDigests(want, got, p.Tolerance)
+		d.Candidates = append(d.Candidates, c)
+		if c.Within {
+			within = append(within, c)
+		}
+	}
+	// Closest first; ties go to the host filed under the snapshot's own ID.
+	sort.SliceStable(within, func(i, j int) bool {
+		if len(within[i].Mismatched) != len(within[j].Mismatched) {
+			return len(within[i].Mismatched) < len(within[j].Mismatched)
+		}
+		return within[i].Host == snap.ID()
+	})
+	switch {
+	case len(within) == 1:
+		d.Action, d.Host = "match", within[0].Host
+	case len(within) > 1 && p.Ambiguous == "merge":
+		d.Action, d.Host = "merge", within[0].Host
+		for _, c := range within[1:] {
+			if err := st.Merge(c.Host, d.Host); err != nil {
+				return d, err
+			}
+			d.Merged = append(d.Merged, c.Host)
+		}
+	case len(d.Candidates) > 0:
+		d.Action, d.Host = "fork", snap.ID()
+	default:
+		d.Action, d.Host = "new", snap.ID()
+	}
+	// A forked identity must not land in a history it was split from.
+	if d.Action == "fork" && slices.ContainsFunc(d.Candidates, func(c Candidate) bool { return c.Host == d.Host }) {
+		d.Host = fmt.Sprintf("%s-%d", d.Host, now.Unix())
+	}
+	return d, file(st, d, snap)
+}
+
+// identity returns the identifying component digests of snap, the keys of
+// the candidate index.
+func identity(snap fingerprint.Snapshot) map[string]string {
+	out := map[string]string{}
+	for k, v := range snap.ComponentDigests() {
+		if expectUnique[k] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// documentKey indexes the SHA-256 of a host's latest record as JSON, the
+// base delta pushes name.
+const documentKey = "document"
+
+// indexKeys returns what the candidate index holds for the latest record
+// of a host: its identity and the digest of the document.
+func indexKeys(snap fingerprint.Snapshot) map[string]string {
+	ids := identity(snap)
+	if b, err := json.Marshal(snap); err == nil {
+		sum := sha256.Sum256(b)
+		ids[documentKey] = hex.EncodeToString(sum[:])
+	}
+	return ids
+}
+
+func file(st Store, d Decision, snap fingerprint.Snapshot) error {
+	if err := st.Put(Record{Host: d.Host, Time: d.Time, Snapshot: snap}); err != nil {
+		return err
+	}
+	return st.Audit(d)
+}
@@ -0,0 +1,128 @@
+package fleet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func machine(id, rootfs string) fingerprint.Snapshot {
+	return fingerprint.Snapshot{SchemaVersion: fingerprint.SchemaVersion, MachineID: id, RootFS: fingerprint.RootFSInfo{UUID: rootfs}}
+}
+
+// countingStore counts the Latest calls reconciliation makes.
+type countingStore struct {
+	DirStore
+	latest int
+}
+
+func (c *countingStore) Latest(host string) (Record, error) {
+	c.latest++
+	return c.DirStore.Latest(host)
+}
+
+func TestReconcileMatchAndFork(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	t0 := time.Unix(1700000000, 0).UTC()
+	first, err := Reconcile(st, DefaultPolicy, machine("m1", "r1"), "", t0)
+	if err != nil || first.Action != "new" {
+		t.Fatalf("first = %+v, %v", first, err)
+	}
+	// A new root file system continues the host.
+	d, err := Reconcile(st, DefaultPolicy, machine("m1", "r2"), "", t0.Add(time.Hour))
+	if err != nil || d.Action != "match" || d.Host != first.Host {
+		t.Fatalf("second = %+v, %v", d, err)
+	}
+	// Beyond the tolerance the snapshot forks.
+	strict := Policy{Tolerance: fingerprint.Tolerance{MaxMismatches: 0}}
+	d, err = Reconcile(st, strict, machine("m1", "r3"), "", t0.Add(2*time.Hour))
+	if err != nil || d.Action != "fork" || d.Host == first.Host {
+		t.Fatalf("third = %+v, %v", d, err)
+	}
+}
+
+func TestReconcileMergeKeepsAliases(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	t0 := time.Unix(1700000000, 0).UTC()
+	if _, err := Reconcile(st, DefaultPolicy, machine("m1", "r1"), "a", t0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reconcile(st, DefaultPolicy, machine("m2", "r2"), "b", t0.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	merge := DefaultPolicy
+	merge.Ambiguous = "merge"
+	d, err := Reconcile(st, merge, machine("m1", "r2"), "", t0.Add(time.Hour))
+	if err != nil || d.Action != "merge" || d.Host != "a" || len(d.Merged) != 1 || d.Merged[0] != "b" {
+		t.Fatalf("merge = %+v, %v", d, err)
+	}
+	// b keeps its own history and is no longer matched.
+	if h, err := st.History("b"); err != nil || len(h) != 1 || h[0].Snapshot.MachineID != "m2" {
+		t.Errorf("History(b) = %+v, %v", h, err)
+	}
+	if h, err := st.History("a"); err != nil || len(h) != 2 {
+		t.Errorf("History(a) has %d records, %v", len(h), err)
+	}
+	if c, err := st.Candidates(identity(machine("m2", "r2"))); err != nil || len(c) != 1 || c[0] != "a" {
+		t.Errorf("Candidates = %v, %v", c, err)
+	}
+	if al, err := st.Aliases(); err != nil || al["b"] != "a" {
+		t.Errorf("Aliases = %v, %v", al, err)
+	}
+	// A snapshot announced under the alias goes to the host it joined.
+	d, err = Reconcile(st, DefaultPolicy, machine("m2", "r2"), "b", t0.Add(2*time.Hour))
+	if err != nil || d.Host != "a" {
+		t.Errorf("announced alias = %+v, %v", d, err)
+	}
+}
+
+func TestReconcileUsesIndex(t *testing.T) {
+	st := &countingStore{DirStore: DirStore{Dir: t.TempDir()}}
+	t0 := time.Unix(1700000000, 0).UTC()
+	for i := range 50 {
+		if _, err := Reconcile(st, DefaultPolicy, machine(fmt.Sprint("m", i), fmt.Sprint("r", i)), "", t0.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.latest = 0
+	d, err := Reconcile(st, DefaultPolicy, machine("m7", "new-disk"), "", t0.Add(time.Hour))
+	if err != nil || d.Action != "match" {
+		t.Fatalf("Reconcile = %+v, %v", d, err)
+	}
+	if st.latest != 1 {
+		t.Errorf("Reconcile read the latest record of %d hosts, want 1", st.latest)
+	}
+}
+
+func TestDirStoreBuildsMissingIndex(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	if _, err := Reconcile(st, DefaultPolicy, machine("m1", "r1"), "a", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// A store written before the index existed.
+	if err := os.RemoveAll(filepath.Join(st.Dir, indexDir)); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := st.Candidates(identity(machine("m1", "other"))); err != nil || len(c) != 1 || c[0] != "a" {
+		t.Errorf("Candidates = %v, %v", c, err)
+	}
+	if hosts, err := st.Hosts(); err != nil || len(hosts) != 1 {
+		t.Errorf("Hosts = %v, %v", hosts, err)
+	}
+}
+
+func TestDirStoreRejectsHiddenHosts(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	for _, h := range []string{"", ".", "..", ".index", ".index-build", "a/b", `a\b`} {
+		if err := st.Put(Record{Host: h, Snapshot: machine("m1", "r1")}); err == nil {
+			t.Errorf("Put(%q) succeeded", h)
+		}
+	}
+	if err := st.Put(Record{Host: "a.b", Snapshot: machine("m1", "r1")}); err != nil {
+		t.Errorf("Put(%q) = %v", "a.b", err)
+	}
+}
@@ -0,0 +1,66 @@
+-- Schema of the fleet server's Postgres store, applied by OpenPGStore.
+-- Statements are idempotent so that every start may run them.
+
+-- Every pushed snapshot. The columns next to the document are copied from
+-- it for dashboards; the document is the source of truth.
+CREATE TABLE IF NOT EXISTS lsf_snapshots (
+	id         bigserial PRIMARY KEY,
+	host       text        NOT NULL,
+	stored_at  timestamptz NOT NULL,
+	hash       text        NOT NULL,
+	hostname   text        NOT NULL DEFAULT '',
+	machine_id text        NOT NULL DEFAULT '',
+	os_name    text        NOT NULL DEFAULT '',
+	os_version text        NOT NULL DEFAULT '',
+	snapshot   jsonb       NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lsf_snapshots_host ON lsf_snapshots (host, stored_at);
+CREATE INDEX IF NOT EXISTS lsf_snapshots_hash ON lsf_snapshots (hash);
+
+-- Field changes between consecutive snapshots of a host, as served by
+-- GET /hosts/{id}/changes, kept so that dashboards need not diff JSON.
+CREATE TABLE IF NOT EXISTS lsf_changes (
+	host       text        NOT NULL,
+	changed_at timestamptz NOT NULL,
+	field      text        NOT NULL,
+	kind       text        NOT NULL, -- added, removed or changed
+	old        text        NOT NULL DEFAULT '',
+	new        text        NOT NULL DEFAULT '',
+	hash       text        NOT NULL,
+	volatile   boolean     NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lsf_changes_time ON lsf_changes (changed_at);
+CREATE INDEX IF NOT EXISTS lsf_changes_host ON lsf_changes (host, changed_at);
+
+-- Identifying component digests of every host's newest snapshot, where
+-- Reconcile looks up the hosts a snapshot may continue, and the SHA-256 of
+-- the snapshot document (component "document") delta pushes apply to.
+CREATE TABLE IF NOT EXISTS lsf_identities (
+	component text NOT NULL,
+	digest    text NOT NULL,
+	host      text NOT NULL,
+	PRIMARY KEY (component, digest, host)
+);
+CREATE INDEX IF NOT EXISTS lsf_identities_host ON lsf_identities (host);
+
+-- Hosts merged into others; their snapshots stay under their own name.
+CREATE TABLE IF NOT EXISTS lsf_aliases (
+	alias text PRIMARY KEY,
+	host  text NOT NULL
+);
+
+-- The reconciliation audit log.
+CREATE TABLE IF NOT EXISTS lsf_decisions (
+	id         bigserial PRIMARY KEY,
+	decided_at timestamptz NOT NULL,
+	action     text        NOT NULL,
+	host       text        NOT NULL,
+	hash       text        NOT NULL,
+	decision   jsonb       NOT NULL
+);
+
+-- The newest snapshot of every host.
+CREATE OR REPLACE VIEW lsf_latest AS
+	SELECT DISTINCT ON (host) host, stored_at, hash, hostname, machine_id, os_name, os_version, snapshot
+	FROM lsf_snapshots
+	ORDER BY host, stored_at DESC, id DESC;
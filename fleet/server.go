@@ -0,0 +1,256 @@
+package fleet
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/httpenc"
+	"AurFingerprintAgent/jsonpatch"
+	"AurFingerprintAgent/push"
+)
+
+// maxSnapshotBytes bounds a pushed request body and the snapshot it
+// decodes to.
+const maxSnapshotBytes = 16 << 20
+
+// Config configures Handler.
+type Config struct {
+	Store  Store
+	Policy Policy
+	// Keys are the public keys whose signed snapshots are accepted.
+	Keys []crypto.PublicKey
+	// ClientCerts accepts unsigned snapshots over TLS connections with a
+	// verified client certificate.
+	ClientCerts bool
+	// Insecure accepts unsigned snapshots from anyone, e.g. behind a
+	// proxy that authenticates the agents. It also opens the read
+	// endpoints.
+	Insecure bool
+	// PublicRead serves the read endpoints to clients without a verified
+	// client certificate.
+	PublicRead bool
+	// MaxHistory bounds the records kept per host, dropping the oldest;
+	// unbounded when zero.
+	MaxHistory int
+}
+
+// Handler returns the fleet server's HTTP API over cfg.Store:
+//
+//	POST /snapshots                 store a snapshot sent by push
+//	GET  /hosts                     list host IDs
+//	GET  /hosts/{id}/changes?since= field changes after since (RFC 3339)
+//	GET  /decisions                 audit log of Reconcile decisions
+//
+// The change feed leaves out volatile fields unless volatile=true is
+// given. A pushed snapshot must be signed by one of cfg.Keys, or come over
+// a connection cfg.ClientCerts or cfg.Insecure accepts; others are refused
+// with 401 or 403 before anything is reconciled. The GET endpoints need
+// a verified client certificate unless cfg.PublicRead or cfg.Insecure is
+// set, and answer 401 otherwise. A snapshot is filed under the
+// X-LSF-Device-ID header of Register, else under the host Reconcile picks
+// with cfg.Policy. Delta pushes are applied to the latest record the
+// X-LSF-Base-Hash header names; when none matches, push is answered with
+// 412 Precondition Failed and sends the full snapshot.
+func Handler(cfg Config) http.Handler {
+	st := cfg.Store
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /snapshots", func(w http.ResponseWriter, r *http.Request) {
+		snap, err := cfg.readSnapshot(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err))
+			return
+		}
+		unlock, err := lockStore(st)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		d, err := Reconcile(st, cfg.Policy, snap, r.Header.Get(push.DeviceIDHeader), time.Now().UTC())
+		if err == nil && cfg.MaxHistory > 0 {
+			err = st.Prune(d.Host, cfg.MaxHistory)
+		}
+		unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /decisions", cfg.reader(func(w http.ResponseWriter, r *http.Request) {
+		d, err := st.Decisions()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if d == nil {
+			d = []Decision{}
+		}
+		writeJSON(w, d)
+	}))
+	mux.HandleFunc("GET /hosts", cfg.reader(func(w http.ResponseWriter, r *http.Request) {
+		hosts, err := st.Hosts()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if hosts == nil {
+			hosts = []string{}
+		}
+		writeJSON(w, hosts)
+	}))
+	mux.HandleFunc("GET /hosts/{id}/changes", cfg.reader(func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				http.Error(w, "since: not an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		history, err := st.History(r.PathValue("id"))
+		if errors.Is(err, ErrUnknownHost) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, Changes(history, since, r.URL.Query().Get("volatile") == "true"))
+	}))
+	return mux
+}
+
+// reader guards a read endpoint: it needs a verified client certificate
+// unless cfg opens reads to everyone.
+func (cfg Config) reader(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.PublicRead && !cfg.Insecure && !verifiedClient(r) {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// verifiedClient reports whether r came with a verified client certificate.
+func verifiedClient(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
+// statusError is a request error with its HTTP status.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func httpStatus(err error) int {
+	if se, ok := err.(*statusError); ok {
+		return se.code
+	}
+	return http.StatusBadRequest
+}
+
+// readSnapshot decodes a pushed snapshot and checks that the push is
+// authenticated.
+func (cfg Config) readSnapshot(w http.ResponseWriter, r *http.Request) (fingerprint.Snapshot, error) {
+	raw := http.MaxBytesReader(w, r.Body, maxSnapshotBytes)
+	body, err := httpenc.NewReader(r.Header.Get("Content-Encoding"), raw)
+	if err != nil {
+		return fingerprint.Snapshot{}, &statusError{http.StatusUnsupportedMediaType, err.Error()}
+	}
+	defer body.Close()
+	b, err := io.ReadAll(io.LimitReader(body, maxSnapshotBytes+1))
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) || len(b) > maxSnapshotBytes {
+		return fingerprint.Snapshot{}, &statusError{http.StatusRequestEntityTooLarge, "snapshot too large"}
+	}
+	if err != nil {
+		return fingerprint.Snapshot{}, err
+	}
+	delta := false
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json-patch+json" {
+		delta = true
+	}
+	var env fingerprint.SignedSnapshot
+	if !delta && json.Unmarshal(b, &env) == nil && env.Signature != nil {
+		for _, k := range cfg.Keys {
+			if fingerprint.KeyID(k) == env.KeyID {
+				snap, err := env.Verify(k)
+				if errors.Is(err, fingerprint.ErrBadSignature) {
+					return snap, &statusError{http.StatusForbidden, err.Error()}
+				}
+				if err != nil {
+					return snap, fmt.Errorf("decoding snapshot: %w", err)
+				}
+				return snap, nil
+			}
+		}
+		return fingerprint.Snapshot{}, &statusError{http.StatusForbidden, "snapshot signed by an untrusted key"}
+	}
+	if !cfg.Insecure && !(cfg.ClientCerts && verifiedClient(r)) {
+		return fingerprint.Snapshot{}, &statusError{http.StatusUnauthorized, "snapshot is neither signed nor sent with a client certificate"}
+	}
+	if delta {
+		if b, err = cfg.applyDelta(r.Header.Get(push.BaseHashHeader), b); err != nil {
+			return fingerprint.Snapshot{}, err
+		}
+	}
+	snap, err := fingerprint.Unmarshal(b)
+	if err != nil {
+		return fingerprint.Snapshot{}, fmt.Errorf("decoding snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// applyDelta applies a JSON Patch to the latest record whose document has
+// the SHA-256 base. A base the store does not hold is answered with 412,
+// a patch that does not apply with 409; push then sends the full snapshot.
+func (cfg Config) applyDelta(base string, patch []byte) ([]byte, error) {
+	hosts, err := cfg.Store.Candidates(map[string]string{documentKey: base})
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, err.Error()}
+	}
+	for _, h := range hosts {
+		last, err := cfg.Store.Latest(h)
+		if err != nil {
+			continue
+		}
+		doc, err := json.Marshal(last.Snapshot)
+		if err != nil {
+			continue
+		}
+		if sum := sha256.Sum256(doc); hex.EncodeToString(sum[:]) != base {
+			continue
+		}
+		var ops []jsonpatch.Operation
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return nil, &statusError{http.StatusConflict, "decoding patch: " + err.Error()}
+		}
+		full, err := jsonpatch.Apply(doc, ops)
+		if err != nil {
+			return nil, &statusError{http.StatusConflict, err.Error()}
+		}
+		if len(full) > maxSnapshotBytes {
+			return nil, &statusError{http.StatusRequestEntityTooLarge, "snapshot too large"}
+		}
+		return full, nil
+	}
+	return nil, &statusError{http.StatusPreconditionFailed, "unknown delta base " + base}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
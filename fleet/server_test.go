@@ -0,0 +1,176 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/push"
+)
+
+func post(t *testing.T, h http.Handler, body []byte, edit func(*http.Request)) int {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/snapshots", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if edit != nil {
+		edit(r)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func testSnapshot() fingerprint.Snapshot {
+	return fingerprint.Snapshot{SchemaVersion: fingerprint.SchemaVersion, Hostname: "app-01", MachineID: "0123456789abcdef0123456789abcdef"}
+}
+
+func signed(t *testing.T, k crypto.Signer) []byte {
+	t.Helper()
+	env, err := fingerprint.Sign(k, testSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(env)
+	return b
+}
+
+func TestHandlerAuth(t *testing.T) {
+	_, trusted, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	plain, _ := json.Marshal(testSnapshot())
+	tampered := bytes.Replace(signed(t, trusted), []byte("app-01"), []byte("app-02"), 1)
+	verifiedTLS := func(r *http.Request) {
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	}
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		body []byte
+		edit func(*http.Request)
+		want int
+	}{
+		{"unsigned", Config{Keys: []crypto.PublicKey{trusted.Public()}}, plain, nil, http.StatusUnauthorized},
+		{"unsigned insecure", Config{Insecure: true}, plain, nil, http.StatusNoContent},
+		{"unsigned without client certificate", Config{ClientCerts: true}, plain, nil, http.StatusUnauthorized},
+		{"unsigned with client certificate", Config{ClientCerts: true}, plain, verifiedTLS, http.StatusNoContent},
+		{"trusted signer", Config{Keys: []crypto.PublicKey{trusted.Public()}}, signed(t, trusted), nil, http.StatusNoContent},
+		{"untrusted signer", Config{Keys: []crypto.PublicKey{trusted.Public()}, Insecure: true}, signed(t, other), nil, http.StatusForbidden},
+		{"tampered", Config{Keys: []crypto.PublicKey{trusted.Public()}}, tampered, nil, http.StatusForbidden},
+		{"too large", Config{Insecure: true}, []byte(`{"hostname":"` + strings.Repeat("a", maxSnapshotBytes) + `"}`), nil, http.StatusRequestEntityTooLarge},
+		{"delta against an unknown base", Config{Insecure: true}, []byte(`[]`), func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json-patch+json")
+		}, http.StatusPreconditionFailed},
+		{"unsigned delta", Config{Keys: []crypto.PublicKey{trusted.Public()}}, []byte(`[]`), func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json-patch+json")
+		}, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Store, tc.cfg.Policy = DirStore{Dir: t.TempDir()}, DefaultPolicy
+			if got := post(t, Handler(tc.cfg), tc.body, tc.edit); got != tc.want {
+				t.Errorf("POST = %d, want %d", got, tc.want)
+			}
+			hosts, _ := tc.cfg.Store.Hosts()
+			if stored := len(hosts) > 0; stored != (tc.want == http.StatusNoContent) {
+				t.Errorf("stored = %v with status %d", stored, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandlerMaxHistory(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	h := Handler(Config{Store: st, Policy: DefaultPolicy, Insecure: true, MaxHistory: 2})
+	body, _ := json.Marshal(testSnapshot())
+	for range 3 {
+		if got := post(t, h, body, nil); got != http.StatusNoContent {
+			t.Fatalf("POST = %d", got)
+		}
+	}
+	hosts, err := st.Hosts()
+	if err != nil || len(hosts) != 1 {
+		t.Fatalf("Hosts = %v, %v", hosts, err)
+	}
+	if history, err := st.History(hosts[0]); err != nil || len(history) != 2 {
+		t.Errorf("History has %d records, want 2 (%v)", len(history), err)
+	}
+}
+
+func TestHandlerDelta(t *testing.T) {
+	st := DirStore{Dir: t.TempDir()}
+	requests := 0
+	h := Handler(Config{Store: st, Policy: DefaultPolicy, Insecure: true})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	c := &push.Client{URL: srv.URL + "/snapshots", DeltaState: filepath.Join(t.TempDir(), "state"), DeltaRatio: 10}
+	snap := testSnapshot()
+	snap.CPU.Model = "Xeon"
+	ctx := context.Background()
+	if err := c.Push(ctx, snap, ""); err != nil {
+		t.Fatal(err)
+	}
+	snap.CPU.Model = "Xeon Gold"
+	if err := c.Push(ctx, snap, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Push(ctx, snap, ""); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("%d requests, want 2: a full push, a delta and none for the unchanged snapshot", requests)
+	}
+	hosts, _ := st.Hosts()
+	if len(hosts) != 1 {
+		t.Fatalf("Hosts = %v", hosts)
+	}
+	last, err := st.Latest(hosts[0])
+	if err != nil || last.Snapshot.CPU.Model != "Xeon Gold" {
+		t.Errorf("Latest = %+v, %v", last.Snapshot.CPU, err)
+	}
+	if decisions, _ := st.Decisions(); len(decisions) != 2 || decisions[1].Action != "match" {
+		t.Errorf("Decisions = %+v", decisions)
+	}
+}
+
+func TestHandlerReadAuth(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		tls  *tls.ConnectionState
+		want int
+	}{
+		{"anonymous", Config{ClientCerts: true}, nil, http.StatusUnauthorized},
+		{"unverified TLS", Config{ClientCerts: true}, &tls.ConnectionState{}, http.StatusUnauthorized},
+		{"client certificate", Config{ClientCerts: true}, verified, http.StatusOK},
+		{"public read", Config{PublicRead: true}, nil, http.StatusOK},
+		{"insecure", Config{Insecure: true}, nil, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Store, tc.cfg.Policy = DirStore{Dir: t.TempDir()}, DefaultPolicy
+			h := Handler(tc.cfg)
+			for _, path := range []string{"/hosts", "/decisions"} {
+				r := httptest.NewRequest("GET", path, nil)
+				r.TLS = tc.tls
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				if w.Code != tc.want {
+					t.Errorf("GET %s = %d, want %d", path, w.Code, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+package fleet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Record is a snapshot a host pushed, as stored by the fleet server.
+type Record struct {
+	Host     string
+	Time     time.Time
+	Snapshot fingerprint.Snapshot
+}
+
+// Store keeps the snapshots of every host.
+type Store interface {
+	// Put stores r.
+	Put(r Record) error
+	// Hosts lists the known hosts, sorted.
+	Hosts() ([]string, error)
+	// History returns the records of host, oldest first; ErrUnknownHost
+	// when there are none.
+	History(host string) ([]Record, error)
+	// Latest returns the newest record of host, or ErrUnknownHost.
+	Latest(host string) (Record, error)
+	// Candidates returns the hosts, sorted, whose latest record shares one
+	// of the identifying component digests ids, or the document digest
+	// under "document", from an index Put keeps.
+	Candidates(ids map[string]string) ([]string, error)
+	// Merge makes host from an alias of host into: from keeps its records
+	// but is no longer a candidate, and snapshots announced under it are
+	// filed under into. Aliases returns the mapping.
+	Merge(from, into string) error
+	Aliases() (map[string]string, error)
+	// Prune drops all but the newest keep records of host.
+	Prune(host string, keep int) error
+	// Audit appends a reconciliation decision to the audit log, and
+	// Decisions returns the log, oldest first.
+	Audit(d Decision) error
+	Decisions() ([]Decision, error)
+}
+
+// ErrUnknownHost is returned for hosts a store has no records of.
+var ErrUnknownHost = errors.New("fleet: unknown host")
+
+// DirStore stores records as <Dir>/<host>/<unix nanoseconds>.json, the
+// layout report -history reads, and the audit log as JSON lines in
+// <Dir>/decisions.jsonl. Aliases are a JSON object in <Dir>/aliases.json,
+// and the candidate index has a file per identifying component digest,
+// <Dir>/.index/<component>/<digest>, listing the hosts carrying it.
+// A missing index is built from the latest records on first use.
+type DirStore struct {
+	Dir string
+}
+
+// Put implements Store.
+func (d DirStore) Put(r Record) error {
+	if err := validHost(r.Host); err != nil {
+		return err
+	}
+	if err := d.index(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(r.Snapshot)
+	if err != nil {
+		return err
+	}
+	prev, perr := d.Latest(r.Host)
+	dir := filepath.Join(d.Dir, r.Host)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, strconv.FormatInt(r.Time.UnixNano(), 10)+".json")
+	if err := writeAtomic(name, b); err != nil {
+		return err
+	}
+	if perr == nil && r.Time.Before(prev.Time) {
+		return nil
+	}
+	var old map[string]string
+	if perr == nil {
+		old = indexKeys(prev.Snapshot)
+	}
+	return d.reindex(r.Host, old, indexKeys(r.Snapshot))
+}
+
+func writeAtomic(name string, b []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Hosts implements Store.
+func (d DirStore) Hosts() ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			out = append(out, e.Name())
+		}
+	}
+	return out, nil
+}
+
+// History implements Store. Files that do not decode are skipped.
+func (d DirStore) History(host string) ([]Record, error) {
+	files, err := d.files(host)
+	if err != nil {
+		return nil, err
+	}
+	var out []Record
+	for _, f := range files {
+		r, err := d.read(host, f)
+		if err != nil {
+			continue
+		}
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil, ErrUnknownHost
+	}
+	return out, nil
+}
+
+// Latest implements Store.
+func (d DirStore) Latest(host string) (Record, error) {
+	files, err := d.files(host)
+	if err != nil {
+		return Record{}, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if r, err := d.read(host, files[i]); err == nil {
+			return r, nil
+		}
+	}
+	return Record{}, ErrUnknownHost
+}
+
+// files lists the record files of host, oldest first.
+func (d DirStore) files(host string) ([]string, error) {
+	if err := validHost(host); err != nil {
+		return nil, ErrUnknownHost
+	}
+	entries, err := os.ReadDir(filepath.Join(d.Dir, host))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUnknownHost
+	}
+	if err != nil {
+		return nil, err
+	}
+	type file struct {
+		name string
+		ns   int64
+	}
+	var fs []file
+	for _, e := range entries {
+		ns, err := strconv.ParseInt(strings.TrimSuffix(e.Name(), ".json"), 10, 64)
+		if err == nil && strings.HasSuffix(e.Name(), ".json") {
+			fs = append(fs, file{e.Name(), ns})
+		}
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].ns < fs[j].ns })
+	out := make([]string, len(fs))
+	for i, f := range fs {
+		out[i] = f.name
+	}
+	return out, nil
+}
+
+func (d DirStore) read(host, name string) (Record, error) {
+	ns, _ := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+	b, err := os.ReadFile(filepath.Join(d.Dir, host, name))
+	if err != nil {
+		return Record{}, err
+	}
+	s, err := fingerprint.Unmarshal(b)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Host: host, Time: time.Unix(0, ns).UTC(), Snapshot: s}, nil
+}
+
+// Merge implements Store.
+func (d DirStore) Merge(from, into string) error {
+	if err := validHost(from); err != nil {
+		return err
+	}
+	if err := validHost(into); err != nil {
+		return err
+	}
+	if err := d.index(); err != nil {
+		return err
+	}
+	aliases, err := d.Aliases()
+	if err != nil {
+		return err
+	}
+	aliases[from] = into
+	for a, h := range aliases {
+		if h == from {
+			aliases[a] = into
+		}
+	}
+	b, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.Dir, 0o750); err != nil {
+		return err
+	}
+	if err := writeAtomic(filepath.Join(d.Dir, "aliases.json"), b); err != nil {
+		return err
+	}
+	if last, err := d.Latest(from); err == nil {
+		return d.reindex(from, indexKeys(last.Snapshot), nil)
+	}
+	return nil
+}
+
+// Aliases implements Store.
+func (d DirStore) Aliases() (map[string]string, error) {
+	aliases := map[string]string{}
+	b, err := os.ReadFile(filepath.Join(d.Dir, "aliases.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &aliases); err != nil {
+		return nil, fmt.Errorf("fleet: aliases.json: %w", err)
+	}
+	return aliases, nil
+}
+
+// Candidates implements Store.
+func (d DirStore) Candidates(ids map[string]string) ([]string, error) {
+	if err := d.index(); err != nil {
+		return nil, err
+	}
+	var out []string
+	for k, v := range ids {
+		hosts, err := d.indexed(k, v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, hosts...)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// indexDir is the candidate index below Dir.
+const indexDir = ".index"
+
+func (d DirStore) indexFile(component, digest string) string {
+	return filepath.Join(d.Dir, indexDir, component, digest)
+}
+
+// indexed lists the hosts of an index file.
+func (d DirStore) indexed(component, digest string) ([]string, error) {
+	b, err := os.ReadFile(d.indexFile(component, digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(b)), nil
+}
+
+// reindex moves host from the index entries of old to those of ids.
+func (d DirStore) reindex(host string, old, ids map[string]string) error {
+	for k, v := range old {
+		if ids[k] != v {
+			if err := d.updateIndex(k, v, func(hosts []string) []string {
+				return slices.DeleteFunc(hosts, func(h string) bool { return h == host })
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range ids {
+		if old[k] != v {
+			if err := d.updateIndex(k, v, func(hosts []string) []string {
+				if slices.Contains(hosts, host) {
+					return hosts
+				}
+				return append(hosts, host)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d DirStore) updateIndex(component, digest string, edit func([]string) []string) error {
+	if !validIndexName(component) || !validIndexName(digest) {
+		return nil
+	}
+	hosts, err := d.indexed(component, digest)
+	if err != nil {
+		return err
+	}
+	hosts = edit(hosts)
+	name := d.indexFile(component, digest)
+	if len(hosts) == 0 {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+	return writeAtomic(name, []byte(strings.Join(hosts, "\n")+"\n"))
+}
+
+func validIndexName(s string) bool {
+	return s != "" && !strings.HasPrefix(s, ".") && !strings.ContainsAny(s, `/\`)
+}
+
+// index builds the candidate index from the latest record of every host
+// that is not an alias, unless it exists.
+func (d DirStore) index() error {
+	if _, err := os.Stat(filepath.Join(d.Dir, indexDir)); !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	hosts, err := d.Hosts()
+	if err != nil {
+		return err
+	}
+	aliases, err := d.Aliases()
+	if err != nil {
+		return err
+	}
+	// Built aside and renamed into place, so that an interrupted build
+	// starts over.
+	tmp := DirStore{Dir: filepath.Join(d.Dir, ".index-build")}
+	if err := os.RemoveAll(tmp.Dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(tmp.Dir, indexDir), 0o750); err != nil {
+		return err
+	}
+	for _, h := range hosts {
+		if _, ok := aliases[h]; ok {
+			continue
+		}
+		if last, err := d.Latest(h); err == nil {
+			if err := tmp.reindex(h, nil, indexKeys(last.Snapshot)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(filepath.Join(tmp.Dir, indexDir), filepath.Join(d.Dir, indexDir)); err != nil {
+		return err
+	}
+	return os.RemoveAll(tmp.Dir)
+}
+
+// Prune implements Store.
+func (d DirStore) Prune(host string, keep int) error {
+	files, err := d.files(host)
+	if err != nil || len(files) <= keep {
+		return err
+	}
+	for _, f := range files[:len(files)-keep] {
+		if err := os.Remove(filepath.Join(d.Dir, host, f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Audit implements Store.
+func (d DirStore) Audit(dec Decision) error {
+	b, err := json.Marshal(dec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.Dir, 0o750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(d.Dir, "decisions.jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Decisions implements Store.
+func (d DirStore) Decisions() ([]Decision, error) {
+	b, err := os.ReadFile(filepath.Join(d.Dir, "decisions.jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Decision
+	for _, ln := range strings.Split(string(b), "\n") {
+		var dec Decision
+		if ln != "" && json.Unmarshal([]byte(ln), &dec) == nil {
+			out = append(out, dec)
+		}
+	}
+	return out, nil
+}
+
+// validHost rejects host IDs that are not a single path element, and those
+// starting with "." that Hosts would hide and that could name .index.
+func validHost(h string) error {
+	if h == "" || strings.HasPrefix(h, ".") || strings.ContainsAny(h, `/\`) {
+		return fmt.Errorf("fleet: invalid host ID %q", h)
+	}
+	return nil
+}
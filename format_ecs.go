@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"runtime"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// ecsVersion is the Elastic Common Schema release the ecs format follows.
+const ecsVersion = "8.11.0"
+
+// ecsDocument is a hostInfo ready to be indexed as an ECS event: a host
+// state event observed by the agent. With -host-root the observer is the
+// agent's container rather than the host described.
+type ecsDocument struct {
+	hostInfo
+	ECS      ecsMeta     `json:"ecs"`
+	Event    ecsEvent    `json:"event"`
+	Observer ecsObserver `json:"observer"`
+}
+
+type ecsMeta struct {
+	Version string `json:"version"`
+}
+
+type ecsEvent struct {
+	Kind     string   `json:"kind"`
+	Category []string `json:"category"`
+	Type     []string `json:"type"`
+	Module   string   `json:"module"`
+	Dataset  string   `json:"dataset"`
+	// Outcome is "failure" when collectors were skipped.
+	Outcome string `json:"outcome"`
+}
+
+type ecsObserver struct {
+	Type     string `json:"type"`
+	Product  string `json:"product"`
+	Version  string `json:"version,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	OS       ecsOS  `json:"os"`
+}
+
+func writeECS(w io.Writer, snap fingerprint.Snapshot) error {
+	doc := ecsDocument{
+		hostInfo: newHostInfo(snap),
+		ECS:      ecsMeta{Version: ecsVersion},
+		Event: ecsEvent{
+			Kind:     "state",
+			Category: []string{"host"},
+			Type:     []string{"info"},
+			Module:   "fingerprint",
+			Dataset:  "fingerprint.snapshot",
+			Outcome:  "success",
+		},
+		Observer: ecsObserver{
+			Type:    "sensor",
+			Product: "LinuxSystemFingerprint",
+			Version: agentVersion(snap),
+			OS:      ecsOS{Type: runtime.GOOS},
+		},
+	}
+	if partial(snap) != nil {
+		doc.Event.Outcome = "failure"
+	}
+	if runtime.GOOS == "darwin" {
+		doc.Observer.OS.Type = "macos"
+	}
+	doc.Observer.Hostname, _ = os.Hostname()
+	return json.NewEncoder(w).Encode(doc)
+}
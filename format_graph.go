@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// hwGraph is the hardware of a snapshot as a directed graph from the host
+// to what is built on it: host → disks → partitions → stacked devices →
+// file systems, and host → NICs → bonds → bridges.
+type hwGraph struct {
+	nodes []graphNode
+	edges [][2]string
+}
+
+type graphNode struct {
+	id, label string
+	// kind is "host", "disk", "partition", "dm", "md", "loop",
+	// "filesystem", "nic" or the kernel device type of virtual
+	// interfaces, e.g. "bond".
+	kind string
+}
+
+func newHWGraph(snap fingerprint.Snapshot) hwGraph {
+	var g hwGraph
+	host := snap.Hostname
+	if host == "" {
+		host = snap.ID()
+	}
+	g.nodes = append(g.nodes, graphNode{"host", host, "host"})
+
+	stacked := map[string]bool{}
+	for _, d := range snap.BlockDevices {
+		for _, h := range d.Holders {
+			stacked[h] = true
+		}
+	}
+	for _, d := range snap.BlockDevices {
+		id := "block:" + d.Name
+		label := d.Name
+		if d.DMName != "" {
+			label += " (" + d.DMName + ")"
+		}
+		if d.Model != "" {
+			label += "\n" + d.Model
+		}
+		label += "\n" + sizeLabel(d.SizeBytes)
+		g.nodes = append(g.nodes, graphNode{id, label, d.Kind})
+		switch {
+		case d.Parent != "":
+			g.edges = append(g.edges, [2]string{"block:" + d.Parent, id})
+		case !stacked[d.Name]:
+			g.edges = append(g.edges, [2]string{"host", id})
+		}
+		for _, h := range d.Holders {
+			g.edges = append(g.edges, [2]string{id, "block:" + h})
+		}
+		if d.Mountpoint != "" {
+			fs := "fs:" + d.Mountpoint
+			g.nodes = append(g.nodes, graphNode{fs, d.Mountpoint + "\n" + d.FSType, "filesystem"})
+			g.edges = append(g.edges, [2]string{id, fs})
+		}
+	}
+
+	masters := map[string]bool{}
+	for _, n := range snap.Network {
+		masters[n.Master] = true
+	}
+	for _, n := range snap.Network {
+		id := "net:" + n.Name
+		kind := n.Kind
+		if kind == "" {
+			kind = "nic"
+		}
+		g.nodes = append(g.nodes, graphNode{id, n.Name + "\n" + n.MAC, kind})
+		if !masters[n.Name] {
+			g.edges = append(g.edges, [2]string{"host", id})
+		}
+		if n.Master != "" {
+			g.edges = append(g.edges, [2]string{id, "net:" + n.Master})
+		}
+	}
+	return g
+}
+
+// sizeLabel renders a byte count in decimal units, as disks are sold.
+func sizeLabel(b uint64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	v, i := float64(b), 0
+	for v >= 1000 && i < len(units)-1 {
+		v, i = v/1000, i+1
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + " " + units[i]
+}
+
+// dotShapes are the Graphviz node shapes by kind.
+var dotShapes = map[string]string{
+	"host": "box3d", "disk": "cylinder", "partition": "box", "dm": "box", "md": "box",
+	"loop": "box", "filesystem": "folder", "nic": "rarrow", "bond": "octagon", "bridge": "octagon",
+}
+
+func writeDOT(w io.Writer, snap fingerprint.Snapshot) error {
+	g := newHWGraph(snap)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph fingerprint {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	for _, n := range g.nodes {
+		shape := dotShapes[n.kind]
+		if shape == "" {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s, shape=%s];\n", dotQuote(n.id), dotQuote(n.label), shape)
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "\t%s -> %s;\n", dotQuote(e[0]), dotQuote(e[1]))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote writes s as a DOT string, with newlines as line breaks.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+func writeGraphML(w io.Writer, snap fingerprint.Snapshot) error {
+	g := newHWGraph(snap)
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "fingerprint", EdgeDefault: "directed"},
+	}
+	for _, n := range g.nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.id, Data: []graphMLData{{"label", n.label}, {"kind", n.kind}}})
+	}
+	for _, e := range g.edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{e[0], e[1]})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
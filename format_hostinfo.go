@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// hostInfo is a snapshot in Elastic Common Schema field names, which
+// Windows inventory pipelines (Winlogbeat, Elastic Agent, WMI exporters
+// mapped to ECS) also emit, so documents of mixed fleets merge without
+// per-OS mapping. Fields ECS has no place for go in the fingerprint
+// namespace.
+type hostInfo struct {
+	Timestamp   time.Time         `json:"@timestamp"`
+	Host        ecsHost           `json:"host"`
+	Device      *ecsDevice        `json:"device,omitempty"`
+	Cloud       *ecsCloud         `json:"cloud,omitempty"`
+	Agent       ecsAgent          `json:"agent"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Fingerprint ecsFingerprint    `json:"fingerprint"`
+}
+
+type ecsHost struct {
+	// ID is the machine ID, as Elastic Agent reports on Linux; Windows
+	// agents report the MachineGuid.
+	ID           string   `json:"id,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Hostname     string   `json:"hostname,omitempty"`
+	Architecture string   `json:"architecture,omitempty"`
+	MAC          []string `json:"mac,omitempty"`
+	OS           ecsOS    `json:"os"`
+}
+
+type ecsOS struct {
+	Type    string `json:"type"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Kernel  string `json:"kernel,omitempty"`
+}
+
+// ecsDevice is the hardware, from DMI; Win32_ComputerSystemProduct and
+// Win32_BIOS provide the same values on Windows.
+type ecsDevice struct {
+	ID           string    `json:"id,omitempty"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Model        *ecsModel `json:"model,omitempty"`
+	SerialNumber string    `json:"serial_number,omitempty"`
+}
+
+type ecsModel struct {
+	Identifier string `json:"identifier,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+type ecsCloud struct {
+	Provider         string       `json:"provider,omitempty"`
+	Region           string       `json:"region,omitempty"`
+	AvailabilityZone string       `json:"availability_zone,omitempty"`
+	Instance         *ecsInstance `json:"instance,omitempty"`
+}
+
+type ecsInstance struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type ecsAgent struct {
+	Type    string `json:"type"`
+	Version string `json:"version,omitempty"`
+}
+
+type ecsFingerprint struct {
+	Hash          string `json:"hash"`
+	ID            string `json:"id"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+// ecsArchitectures maps GOARCH to the uname machine names ECS uses.
+var ecsArchitectures = map[string]string{
+	"amd64": "x86_64", "arm64": "aarch64", "386": "i686", "arm": "armv7l",
+	"ppc64le": "ppc64le", "s390x": "s390x", "riscv64": "riscv64",
+}
+
+// ecsProviders maps cloud-init cloud names to ECS cloud.provider values.
+var ecsProviders = map[string]string{"gce": "gcp"}
+
+func writeHostInfo(w io.Writer, snap fingerprint.Snapshot) error {
+	return json.NewEncoder(w).Encode(newHostInfo(snap))
+}
+
+func newHostInfo(snap fingerprint.Snapshot) hostInfo {
+	doc := hostInfo{
+		Timestamp: time.Now().UTC(),
+		Host: ecsHost{
+			ID:           snap.MachineID,
+			Name:         snap.Hostname,
+			Hostname:     snap.Hostname,
+			Architecture: snap.Agent.GOARCH,
+			OS: ecsOS{
+				Type:    "linux",
+				Name:    snap.OS.Name,
+				Version: snap.OS.Version,
+				Kernel:  snap.OS.KernelRel,
+			},
+		},
+		Agent:  ecsAgent{Type: "fingerprint"},
+		Labels: snap.Labels,
+		Fingerprint: ecsFingerprint{
+			Hash:          snap.Hash(),
+			ID:            snap.ID(),
+			SchemaVersion: snap.SchemaVersion,
+		},
+	}
+	if a, ok := ecsArchitectures[snap.Agent.GOARCH]; ok {
+		doc.Host.Architecture = a
+	}
+	for _, n := range snap.Network {
+		if m := ecsMAC(n.MAC); m != "" {
+			doc.Host.MAC = append(doc.Host.MAC, m)
+		}
+	}
+	d := snap.DMI
+	if d.ProductUUID != "" || d.SysVendor != "" || d.ProductName != "" || d.ProductSerial != "" {
+		doc.Device = &ecsDevice{ID: d.ProductUUID, Manufacturer: d.SysVendor, SerialNumber: d.ProductSerial}
+		if d.ProductName != "" || d.ProductFamily != "" {
+			doc.Device.Model = &ecsModel{Identifier: d.ProductName, Name: d.ProductFamily}
+		}
+	}
+	if c := snap.Cloud; c != nil {
+		doc.Cloud = &ecsCloud{Provider: c.CloudName, Region: c.Region, AvailabilityZone: c.AvailabilityZone}
+		if p, ok := ecsProviders[c.CloudName]; ok {
+			doc.Cloud.Provider = p
+		}
+		if c.InstanceID != "" || c.LocalHostname != "" {
+			doc.Cloud.Instance = &ecsInstance{ID: c.InstanceID, Name: c.LocalHostname}
+		}
+	}
+	doc.Agent.Version = agentVersion(snap)
+	return doc
+}
+
+// agentVersion is the module version of the agent build, when recorded.
+func agentVersion(snap fingerprint.Snapshot) string {
+	if m := snap.Meta; m != nil && m.Build != nil {
+		return m.Build.Main.Version
+	}
+	return ""
+}
+
+// ecsMAC writes a MAC address the ECS way, upper case and separated by
+// hyphens as Windows does; "" for the all-zero address of loopback.
+func ecsMAC(mac string) string {
+	if mac == "" || strings.Trim(mac, "0:") == "" {
+		return ""
+	}
+	return strings.ToUpper(strings.ReplaceAll(mac, ":", "-"))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// writeTerraformExternal implements the Terraform "external" data source
+// protocol: a JSON object of strings is read from stdin and a flat JSON
+// object of strings is written to stdout. The optional query key "fields"
+// restricts the result to a comma separated list of flattened keys.
+func writeTerraformExternal(stdin *os.File, w io.Writer, snap fingerprint.Snapshot) error {
+	query := map[string]string{}
+	if fi, err := stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice == 0 {
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(b))) > 0 {
+			if err := json.Unmarshal(b, &query); err != nil {
+				return fmt.Errorf("terraform query: %w", err)
+			}
+		}
+	}
+	flat := snap.Flatten()
+	if fields := strings.TrimSpace(query["fields"]); fields != "" {
+		sel := map[string]string{}
+		for _, f := range strings.Split(fields, ",") {
+			f = strings.TrimSpace(f)
+			if v, ok := flat[f]; ok {
+				sel[f] = v
+			}
+		}
+		flat = sel
+	}
+	return json.NewEncoder(w).Encode(flat)
+}
@@ -1,3 +1,11 @@
 module AurFingerprintAgent
 
 go 1.24.1
+
+require (
+	github.com/google/go-tpm v0.9.8
+	github.com/klauspost/compress v1.18.5
+	github.com/miekg/pkcs11 v1.1.1
+	golang.org/x/sys v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
+)
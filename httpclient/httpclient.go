@@ -0,0 +1,155 @@
+// Package httpclient provides the HTTP clients the agent shares between its
+// Docker probe, snapshot pushes and enrollment, so that a long-running agent
+// reuses connections instead of opening and abandoning a transport per
+// request. Connections are dialed through fdcap.Default.
+//
+// Shared serves HTTP and HTTPS URLs, honoring the configured proxy. Unix
+// serves a daemon socket; its clients are cached per socket path. Both
+// follow the Config last passed to Configure.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// Direct, as Config.Proxy, disables proxying.
+const Direct = "direct"
+
+// Config tunes the clients.
+type Config struct {
+	// Timeout bounds a whole request including reading the body; none when
+	// zero. Contexts can set shorter bounds per request.
+	Timeout time.Duration
+	// DialTimeout bounds establishing a connection.
+	DialTimeout time.Duration
+	// IdleConnTimeout closes connections idle for longer.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is how many idle connections are kept per host
+	// or socket; each holds a descriptor.
+	MaxIdleConnsPerHost int
+	// Proxy is the proxy URL for HTTP and HTTPS requests. When empty the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables apply;
+	// Direct disables proxying. Unix sockets are never proxied.
+	Proxy string
+}
+
+// DefaultConfig returns the configuration in effect until Configure is
+// called.
+func DefaultConfig() Config {
+	return Config{
+		Timeout:             30 * time.Second,
+		DialTimeout:         10 * time.Second,
+		IdleConnTimeout:     30 * time.Second,
+		MaxIdleConnsPerHost: 2,
+	}
+}
+
+var (
+	mu     sync.Mutex
+	cfg    = DefaultConfig()
+	shared *http.Client
+	unix   = map[string]*http.Client{}
+)
+
+// Configure replaces the configuration of the shared clients. Clients
+// handed out earlier keep their settings; their idle connections are
+// closed.
+func Configure(c Config) error {
+	if _, err := proxyFunc(c.Proxy); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if shared != nil {
+		shared.CloseIdleConnections()
+	}
+	for _, u := range unix {
+		u.CloseIdleConnections()
+	}
+	cfg, shared, unix = c, nil, map[string]*http.Client{}
+	return nil
+}
+
+// Shared returns the client for HTTP and HTTPS URLs.
+func Shared() *http.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	if shared == nil {
+		// Configure validated the proxy.
+		shared, _ = New(cfg)
+	}
+	return shared
+}
+
+// Unix returns the client for the server listening on the unix socket at
+// path. Requests may use any host in their URL, e.g. "http://docker/info".
+func Unix(path string) *http.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	c := unix[path]
+	if c == nil {
+		c = newClient(cfg, func(ctx context.Context, d *net.Dialer, _, _ string) (net.Conn, error) {
+			return fdcap.Default.DialContext(ctx, d, "unix", path)
+		}, nil)
+		unix[path] = c
+	}
+	return c
+}
+
+// New returns an HTTP and HTTPS client configured by c, independent of the
+// shared one.
+func New(c Config) (*http.Client, error) {
+	proxy, err := proxyFunc(c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(c, func(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+		return fdcap.Default.DialContext(ctx, d, network, addr)
+	}, proxy), nil
+}
+
+type dialFunc func(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error)
+
+func newClient(c Config, dial dialFunc, proxy func(*http.Request) (*url.URL, error)) *http.Client {
+	d := &net.Dialer{Timeout: c.DialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: c.Timeout,
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dial(ctx, d, network, addr)
+			},
+			ForceAttemptHTTP2:     true,
+			MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+			IdleConnTimeout:       c.IdleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+func proxyFunc(p string) (func(*http.Request) (*url.URL, error), error) {
+	switch p {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case Direct:
+		return nil, nil
+	}
+	u, err := url.Parse(p)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: proxy: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("httpclient: proxy must be an absolute URL, e.g. http://proxy:3128")
+	}
+	return http.ProxyURL(u), nil
+}
@@ -0,0 +1,93 @@
+// Package httpenc implements the content encodings used for snapshot
+// transfer: gzip and zstd, negotiated through Accept-Encoding.
+package httpenc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported content codings in order of preference.
+const (
+	Zstd     = "zstd"
+	Gzip     = "gzip"
+	Identity = ""
+)
+
+// Encode compresses b using the named coding. Identity returns b unchanged.
+func Encode(coding string, b []byte) ([]byte, error) {
+	switch coding {
+	case Identity:
+		return b, nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer enc.Close()
+		return enc.EncodeAll(b, nil), nil
+	}
+	return nil, fmt.Errorf("httpenc: unsupported content coding %q", coding)
+}
+
+// NewReader returns a reader decoding r according to a Content-Encoding
+// header value.
+func NewReader(coding string, r io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case Gzip, "x-gzip":
+		return gzip.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("httpenc: unsupported content coding %q", coding)
+}
+
+// Negotiate picks the preferred supported coding acceptable according to an
+// Accept-Encoding header, or Identity when none is.
+func Negotiate(accept string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[name] = weight
+	}
+	for _, c := range []string{Zstd, Gzip} {
+		w, ok := q[c]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > 0 {
+			return c
+		}
+	}
+	return Identity
+}
@@ -0,0 +1,7 @@
+// Package integration holds end-to-end tests that run the collectors
+// against real kernel objects: loop devices with known file system UUIDs,
+// veth interfaces with set MACs, a network namespace and a fake Docker
+// socket. They are built with the integration tag, need root, losetup,
+// mkfs.ext4 and ip, and change the host while they run; use
+// "make integration".
+package integration
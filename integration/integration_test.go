@@ -0,0 +1,195 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// need skips t unless it runs as root with the given tools.
+func need(t *testing.T, tools ...string) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("needs root")
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("needs %s", tool)
+		}
+	}
+}
+
+func run(t *testing.T, name string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s", name, strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func write(t *testing.T, root, name, content string) {
+	t.Helper()
+	p := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, root, name, target string) {
+	t.Helper()
+	p := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loopDevice formats an image with uuid and attaches it, returning the
+// device path.
+func loopDevice(t *testing.T, uuid string) string {
+	t.Helper()
+	img := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(img, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(img, 16<<20); err != nil {
+		t.Fatal(err)
+	}
+	run(t, "mkfs.ext4", "-q", "-F", "-U", uuid, img)
+	dev := run(t, "losetup", "--find", "--show", img)
+	t.Cleanup(func() { exec.Command("losetup", "-d", dev).Run() })
+	return dev
+}
+
+func TestRootFSUUID(t *testing.T) {
+	need(t, "losetup", "mkfs.ext4", "blkid")
+	// The links carry a UUID other than the file system's, so that a
+	// match found through blkid instead of the links shows.
+	const uuid = "4c53462d-6974-4000-8000-000000000001"
+	const linked = "4c53462d-6974-4000-8000-0000000000aa"
+	dev := loopDevice(t, uuid)
+	name := filepath.Base(dev)
+	mountinfo := func(source string) string {
+		return "26 1 7:0 / / rw,relatime shared:1 - ext4 " + source + " rw\n"
+	}
+	for _, tc := range []struct {
+		name  string
+		setup func(t *testing.T, root string) string
+		want  string
+	}{
+		{"by-uuid link", func(t *testing.T, root string) string {
+			symlink(t, root, "dev/disk/by-uuid/00000000-0000-4000-8000-000000000000", "../../sda1")
+			symlink(t, root, "dev/disk/by-uuid/"+linked, "../../"+name)
+			return dev
+		}, linked},
+		{"source is a link", func(t *testing.T, root string) string {
+			symlink(t, root, "dev/root", name)
+			symlink(t, root, "dev/disk/by-uuid/"+linked, "../../"+name)
+			return "/dev/root"
+		}, linked},
+		{"link chain", func(t *testing.T, root string) string {
+			symlink(t, root, "dev/mapper/vg-root", "../dm-0")
+			symlink(t, root, "dev/dm-0", "/dev/"+name)
+			symlink(t, root, "dev/disk/by-uuid/"+linked, "../../mapper/vg-root")
+			return "/dev/mapper/vg-root"
+		}, linked},
+		{"blkid fallback", func(t *testing.T, root string) string {
+			return dev
+		}, uuid},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			symlink(t, root, "dev/"+name, dev)
+			source := tc.setup(t, root)
+			write(t, root, "proc/self/mountinfo", mountinfo(source))
+			snap := fingerprint.GetSnapshot(fingerprint.WithRootPrefix(root), fingerprint.WithCollectors("rootfs"))
+			if snap.RootFS.Source != source || snap.RootFS.Fstype != "ext4" || snap.RootFS.UUID != tc.want {
+				t.Errorf("rootfs = %+v, want %s ext4 %s", snap.RootFS, source, tc.want)
+			}
+		})
+	}
+}
+
+func TestNetworkVeth(t *testing.T) {
+	need(t, "ip")
+	const a, b = "lsfita", "lsfitb"
+	const macA, macB = "02:4c:53:46:00:01", "02:4c:53:46:00:02"
+	run(t, "ip", "link", "add", a, "address", macA, "type", "veth", "peer", "name", b, "address", macB)
+	t.Cleanup(func() { exec.Command("ip", "link", "del", a).Run() })
+
+	snap := fingerprint.GetSnapshot(fingerprint.WithCollectors("network"))
+	for name, mac := range map[string]string{a: macA, b: macB} {
+		if !slices.Contains(snap.Network, fingerprint.NetIf{Name: name, MAC: mac}) {
+			t.Errorf("network lacks %s %s: %+v", name, mac, snap.Network)
+		}
+	}
+	snap = fingerprint.GetSnapshot(fingerprint.WithCollectors("network"), fingerprint.WithInterfaceExclude("lsfit*"))
+	for _, n := range snap.Network {
+		if strings.HasPrefix(n.Name, "lsfit") {
+			t.Errorf("excluded interface %s reported", n.Name)
+		}
+	}
+}
+
+func TestNetNamespace(t *testing.T) {
+	need(t, "ip")
+	const ns = "lsfit"
+	run(t, "ip", "netns", "add", ns)
+	t.Cleanup(func() { exec.Command("ip", "netns", "del", ns).Run() })
+	run(t, "ip", "-n", ns, "link", "add", "lsfitc", "type", "veth", "peer", "name", "lsfitd")
+
+	snap := fingerprint.GetSnapshot(fingerprint.WithNetNamespaces(), fingerprint.WithCollectors("netns"))
+	for _, n := range snap.NetNamespaces {
+		if slices.Contains(n.Names, ns) {
+			if n.Interfaces == nil || *n.Interfaces != 2 {
+				t.Errorf("namespace %s interfaces = %v, want 2", ns, n.Interfaces)
+			}
+			return
+		}
+	}
+	t.Errorf("namespace %s not listed: %+v", ns, snap.NetNamespaces)
+}
+
+func TestDockerSocket(t *testing.T) {
+	need(t)
+	const id = "LSFI:TEST:AAAA:BBBB:CCCC:DDDD:EEEE:FFFF:0000:1111:2222:3333"
+	root := t.TempDir()
+	sock := filepath.Join(root, "var/run/docker.sock")
+	if err := os.MkdirAll(filepath.Dir(sock), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/info") {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	snap := fingerprint.GetSnapshot(fingerprint.WithRootPrefix(root), fingerprint.WithCollectors("docker"))
+	if snap.Docker.DaemonID != id {
+		t.Errorf("docker daemon id = %q, want %q", snap.Docker.DaemonID, id)
+	}
+}
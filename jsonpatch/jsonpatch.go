@@ -0,0 +1,193 @@
+// Package jsonpatch computes and applies RFC 6902 JSON Patch documents for
+// the subset of operations needed to transfer snapshot deltas: add, remove
+// and replace.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single JSON Patch operation.
+type Operation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// MarshalJSON keeps explicit null, false and zero values for add and
+// replace operations, which omitempty would otherwise drop.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+	return json.Marshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+// Diff returns the operations transforming JSON document a into b.
+func Diff(a, b []byte) ([]Operation, error) {
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return nil, err
+	}
+	var ops []Operation
+	diff("", va, vb, &ops)
+	return ops, nil
+}
+
+func diff(path string, a, b any, ops *[]Operation) {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(av) {
+			if _, ok := bv[k]; !ok {
+				*ops = append(*ops, Operation{Op: "remove", Path: path + "/" + escape(k)})
+			}
+		}
+		for _, k := range sortedKeys(bv) {
+			p := path + "/" + escape(k)
+			if old, ok := av[k]; ok {
+				diff(p, old, bv[k], ops)
+			} else {
+				*ops = append(*ops, Operation{Op: "add", Path: p, Value: bv[k]})
+			}
+		}
+		return
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			break
+		}
+		for i := range av {
+			diff(path+"/"+strconv.Itoa(i), av[i], bv[i], ops)
+		}
+		return
+	default:
+		if equalScalar(a, b) {
+			return
+		}
+	}
+	*ops = append(*ops, Operation{Op: "replace", Path: path, Value: b})
+}
+
+func equalScalar(a, b any) bool {
+	switch a.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	switch b.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	return a == b
+}
+
+// Apply applies ops to the JSON document doc and returns the result.
+func Apply(doc []byte, ops []Operation) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		var err error
+		if v, err = apply(v, op); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(v)
+}
+
+func apply(root any, op Operation) (any, error) {
+	switch op.Op {
+	case "add", "remove", "replace":
+	default:
+		return nil, fmt.Errorf("jsonpatch: unsupported op %q", op.Op)
+	}
+	if op.Path == "" {
+		if op.Op == "remove" {
+			return nil, nil
+		}
+		return op.Value, nil
+	}
+	if !strings.HasPrefix(op.Path, "/") {
+		return nil, fmt.Errorf("jsonpatch: path %q does not start with /", op.Path)
+	}
+	tokens := strings.Split(op.Path, "/")[1:]
+	parent := root
+	for _, t := range tokens[:len(tokens)-1] {
+		next, err := child(parent, unescape(t))
+		if err != nil {
+			return nil, fmt.Errorf("jsonpatch: %s: %w", op.Path, err)
+		}
+		parent = next
+	}
+	last := unescape(tokens[len(tokens)-1])
+	switch p := parent.(type) {
+	case map[string]any:
+		// Replacing or removing a member requires it to exist.
+		if _, ok := p[last]; !ok && op.Op != "add" {
+			return nil, fmt.Errorf("jsonpatch: %s: path token %q not found", op.Path, last)
+		}
+		if op.Op == "remove" {
+			delete(p, last)
+		} else {
+			p[last] = op.Value
+		}
+		return root, nil
+	case []any:
+		i, err := strconv.Atoi(last)
+		if err != nil || i < 0 || i >= len(p) || op.Op != "replace" {
+			return nil, fmt.Errorf("jsonpatch: %s: unsupported array operation", op.Path)
+		}
+		p[i] = op.Value
+		return root, nil
+	}
+	return nil, fmt.Errorf("jsonpatch: %s: parent is not a container", op.Path)
+}
+
+func child(v any, tok string) (any, error) {
+	switch c := v.(type) {
+	case map[string]any:
+		if n, ok := c[tok]; ok {
+			return n, nil
+		}
+	case []any:
+		if i, err := strconv.Atoi(tok); err == nil && i >= 0 && i < len(c) {
+			return c[i], nil
+		}
+	}
+	return nil, fmt.Errorf("path token %q not found", tok)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func unescape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,110 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffApply(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{`{"a":1,"b":{"c":"x","d":[1,2]}}`, `{"a":2,"b":{"c":"x","d":[1,3]},"e":null}`},
+		{`{"a/b":1,"m~n":2}`, `{"a/b":false,"m~n":{}}`},
+		{`{"list":[1,2]}`, `{"list":[1,2,3]}`},
+		{`{"gone":{"x":1},"kept":0}`, `{"kept":0}`},
+		{`{"a":{"b":1}}`, `{"a":"flat"}`},
+		{`[1]`, `{"a":1}`},
+		{`"x"`, `"x"`},
+	}
+	for _, tt := range tests {
+		ops, err := Diff([]byte(tt.a), []byte(tt.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Operations travel as JSON.
+		b, err := json.Marshal(ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops = nil
+		if err := json.Unmarshal(b, &ops); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Apply([]byte(tt.a), ops)
+		if err != nil {
+			t.Fatalf("Apply(%s, %s): %v", tt.a, b, err)
+		}
+		if !sameJSON(t, got, []byte(tt.b)) {
+			t.Errorf("Apply(%s, %s) = %s, want %s", tt.a, b, got, tt.b)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	const doc = `{"a":{"b":1},"l":[1,2]}`
+	for _, ops := range []string{
+		`[{"op":"add","path":"x","value":1}]`,
+		`[{"op":"remove","path":"a"}]`,
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"remove","path":"/a/missing"}]`,
+		`[{"op":"add","path":"/missing/b","value":1}]`,
+		`[{"op":"add","path":"/l/2","value":3}]`,
+		`[{"op":"replace","path":"/l/-1","value":3}]`,
+		`[{"op":"move","path":"/a"}]`,
+		`[{"op":"copy","path":""}]`,
+		`[{"op":"add","path":"/a/b/c","value":1}]`,
+	} {
+		var o []Operation
+		if err := json.Unmarshal([]byte(ops), &o); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Apply([]byte(doc), o); err == nil {
+			t.Errorf("Apply(%s) = %s, want an error", ops, got)
+		}
+	}
+}
+
+func FuzzApply(f *testing.F) {
+	f.Add(`{"a":{"b":[1,2]}}`, `[{"op":"replace","path":"/a/b/0","value":3}]`)
+	f.Add(`{"a":1}`, `[{"op":"add","path":"x","value":1}]`)
+	f.Add(`{"a":1}`, `[{"op":"remove","path":"/"},{"op":"add","path":"","value":[]}]`)
+	f.Add(`[]`, `[{"op":"remove","path":"/~1/~0"}]`)
+	f.Fuzz(func(t *testing.T, doc, patch string) {
+		var ops []Operation
+		if json.Unmarshal([]byte(patch), &ops) != nil {
+			return
+		}
+		Apply([]byte(doc), ops)
+	})
+}
+
+func FuzzDiff(f *testing.F) {
+	f.Add(`{"a":1,"b":[1,2]}`, `{"a":"1","b":[1],"c":{"d":null}}`)
+	f.Add(`{"":{"~/":0}}`, `{"":{"~/":1}}`)
+	f.Add(`null`, `{}`)
+	f.Fuzz(func(t *testing.T, a, b string) {
+		ops, err := Diff([]byte(a), []byte(b))
+		if err != nil {
+			return
+		}
+		got, err := Apply([]byte(a), ops)
+		if err != nil {
+			t.Fatalf("Apply(%s, Diff(%s, %s)): %v", a, a, b, err)
+		}
+		if !sameJSON(t, got, []byte(b)) {
+			t.Fatalf("Apply(%s, Diff(%s, %s)) = %s", a, a, b, got)
+		}
+	})
+}
+
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,144 @@
+// Package license issues and validates signed license files bound to the
+// machine fingerprint.
+//
+// A license is a PEM block of type "LINUXSYSTEMFINGERPRINT LICENSE" whose
+// body is the JSON encoded License and whose "Signature" header carries the
+// base64 Ed25519 signature over that body. The license stores component
+// digests rather than raw values, but they are unsalted SHA-256 digests:
+// whoever holds the file can confirm a guessed serial or MAC, and recover
+// short or structured ones by enumeration. Treat license files as being as
+// sensitive as the identifiers they are bound to.
+package license
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+const pemType = "LINUXSYSTEMFINGERPRINT LICENSE"
+
+var (
+	// ErrSignature is returned for blobs that are malformed or not signed
+	// by the expected key.
+	ErrSignature = errors.New("license: invalid signature")
+	// ErrExpired is returned once ExpiresAt has passed.
+	ErrExpired = errors.New("license: expired")
+	// ErrNotYetValid is returned before NotBefore.
+	ErrNotYetValid = errors.New("license: not yet valid")
+)
+
+// MismatchError reports that the current machine differs from the licensed
+// one beyond the tolerance recorded in the license.
+type MismatchError struct {
+	Components []string
+}
+
+func (e *MismatchError) Error() string {
+	return "license: fingerprint mismatch in " + strings.Join(e.Components, ", ")
+}
+
+// Claims are the application-defined terms of a license.
+type Claims struct {
+	Licensee  string            `json:"licensee,omitempty"`
+	Product   string            `json:"product,omitempty"`
+	Features  []string          `json:"features,omitempty"`
+	NotBefore time.Time         `json:"not_before,omitzero"`
+	ExpiresAt time.Time         `json:"expires_at,omitzero"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// License is the signed content of a license file.
+type License struct {
+	Claims
+	IssuedAt    time.Time             `json:"issued_at"`
+	Fingerprint string                `json:"fingerprint"`
+	Components  map[string]string     `json:"components"`
+	Tolerance   fingerprint.Tolerance `json:"tolerance"`
+}
+
+// Issue produces a license blob for the machine described by snap.
+func Issue(priv ed25519.PrivateKey, snap fingerprint.Snapshot, claims Claims, tol fingerprint.Tolerance) ([]byte, error) {
+	lic := License{
+		Claims:      claims,
+		IssuedAt:    time.Now().UTC(),
+		Fingerprint: snap.Hash(),
+		Components:  snap.ComponentDigests(),
+		Tolerance:   tol,
+	}
+	body, err := json.MarshalIndent(lic, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sig := ed25519.Sign(priv, body)
+	return encode(body, sig), nil
+}
+
+// Parse verifies the signature of blob and returns the license without
+// checking validity dates or the fingerprint.
+func Parse(pub ed25519.PublicKey, blob []byte) (*License, error) {
+	body, sig, err := decode(blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, body, sig) {
+		return nil, ErrSignature
+	}
+	var lic License
+	if err := json.Unmarshal(body, &lic); err != nil {
+		return nil, fmt.Errorf("license: %w", err)
+	}
+	return &lic, nil
+}
+
+// Validate verifies blob, re-collects the fingerprint of the running machine
+// and checks it against the license using the license's tolerance policy.
+func Validate(pub ed25519.PublicKey, blob []byte) (*License, error) {
+	return ValidateSnapshot(pub, blob, fingerprint.GetSnapshot())
+}
+
+// ValidateSnapshot is like Validate but checks against the given snapshot.
+func ValidateSnapshot(pub ed25519.PublicKey, blob []byte, snap fingerprint.Snapshot) (*License, error) {
+	lic, err := Parse(pub, blob)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !lic.NotBefore.IsZero() && now.Before(lic.NotBefore) {
+		return lic, ErrNotYetValid
+	}
+	if !lic.ExpiresAt.IsZero() && now.After(lic.ExpiresAt) {
+		return lic, ErrExpired
+	}
+	if diff, ok := fingerprint.MatchDigests(lic.Components, snap.ComponentDigests(), lic.Tolerance); !ok {
+		return lic, &MismatchError{Components: diff}
+	}
+	return lic, nil
+}
+
+func encode(body, sig []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    pemType,
+		Headers: map[string]string{"Signature": base64.StdEncoding.EncodeToString(sig)},
+		Bytes:   body,
+	})
+}
+
+func decode(blob []byte) (body, sig []byte, err error) {
+	blk, _ := pem.Decode(blob)
+	if blk == nil || blk.Type != pemType {
+		return nil, nil, ErrSignature
+	}
+	sig, err = base64.StdEncoding.DecodeString(blk.Headers["Signature"])
+	if err != nil {
+		return nil, nil, ErrSignature
+	}
+	return blk.Bytes, sig, nil
+}
@@ -0,0 +1,88 @@
+package license
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func TestParse(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := fingerprint.Snapshot{MachineID: "4c4c4544004d3110"}
+	blob, err := Issue(priv, snap, Claims{Licensee: "ACME"}, fingerprint.Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateSnapshot(pub, blob, snap); err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	for name, key := range map[string]ed25519.PublicKey{"other": other, "short": pub[:16], "empty": nil} {
+		if _, err := Parse(key, blob); !errors.Is(err, ErrSignature) {
+			t.Errorf("%s key: Parse = %v, want ErrSignature", name, err)
+		}
+	}
+}
+
+func TestValidateSnapshotRejects(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := fingerprint.Snapshot{MachineID: "4c4c4544004d3110", CPU: fingerprint.CPUInfo{Model: "Xeon"}}
+	snap.DMI.BoardSerial = "PHKL812345AB"
+	snap.Memory.MemTotalKB = 16 << 20
+	upgraded := snap
+	upgraded.Memory.MemTotalKB = 32 << 20
+	rebuilt := upgraded
+	rebuilt.CPU.Model = "Xeon Gold"
+	reinstalled := snap
+	reinstalled.MachineID = "0123456789abcdef"
+	now := time.Now()
+	oneMismatch := fingerprint.Tolerance{MaxMismatches: 1, Required: []string{"machine_id"}}
+	for _, tc := range []struct {
+		name   string
+		claims Claims
+		tol    fingerprint.Tolerance
+		snap   fingerprint.Snapshot
+		err    error
+		diff   []string
+	}{
+		{"valid", Claims{NotBefore: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}, fingerprint.Tolerance{}, snap, nil, nil},
+		{"expired", Claims{ExpiresAt: now.Add(-time.Second)}, fingerprint.Tolerance{}, snap, ErrExpired, nil},
+		{"not yet valid", Claims{NotBefore: now.Add(time.Hour)}, fingerprint.Tolerance{}, snap, ErrNotYetValid, nil},
+		{"expired before the fingerprint is checked", Claims{ExpiresAt: now.Add(-time.Second)}, fingerprint.Tolerance{}, reinstalled, ErrExpired, nil},
+		{"strict mismatch", Claims{}, fingerprint.Tolerance{}, upgraded, nil, []string{"memory.mem_total_kb"}},
+		{"within tolerance", Claims{}, oneMismatch, upgraded, nil, nil},
+		{"beyond tolerance", Claims{}, oneMismatch, rebuilt, nil, []string{"cpu.model", "memory.mem_total_kb"}},
+		{"required component", Claims{}, oneMismatch, reinstalled, nil, []string{"machine_id"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			blob, err := Issue(priv, snap, tc.claims, tc.tol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lic, err := ValidateSnapshot(pub, blob, tc.snap)
+			var mm *MismatchError
+			switch {
+			case tc.diff != nil:
+				if !errors.As(err, &mm) || strings.Join(mm.Components, ",") != strings.Join(tc.diff, ",") {
+					t.Errorf("ValidateSnapshot = %v, want a mismatch in %v", err, tc.diff)
+				}
+			case !errors.Is(err, tc.err):
+				t.Errorf("ValidateSnapshot = %v, want %v", err, tc.err)
+			}
+			if lic == nil || lic.Fingerprint != snap.Hash() {
+				t.Errorf("ValidateSnapshot returned license %+v, want the parsed license", lic)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package localsock implements a minimal line-based query protocol over a
+// unix socket for local consumers that do not want to speak HTTP.
+//
+// Each request is a single line. Supported requests:
+//
+//	GET hash      -> hex fingerprint hash followed by "\n"
+//	GET snapshot  -> snapshot JSON on a single line followed by "\n"
+//
+// Unknown requests are answered with "ERR <reason>\n". A connection may carry
+// any number of requests and is closed by the client.
+package localsock
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+	"AurFingerprintAgent/fingerprint"
+)
+
+// DefaultPath is the conventional socket location.
+const DefaultPath = "/run/linuxsystemfingerprint.sock"
+
+const idleTimeout = 30 * time.Second
+
+// maxConns bounds concurrent clients; further clients wait in the listen
+// backlog. They get their own cap so they cannot starve the collectors of
+// fdcap.Default.
+const maxConns = 64
+
+// Mode is the permission of the socket: its owner and group may connect.
+const Mode = 0o660
+
+// Listen creates a unix socket at path, removing a stale socket left behind
+// by a previous run. The socket gets Mode and, unless gid is negative, the
+// group gid, so the consumers allowed to query it are those in the group.
+func Listen(path string, gid int) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, Mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if gid >= 0 {
+		if err := os.Chown(path, -1, gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Serve accepts connections on l until ctx is cancelled. snap is called for
+// every request, so it should be cheap, e.g. a fingerprint.CachedProvider
+// that collects at most once per interval however many clients connect.
+func Serve(ctx context.Context, l net.Listener, snap func() fingerprint.Snapshot) error {
+	l = fdcap.New(maxConns, 0).Listener(l)
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go handle(conn, snap)
+	}
+}
+
+func handle(conn net.Conn, snap func() fingerprint.Snapshot) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		conn.SetDeadline(time.Now().Add(idleTimeout))
+		ln, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(answer(strings.TrimSpace(ln), snap)); err != nil {
+			return
+		}
+	}
+}
+
+func answer(req string, snap func() fingerprint.Snapshot) []byte {
+	f := strings.Fields(req)
+	if len(f) != 2 || f[0] != "GET" {
+		return []byte("ERR malformed request\n")
+	}
+	switch f[1] {
+	case "hash":
+		return []byte(snap().Hash() + "\n")
+	case "snapshot":
+		b, err := json.Marshal(snap())
+		if err != nil {
+			return []byte("ERR " + err.Error() + "\n")
+		}
+		return append(b, '\n')
+	}
+	return []byte("ERR unknown resource\n")
+}
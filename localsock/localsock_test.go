@@ -0,0 +1,57 @@
+package localsock
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func TestServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lsf.sock")
+	l, err := Listen(path, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != Mode {
+		t.Errorf("socket mode = %v, %v, want %v", fi.Mode().Perm(), err, os.FileMode(Mode))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	snap := fingerprint.Snapshot{SchemaVersion: fingerprint.SchemaVersion, Hostname: "app-01"}
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, l, func() fingerprint.Snapshot { calls++; return snap })
+	}()
+
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for _, tc := range []struct{ req, want string }{
+		{"GET hash\n", snap.Hash() + "\n"},
+		{"GET nothing\n", "ERR unknown resource\n"},
+		{"PUT hash\n", "ERR malformed request\n"},
+	} {
+		if _, err := c.Write([]byte(tc.req)); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := r.ReadString('\n'); got != tc.want {
+			t.Errorf("%q = %q, %v, want %q", tc.req, got, err, tc.want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("snap called %d times, want 1", calls)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+}
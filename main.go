@@ -1,19 +1,353 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/httpclient"
+	"AurFingerprintAgent/signer"
+	"AurFingerprintAgent/tlog"
 )
 
+// commands maps subcommand names to their entry points. Running the binary
+// without a subcommand prints the snapshot as JSON.
+var commands = map[string]func(args []string) error{
+	"activation-code": runActivationCode,
+	"aggregate":       runAggregate,
+	"attest":          runAttest,
+	"collectors":      runCollectors,
+	"enroll":          runEnroll,
+	"export-bundle":   runExportBundle,
+	"fleetserver":     runFleetServer,
+	"get":             runGet,
+	"import-bundle":   runImportBundle,
+	"install":         runInstall,
+	"permissions":     runPermissions,
+	"provision":       runProvision,
+	"push":            runPush,
+	"record":          runRecord,
+	"replay":          runReplay,
+	"report":          runReport,
+	"sbom":            runSbom,
+	"serve":           runServe,
+	"snmp":            runSNMP,
+	"socket":          runSocket,
+	"token":           runToken,
+	"view":            runView,
+	"watch":           runWatch,
+}
+
 func main() {
-	snap := fingerprint.GetSnapshot()
-	b, err := json.Marshal(snap)
+	errFormat, args := cutErrorFormat(os.Args[1:])
+	if errFormat != "text" && errFormat != "json" {
+		exit("text", "main", fmt.Errorf("unknown error format %q", errFormat))
+	}
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			if err := cmd(args[1:]); err != nil {
+				exit(errFormat, args[0], err)
+			}
+			return
+		}
+	}
+	if err := runSnapshot(args); err != nil {
+		exit(errFormat, "snapshot", err)
+	}
+}
+
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	signKey := fs.String("sign-key", "", "sign the snapshot with a key file, tpm:// handle or pkcs11: URI")
+	format := fs.String("format", "json", "output format: json, fields (one JSON line per section as it is collected), hostinfo (Elastic Common Schema host document), ecs (the same as an indexable ECS event), dot or graphml (graph of disks and NICs) or terraform-external")
+	tlogURL := fs.String("tlog", "", "record the signed snapshot in this Rekor-compatible transparency log, e.g. "+tlog.DefaultURL)
+	stream := fs.Bool("stream", false, "write large sections while collecting them instead of buffering the snapshot")
+	opts := optionFlags(fs)
+	fs.Parse(args)
+
+	if *tlogURL != "" && (*format != "json" || *signKey == "") {
+		return errors.New("-tlog requires -sign-key and json output")
+	}
+	if *stream {
+		if *format != "json" || *signKey != "" {
+			return errors.New("-stream only supports unsigned json output")
+		}
+		return fingerprint.EncodeStream(os.Stdout, opts()...)
+	}
+	if *format == "fields" {
+		if *signKey != "" || *stream {
+			return errors.New("-format fields does not support -sign-key or -stream")
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for f := range fingerprint.CollectStream(context.Background(), opts()...) {
+			if err := enc.Encode(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	snap := fingerprint.GetSnapshot(opts()...)
+	switch *format {
+	case "json":
+	case "terraform-external":
+		return writeTerraformExternal(os.Stdin, os.Stdout, snap)
+	case "hostinfo", "ecs", "dot", "graphml":
+		write := map[string]func(io.Writer, fingerprint.Snapshot) error{
+			"hostinfo": writeHostInfo, "ecs": writeECS, "dot": writeDOT, "graphml": writeGraphML,
+		}[*format]
+		if err := write(os.Stdout, snap); err != nil {
+			return err
+		}
+		return partial(snap)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	var v any = snap
+	if *signKey != "" {
+		k, err := signer.Open(*signKey)
+		if err != nil {
+			return err
+		}
+		defer k.Close()
+		if *tlogURL != "" {
+			if err := logSnapshot(context.Background(), *tlogURL, k, &snap); err != nil {
+				return err
+			}
+		}
+		ss, err := fingerprint.Sign(k, snap)
+		if err != nil {
+			return err
+		}
+		v = ss
+	}
+	b, err := json.Marshal(v)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "snapshot error:", err)
-		os.Exit(1)
+		return err
+	}
+	fmt.Println(string(b))
+	return partial(snap)
+}
+
+// selfCheckKey is the base64 Ed25519 public key verifying the detached
+// signature of release binaries. Set it at build time with
+// -ldflags "-X main.selfCheckKey=<base64>".
+var selfCheckKey string
+
+func selfCheckPublicKey() ed25519.PublicKey {
+	if selfCheckKey == "" {
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(selfCheckKey)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		fmt.Fprintln(os.Stderr, "warning: embedded self-check key is invalid")
+		return nil
+	}
+	return b
+}
+
+// collectFlags registers the collection flags shared by the commands that
+// emit snapshots and returns a function collecting with the parsed options.
+func collectFlags(fs *flag.FlagSet) func() fingerprint.Snapshot {
+	opts := optionFlags(fs)
+	return func() fingerprint.Snapshot {
+		return fingerprint.GetSnapshot(opts()...)
+	}
+}
+
+// optionFlags registers the collection flags and returns a function turning
+// the parsed values into options.
+func optionFlags(fs *flag.FlagSet) func() []fingerprint.Option {
+	cloudInit := fs.Bool("cloud-init", false, "merge cloud-init instance data into the cloud section")
+	storage := fs.Bool("storage-health", false, "query NVMe/ATA SMART wear and health data (needs root)")
+	drives := fs.Bool("drive-identity", false, "ask SATA/USB disks for serial and firmware via ATA IDENTIFY or smartctl (needs root)")
+	netns := fs.Bool("netns", false, "list network namespaces with their interface counts")
+	neighbors := fs.Bool("neighbors", false, "sample the ARP table: gateway MACs and neighbor counts")
+	partTables := fs.Bool("partition-tables", false, "read partition tables udev has not probed off the disks themselves (needs root)")
+	udevRootUUID := fs.Bool("udev-rootfs-uuid", false, "fall back to the udev database for the root file system UUID (changes the hash where it was empty)")
+	packages := fs.Bool("packages", false, "list installed packages")
+	pci := fs.Bool("pci", false, "list PCI devices")
+	usb := fs.Bool("usb", false, "list USB devices")
+	provenance := fs.Bool("provenance", false, "record the source and stability of every field in a provenance section")
+	selfCheck := fs.Bool("self-check", false, "hash the agent executable and verify it, recording the result in meta")
+	selfDigest := fs.String("self-check-digest", "", "expected hex SHA-256 of the agent executable for -self-check")
+	hostRoot := fs.String("host-root", "", "fingerprint the host whose root file system is mounted here, e.g. /host (run the container with --pid=host)")
+	dockerEP := fs.String("docker-endpoint", "", "query the Docker daemon at this socket path or unix://, tcp:// URL instead of /var/run/docker.sock")
+	pluginDir := fs.String("plugin-dir", fingerprint.DefaultPluginDir, "run the collector plugins in this directory into the custom section (empty disables)")
+	debug := fs.Bool("debug", false, "log the files, commands and sockets the collectors use to stderr")
+	budget := fs.Duration("budget", 0, "bound collection time, skipping collectors that do not fit")
+	exclude := fs.String("exclude-ifaces", strings.Join(fingerprint.DefaultInterfaceExclude, ","), "comma-separated interface name patterns to leave out (empty keeps all)")
+	disable := fs.String("disable-collectors", "", "comma-separated collectors to skip, e.g. docker,boot")
+	waitReady := fs.Duration("wait-for-ready", 0, "first wait up to this long for udev, the network and the Docker daemon to come up after boot")
+	timeout := fs.Duration("timeout", 0, "abandon collectors still running after this overall deadline")
+	timeouts := fingerprint.DefaultTimeouts
+	timeouts.Collectors = maps.Clone(timeouts.Collectors)
+	timeouts.Commands = maps.Clone(timeouts.Commands)
+	fs.Func("collector-timeout", "bound a collector, e.g. docker=2s (repeatable, comma-separated; 0 = unbounded)", durationsFlag(timeouts.Collectors))
+	fs.Func("command-timeout", "bound an external command, e.g. blkid=500ms (repeatable, comma-separated; 0 = unbounded)", durationsFlag(timeouts.Commands))
+	fs.DurationVar(&timeouts.FileRead, "read-timeout", timeouts.FileRead, "bound reading one system file (0 = unbounded)")
+	attempts := map[string]int{}
+	fs.Func("retries", "tries of a probe that may fail during boot, e.g. docker=3,cloud-init=4 (repeatable; 1 = no retries)", func(v string) error {
+		for _, kv := range strings.Split(v, ",") {
+			name, n, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if _, known := fingerprint.DefaultRetries[name]; !ok || !known {
+				return fmt.Errorf("%q is not probe=count with a probe of docker, cloud-init", kv)
+			}
+			i, err := strconv.Atoi(n)
+			if err != nil {
+				return err
+			}
+			attempts[name] = i
+		}
+		return nil
+	})
+	labelsFile := fs.String("labels-file", fingerprint.DefaultLabelsFile, "read static labels from this key=value file (empty disables)")
+	labels := map[string]string{}
+	fs.Func("label", "attach a static label, e.g. site=fra1 (repeatable; overrides -labels-file)", func(v string) error {
+		k, val, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("%q is not key=value", v)
+		}
+		if err := fingerprint.ValidLabelKey(k); err != nil {
+			return err
+		}
+		labels[k] = val
+		return nil
+	})
+	aliases := fs.Int("deprecated-aliases", fingerprint.DefaultAliasVersions, "also write renamed fields under their old names for this many schema versions (0 = new names only)")
+	limits := fingerprint.DefaultLimits
+	fs.IntVar(&limits.MaxInterfaces, "max-interfaces", limits.MaxInterfaces, "cap the number of network interfaces (0 = unlimited)")
+	fs.IntVar(&limits.MaxPackages, "max-packages", limits.MaxPackages, "cap the number of listed packages (0 = unlimited)")
+	fs.IntVar(&limits.MaxStringLength, "max-string", limits.MaxStringLength, "cap the length of any string value (0 = unlimited)")
+	return func() []fingerprint.Option {
+		opts := []fingerprint.Option{fingerprint.WithLimits(limits), fingerprint.WithTimeouts(timeouts), fingerprint.WithDeprecatedAliases(*aliases)}
+		var patterns []string
+		for _, p := range strings.Split(*exclude, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				patterns = append(patterns, p)
+			}
+		}
+		opts = append(opts, fingerprint.WithInterfaceExclude(patterns...))
+		var disabled []string
+		for _, c := range strings.Split(*disable, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				disabled = append(disabled, c)
+			}
+		}
+		if len(disabled) > 0 {
+			opts = append(opts, fingerprint.WithoutCollectors(disabled...))
+		}
+		if *cloudInit {
+			opts = append(opts, fingerprint.WithCloudInit())
+		}
+		if *storage {
+			opts = append(opts, fingerprint.WithStorageHealth())
+		}
+		if *drives {
+			opts = append(opts, fingerprint.WithDriveIdentity())
+		}
+		if *netns {
+			opts = append(opts, fingerprint.WithNetNamespaces())
+		}
+		if *neighbors {
+			opts = append(opts, fingerprint.WithNeighbors())
+		}
+		if *partTables {
+			opts = append(opts, fingerprint.WithPartitionTables())
+		}
+		if *udevRootUUID {
+			opts = append(opts, fingerprint.WithUdevRootFSUUID())
+		}
+		if *packages {
+			opts = append(opts, fingerprint.WithPackages())
+		}
+		if *pci {
+			opts = append(opts, fingerprint.WithPCI())
+		}
+		if *usb {
+			opts = append(opts, fingerprint.WithUSB())
+		}
+		if *provenance {
+			opts = append(opts, fingerprint.WithProvenance())
+		}
+		if *hostRoot != "" {
+			opts = append(opts, fingerprint.WithHostRoot(*hostRoot))
+		}
+		if *dockerEP != "" {
+			opts = append(opts, fingerprint.WithDockerEndpoint(*dockerEP))
+		}
+		if *pluginDir != "" {
+			opts = append(opts, fingerprint.WithPlugins(*pluginDir))
+		}
+		if *labelsFile != "" {
+			opts = append(opts, fingerprint.WithLabelsFile(*labelsFile))
+		}
+		if len(labels) > 0 {
+			opts = append(opts, fingerprint.WithLabels(labels))
+		}
+		if *debug {
+			h := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+			opts = append(opts, fingerprint.WithLogger(slog.New(h)))
+		}
+		if *budget > 0 {
+			opts = append(opts, fingerprint.WithBudget(*budget))
+		}
+		if *timeout > 0 {
+			opts = append(opts, fingerprint.WithTimeout(*timeout))
+		}
+		if *waitReady > 0 {
+			opts = append(opts, fingerprint.WithWaitForReady(*waitReady))
+		}
+		for name, n := range attempts {
+			p := fingerprint.DefaultRetries[name]
+			p.Attempts = n
+			opts = append(opts, fingerprint.WithRetry(name, p))
+		}
+		if *selfCheck {
+			opts = append(opts, fingerprint.WithSelfCheck(*selfDigest, selfCheckPublicKey()))
+		}
+		return opts
+	}
+}
+
+// durationsFlag parses name=duration pairs into m.
+func durationsFlag(m map[string]time.Duration) func(string) error {
+	return func(v string) error {
+		for _, kv := range strings.Split(v, ",") {
+			name, d, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok || name == "" {
+				return fmt.Errorf("%q is not name=duration", kv)
+			}
+			dur, err := time.ParseDuration(d)
+			if err != nil {
+				return err
+			}
+			m[name] = dur
+		}
+		return nil
+	}
+}
+
+// httpFlags registers the flags of the shared HTTP client and returns a
+// function applying the parsed values.
+func httpFlags(fs *flag.FlagSet) func() error {
+	def := httpclient.DefaultConfig()
+	timeout := fs.Duration("http-timeout", def.Timeout, "bound each HTTP request")
+	proxy := fs.String("http-proxy", "", "proxy URL for HTTP requests, or \"direct\" (default from HTTPS_PROXY/HTTP_PROXY)")
+	return func() error {
+		c := def
+		c.Timeout, c.Proxy = *timeout, *proxy
+		return httpclient.Configure(c)
 	}
-	fmt.Println(b)
 }
@@ -0,0 +1,235 @@
+package pgwire
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// backend is the server side of one connection in a test.
+type backend struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+// serve accepts one connection and runs fn on it, returning the address
+// to connect to and a channel closed when fn returns.
+func serve(t *testing.T, fn func(b *backend)) (addr string, done <-chan struct{}) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		fn(&backend{c: c, r: bufio.NewReader(c)})
+	}()
+	return l.Addr().String(), ch
+}
+
+// startup reads the optional SSLRequest, answering it with ssl, and the
+// startup message, returning its parameters.
+func (b *backend) startup(ssl byte) map[string]string {
+	var n [4]byte
+	if _, err := io.ReadFull(b.r, n[:]); err != nil {
+		return nil
+	}
+	body := make([]byte, binary.BigEndian.Uint32(n[:])-4)
+	io.ReadFull(b.r, body)
+	if binary.BigEndian.Uint32(body) == 80877103 {
+		b.c.Write([]byte{ssl})
+		return b.startup(0)
+	}
+	params := map[string]string{}
+	f := strings.Split(string(body[4:]), "\x00")
+	for i := 0; i+1 < len(f); i += 2 {
+		params[f[i]] = f[i+1]
+	}
+	return params
+}
+
+func (b *backend) send(t byte, body []byte) {
+	msg := binary.BigEndian.AppendUint32([]byte{t}, uint32(len(body)+4))
+	b.c.Write(append(msg, body...))
+}
+
+func (b *backend) auth(code uint32, data []byte) {
+	b.send('R', append(binary.BigEndian.AppendUint32(nil, code), data...))
+}
+
+func (b *backend) ready() { b.send('Z', []byte{'I'}) }
+
+// receive reads one message; t is 0 once the client hung up.
+func (b *backend) receive() (t byte, body []byte) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(b.r, hdr[:]); err != nil {
+		return 0, nil
+	}
+	body = make([]byte, binary.BigEndian.Uint32(hdr[1:])-4)
+	io.ReadFull(b.r, body)
+	return hdr[0], body
+}
+
+// trust completes a startup without a password.
+func (b *backend) trust() {
+	b.startup('N')
+	b.auth(0, nil)
+	b.ready()
+}
+
+func TestRequireIsDefault(t *testing.T) {
+	addr, done := serve(t, func(b *backend) { b.startup('N') })
+	_, err := Connect(context.Background(), "postgres://fleet@"+addr+"/fleet")
+	if err == nil || !strings.Contains(err.Error(), "does not support TLS") {
+		t.Fatalf("Connect = %v, want a TLS error", err)
+	}
+	<-done
+}
+
+func TestPreferRefusesPlaintextPasswords(t *testing.T) {
+	for _, code := range []uint32{3, 5} {
+		var got []byte
+		addr, done := serve(t, func(b *backend) {
+			b.startup('N')
+			b.auth(code, []byte{1, 2, 3, 4})
+			_, got = b.receive()
+		})
+		_, err := Connect(context.Background(), "postgres://fleet:s3cret@"+addr+"/fleet?sslmode=prefer")
+		<-done
+		if err != errPlainPassword {
+			t.Errorf("auth %d: Connect = %v, want errPlainPassword", code, err)
+		}
+		if got != nil {
+			t.Errorf("auth %d: client sent %q", code, got)
+		}
+	}
+}
+
+func TestMD5(t *testing.T) {
+	var got string
+	addr, done := serve(t, func(b *backend) {
+		if p := b.startup('N'); p["user"] != "fleet" || p["database"] != "inventory" {
+			t.Errorf("startup parameters %v", p)
+		}
+		b.auth(5, []byte{1, 2, 3, 4})
+		_, body := b.receive()
+		got = string(body)
+		b.auth(0, nil)
+		b.ready()
+	})
+	c, err := Connect(context.Background(), "postgres://fleet:s3cret@"+addr+"/inventory?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-done
+	if want := "md5b6231b70c8d7091f962b6f6950651f85\x00"; got != want {
+		t.Errorf("password message %q, want %q", got, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var types []byte
+	var bind []byte
+	addr, done := serve(t, func(b *backend) {
+		b.trust()
+		for {
+			typ, body := b.receive()
+			if typ == 0 || typ == 'X' {
+				return
+			}
+			types = append(types, typ)
+			switch typ {
+			case 'B':
+				bind = body
+			case 'S':
+				b.send('T', nil)
+				b.send('D', []byte{0, 2, 0, 0, 0, 5, 'a', 'p', 'p', '-', '3', 0xff, 0xff, 0xff, 0xff})
+				b.send('C', []byte("SELECT 1\x00"))
+				b.ready()
+			}
+		}
+	})
+	c, err := Connect(context.Background(), "postgres://fleet@"+addr+"/fleet?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := c.Query(context.Background(), "SELECT host, alias FROM t WHERE n = $1 AND x = $2", 42, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-done
+	if !slices.Equal(types, []byte("PBES")) {
+		t.Errorf("messages %q, want PBES", types)
+	}
+	wantBind := []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 2, '4', '2', 0xff, 0xff, 0xff, 0xff, 0, 0}
+	if !bytes.Equal(bind, wantBind) {
+		t.Errorf("Bind %v, want %v", bind, wantBind)
+	}
+	if len(rows) != 1 || !slices.Equal(rows[0], []string{"app-3", ""}) {
+		t.Errorf("rows %q", rows)
+	}
+}
+
+// TestEncodeError checks that an argument that cannot be sent leaves
+// nothing half-written for the next query.
+func TestEncodeError(t *testing.T) {
+	var first byte
+	addr, done := serve(t, func(b *backend) {
+		b.trust()
+		first, _ = b.receive()
+		b.send('C', []byte("SELECT 0\x00"))
+		b.ready()
+		b.receive()
+	})
+	c, err := Connect(context.Background(), "postgres://fleet@"+addr+"/fleet?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Exec(context.Background(), "SELECT $1, $2", "ok", struct{}{}); err == nil {
+		t.Fatal("Exec accepted an unsupported argument")
+	}
+	if err := c.Exec(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-done
+	if first != 'Q' {
+		t.Errorf("server got %q first, want the simple query", first)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	addr, done := serve(t, func(b *backend) {
+		b.trust()
+		b.receive()
+		b.send('E', []byte("SERROR\x00VERROR\x00C23505\x00Mduplicate key\x00\x00"))
+		b.ready()
+		b.receive()
+	})
+	c, err := Connect(context.Background(), "postgres://fleet@"+addr+"/fleet?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Exec(context.Background(), "INSERT INTO t VALUES (1)")
+	c.Close()
+	<-done
+	pe, ok := err.(*Error)
+	if !ok || pe.Code != "23505" || pe.Message != "duplicate key" {
+		t.Fatalf("Exec = %v, want SQLSTATE 23505", err)
+	}
+}
@@ -0,0 +1,35 @@
+package pgwire
+
+import "testing"
+
+// TestSCRAMVector checks the exchange of RFC 7677, section 3.
+func TestSCRAMVector(t *testing.T) {
+	s := &scram{password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO", clientBare: "n=user,r=rOprNGfwEbeRWgbNEkqO"}
+	final, err := s.clientFinal("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="; final != want {
+		t.Errorf("clientFinal = %q, want %q", final, want)
+	}
+	if err := s.verifyServer("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="); err != nil {
+		t.Error(err)
+	}
+	if err := s.verifyServer("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="); err == nil {
+		t.Error("verifyServer accepted a forged server signature")
+	}
+}
+
+func TestSCRAMRejectsServerFirst(t *testing.T) {
+	for _, msg := range []string{
+		"r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",                // foreign nonce
+		"r=rOprNGfwEbeRWgbNEkqO,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", // not extended
+		"r=rOprNGfwEbeRWgbNEkqOx,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=0",   // iterations
+		"r=rOprNGfwEbeRWgbNEkqOx,s=not base64!,i=4096",             // salt
+	} {
+		s := &scram{password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO", clientBare: "n=,r=rOprNGfwEbeRWgbNEkqO"}
+		if _, err := s.clientFinal(msg); err == nil {
+			t.Errorf("clientFinal(%q) succeeded", msg)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package push delivers snapshots to a remote inventory endpoint.
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"AurFingerprintAgent/fdcap"
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/httpclient"
+	"AurFingerprintAgent/httpenc"
+	"AurFingerprintAgent/jsonpatch"
+	"AurFingerprintAgent/retry"
+	"AurFingerprintAgent/spool"
+)
+
+// Client posts snapshots to URL.
+type Client struct {
+	URL string
+	// HTTPClient is used for requests; httpclient.Shared() when nil.
+	HTTPClient *http.Client
+	// Signer signs pushed snapshots when set. A nonce can only be sent
+	// together with a signature.
+	Signer crypto.Signer
+	// DeltaState names a file holding the last snapshot acknowledged by the
+	// server. When set, unsigned pushes are sent as an RFC 6902 JSON Patch
+	// against that snapshot whenever the patch is small enough. Signed
+	// snapshots are always sent in full since the signature covers the
+	// complete document. Deltas leave out the agent_runtime section, which
+	// changes with every run of the agent; the server keeps the one of the
+	// last full push.
+	DeltaState string
+	// DeltaRatio is the maximum patch size relative to the full document
+	// for a delta to be sent; 0.5 when zero.
+	DeltaRatio float64
+	// Compression is the content coding applied to request bodies
+	// ("gzip" or "zstd"). Servers rejecting it with 415 Unsupported Media
+	// Type receive the uncompressed body instead.
+	Compression string
+	// Retry repeats requests that failed for a network error or a 408,
+	// 429 or 5xx answer; a single try when zero.
+	Retry retry.Policy
+}
+
+// Headers used by delta pushes. BaseHashHeader carries the SHA-256 of the
+// document the patch applies to; servers that do not hold that base answer
+// 409 Conflict or 412 Precondition Failed and receive the full snapshot.
+const (
+	BaseHashHeader   = "X-LSF-Base-Hash"
+	patchContentType = "application/json-patch+json"
+)
+
+// DeviceIDHeader carries the device ID announced by Register.
+const DeviceIDHeader = "X-LSF-Device-ID"
+
+// Push sends snap, signed and bound to nonce when a signer is configured.
+// Retries reuse nonce; use PushNonce against servers that accept each
+// nonce once.
+func (c *Client) Push(ctx context.Context, snap fingerprint.Snapshot, nonce string) error {
+	return c.PushNonce(ctx, snap, func(context.Context) (string, error) { return nonce, nil })
+}
+
+// PushNonce is Push with the nonce taken from nonce, which is called again
+// for every retry of a signed push so that each attempt carries a fresh
+// challenge.
+func (c *Client) PushNonce(ctx context.Context, snap fingerprint.Snapshot, nonce func(context.Context) (string, error)) error {
+	if c.Signer == nil {
+		n, err := nonce(ctx)
+		if err != nil {
+			return err
+		}
+		if n != "" {
+			return errors.New("push: nonce requires a signing key")
+		}
+		body, err := json.Marshal(snap)
+		if err != nil {
+			return err
+		}
+		if c.DeltaState != "" {
+			sent, err := c.pushDelta(ctx, snap)
+			if err != nil || sent {
+				return err
+			}
+		}
+		if err := c.post(ctx, body, "application/json", "", nil); err != nil {
+			return err
+		}
+		c.saveState(body)
+		return nil
+	}
+	var body []byte
+	err := c.Retry.Do(ctx, func(ctx context.Context) error {
+		n, err := nonce(ctx)
+		if err != nil {
+			return permanent(err)
+		}
+		ss, err := fingerprint.SignNonce(c.Signer, snap, n)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		if body, err = json.Marshal(ss); err != nil {
+			return retry.Permanent(err)
+		}
+		return permanent(c.postOnce(ctx, body, "application/json", n, nil))
+	})
+	if err != nil {
+		return err
+	}
+	c.saveState(body)
+	return nil
+}
+
+// Register announces a newly provisioned device: it sends snap in full,
+// signed when a signer is configured, with id in the DeviceIDHeader. The
+// delta state is neither used nor updated.
+func (c *Client) Register(ctx context.Context, id string, snap fingerprint.Snapshot) error {
+	var v any = snap
+	if c.Signer != nil {
+		ss, err := fingerprint.Sign(c.Signer, snap)
+		if err != nil {
+			return err
+		}
+		v = ss
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, body, "application/json", "", http.Header{DeviceIDHeader: {id}})
+}
+
+// pushDelta sends a patch against the acknowledged base when worthwhile,
+// and nothing when the snapshot did not change. sent is false when the
+// full document has to be sent instead.
+func (c *Client) pushDelta(ctx context.Context, snap fingerprint.Snapshot) (sent bool, err error) {
+	var base []byte
+	err = fdcap.Default.Do(func() (err error) {
+		base, err = os.ReadFile(c.DeltaState)
+		return err
+	})
+	if err != nil {
+		return false, nil
+	}
+	prev, err := fingerprint.Unmarshal(base)
+	if err != nil {
+		return false, nil
+	}
+	// The PID and start time alone would make every delta non-empty.
+	snap.Agent = prev.Agent
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return false, err
+	}
+	ops, err := jsonpatch.Diff(base, body)
+	if err != nil {
+		return false, nil
+	}
+	if len(ops) == 0 {
+		// The server already holds this snapshot.
+		return true, nil
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return false, err
+	}
+	ratio := c.DeltaRatio
+	if ratio <= 0 {
+		ratio = 0.5
+	}
+	if float64(len(patch)) > ratio*float64(len(body)) {
+		return false, nil
+	}
+	sum := sha256.Sum256(base)
+	hdr := http.Header{BaseHashHeader: {hex.EncodeToString(sum[:])}}
+	err = c.post(ctx, patch, patchContentType, "", hdr)
+	var se *statusError
+	if errors.As(err, &se) && (se.code == http.StatusConflict || se.code == http.StatusPreconditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	c.saveState(body)
+	return true, nil
+}
+
+func (c *Client) saveState(body []byte) {
+	if c.DeltaState == "" {
+		return
+	}
+	tmp := c.DeltaState + ".tmp"
+	if os.WriteFile(tmp, body, 0o600) == nil {
+		os.Rename(tmp, c.DeltaState)
+	}
+}
+
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func (c *Client) post(ctx context.Context, body []byte, contentType, nonce string, hdr http.Header) error {
+	return c.Retry.Do(ctx, func(ctx context.Context) error {
+		return permanent(c.postOnce(ctx, body, contentType, nonce, hdr))
+	})
+}
+
+// permanent marks err as not worth retrying unless it is transient.
+func permanent(err error) error {
+	if err != nil && !transient(err) {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// transient reports whether a failed request may succeed when repeated.
+func transient(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests || se.code == http.StatusRequestTimeout
+	}
+	// Transport failures, not contexts ending or bodies failing to encode.
+	var ne net.Error
+	return errors.As(err, &ne) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func (c *Client) postOnce(ctx context.Context, body []byte, contentType, nonce string, hdr http.Header) error {
+	if c.Compression != httpenc.Identity {
+		err := c.postEncoded(ctx, c.Compression, body, contentType, nonce, hdr)
+		var se *statusError
+		if !errors.As(err, &se) || se.code != http.StatusUnsupportedMediaType {
+			return err
+		}
+	}
+	return c.postEncoded(ctx, httpenc.Identity, body, contentType, nonce, hdr)
+}
+
+func (c *Client) postEncoded(ctx context.Context, coding string, body []byte, contentType, nonce string, hdr http.Header) error {
+	enc, err := httpenc.Encode(coding, body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(enc))
+	if err != nil {
+		return err
+	}
+	if coding != httpenc.Identity {
+		req.Header.Set("Content-Encoding", coding)
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	if nonce != "" {
+		req.Header.Set(fingerprint.NonceHeader, nonce)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{
+			code: resp.StatusCode,
+			msg:  fmt.Sprintf("push: server returned %s: %s", resp.Status, bytes.TrimSpace(msg)),
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// FetchNonce retrieves a fresh challenge from url. The nonce is taken from
+// the X-LSF-Nonce response header, or from the trimmed body when the header
+// is absent.
+func (c *Client) FetchNonce(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("push: nonce endpoint returned %s", resp.Status)
+	}
+	if n := resp.Header.Get(fingerprint.NonceHeader); n != "" {
+		return n, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	n := strings.TrimSpace(string(b))
+	if n == "" {
+		return "", errors.New("push: empty nonce")
+	}
+	return n, nil
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return httpclient.Shared()
+}
+
+// Drain pushes spooled snapshots oldest first and removes each one once the
+// server accepted it. It stops at the first failure, leaving the remaining
+// items queued. nonce, when set, is called before every attempt.
+func (c *Client) Drain(ctx context.Context, q *spool.Queue, nonce func(context.Context) (string, error)) error {
+	for {
+		name, b, err := q.Peek()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		snap, err := fingerprint.Unmarshal(b)
+		if err != nil {
+			// A corrupt item would block the queue forever.
+			q.Remove(name)
+			continue
+		}
+		if nonce == nil {
+			err = c.Push(ctx, snap, "")
+		} else {
+			err = c.PushNonce(ctx, snap, nonce)
+		}
+		if err != nil {
+			return err
+		}
+		if err := q.Remove(name); err != nil {
+			return err
+		}
+	}
+}
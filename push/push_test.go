@@ -0,0 +1,76 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/retry"
+)
+
+// TestDeltaIgnoresAgentRuntime checks that a new run of the agent, with
+// its own PID, does not send a delta when nothing else changed.
+func TestDeltaIgnoresAgentRuntime(t *testing.T) {
+	var types []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		types = append(types, r.Header.Get("Content-Type"))
+	}))
+	defer srv.Close()
+	c := &Client{URL: srv.URL, DeltaState: filepath.Join(t.TempDir(), "state.json")}
+	snap := fingerprint.Snapshot{SchemaVersion: fingerprint.SchemaVersion, MachineID: "4c4c4544004d3110"}
+	snap.Agent.PID = 100
+	if err := c.Push(context.Background(), snap, ""); err != nil {
+		t.Fatal(err)
+	}
+	snap.Agent.PID = 200
+	if err := c.Push(context.Background(), snap, ""); err != nil {
+		t.Fatal(err)
+	}
+	snap.Hostname = "app-03"
+	if err := c.Push(context.Background(), snap, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != "application/json" || types[1] != patchContentType {
+		t.Errorf("requests %q, want a full push and one patch", types)
+	}
+}
+
+// TestRetryFetchesNonce checks that a retried signed push carries a fresh
+// nonce, since the server accepts each one once.
+func TestRetryFetchesNonce(t *testing.T) {
+	var issued int
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			issued++
+			w.Header().Set(fingerprint.NonceHeader, "n"+strconv.Itoa(issued))
+			return
+		}
+		got = append(got, r.Header.Get(fingerprint.NonceHeader))
+		if len(got) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{URL: srv.URL, Signer: key, Retry: retry.Policy{Attempts: 2, Base: time.Millisecond}}
+	nonce := func(ctx context.Context) (string, error) { return c.FetchNonce(ctx, srv.URL) }
+	snap := fingerprint.Snapshot{SchemaVersion: fingerprint.SchemaVersion, MachineID: "4c4c4544004d3110"}
+	if err := c.PushNonce(context.Background(), snap, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "n1" || got[1] != "n2" {
+		t.Errorf("nonces %q, want n1 then n2", got)
+	}
+}
@@ -0,0 +1,326 @@
+// Package query evaluates a small path language over decoded JSON, enough
+// for scripts to pick values out of a snapshot without jq:
+//
+//	dmi.product_uuid          a field
+//	network.0.mac             an array element, as in Snapshot.Flatten
+//	network[0].mac            the same
+//	network[-1].name          counting from the end
+//	network.mac               a field of every element
+//	network[*].mac            the same
+//	network[?physical].mac    elements whose field is set and not false
+//	network[?!master].name    elements whose field is unset or false
+//	network[?kind==bond].name elements whose field equals a value
+//	network[?name!='eth 0']   quoted values may hold any character
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed expression.
+type Query struct {
+	steps []step
+}
+
+type step struct {
+	name   string // field, or "" for a selector
+	index  *int
+	all    bool
+	filter *filter
+}
+
+type filter struct {
+	field []string
+	op    string // "", "!", "==" or "!="
+	value string
+}
+
+// Parse parses expr.
+func Parse(expr string) (Query, error) {
+	p := parser{s: expr}
+	var q Query
+	if strings.TrimSpace(expr) == "" || expr == "." {
+		return q, nil
+	}
+	for {
+		if err := p.segment(&q); err != nil {
+			return Query{}, fmt.Errorf("query %q: %w", expr, err)
+		}
+		if p.done() {
+			return q, nil
+		}
+		if p.s[p.i] == '.' {
+			p.i++
+		}
+	}
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) done() bool { return p.i >= len(p.s) }
+
+// segment parses a field or "*" followed by any selectors.
+func (p *parser) segment(q *Query) error {
+	start := p.i
+	for !p.done() && p.s[p.i] != '.' && p.s[p.i] != '[' {
+		p.i++
+	}
+	switch name := p.s[start:p.i]; {
+	case name == "*":
+		q.steps = append(q.steps, step{all: true})
+	case name != "":
+		q.steps = append(q.steps, step{name: name})
+	case p.done() || p.s[p.i] != '[':
+		return fmt.Errorf("empty field at offset %d", start)
+	}
+	for !p.done() && p.s[p.i] == '[' {
+		end := p.closing()
+		if end < 0 {
+			return fmt.Errorf("unterminated [ at offset %d", p.i)
+		}
+		sel := p.s[p.i+1 : end]
+		p.i = end + 1
+		st, err := selector(sel)
+		if err != nil {
+			return err
+		}
+		q.steps = append(q.steps, st)
+	}
+	return nil
+}
+
+// closing finds the ] matching the [ at p.i, skipping quoted text.
+func (p *parser) closing() int {
+	var quote byte
+	for j := p.i + 1; j < len(p.s); j++ {
+		switch c := p.s[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return j
+		}
+	}
+	return -1
+}
+
+func selector(sel string) (step, error) {
+	sel = strings.TrimSpace(sel)
+	switch {
+	case sel == "*":
+		return step{all: true}, nil
+	case strings.HasPrefix(sel, "?"):
+		f, err := parseFilter(strings.TrimSpace(sel[1:]))
+		return step{filter: f}, err
+	}
+	n, err := strconv.Atoi(sel)
+	if err != nil {
+		return step{}, fmt.Errorf("selector [%s] is not an index, * or ?filter", sel)
+	}
+	return step{index: &n}, nil
+}
+
+// parseFilter parses "field", "!field" or "field OP value": the field
+// runs up to the first space, "!" or "=", and the value is either quoted
+// or free of quotes, "!" and "=".
+func parseFilter(s string) (*filter, error) {
+	f := &filter{}
+	i := 0
+	if strings.HasPrefix(s, "!") && !strings.HasPrefix(s, "!=") {
+		f.op, i = "!", 1
+	}
+	start := i
+	for i < len(s) && !strings.ContainsRune(" \t!=", rune(s[i])) {
+		i++
+	}
+	field := s[start:i]
+	if field == "" {
+		return nil, fmt.Errorf("filter %q has no field", s)
+	}
+	if f.field = strings.Split(field, "."); slices.Contains(f.field, "") {
+		return nil, fmt.Errorf("filter %q has an empty field", s)
+	}
+	rest := strings.TrimLeft(s[i:], " \t")
+	if rest == "" {
+		return f, nil
+	}
+	if op := rest[:min(2, len(rest))]; f.op == "" && (op == "==" || op == "!=") {
+		f.op = op
+	} else {
+		return nil, fmt.Errorf("filter %q: unexpected %q", s, rest)
+	}
+	v, err := filterValue(strings.TrimSpace(rest[2:]))
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", s, err)
+	}
+	f.value = v
+	return f, nil
+}
+
+func filterValue(v string) (string, error) {
+	switch {
+	case v == "":
+		return "", errors.New("no value")
+	case v[0] == '\'' || v[0] == '"':
+		end := strings.IndexByte(v[1:], v[0]) + 1
+		if end == 0 {
+			return "", fmt.Errorf("unterminated %c", v[0])
+		}
+		if end != len(v)-1 {
+			return "", fmt.Errorf("unexpected %q after the quoted value", v[end+1:])
+		}
+		return v[1:end], nil
+	case strings.ContainsAny(v, "'\"!="):
+		return "", fmt.Errorf("value %q needs quotes", v)
+	}
+	return v, nil
+}
+
+// Eval returns the values q selects from v, a value decoded from JSON
+// into any. Decode with json.Decoder.UseNumber, so that integers beyond
+// 2^53 compare and print exactly.
+func (q Query) Eval(v any) []any {
+	cur := []any{v}
+	for _, st := range q.steps {
+		var next []any
+		for _, c := range cur {
+			next = append(next, st.apply(c)...)
+		}
+		cur = next
+	}
+	return cur
+}
+
+func (st step) apply(v any) []any {
+	switch {
+	case st.name != "":
+		switch t := v.(type) {
+		case map[string]any:
+			if c, ok := t[st.name]; ok {
+				return []any{c}
+			}
+		case []any:
+			// A number indexes, as in Flatten paths; a name maps over
+			// the elements.
+			if n, err := strconv.Atoi(st.name); err == nil {
+				return index(t, n)
+			}
+			var out []any
+			for _, e := range t {
+				out = append(out, st.apply(e)...)
+			}
+			return out
+		}
+		return nil
+	case st.index != nil:
+		if t, ok := v.([]any); ok {
+			return index(t, *st.index)
+		}
+		return nil
+	case st.all:
+		switch t := v.(type) {
+		case []any:
+			return t
+		case map[string]any:
+			out := make([]any, 0, len(t))
+			for _, k := range slices.Sorted(maps.Keys(t)) {
+				out = append(out, t[k])
+			}
+			return out
+		}
+		return nil
+	case st.filter != nil:
+		t, ok := v.([]any)
+		if !ok {
+			t = []any{v}
+		}
+		var out []any
+		for _, e := range t {
+			if st.filter.match(e) {
+				out = append(out, e)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func index(a []any, n int) []any {
+	if n < 0 {
+		n += len(a)
+	}
+	if n < 0 || n >= len(a) {
+		return nil
+	}
+	return []any{a[n]}
+}
+
+func (f *filter) match(e any) bool {
+	v, ok := e, true
+	for _, k := range f.field {
+		m, isMap := v.(map[string]any)
+		if !isMap {
+			ok = false
+			break
+		}
+		if v, ok = m[k]; !ok {
+			break
+		}
+	}
+	switch f.op {
+	case "":
+		return ok && truthy(v)
+	case "!":
+		return !ok || !truthy(v)
+	case "==":
+		return ok && Format(v) == f.value
+	default:
+		return !ok || Format(v) != f.value
+	}
+}
+
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case float64:
+		return t != 0
+	case json.Number:
+		f, err := t.Float64()
+		return err != nil || f != 0
+	}
+	return true
+}
+
+// Format renders a result for output: strings unquoted, numbers and
+// booleans as in JSON, objects and arrays as compact JSON.
+func Format(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case json.Number:
+		return t.String()
+	case nil:
+		return "null"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,107 @@
+package query
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const doc = `{
+	"memory": {"mem_total_kb": 9007199254740993},
+	"network": [
+		{"name": "eth 0", "mac": "52:54:00:12:34:56", "physical": true, "mtu": 1500},
+		{"name": "bond0", "kind": "bond", "mac": "52:54:00:12:34:57", "mtu": 9000},
+		{"name": "a!=b", "kind": "x==y", "physical": false, "mtu": 0}
+	]
+}`
+
+func decode(t testing.TB) any {
+	d := json.NewDecoder(strings.NewReader(doc))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestEval(t *testing.T) {
+	v := decode(t)
+	for _, tt := range []struct {
+		expr string
+		want []string
+	}{
+		{"memory.mem_total_kb", []string{"9007199254740993"}},
+		{"network[-1].name", []string{"a!=b"}},
+		{"network.1.mac", []string{"52:54:00:12:34:57"}},
+		{"network[?physical].name", []string{"eth 0"}},
+		{"network[?!physical].name", []string{"bond0", "a!=b"}},
+		{"network[?mtu].name", []string{"eth 0", "bond0"}},
+		{"network[?kind==bond].name", []string{"bond0"}},
+		{"network[?kind != bond].name", []string{"eth 0", "a!=b"}},
+		{"network[?name!='eth 0'].name", []string{"bond0", "a!=b"}},
+		{`network[?name=="a!=b"].kind`, []string{"x==y"}},
+		{"network[?kind=='x==y'].name", []string{"a!=b"}},
+		{"network[?mtu==9000].name", []string{"bond0"}},
+	} {
+		q, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		var got []string
+		for _, r := range q.Eval(v) {
+			got = append(got, Format(r))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"network[?]",
+		"network[?==x]",
+		"network[?kind==]",
+		"network[?kind=x]",
+		"network[?kind==a==b]",
+		"network[?kind!=a!=b]",
+		"network[?!kind==bond]",
+		"network[?kind=='bond]",
+		"network[?kind=='bond'x]",
+		"network[?kind..name]",
+		"network[?kind bond]",
+		"network[0",
+		"network[x]",
+		"a..b",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{
+		"dmi.product_uuid", "network[0].mac", "network[*].mac", "*.name",
+		"network[?physical].mac", "network[?!master].name", "network[?kind==bond]",
+		"network[?name!='eth 0']", `network[?name=="a]b"]`, "network[?a!=b==c]", "[?x]", "[",
+	} {
+		f.Add(s)
+	}
+	v := decode(f)
+	f.Fuzz(func(t *testing.T, expr string) {
+		q, err := Parse(expr)
+		if err != nil {
+			return
+		}
+		for _, st := range q.steps {
+			if st.filter != nil && (len(st.filter.field) == 0 || slices.Contains(st.filter.field, "")) {
+				t.Fatalf("Parse(%q) accepted an empty filter field", expr)
+			}
+		}
+		q.Eval(v)
+	})
+}
@@ -0,0 +1,195 @@
+// Package redact derives the view of a snapshot one recipient may see, so
+// that a single collection can feed a full internal inventory and an
+// anonymized subset for an outside vendor.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Profile selects and masks the fields of a snapshot. Fields are dotted JSON
+// paths as in Snapshot.Flatten, e.g. "dmi.product_uuid", where * matches
+// any key or array index, e.g. "network.*.mac". A path covers the field and
+// everything below it. The zero Profile passes the snapshot unchanged.
+type Profile struct {
+	// Include keeps only these fields; all fields when empty.
+	Include []string `json:"include,omitempty"`
+	// Exclude drops these fields.
+	Exclude []string `json:"exclude,omitempty"`
+	// Hash replaces the string values of these fields with their
+	// HMAC-SHA256 under Salt, so that the recipient can correlate machines
+	// without learning serials or addresses. Values of other types are
+	// dropped. Salt is required with Hash: without a key the digests of
+	// serials and MACs are recovered by enumeration.
+	Hash []string `json:"hash,omitempty"`
+	Salt string   `json:"salt,omitempty"`
+}
+
+// ErrNoSalt is returned for profiles that hash fields without a Salt.
+var ErrNoSalt = errors.New("redact: hash needs a salt")
+
+// Load reads a JSON encoded profile.
+func Load(path string) (Profile, error) {
+	var p Profile
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	if err := p.Validate(); err != nil {
+		return p, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return p, nil
+}
+
+// Validate reports a profile that cannot be applied safely.
+func (p Profile) Validate() error {
+	if len(split(p.Hash)) > 0 && strings.TrimSpace(p.Salt) == "" {
+		return ErrNoSalt
+	}
+	return nil
+}
+
+// IsZero reports whether p leaves snapshots unchanged.
+func (p Profile) IsZero() bool {
+	return len(p.Include) == 0 && len(p.Exclude) == 0 && len(p.Hash) == 0
+}
+
+// Apply returns the view of s under p. The schema version is always kept.
+func (p Profile) Apply(s fingerprint.Snapshot) (fingerprint.Snapshot, error) {
+	if p.IsZero() {
+		return s, nil
+	}
+	if err := p.Validate(); err != nil {
+		return s, err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return s, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return s, err
+	}
+	r := redactor{include: split(p.Include), exclude: split(p.Exclude), hash: split(p.Hash), salt: []byte(p.Salt)}
+	v, _ := r.walk(nil, doc)
+	out, _ := v.(map[string]any)
+	if out == nil {
+		out = map[string]any{}
+	}
+	out["schema_version"] = s.SchemaVersion
+	if b, err = json.Marshal(out); err != nil {
+		return s, err
+	}
+	var red fingerprint.Snapshot
+	err = json.Unmarshal(b, &red)
+	return red, err
+}
+
+type redactor struct {
+	include, exclude, hash [][]string
+	salt                   []byte
+}
+
+func split(paths []string) [][]string {
+	out := make([][]string, 0, len(paths))
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, strings.Split(p, "."))
+		}
+	}
+	return out
+}
+
+// covers reports whether path is pattern or lies below it.
+func covers(pattern, path []string) bool {
+	if len(path) < len(pattern) {
+		return false
+	}
+	for i, seg := range pattern {
+		if seg != "*" && seg != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// leadsTo reports whether path is an ancestor of a field pattern names.
+func leadsTo(pattern, path []string) bool {
+	return len(path) < len(pattern) && covers(pattern[:len(path)], path)
+}
+
+// matchAny reports whether f holds for path and any of patterns.
+func matchAny(patterns [][]string, path []string, f func(pattern, path []string) bool) bool {
+	for _, p := range patterns {
+		if f(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// walk returns the redacted v at path, and false when it is dropped.
+func (r redactor) walk(path []string, v any) (any, bool) {
+	if len(path) > 0 {
+		if matchAny(r.exclude, path, covers) {
+			return nil, false
+		}
+		if len(r.include) > 0 && !matchAny(r.include, path, covers) && !matchAny(r.include, path, leadsTo) {
+			return nil, false
+		}
+		if matchAny(r.hash, path, covers) {
+			switch t := v.(type) {
+			case string:
+				return r.digest(t), true
+			case map[string]any, []any:
+				// Masked field by field.
+			default:
+				return nil, false
+			}
+		}
+	}
+	child := func(k string) []string { return append(path[:len(path):len(path)], k) }
+	switch t := v.(type) {
+	case map[string]any:
+		out := map[string]any{}
+		for k, c := range t {
+			if rc, ok := r.walk(child(k), c); ok {
+				out[k] = rc
+			}
+		}
+		if len(out) == 0 && len(t) > 0 {
+			return nil, false
+		}
+		return out, true
+	case []any:
+		out := make([]any, 0, len(t))
+		for i, c := range t {
+			if rc, ok := r.walk(child(strconv.Itoa(i)), c); ok {
+				out = append(out, rc)
+			}
+		}
+		if len(out) == 0 && len(t) > 0 {
+			return nil, false
+		}
+		return out, true
+	}
+	return v, true
+}
+
+func (r redactor) digest(v string) string {
+	m := hmac.New(sha256.New, r.salt)
+	m.Write([]byte(v))
+	return hex.EncodeToString(m.Sum(nil))
+}
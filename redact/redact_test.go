@@ -0,0 +1,69 @@
+package redact
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+func testSnapshot() fingerprint.Snapshot {
+	return fingerprint.Snapshot{
+		SchemaVersion: fingerprint.SchemaVersion,
+		Hostname:      "app-03",
+		MachineID:     "4c4c4544004d3110",
+		DMI:           fingerprint.DMIInfo{ProductUUID: "4c4c4544-004d-3110-8031-b4c04f4e3432", BoardSerial: "CN7016"},
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := testSnapshot()
+	p := Profile{Include: []string{"dmi", "machine_id"}, Exclude: []string{"dmi.board_serial"},
+		Hash: []string{"machine_id"}, Salt: "site-secret"}
+	got, err := p.Apply(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hostname != "" || got.DMI.BoardSerial != "" || got.DMI.ProductUUID != s.DMI.ProductUUID {
+		t.Errorf("Apply = %+v", got)
+	}
+	if got.MachineID == s.MachineID || len(got.MachineID) != 64 {
+		t.Errorf("machine_id = %q, want its HMAC", got.MachineID)
+	}
+	if got.SchemaVersion != s.SchemaVersion {
+		t.Errorf("schema_version = %d", got.SchemaVersion)
+	}
+	other, _ := Profile{Hash: []string{"machine_id"}, Salt: "other-site"}.Apply(s)
+	if other.MachineID == got.MachineID {
+		t.Error("digests do not depend on the salt")
+	}
+}
+
+func TestHashNeedsSalt(t *testing.T) {
+	for _, salt := range []string{"", "  "} {
+		p := Profile{Hash: []string{"machine_id"}, Salt: salt}
+		if _, err := p.Apply(testSnapshot()); !errors.Is(err, ErrNoSalt) {
+			t.Errorf("Apply with salt %q = %v, want ErrNoSalt", salt, err)
+		}
+	}
+	if err := (Profile{Exclude: []string{"hostname"}}).Validate(); err != nil {
+		t.Errorf("Validate without Hash = %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vendor.json")
+	if err := os.WriteFile(path, []byte(`{"hash": ["network.*.mac"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !errors.Is(err, ErrNoSalt) {
+		t.Errorf("Load = %v, want ErrNoSalt", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"hash": ["network.*.mac"], "salt": "site-secret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load = %v", err)
+	}
+}
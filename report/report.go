@@ -0,0 +1,127 @@
+// Package report renders snapshots as a self-contained HTML document for
+// attaching to change-management tickets. The output embeds its styles and
+// references no external resources.
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// Entry is a historical snapshot.
+type Entry struct {
+	Time     time.Time
+	Source   string
+	Snapshot fingerprint.Snapshot
+}
+
+// Change is a difference in a flattened field.
+type Change struct {
+	Key, Old, New string
+}
+
+// Kind classifies the change for highlighting.
+func (c Change) Kind() string {
+	switch {
+	case c.Old == "":
+		return "added"
+	case c.New == "":
+		return "removed"
+	}
+	return "changed"
+}
+
+// Diff compares two snapshots field by field, sorted by key.
+func Diff(old, cur fingerprint.Snapshot) []Change {
+	a, b := old.Flatten(), cur.Flatten()
+	var out []Change
+	for k, v := range b {
+		if a[k] != v {
+			out = append(out, Change{Key: k, Old: a[k], New: v})
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			out = append(out, Change{Key: k, Old: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+type timelineItem struct {
+	Entry
+	Hash    string
+	Changes []Change
+}
+
+type field struct {
+	Key, Value string
+	Changed    bool
+}
+
+type section struct {
+	Name   string
+	Fields []field
+}
+
+// HTML writes the report for cur. history may be empty; it is sorted by
+// time, and cur is compared against its newest entry.
+func HTML(w io.Writer, cur fingerprint.Snapshot, history []Entry) error {
+	sort.Slice(history, func(i, j int) bool { return history[i].Time.Before(history[j].Time) })
+	var timeline []timelineItem
+	for i, e := range history {
+		it := timelineItem{Entry: e, Hash: e.Snapshot.Hash()}
+		if i > 0 {
+			it.Changes = Diff(history[i-1].Snapshot, e.Snapshot)
+		}
+		timeline = append(timeline, it)
+	}
+	var diff []Change
+	if len(history) > 0 {
+		diff = Diff(history[len(history)-1].Snapshot, cur)
+	}
+	changed := map[string]bool{}
+	for _, c := range diff {
+		changed[c.Key] = true
+	}
+	flat := cur.Flatten()
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sections []section
+	for _, k := range keys {
+		name := k
+		for i := range k {
+			if k[i] == '.' {
+				name = k[:i]
+				break
+			}
+		}
+		if len(sections) == 0 || sections[len(sections)-1].Name != name {
+			sections = append(sections, section{Name: name})
+		}
+		s := &sections[len(sections)-1]
+		s.Fields = append(s.Fields, field{Key: k, Value: flat[k], Changed: changed[k]})
+	}
+	return page.Execute(w, map[string]any{
+		"Generated": time.Now().UTC(),
+		"Hostname":  cur.Hostname,
+		"Hash":      cur.Hash(),
+		"Sections":  sections,
+		"Diff":      diff,
+		"Timeline":  timeline,
+	})
+}
+
+//go:embed report.html.tmpl
+var pageSrc string
+
+var page = template.Must(template.New("report").Parse(pageSrc))
@@ -0,0 +1,86 @@
+// Package retry repeats operations that fail transiently, such as probes
+// running while the system boots or a push during a network hiccup, with
+// jittered exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy describes how often and how fast an operation is repeated.
+type Policy struct {
+	// Attempts is the total number of tries; one when zero or less.
+	Attempts int
+	// Base is the delay before the second try. It doubles after each try,
+	// up to Max when Max is positive.
+	Base time.Duration
+	Max  time.Duration
+	// Jitter shortens each delay by a random fraction of up to Jitter, in
+	// [0, 1], so that agents booting together do not retry in lockstep.
+	Jitter float64
+}
+
+// Once tries an operation a single time.
+var Once = Policy{Attempts: 1}
+
+// IsZero reports whether p is the zero Policy, which callers may replace
+// by a default.
+func (p Policy) IsZero() bool { return p == Policy{} }
+
+// Delay returns the wait before try n+1, after n failed tries, without
+// jitter.
+func (p Policy) Delay(n int) time.Duration {
+	d := p.Base
+	for i := 1; i < n && d > 0; i++ {
+		if p.Max > 0 && d >= p.Max {
+			break
+		}
+		d *= 2
+	}
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+	return d
+}
+
+// Do calls op until it succeeds, returns an error marked Permanent, the
+// tries are used up or ctx is done, and returns op's last error.
+func (p Policy) Do(ctx context.Context, op func(context.Context) error) error {
+	for n := 1; ; n++ {
+		err := op(ctx)
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if err == nil || n >= p.Attempts || ctx.Err() != nil {
+			return err
+		}
+		d := p.Delay(n)
+		if p.Jitter > 0 {
+			d -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(d))
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// Permanent marks err as not worth retrying. Do returns err itself.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
@@ -0,0 +1,363 @@
+// Package selfupdate replaces the running agent executable with a newer
+// release, verifying its Ed25519 signature first and restoring the
+// previous binary when the new one fails a health check.
+//
+// A release channel is a directory served over HTTP(S) holding, for every
+// platform, the binary linuxsystemfingerprint-<GOOS>-<GOARCH>, its manifest
+// with ".json" appended and the detached signature of the manifest with
+// ".json.sig" appended (raw or base64). The manifest names the release's
+// version and platform and pins the binary's SHA-256 digest:
+//
+//	{"version": "v1.8.0", "goos": "linux", "goarch": "amd64", "sha256": "<hex>", "sig": "<base64>"}
+//
+// sig is the signature of the digest in the format the agent's self-check
+// reads from "<exe>.sig"; it is installed there with the binary. Releases
+// older than the running agent are refused, so a channel serving an old,
+// validly signed release cannot roll agents back.
+package selfupdate
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"AurFingerprintAgent/httpclient"
+)
+
+// maxBinaryBytes bounds a downloaded release.
+const maxBinaryBytes = 256 << 20
+
+// ErrBadSignature is returned when a release does not verify against the
+// key; nothing is replaced.
+var ErrBadSignature = errors.New("selfupdate: release signature does not verify")
+
+// ErrDowngrade is returned when the channel's release is older than the
+// running agent; nothing is replaced.
+var ErrDowngrade = errors.New("selfupdate: channel release is older than the running agent")
+
+// ErrRolledBack wraps the health check failure after which the previous
+// executable was restored.
+var ErrRolledBack = errors.New("selfupdate: new release failed its health check and was rolled back")
+
+// Updater keeps Executable at the release of URL.
+type Updater struct {
+	// URL is the release channel, e.g. https://releases.example.com/stable.
+	URL string
+	// Key verifies release signatures.
+	Key ed25519.PublicKey
+	// Executable is the binary to replace; os.Executable() when empty.
+	Executable string
+	// Version is the running agent's semantic version, e.g. its module
+	// version. Only newer releases are installed.
+	Version string
+	// Check runs the installed new binary and reports whether it works.
+	// It is skipped when nil.
+	Check func(ctx context.Context, exe string) error
+	// HTTPClient is used for requests; httpclient.Shared() when nil.
+	HTTPClient *http.Client
+}
+
+// Manifest describes a release of a channel.
+type Manifest struct {
+	Version string `json:"version"`
+	GOOS    string `json:"goos"`
+	GOARCH  string `json:"goarch"`
+	// SHA256 is the hex digest of the binary.
+	SHA256 string `json:"sha256"`
+	// Signature is the detached signature of the digest for the
+	// self-check.
+	Signature []byte `json:"sig"`
+}
+
+// Artifact is the release file name for the running platform.
+func Artifact() string {
+	return "linuxsystemfingerprint-" + runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// Update installs the channel's release when it is newer than the running
+// one and reports whether it did. The replaced binary stays next to the
+// new one as "<exe>.old". The caller should restart into the new
+// executable.
+func (u *Updater) Update(ctx context.Context) (bool, error) {
+	if len(u.Key) != ed25519.PublicKeySize {
+		return false, errors.New("selfupdate: no release key")
+	}
+	exe, err := u.executable()
+	if err != nil {
+		return false, err
+	}
+	base := strings.TrimSuffix(u.URL, "/") + "/" + Artifact()
+	m, err := u.manifest(ctx, base)
+	if err != nil {
+		return false, err
+	}
+	switch c, err := compareVersions(m.Version, u.Version); {
+	case err != nil:
+		return false, err
+	case c < 0:
+		return false, fmt.Errorf("%w (%s < %s)", ErrDowngrade, m.Version, u.Version)
+	case c == 0:
+		return false, nil
+	}
+	want, _ := hex.DecodeString(m.SHA256)
+
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return false, err
+	}
+	tmp, sum, err := u.download(ctx, base, exe, fi.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+	if !bytes.Equal(sum, want) {
+		return false, fmt.Errorf("%w: binary digest differs from the manifest", ErrBadSignature)
+	}
+
+	sig := []byte(base64.StdEncoding.EncodeToString(m.Signature) + "\n")
+	oldSig, sigErr := os.ReadFile(exe + ".sig")
+	if err := os.Rename(exe, exe+".old"); err != nil {
+		return false, err
+	}
+	rollback := func() error {
+		if sigErr == nil {
+			writeFile(exe+".sig", oldSig)
+		} else {
+			os.Remove(exe + ".sig")
+		}
+		return os.Rename(exe+".old", exe)
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		return false, errors.Join(err, rollback())
+	}
+	if err := writeFile(exe+".sig", sig); err != nil {
+		return false, errors.Join(err, rollback())
+	}
+	if u.Check != nil {
+		if err := u.Check(ctx, exe); err != nil {
+			if rerr := rollback(); rerr != nil {
+				return false, fmt.Errorf("selfupdate: health check: %w; rollback: %w", err, rerr)
+			}
+			return false, fmt.Errorf("%w: %w", ErrRolledBack, err)
+		}
+	}
+	return true, nil
+}
+
+// manifest fetches the release manifest at base+".json" and checks its
+// signature, its platform and the signature of the digest it pins.
+func (u *Updater) manifest(ctx context.Context, base string) (Manifest, error) {
+	raw, err := u.get(ctx, base+".json", 16<<10)
+	if err != nil {
+		return Manifest{}, err
+	}
+	b, err := u.get(ctx, base+".json.sig", 1<<10)
+	if err != nil {
+		return Manifest{}, err
+	}
+	sig, err := ParseSignature(b)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if !ed25519.Verify(u.Key, raw, sig) {
+		return Manifest{}, ErrBadSignature
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Manifest{}, fmt.Errorf("selfupdate: manifest: %w", err)
+	}
+	if m.GOOS != runtime.GOOS || m.GOARCH != runtime.GOARCH {
+		return Manifest{}, fmt.Errorf("selfupdate: manifest is for %s/%s, not %s/%s", m.GOOS, m.GOARCH, runtime.GOOS, runtime.GOARCH)
+	}
+	sum, err := hex.DecodeString(m.SHA256)
+	if err != nil || len(sum) != sha256.Size {
+		return Manifest{}, errors.New("selfupdate: manifest: malformed sha256")
+	}
+	if len(m.Signature) != ed25519.SignatureSize || !ed25519.Verify(u.Key, sum, m.Signature) {
+		return Manifest{}, ErrBadSignature
+	}
+	return m, nil
+}
+
+// compareVersions compares two semantic versions, with or without a
+// leading "v", as cmp.Compare does. Build metadata is ignored.
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range 3 {
+		if c := cmp.Compare(pa.core[i], pb.core[i]); c != 0 {
+			return c, nil
+		}
+	}
+	// A pre-release precedes its release.
+	switch {
+	case pa.pre == nil && pb.pre == nil:
+		return 0, nil
+	case pa.pre == nil:
+		return 1, nil
+	case pb.pre == nil:
+		return -1, nil
+	}
+	for i := 0; i < len(pa.pre) && i < len(pb.pre); i++ {
+		if c := comparePre(pa.pre[i], pb.pre[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return cmp.Compare(len(pa.pre), len(pb.pre)), nil
+}
+
+type version struct {
+	core [3]uint64
+	pre  []string
+}
+
+func parseVersion(v string) (version, error) {
+	s, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	var out version
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || hasPre && pre == "" {
+		return out, fmt.Errorf("selfupdate: %q is not a semantic version", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return out, fmt.Errorf("selfupdate: %q is not a semantic version", v)
+		}
+		out.core[i] = n
+	}
+	if hasPre {
+		out.pre = strings.Split(pre, ".")
+	}
+	return out, nil
+}
+
+// comparePre orders pre-release identifiers: numeric ones numerically and
+// before alphanumeric ones, which compare as strings.
+func comparePre(a, b string) int {
+	na, ea := strconv.ParseUint(a, 10, 64)
+	nb, eb := strconv.ParseUint(b, 10, 64)
+	switch {
+	case ea == nil && eb == nil:
+		return cmp.Compare(na, nb)
+	case ea == nil:
+		return -1
+	case eb == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// download fetches the release into a temporary file next to exe and
+// returns its name and SHA-256 digest.
+func (u *Updater) download(ctx context.Context, url, exe string, perm os.FileMode) (string, []byte, error) {
+	resp, err := u.do(ctx, url)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	f, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".new-*")
+	if err != nil {
+		return "", nil, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(resp.Body, maxBinaryBytes+1))
+	if err == nil && n > maxBinaryBytes {
+		err = errors.New("selfupdate: release too large")
+	}
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	return f.Name(), h.Sum(nil), nil
+}
+
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	resp, err := u.do(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+func (u *Updater) do(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := u.HTTPClient
+	if c == nil {
+		c = httpclient.Shared()
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("selfupdate: %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func (u *Updater) executable() (string, error) {
+	if u.Executable != "" {
+		return u.Executable, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// ParseSignature accepts a raw 64-byte signature or its base64 encoding.
+func ParseSignature(b []byte) ([]byte, error) {
+	if len(b) == ed25519.SignatureSize {
+		return b, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("selfupdate: malformed signature")
+	}
+	return sig, nil
+}
+
+// writeFile replaces name atomically.
+func writeFile(name string, b []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,183 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// channel serves a release of bin signed with key, with m adjusted by
+// edit before signing.
+func channel(t *testing.T, key ed25519.PrivateKey, bin []byte, version string, edit func(*Manifest)) string {
+	t.Helper()
+	sum := sha256.Sum256(bin)
+	m := Manifest{Version: version, GOOS: runtime.GOOS, GOARCH: runtime.GOARCH,
+		SHA256: hex.EncodeToString(sum[:]), Signature: ed25519.Sign(key, sum[:])}
+	if edit != nil {
+		edit(&m)
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"/" + Artifact():               bin,
+		"/" + Artifact() + ".json":     raw,
+		"/" + Artifact() + ".json.sig": []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw))),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func setup(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return key, exe
+}
+
+func updater(key ed25519.PrivateKey, url, exe string) *Updater {
+	return &Updater{URL: url, Key: key.Public().(ed25519.PublicKey), Executable: exe, Version: "v1.2.0"}
+}
+
+func content(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestUpdateInstallsNewer(t *testing.T) {
+	key, exe := setup(t)
+	u := updater(key, channel(t, key, []byte("new"), "v1.3.0", nil), exe)
+	if ok, err := u.Update(context.Background()); !ok || err != nil {
+		t.Fatalf("Update = %v, %v", ok, err)
+	}
+	if content(t, exe) != "new" || content(t, exe+".old") != "old" {
+		t.Error("release not installed")
+	}
+	sig, err := ParseSignature([]byte(content(t, exe+".sig")))
+	sum := sha256.Sum256([]byte("new"))
+	if err != nil || !ed25519.Verify(u.Key, sum[:], sig) {
+		t.Errorf("installed signature does not verify the binary: %v", err)
+	}
+}
+
+func TestUpdateRefusesDowngrade(t *testing.T) {
+	key, exe := setup(t)
+	u := updater(key, channel(t, key, []byte("new"), "v1.1.9", nil), exe)
+	if _, err := u.Update(context.Background()); !errors.Is(err, ErrDowngrade) {
+		t.Fatalf("Update = %v, want ErrDowngrade", err)
+	}
+	u = updater(key, channel(t, key, []byte("new"), "v1.2.0", nil), exe)
+	if ok, err := u.Update(context.Background()); ok || err != nil {
+		t.Fatalf("Update of the same version = %v, %v", ok, err)
+	}
+	if content(t, exe) != "old" {
+		t.Error("binary replaced")
+	}
+}
+
+func TestUpdateRejects(t *testing.T) {
+	for name, tc := range map[string]struct {
+		signer func(own ed25519.PrivateKey) ed25519.PrivateKey
+		edit   func(*Manifest)
+		bin    string
+	}{
+		"untrusted key": {signer: func(ed25519.PrivateKey) ed25519.PrivateKey {
+			_, k, _ := ed25519.GenerateKey(rand.Reader)
+			return k
+		}},
+		"other platform":       {edit: func(m *Manifest) { m.GOARCH = "pdp11" }},
+		"digest mismatch":      {bin: "tampered"},
+		"bad digest signature": {edit: func(m *Manifest) { m.Signature[0] ^= 1 }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			key, exe := setup(t)
+			signer := key
+			if tc.signer != nil {
+				signer = tc.signer(key)
+			}
+			url := channel(t, signer, []byte("new"), "v1.3.0", tc.edit)
+			if tc.bin != "" {
+				// Serve other bytes under the manifest of "new".
+				sum := sha256.Sum256([]byte("new"))
+				url = channel(t, signer, []byte(tc.bin), "v1.3.0", func(m *Manifest) {
+					m.SHA256, m.Signature = hex.EncodeToString(sum[:]), ed25519.Sign(signer, sum[:])
+				})
+			}
+			if ok, err := updater(key, url, exe).Update(context.Background()); ok || err == nil {
+				t.Fatalf("Update = %v, %v", ok, err)
+			}
+			if content(t, exe) != "old" {
+				t.Error("binary replaced")
+			}
+		})
+	}
+}
+
+func TestUpdateRollsBack(t *testing.T) {
+	key, exe := setup(t)
+	if err := os.WriteFile(exe+".sig", []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	u := updater(key, channel(t, key, []byte("new"), "v1.3.0", nil), exe)
+	u.Check = func(context.Context, string) error { return errors.New("broken") }
+	if ok, err := u.Update(context.Background()); ok || !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Update = %v, %v", ok, err)
+	}
+	if content(t, exe) != "old" || content(t, exe+".sig") != "previous" {
+		t.Error("previous release not restored")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v1.2.3-rc.1", "v1.2.3", -1},
+		{"v1.2.3-rc.2", "v1.2.3-rc.10", -1},
+		{"v1.2.3-alpha", "v1.2.3-1", 1},
+		{"v0.0.0-20261001120000-abcdef012345", "v0.0.0-20260901120000-abcdef012345", 1},
+		{"v1.2.3+dirty", "v1.2.3", 0},
+	} {
+		if got, err := compareVersions(tc.a, tc.b); err != nil || got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, %v, want %d", tc.a, tc.b, got, err, tc.want)
+		}
+	}
+	for _, v := range []string{"(devel)", "v1.2", "v1.2.x", "v1.2.3-"} {
+		if _, err := compareVersions(v, "v1.0.0"); err == nil {
+			t.Errorf("compareVersions(%q) accepted", v)
+		}
+	}
+}
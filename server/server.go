@@ -0,0 +1,87 @@
+// Package server exposes the snapshot over HTTP.
+//
+// Endpoints:
+//
+//	GET /snapshot  snapshot JSON, or a signed envelope when a signer is set
+//	GET /hash      fingerprint hash as plain text
+//
+// Clients may send a challenge in the X-LSF-Nonce header; the returned
+// envelope is then signed over the snapshot and that nonce, which lets the
+// caller reject replayed responses.
+//
+// Snapshot responses are compressed with zstd or gzip according to the
+// request's Accept-Encoding header.
+package server
+
+import (
+	"crypto"
+	"encoding/json"
+	"net/http"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/httpenc"
+)
+
+// Config configures the handler.
+type Config struct {
+	// Snapshot collects the snapshot; fingerprint.GetSnapshot when nil.
+	Snapshot func() fingerprint.Snapshot
+	// Signer signs responses when set.
+	Signer crypto.Signer
+	// RequireNonce rejects snapshot requests without a nonce header.
+	RequireNonce bool
+}
+
+// Handler returns the HTTP handler for cfg.
+func Handler(cfg Config) http.Handler {
+	if cfg.Snapshot == nil {
+		cfg.Snapshot = func() fingerprint.Snapshot { return fingerprint.GetSnapshot() }
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /snapshot", cfg.serveSnapshot)
+	mux.HandleFunc("GET /hash", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(cfg.Snapshot().Hash() + "\n"))
+	})
+	return mux
+}
+
+func (cfg Config) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	nonce := r.Header.Get(fingerprint.NonceHeader)
+	switch {
+	case nonce == "" && cfg.RequireNonce:
+		http.Error(w, "missing "+fingerprint.NonceHeader+" header", http.StatusBadRequest)
+		return
+	case nonce != "" && cfg.Signer == nil:
+		http.Error(w, "nonce requires a signing key", http.StatusNotImplemented)
+		return
+	}
+	var v any = cfg.Snapshot()
+	if cfg.Signer != nil {
+		ss, err := fingerprint.SignNonce(cfg.Signer, v.(fingerprint.Snapshot), nonce)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		v = ss
+		if nonce != "" {
+			w.Header().Set(fingerprint.NonceHeader, nonce)
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	coding := httpenc.Negotiate(r.Header.Get("Accept-Encoding"))
+	if b, err = httpenc.Encode(coding, append(b, '\n')); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if coding != httpenc.Identity {
+		w.Header().Set("Content-Encoding", coding)
+	}
+	w.Write(b)
+}
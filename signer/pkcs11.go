@@ -0,0 +1,250 @@
+//go:build pkcs11
+
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+var oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+
+// sha256DigestInfo is the DER prefix of a PKCS#1 v1.5 SHA-256 DigestInfo.
+var sha256DigestInfo = []byte{0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20}
+
+type pkcs11Key struct {
+	mu   sync.Mutex
+	p    *pkcs11.Ctx
+	sess pkcs11.SessionHandle
+	priv pkcs11.ObjectHandle
+	pub  crypto.PublicKey
+}
+
+// openPKCS11 resolves an RFC 7512 URI. The token is selected by "token" or
+// "serial", the key by "object" (label) and/or "id".
+func openPKCS11(uri string) (Key, error) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(uri, "pkcs11:"), "?")
+	attrs := map[string]string{}
+	for _, kv := range strings.Split(path, ";") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		dv, err := url.PathUnescape(v)
+		if err != nil {
+			return nil, fmt.Errorf("signer: pkcs11 uri: %w", err)
+		}
+		attrs[k] = dv
+	}
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("signer: pkcs11 uri: %w", err)
+	}
+	pin := q.Get("pin-value")
+	if src := q.Get("pin-source"); src != "" {
+		b, err := os.ReadFile(strings.TrimPrefix(src, "file:"))
+		if err != nil {
+			return nil, err
+		}
+		pin = strings.TrimSpace(string(b))
+	}
+	module := q.Get("module-path")
+	if module == "" {
+		return nil, errors.New("signer: pkcs11 uri needs module-path")
+	}
+	p := pkcs11.New(module)
+	if p == nil {
+		return nil, fmt.Errorf("signer: cannot load pkcs11 module %s", module)
+	}
+	if err := p.Initialize(); err != nil {
+		p.Destroy()
+		return nil, fmt.Errorf("signer: pkcs11 initialize: %w", err)
+	}
+	k, err := openPKCS11Key(p, attrs, pin)
+	if err != nil {
+		p.Finalize()
+		p.Destroy()
+		return nil, err
+	}
+	return k, nil
+}
+
+func openPKCS11Key(p *pkcs11.Ctx, attrs map[string]string, pin string) (*pkcs11Key, error) {
+	slots, err := p.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("signer: pkcs11 slots: %w", err)
+	}
+	for _, slot := range slots {
+		ti, err := p.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if l, ok := attrs["token"]; ok && strings.TrimSpace(ti.Label) != l {
+			continue
+		}
+		if s, ok := attrs["serial"]; ok && strings.TrimSpace(ti.SerialNumber) != s {
+			continue
+		}
+		sess, err := p.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return nil, fmt.Errorf("signer: pkcs11 session: %w", err)
+		}
+		if pin != "" {
+			if err := p.Login(sess, pkcs11.CKU_USER, pin); err != nil {
+				p.CloseSession(sess)
+				return nil, fmt.Errorf("signer: pkcs11 login: %w", err)
+			}
+		}
+		k := &pkcs11Key{p: p, sess: sess}
+		if err := k.load(attrs); err != nil {
+			p.CloseSession(sess)
+			return nil, err
+		}
+		return k, nil
+	}
+	return nil, errors.New("signer: pkcs11 token not found")
+}
+
+func (k *pkcs11Key) find(class uint, attrs map[string]string) (pkcs11.ObjectHandle, error) {
+	tmpl := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, class)}
+	if v, ok := attrs["object"]; ok {
+		tmpl = append(tmpl, pkcs11.NewAttribute(pkcs11.CKA_LABEL, v))
+	}
+	if v, ok := attrs["id"]; ok {
+		tmpl = append(tmpl, pkcs11.NewAttribute(pkcs11.CKA_ID, []byte(v)))
+	}
+	if err := k.p.FindObjectsInit(k.sess, tmpl); err != nil {
+		return 0, err
+	}
+	objs, _, err := k.p.FindObjects(k.sess, 1)
+	k.p.FindObjectsFinal(k.sess)
+	if err != nil {
+		return 0, err
+	}
+	if len(objs) == 0 {
+		return 0, errors.New("signer: pkcs11 object not found")
+	}
+	return objs[0], nil
+}
+
+func (k *pkcs11Key) load(attrs map[string]string) error {
+	var err error
+	if k.priv, err = k.find(pkcs11.CKO_PRIVATE_KEY, attrs); err != nil {
+		return err
+	}
+	pubObj, err := k.find(pkcs11.CKO_PUBLIC_KEY, attrs)
+	if err != nil {
+		return err
+	}
+	kt, err := k.p.GetAttributeValue(k.sess, k.priv, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)})
+	if err != nil || len(kt) != 1 {
+		return fmt.Errorf("signer: pkcs11 key type: %v", err)
+	}
+	switch bytesToUint(kt[0].Value) {
+	case pkcs11.CKK_EC:
+		k.pub, err = k.ecPublic(pubObj)
+	case pkcs11.CKK_RSA:
+		k.pub, err = k.rsaPublic(pubObj)
+	default:
+		err = errors.New("signer: unsupported pkcs11 key type")
+	}
+	return err
+}
+
+func (k *pkcs11Key) ecPublic(obj pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	a, err := k.p.GetAttributeValue(k.sess, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(a[0].Value, &oid); err != nil || !oid.Equal(oidP256) {
+		return nil, errors.New("signer: only P-256 pkcs11 keys are supported")
+	}
+	var point []byte
+	if _, err := asn1.Unmarshal(a[1].Value, &point); err != nil {
+		point = a[1].Value
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		return nil, errors.New("signer: malformed pkcs11 EC point")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+func (k *pkcs11Key) rsaPublic(obj pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	a, err := k.p.GetAttributeValue(k.sess, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(a[0].Value),
+		E: int(new(big.Int).SetBytes(a[1].Value).Int64()),
+	}, nil
+}
+
+func (k *pkcs11Key) Public() crypto.PublicKey { return k.pub }
+
+func (k *pkcs11Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, errors.New("signer: pkcs11 keys only sign SHA-256 digests")
+	}
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("signer: RSA-PSS is not supported")
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	switch k.pub.(type) {
+	case *ecdsa.PublicKey:
+		if err := k.p.SignInit(k.sess, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, k.priv); err != nil {
+			return nil, err
+		}
+		raw, err := k.p.Sign(k.sess, digest)
+		if err != nil {
+			return nil, err
+		}
+		n := len(raw) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(raw[:n]),
+			new(big.Int).SetBytes(raw[n:]),
+		})
+	default:
+		if err := k.p.SignInit(k.sess, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}, k.priv); err != nil {
+			return nil, err
+		}
+		return k.p.Sign(k.sess, append(append([]byte{}, sha256DigestInfo...), digest...))
+	}
+}
+
+func (k *pkcs11Key) Close() error {
+	k.p.CloseSession(k.sess)
+	k.p.Finalize()
+	k.p.Destroy()
+	return nil
+}
+
+func bytesToUint(b []byte) uint {
+	var v uint
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint(b[i])
+	}
+	return v
+}
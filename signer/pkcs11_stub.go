@@ -0,0 +1,9 @@
+//go:build !pkcs11
+
+package signer
+
+import "errors"
+
+func openPKCS11(string) (Key, error) {
+	return nil, errors.New("signer: pkcs11 support not built in (rebuild with -tags pkcs11)")
+}
@@ -0,0 +1,68 @@
+// Package signer resolves the snapshot signing key from a URI so the key
+// can live in a file, a TPM or a PKCS#11 token.
+//
+// Supported forms:
+//
+//	/path/to/key.pem                  PKCS#8 PEM private key
+//	file:///path/to/key.pem           same as above
+//	tpm://0x81000010                  persistent TPM 2.0 ECC key
+//	tpm://0x81000010?create=1         create and persist the key when absent
+//	pkcs11:token=hsm;object=agent?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234
+//
+// Keys held in hardware never leave the device, so a compromised host cannot
+// copy the agent's signing identity to another machine.
+package signer
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Key is a signing key that may hold an open device handle.
+type Key interface {
+	crypto.Signer
+	Close() error
+}
+
+// Open returns the signing key described by uri.
+func Open(uri string) (Key, error) {
+	switch {
+	case strings.HasPrefix(uri, "tpm://"):
+		return openTPM(strings.TrimPrefix(uri, "tpm://"))
+	case strings.HasPrefix(uri, "pkcs11:"):
+		return openPKCS11(uri)
+	case strings.HasPrefix(uri, "file://"):
+		return openFile(strings.TrimPrefix(uri, "file://"))
+	}
+	return openFile(uri)
+}
+
+type fileKey struct {
+	crypto.Signer
+}
+
+func (fileKey) Close() error { return nil }
+
+func openFile(path string) (Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, fmt.Errorf("signer: %s: no PEM data", path)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signer: %s: %w", path, err)
+	}
+	s, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signer: %s: unsupported key type %T", path, k)
+	}
+	return fileKey{s}, nil
+}
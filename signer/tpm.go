@@ -0,0 +1,162 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+type tpmKey struct {
+	tpm    transport.TPMCloser
+	handle tpm2.TPMHandle
+	name   tpm2.TPM2BName
+	pub    crypto.PublicKey
+}
+
+func openTPM(spec string) (Key, error) {
+	loc, query, _ := strings.Cut(spec, "?")
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("signer: tpm uri: %w", err)
+	}
+	h, err := strconv.ParseUint(loc, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("signer: tpm handle %q: %w", loc, err)
+	}
+	dev := q.Get("device")
+	if dev == "" {
+		dev = "/dev/tpmrm0"
+		if _, err := os.Stat(dev); errors.Is(err, os.ErrNotExist) {
+			dev = "/dev/tpm0"
+		}
+	}
+	t, err := openTPMDevice(dev)
+	if err != nil {
+		return nil, err
+	}
+	k, err := loadTPMKey(t, tpm2.TPMHandle(h), q.Get("create") == "1")
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+func loadTPMKey(t transport.TPMCloser, h tpm2.TPMHandle, create bool) (*tpmKey, error) {
+	rp, err := tpm2.ReadPublic{ObjectHandle: h}.Execute(t)
+	if err != nil {
+		if !create {
+			return nil, fmt.Errorf("signer: read tpm key 0x%x: %w", uint32(h), err)
+		}
+		if err := createTPMKey(t, h); err != nil {
+			return nil, err
+		}
+		if rp, err = (tpm2.ReadPublic{ObjectHandle: h}).Execute(t); err != nil {
+			return nil, err
+		}
+	}
+	pt, err := rp.OutPublic.Contents()
+	if err != nil {
+		return nil, err
+	}
+	pub, err := tpm2.Pub(*pt)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := pub.(*ecdsa.PublicKey); !ok {
+		return nil, fmt.Errorf("signer: tpm key 0x%x is not an ECC key", uint32(h))
+	}
+	return &tpmKey{tpm: t, handle: h, name: rp.Name, pub: pub}, nil
+}
+
+func createTPMKey(t transport.TPM, h tpm2.TPMHandle) error {
+	cp, err := tpm2.CreatePrimary{
+		PrimaryHandle: tpm2.TPMRHOwner,
+		InPublic:      tpm2.New2B(tpmKeyTemplate),
+	}.Execute(t)
+	if err != nil {
+		return fmt.Errorf("signer: create tpm key: %w", err)
+	}
+	defer tpm2.FlushContext{FlushHandle: cp.ObjectHandle}.Execute(t)
+	_, err = tpm2.EvictControl{
+		Auth:             tpm2.TPMRHOwner,
+		ObjectHandle:     tpm2.NamedHandle{Handle: cp.ObjectHandle, Name: cp.Name},
+		PersistentHandle: h,
+	}.Execute(t)
+	if err != nil {
+		return fmt.Errorf("signer: persist tpm key: %w", err)
+	}
+	return nil
+}
+
+func (k *tpmKey) Public() crypto.PublicKey { return k.pub }
+
+// Sign signs a SHA-256 digest and returns an ASN.1 ECDSA signature as
+// required by crypto.Signer.
+func (k *tpmKey) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, errors.New("signer: tpm keys only sign SHA-256 digests")
+	}
+	rsp, err := tpm2.Sign{
+		KeyHandle: tpm2.NamedHandle{Handle: k.handle, Name: k.name},
+		Digest:    tpm2.TPM2BDigest{Buffer: digest},
+		InScheme: tpm2.TPMTSigScheme{
+			Scheme: tpm2.TPMAlgECDSA,
+			Details: tpm2.NewTPMUSigScheme(
+				tpm2.TPMAlgECDSA,
+				&tpm2.TPMSSchemeHash{HashAlg: tpm2.TPMAlgSHA256},
+			),
+		},
+		Validation: tpm2.TPMTTKHashCheck{Tag: tpm2.TPMSTHashCheck, Hierarchy: tpm2.TPMRHNull},
+	}.Execute(k.tpm)
+	if err != nil {
+		return nil, fmt.Errorf("signer: tpm sign: %w", err)
+	}
+	es, err := rsp.Signature.Signature.ECDSA()
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(es.SignatureR.Buffer),
+		new(big.Int).SetBytes(es.SignatureS.Buffer),
+	})
+}
+
+func (k *tpmKey) Close() error { return k.tpm.Close() }
+
+var tpmKeyTemplate = tpm2.TPMTPublic{
+	Type:    tpm2.TPMAlgECC,
+	NameAlg: tpm2.TPMAlgSHA256,
+	ObjectAttributes: tpm2.TPMAObject{
+		FixedTPM:            true,
+		FixedParent:         true,
+		SensitiveDataOrigin: true,
+		UserWithAuth:        true,
+		NoDA:                true,
+		SignEncrypt:         true,
+	},
+	Parameters: tpm2.NewTPMUPublicParms(
+		tpm2.TPMAlgECC,
+		&tpm2.TPMSECCParms{
+			Scheme: tpm2.TPMTECCScheme{
+				Scheme: tpm2.TPMAlgECDSA,
+				Details: tpm2.NewTPMUAsymScheme(
+					tpm2.TPMAlgECDSA,
+					&tpm2.TPMSSigSchemeECDSA{HashAlg: tpm2.TPMAlgSHA256},
+				),
+			},
+			CurveID: tpm2.TPMECCNistP256,
+		},
+	),
+}
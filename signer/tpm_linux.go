@@ -0,0 +1,10 @@
+package signer
+
+import (
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/linuxtpm"
+)
+
+func openTPMDevice(dev string) (transport.TPMCloser, error) {
+	return linuxtpm.Open(dev)
+}
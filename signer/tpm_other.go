@@ -0,0 +1,13 @@
+//go:build !linux
+
+package signer
+
+import (
+	"errors"
+
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+func openTPMDevice(string) (transport.TPMCloser, error) {
+	return nil, errors.New("signer: tpm keys need Linux")
+}
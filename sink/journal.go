@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"AurFingerprintAgent/fdcap"
+	"AurFingerprintAgent/fingerprint"
+)
+
+// JournalSocket is where journald accepts native protocol datagrams.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// Journal logs a summary of each snapshot to the systemd journal, with the
+// full document in the SNAPSHOT field, so that journal forwarders pick up
+// inventory without an extra channel.
+type Journal struct {
+	// Identifier is the SYSLOG_IDENTIFIER; "linuxsystemfingerprint" when
+	// empty.
+	Identifier string
+	// Socket overrides JournalSocket.
+	Socket string
+}
+
+func (j Journal) Write(ctx context.Context, s fingerprint.Snapshot) error {
+	doc, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	id := j.Identifier
+	if id == "" {
+		id = "linuxsystemfingerprint"
+	}
+	var b bytes.Buffer
+	field(&b, "MESSAGE", "fingerprint snapshot "+s.Hash())
+	field(&b, "PRIORITY", "6")
+	field(&b, "SYSLOG_IDENTIFIER", id)
+	field(&b, "FINGERPRINT_HASH", s.Hash())
+	field(&b, "FINGERPRINT_ID", s.ID())
+	field(&b, "SNAPSHOT", string(doc))
+
+	path := j.Socket
+	if path == "" {
+		path = JournalSocket
+	}
+	c, err := fdcap.Default.DialContext(ctx, &net.Dialer{}, "unixgram", path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err = c.Write(b.Bytes()); tooLarge(err) {
+		// journald takes entries above the datagram limit from a sealed
+		// memfd passed over the socket.
+		return writeMemfd(path, b.Bytes())
+	}
+	return err
+}
+
+// field appends a journal field, in the binary form when the value holds a
+// newline.
+func field(b *bytes.Buffer, k, v string) {
+	b.WriteString(k)
+	if !strings.Contains(v, "\n") {
+		b.WriteByte('=')
+		b.WriteString(v)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(v)))
+	b.WriteString(v)
+	b.WriteByte('\n')
+}
@@ -0,0 +1,43 @@
+package sink
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// tooLarge reports a datagram over the socket's size limit.
+func tooLarge(err error) bool {
+	return errors.Is(err, unix.EMSGSIZE) || errors.Is(err, unix.ENOBUFS)
+}
+
+// writeMemfd sends entry to the journal socket at path as a sealed memfd.
+func writeMemfd(path string, entry []byte) error {
+	return fdcap.Default.Do(func() error {
+		fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+		if err != nil {
+			return &os.SyscallError{Syscall: "memfd_create", Err: err}
+		}
+		f := os.NewFile(uintptr(fd), "journal-entry")
+		defer f.Close()
+		if _, err := f.Write(entry); err != nil {
+			return err
+		}
+		if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+			return &os.SyscallError{Syscall: "fcntl", Err: err}
+		}
+		// An unconnected, autobound socket: Go refuses sendmsg with rights
+		// on a connected datagram socket.
+		c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		_, _, err = c.WriteMsgUnix(nil, unix.UnixRights(int(f.Fd())), &net.UnixAddr{Name: path, Net: "unixgram"})
+		return err
+	})
+}
@@ -0,0 +1,96 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+// journald listens on a datagram socket standing in for journald and
+// returns the entries it receives, read from a memfd when one is passed.
+func journald(t *testing.T) (path string, entries <-chan []byte) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "socket")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	ch := make(chan []byte, 1)
+	go func() {
+		buf, oob := make([]byte, 1<<16), make([]byte, 64)
+		n, oobn, _, _, err := l.ReadMsgUnix(buf, oob)
+		if err != nil {
+			return
+		}
+		if oobn == 0 {
+			ch <- buf[:n]
+			return
+		}
+		msgs, _ := unix.ParseSocketControlMessage(oob[:oobn])
+		if len(msgs) != 1 {
+			return
+		}
+		fds, _ := unix.ParseUnixRights(&msgs[0])
+		if len(fds) != 1 {
+			return
+		}
+		f := os.NewFile(uintptr(fds[0]), "memfd")
+		defer f.Close()
+		if seals, _ := unix.FcntlInt(f.Fd(), unix.F_GET_SEALS, 0); seals&unix.F_SEAL_WRITE == 0 {
+			return
+		}
+		// The offset is shared with the sender, so read from the start.
+		st, err := f.Stat()
+		if err != nil {
+			return
+		}
+		b, _ := io.ReadAll(io.NewSectionReader(f, 0, st.Size()))
+		ch <- b
+	}()
+	return path, ch
+}
+
+// fields parses the journal native protocol.
+func fields(b []byte) map[string]string {
+	m := map[string]string{}
+	for len(b) > 0 {
+		ln, rest, _ := bytes.Cut(b, []byte("\n"))
+		if k, v, ok := bytes.Cut(ln, []byte("=")); ok {
+			m[string(k)], b = string(v), rest
+			continue
+		}
+		n := binary.LittleEndian.Uint64(rest)
+		m[string(ln)], b = string(rest[8:8+n]), rest[8+n+1:]
+	}
+	return m
+}
+
+func TestJournal(t *testing.T) {
+	for _, size := range []int{0, 4 << 20} {
+		path, entries := journald(t)
+		snap := fingerprint.Snapshot{MachineID: "4c4c4544004d3110", Hostname: strings.Repeat("x", size)}
+		if err := (Journal{Socket: path}).Write(context.Background(), snap); err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		m := fields(<-entries)
+		if m["FINGERPRINT_HASH"] != snap.Hash() || m["SYSLOG_IDENTIFIER"] != "linuxsystemfingerprint" {
+			t.Errorf("%d bytes: entry %q", size, m["MESSAGE"])
+		}
+		var got fingerprint.Snapshot
+		if err := json.Unmarshal([]byte(m["SNAPSHOT"]), &got); err != nil || got.Hostname != snap.Hostname {
+			t.Errorf("%d bytes: SNAPSHOT does not decode: %v", size, err)
+		}
+	}
+}
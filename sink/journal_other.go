@@ -0,0 +1,11 @@
+//go:build !linux
+
+package sink
+
+import "errors"
+
+func tooLarge(error) bool { return false }
+
+func writeMemfd(string, []byte) error {
+	return errors.New("journal: memfd entries need Linux")
+}
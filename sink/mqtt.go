@@ -0,0 +1,189 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+	"AurFingerprintAgent/fingerprint"
+)
+
+// MQTT publishes each snapshot as a JSON message with MQTT 3.1.1, on a
+// fresh connection per snapshot since snapshots are infrequent.
+type MQTT struct {
+	// Broker is host:port; port 1883, or 8883 with TLS, when omitted.
+	Broker string
+	TLS    bool
+	Topic  string
+	// ClientID defaults to "lsf-" and the snapshot's device ID.
+	ClientID           string
+	Username, Password string
+	// QoS 1 waits for the broker to acknowledge the message.
+	QoS    byte
+	Retain bool
+}
+
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttDisconnect = 14
+)
+
+func (m *MQTT) Write(ctx context.Context, s fingerprint.Snapshot) error {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	addr := m.Broker
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		port := "1883"
+		if m.TLS {
+			port = "8883"
+		}
+		addr = net.JoinHostPort(addr, port)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+	}
+	var conn net.Conn
+	conn, err = fdcap.Default.DialContext(ctx, &net.Dialer{}, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if m.TLS {
+		host, _, _ := net.SplitHostPort(addr)
+		tc := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("mqtt: %w", err)
+		}
+		conn = tc
+	}
+	r := bufio.NewReader(conn)
+
+	id := m.ClientID
+	if id == "" {
+		id = "lsf-" + s.ID()
+	}
+	// Variable header: protocol name, level 4, flags, keep-alive.
+	var flags byte = 0x02 // clean session
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, 0, 0, 60)
+	body = mqttString(body, id)
+	if m.Username != "" {
+		flags |= 0x80
+		body = mqttString(body, m.Username)
+		if m.Password != "" {
+			flags |= 0x40
+			body = mqttString(body, m.Password)
+		}
+	}
+	body[7] = flags
+	if err := mqttWrite(conn, mqttConnect<<4, body); err != nil {
+		return err
+	}
+	typ, ack, err := mqttRead(r)
+	if err != nil {
+		return err
+	}
+	if typ != mqttConnAck || len(ack) != 2 {
+		return errors.New("mqtt: unexpected answer to CONNECT")
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused (code %d)", ack[1])
+	}
+
+	hdr := byte(mqttPublish<<4) | m.QoS<<1
+	if m.Retain {
+		hdr |= 1
+	}
+	body = mqttString(nil, m.Topic)
+	if m.QoS > 0 {
+		body = append(body, 0, 1) // packet identifier
+	}
+	if err := mqttWrite(conn, hdr, append(body, payload...)); err != nil {
+		return err
+	}
+	if m.QoS > 0 {
+		typ, ack, err := mqttRead(r)
+		if err != nil {
+			return err
+		}
+		if typ != mqttPubAck || len(ack) != 2 || binary.BigEndian.Uint16(ack) != 1 {
+			return errors.New("mqtt: unexpected answer to PUBLISH")
+		}
+	}
+	return mqttWrite(conn, mqttDisconnect<<4, nil)
+}
+
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttWrite sends a control packet.
+func mqttWrite(w io.Writer, hdr byte, body []byte) error {
+	pkt := []byte{hdr}
+	n := len(body)
+	if n > 268435455 {
+		return errors.New("mqtt: message too large")
+	}
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		pkt = append(pkt, d)
+		if n == 0 {
+			break
+		}
+	}
+	if _, err := w.Write(append(pkt, body...)); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return nil
+}
+
+// mqttRead receives a control packet and returns its type and body.
+func mqttRead(r *bufio.Reader) (byte, []byte, error) {
+	hdr, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("mqtt: %w", err)
+	}
+	n, mul := 0, 1
+	for i := 0; ; i++ {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, fmt.Errorf("mqtt: %w", err)
+		}
+		n += int(d&0x7f) * mul
+		if d&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("mqtt: malformed packet length")
+		}
+		mul *= 128
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, fmt.Errorf("mqtt: %w", err)
+	}
+	return hdr >> 4, body, nil
+}
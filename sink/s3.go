@@ -0,0 +1,164 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/httpclient"
+)
+
+// S3 uploads each snapshot to an S3 compatible bucket, signed with AWS
+// Signature Version 4. Credentials come from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN unless set.
+type S3 struct {
+	Bucket string
+	// Key is the object key. A key ending in "/" or an empty key is a
+	// prefix, to which the device ID and ".json" are appended so that every
+	// machine keeps one current object.
+	Key string
+	// Region defaults to AWS_REGION, then us-east-1.
+	Region string
+	// Endpoint is the service URL for S3 compatible stores, used with
+	// path-style addressing; AWS virtual-hosted style when empty.
+	Endpoint string
+
+	AccessKey, SecretKey, SessionToken string
+	// HTTPClient is used for requests; httpclient.Shared() when nil.
+	HTTPClient *http.Client
+}
+
+func (s S3) Write(ctx context.Context, snap fingerprint.Snapshot) error {
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	key := s.Key
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += snap.ID() + ".json"
+	}
+	region := first(s.Region, os.Getenv("AWS_REGION"), "us-east-1")
+	access := first(s.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secret := first(s.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	token := first(s.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	if access == "" || secret == "" {
+		return fmt.Errorf("s3: no credentials for bucket %s", s.Bucket)
+	}
+
+	var u *url.URL
+	if s.Endpoint != "" {
+		if u, err = url.Parse(strings.TrimSuffix(s.Endpoint, "/")); err != nil {
+			return fmt.Errorf("s3: %w", err)
+		}
+		u.Path += "/" + s.Bucket + "/" + key
+	} else {
+		u = &url.URL{Scheme: "https", Host: s.Bucket + ".s3." + region + ".amazonaws.com", Path: "/" + key}
+	}
+	// Send the path as signed; url.URL would leave characters such as
+	// "$" or "+" unescaped.
+	u.RawPath = escapePath(u.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	sigV4(req, body, region, access, secret, token, time.Now())
+
+	c := s.HTTPClient
+	if c == nil {
+		c = httpclient.Shared()
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("s3: PUT %s: %s: %s", u.Redacted(), resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// sigV4 signs req for the s3 service, covering the host and every header
+// set on req.
+func sigV4(req *http.Request, body []byte, region, access, secret, token string, now time.Time) {
+	now = now.UTC()
+	stamp, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	names := []string{"host"}
+	for n := range req.Header {
+		names = append(names, strings.ToLower(n))
+	}
+	slices.Sort(names)
+	var canon strings.Builder
+	for _, n := range names {
+		v := req.Header.Get(n)
+		if n == "host" {
+			v = req.URL.Host
+		}
+		canon.WriteString(n + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	creq := strings.Join([]string{req.Method, escapePath(req.URL.Path), req.URL.RawQuery, canon.String(), signed, payload}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	h := sha256.Sum256([]byte(creq))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+
+	k := hmacSHA256([]byte("AWS4"+secret), day)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(k, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+access+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+sig)
+}
+
+// escapePath URI-encodes each segment of p as SigV4 requires: everything
+// but letters, digits and "-._~" becomes %XX. S3 paths are encoded once.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+func first(v ...string) string {
+	for _, s := range v {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+// Package sink delivers snapshots to their destinations: standard output,
+// files, HTTP endpoints, MQTT brokers, S3 buckets and the systemd journal.
+// Embedders can supply their own by implementing Sink.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/push"
+)
+
+// Sink receives snapshots.
+type Sink interface {
+	Write(ctx context.Context, s fingerprint.Snapshot) error
+}
+
+// Func adapts a function to the Sink interface.
+type Func func(ctx context.Context, s fingerprint.Snapshot) error
+
+func (f Func) Write(ctx context.Context, s fingerprint.Snapshot) error { return f(ctx, s) }
+
+// Multi returns a sink writing to every sink in turn. A failing sink does
+// not keep the snapshot from the others; the errors are joined.
+func Multi(sinks ...Sink) Sink {
+	return multi(sinks)
+}
+
+type multi []Sink
+
+func (m multi) Write(ctx context.Context, s fingerprint.Snapshot) error {
+	var errs []error
+	for _, k := range m {
+		if err := k.Write(ctx, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Writer writes each snapshot as one line of JSON to W.
+type Writer struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Stdout returns a sink printing to standard output.
+func Stdout() *Writer { return &Writer{W: os.Stdout} }
+
+func (w *Writer) Write(_ context.Context, s fingerprint.Snapshot) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.W.Write(append(b, '\n'))
+	return err
+}
+
+// File replaces the file at Path with each snapshot, so that readers never
+// see a partial document.
+type File struct {
+	Path string
+	// Mode is the file mode; 0644 when zero.
+	Mode os.FileMode
+}
+
+func (f File) Write(_ context.Context, s fingerprint.Snapshot) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	mode := f.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), "."+filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// HTTP pushes snapshots with a push client, without a nonce.
+type HTTP struct {
+	Client *push.Client
+}
+
+func (h HTTP) Write(ctx context.Context, s fingerprint.Snapshot) error {
+	return h.Client.Push(ctx, s, "")
+}
+
+// Parse returns the sink a spec names:
+//
+//	stdout, -                      Stdout
+//	file:/var/lib/lsf/snap.json    File (or any path starting with /)
+//	http://..., https://...        HTTP
+//	mqtt://[user:pass@]host[:port]/topic?qos=1&retain=1, mqtts://...
+//	s3://bucket/prefix/?region=eu-west-1&endpoint=https://minio:9000
+//	journal                        Journal
+func Parse(spec string) (Sink, error) {
+	switch {
+	case spec == "stdout" || spec == "-":
+		return Stdout(), nil
+	case spec == "journal":
+		return Journal{}, nil
+	case strings.HasPrefix(spec, "/"):
+		return File{Path: spec}, nil
+	case strings.HasPrefix(spec, "file:"):
+		return File{Path: strings.TrimPrefix(spec, "file:")}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("sink: %w", err)
+	}
+	q := u.Query()
+	switch u.Scheme {
+	case "http", "https":
+		return HTTP{Client: &push.Client{URL: spec}}, nil
+	case "mqtt", "mqtts":
+		m := &MQTT{Broker: u.Host, TLS: u.Scheme == "mqtts", Topic: strings.TrimPrefix(u.Path, "/"),
+			ClientID: q.Get("client_id"), Retain: q.Get("retain") == "1" || q.Get("retain") == "true"}
+		if u.User != nil {
+			m.Username = u.User.Username()
+			m.Password, _ = u.User.Password()
+		}
+		if v := q.Get("qos"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 1 {
+				return nil, fmt.Errorf("sink: %s: qos must be 0 or 1", spec)
+			}
+			m.QoS = byte(n)
+		}
+		if m.Topic == "" {
+			return nil, fmt.Errorf("sink: %s: no topic", spec)
+		}
+		return m, nil
+	case "s3":
+		s := S3{Bucket: u.Host, Key: strings.TrimPrefix(u.Path, "/"), Region: q.Get("region"), Endpoint: q.Get("endpoint")}
+		if s.Bucket == "" {
+			return nil, fmt.Errorf("sink: %s: no bucket", spec)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("sink: unknown sink %q", spec)
+}
@@ -0,0 +1,178 @@
+package smart
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// nvmePassthruCmd mirrors struct nvme_passthru_cmd.
+type nvmePassthruCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMS   uint32
+	result      uint32
+}
+
+// nvmeIoctlAdminCmd is _IOWR('N', 0x41, struct nvme_admin_cmd).
+const nvmeIoctlAdminCmd = 0xc0484e41
+
+// ReadNVMe fetches the SMART / Health Information log from an NVMe
+// controller character device such as /dev/nvme0.
+func ReadNVMe(dev string) (*NVMeLog, error) {
+	f, err := fdcap.Default.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	cmd := nvmePassthruCmd{
+		opcode:    0x02, // Get Log Page
+		nsid:      0xffffffff,
+		addr:      uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		dataLen:   uint32(len(buf)),
+		cdw10:     uint32(len(buf)/4-1)<<16 | 0x02, // NUMDL, SMART log
+		timeoutMS: 5000,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+	runtime.KeepAlive(buf)
+	if errno != 0 {
+		return nil, errno
+	}
+	return ParseNVMeLog(buf)
+}
+
+// sgIOHdr mirrors struct sg_io_hdr.
+type sgIOHdr struct {
+	interfaceID    int32
+	dxferDirection int32
+	cmdLen         uint8
+	mxSbLen        uint8
+	iovecCount     uint16
+	dxferLen       uint32
+	dxferp         unsafe.Pointer
+	cmdp           unsafe.Pointer
+	sbp            unsafe.Pointer
+	timeout        uint32
+	flags          uint32
+	packID         int32
+	usrPtr         unsafe.Pointer
+	status         uint8
+	maskedStatus   uint8
+	msgStatus      uint8
+	sbLenWr        uint8
+	hostStatus     uint16
+	driverStatus   uint16
+	resid          int32
+	duration       uint32
+	info           uint32
+}
+
+const (
+	sgIO          = 0x2285
+	sgDxferFromDv = -3
+)
+
+// ReadATA fetches SMART attributes and thresholds from an ATA disk (or a
+// SAT-capable bridge) such as /dev/sda.
+func ReadATA(dev string) ([]Attribute, error) {
+	f, err := fdcap.Default.OpenFile(dev, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ataPIORead(f.File, ataSMART, 0xd0)
+	if err != nil {
+		return nil, err
+	}
+	thresh, _ := ataPIORead(f.File, ataSMART, 0xd1)
+	return ParseATA(data, thresh)
+}
+
+// ReadATAIdentity issues IDENTIFY DEVICE to an ATA disk (or a SAT-capable
+// bridge) such as /dev/sda.
+func ReadATAIdentity(dev string) (*Identity, error) {
+	f, err := fdcap.Default.OpenFile(dev, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ataPIORead(f.File, ataIdentify, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ParseATAIdentity(data)
+}
+
+// ATA commands.
+const (
+	ataIdentify = 0xec
+	ataSMART    = 0xb0
+)
+
+// ataPIORead issues an ATA command with a single sector PIO data-in
+// transfer. SMART sub-commands are selected by feature.
+func ataPIORead(f *os.File, command, feature uint8) ([]byte, error) {
+	var lbaMid, lbaHigh uint8
+	if command == ataSMART {
+		lbaMid, lbaHigh = 0x4f, 0xc2
+	}
+	buf := make([]byte, 512)
+	sense := make([]byte, 32)
+	cdb := []byte{
+		0x85,       // ATA PASS-THROUGH (16)
+		4 << 1,     // PIO data-in
+		0x0e,       // T_DIR in, BYT_BLOK, T_LENGTH in sector count
+		0, feature, // features
+		0, 1, // sector count
+		0, 0, // LBA low
+		0, lbaMid, // LBA mid
+		0, lbaHigh, // LBA high
+		0,       // device
+		command, // command
+		0,
+	}
+	hdr := sgIOHdr{
+		interfaceID:    'S',
+		dxferDirection: sgDxferFromDv,
+		cmdLen:         uint8(len(cdb)),
+		mxSbLen:        uint8(len(sense)),
+		dxferLen:       uint32(len(buf)),
+		dxferp:         unsafe.Pointer(&buf[0]),
+		cmdp:           unsafe.Pointer(&cdb[0]),
+		sbp:            unsafe.Pointer(&sense[0]),
+		timeout:        5000,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), sgIO, uintptr(unsafe.Pointer(&hdr)))
+	runtime.KeepAlive(buf)
+	runtime.KeepAlive(cdb)
+	runtime.KeepAlive(sense)
+	if errno != 0 {
+		return nil, errno
+	}
+	// CHECK CONDITION with ATA status return descriptors is normal for
+	// pass-through; only transport failures are fatal.
+	if hdr.hostStatus != 0 || hdr.driverStatus&^0x08 != 0 {
+		return nil, errors.New("smart: SG_IO transport error")
+	}
+	return buf, nil
+}
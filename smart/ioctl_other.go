@@ -0,0 +1,16 @@
+//go:build !linux
+
+package smart
+
+import "errors"
+
+var errUnsupported = errors.New("smart: unsupported platform")
+
+// ReadNVMe is only implemented on Linux.
+func ReadNVMe(string) (*NVMeLog, error) { return nil, errUnsupported }
+
+// ReadATA is only implemented on Linux.
+func ReadATA(string) ([]Attribute, error) { return nil, errUnsupported }
+
+// ReadATAIdentity is only implemented on Linux.
+func ReadATAIdentity(string) (*Identity, error) { return nil, errUnsupported }
@@ -0,0 +1,168 @@
+// Package smart reads drive health data directly from the kernel: the NVMe
+// SMART / Health Information log page through the NVMe admin passthrough
+// ioctl and ATA SMART attributes through SG_IO with ATA PASS-THROUGH (16).
+// The same pass-through reads ATA IDENTIFY DEVICE for the drive's serial
+// number and firmware, which USB-SATA bridges often hide from sysfs.
+//
+// No drive database is used. ATA attributes are reported by ID with their
+// normalized value and threshold; only a handful of IDs whose meaning is
+// consistent across vendors are interpreted.
+package smart
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NVMeLog holds the fields of the NVMe SMART / Health Information log.
+type NVMeLog struct {
+	CriticalWarning  uint8
+	TemperatureK     uint16
+	AvailableSpare   uint8
+	SpareThreshold   uint8
+	PercentUsed      uint8
+	DataUnitsRead    uint64
+	DataUnitsWritten uint64
+	PowerOnHours     uint64
+	UnsafeShutdowns  uint64
+	MediaErrors      uint64
+}
+
+// Critical warning bits.
+const (
+	WarnSpare       = 1 << 0
+	WarnTemperature = 1 << 1
+	WarnReliability = 1 << 2
+	WarnReadOnly    = 1 << 3
+	WarnBackup      = 1 << 4
+)
+
+// ParseNVMeLog decodes a 512-byte SMART / Health Information log page.
+// 128-bit counters are truncated to their low 64 bits.
+func ParseNVMeLog(b []byte) (*NVMeLog, error) {
+	if len(b) < 176 {
+		return nil, errors.New("smart: short NVMe log page")
+	}
+	le := binary.LittleEndian
+	return &NVMeLog{
+		CriticalWarning:  b[0],
+		TemperatureK:     le.Uint16(b[1:]),
+		AvailableSpare:   b[3],
+		SpareThreshold:   b[4],
+		PercentUsed:      b[5],
+		DataUnitsRead:    le.Uint64(b[32:]),
+		DataUnitsWritten: le.Uint64(b[48:]),
+		PowerOnHours:     le.Uint64(b[128:]),
+		UnsafeShutdowns:  le.Uint64(b[144:]),
+		MediaErrors:      le.Uint64(b[160:]),
+	}, nil
+}
+
+// Attribute is an ATA SMART attribute.
+type Attribute struct {
+	ID        uint8
+	Current   uint8
+	Worst     uint8
+	Threshold uint8
+	Raw       uint64
+}
+
+// Failing reports whether the normalized value has reached its threshold.
+func (a Attribute) Failing() bool {
+	return a.Threshold != 0 && a.Current <= a.Threshold
+}
+
+// Well-known ATA attribute IDs.
+const (
+	AttrReallocated   = 5
+	AttrPowerOnHours  = 9
+	AttrWearLeveling  = 177
+	AttrTemperature   = 194
+	AttrPending       = 197
+	AttrUncorrectable = 198
+	AttrSSDLifeLeft   = 231
+	AttrMediaWearout  = 233
+)
+
+const (
+	ataAttributeCount  = 30
+	ataAttributeLength = 12
+)
+
+// ParseATA decodes the SMART READ DATA and READ THRESHOLDS sectors. thresh
+// may be nil.
+func ParseATA(data, thresh []byte) ([]Attribute, error) {
+	if len(data) < 2+ataAttributeCount*ataAttributeLength {
+		return nil, errors.New("smart: short ATA SMART data")
+	}
+	th := map[uint8]uint8{}
+	if len(thresh) >= 2+ataAttributeCount*ataAttributeLength {
+		for i := range ataAttributeCount {
+			e := thresh[2+i*ataAttributeLength:]
+			if e[0] != 0 {
+				th[e[0]] = e[1]
+			}
+		}
+	}
+	var out []Attribute
+	for i := range ataAttributeCount {
+		e := data[2+i*ataAttributeLength:]
+		if e[0] == 0 {
+			continue
+		}
+		var raw [8]byte
+		copy(raw[:], e[5:11])
+		out = append(out, Attribute{
+			ID:        e[0],
+			Current:   e[3],
+			Worst:     e[4],
+			Threshold: th[e[0]],
+			Raw:       binary.LittleEndian.Uint64(raw[:]),
+		})
+	}
+	return out, nil
+}
+
+// Identity is the drive identification from ATA IDENTIFY DEVICE.
+type Identity struct {
+	Model    string
+	Serial   string
+	Firmware string
+	// WWN is the World Wide Name as 16 hex digits, empty when the drive
+	// reports none.
+	WWN string
+}
+
+// ParseATAIdentity decodes the 512-byte IDENTIFY DEVICE data.
+func ParseATAIdentity(b []byte) (*Identity, error) {
+	if len(b) < 512 {
+		return nil, errors.New("smart: short ATA IDENTIFY data")
+	}
+	word := func(i int) uint16 { return binary.LittleEndian.Uint16(b[2*i:]) }
+	id := &Identity{
+		Serial:   ataString(b[20:40]),
+		Firmware: ataString(b[46:54]),
+		Model:    ataString(b[54:94]),
+	}
+	// Word 87 bit 8 announces the WWN in words 108-111, whose first
+	// nibble is the NAA, 5 for IEEE registered names.
+	if word(87)&0xc000 == 0x4000 && word(87)&(1<<8) != 0 && word(108)>>12 != 0 {
+		id.WWN = fmt.Sprintf("%04x%04x%04x%04x", word(108), word(109), word(110), word(111))
+	}
+	if id.Serial == "" && id.Model == "" {
+		return nil, errors.New("smart: empty ATA IDENTIFY data")
+	}
+	return id, nil
+}
+
+// ataString decodes an ATA string, whose words hold two characters each
+// with the first in the high byte, padded with spaces.
+func ataString(b []byte) string {
+	s := make([]byte, len(b))
+	for i := 0; i+1 < len(b); i += 2 {
+		s[i], s[i+1] = b[i+1], b[i]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(s), "\x00"))
+}
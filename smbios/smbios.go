@@ -0,0 +1,168 @@
+// Package smbios parses the raw SMBIOS tables exported by the kernel under
+// /sys/firmware/dmi/tables without relying on the dmidecode binary.
+//
+// Decoders are provided for the structure types used by the fingerprint:
+// BIOS (0), System (1), Baseboard (2), Chassis (3), Processor (4), OEM
+// Strings (11) and Memory Device (17). All decoders tolerate truncated or malformed
+// structures and simply leave missing fields empty.
+package smbios
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+// DefaultDir is where the kernel exposes the raw tables.
+const DefaultDir = "/sys/firmware/dmi/tables"
+
+// Structure type numbers.
+const (
+	TypeBIOS         = 0
+	TypeSystem       = 1
+	TypeBaseboard    = 2
+	TypeChassis      = 3
+	TypeProcessor    = 4
+	TypeOEMStrings   = 11
+	TypeMemoryDevice = 17
+	TypeEndOfTable   = 127
+)
+
+// Table is a decoded SMBIOS table.
+type Table struct {
+	Major, Minor int
+	Structures   []Structure
+}
+
+// Structure is a raw SMBIOS structure.
+type Structure struct {
+	Type   uint8
+	Handle uint16
+	// Formatted is the formatted area including the 4-byte header.
+	Formatted []byte
+	// Strings are the structure's strings; string number n is Strings[n-1].
+	Strings []string
+}
+
+// Read loads and parses the tables from dir (DefaultDir when empty).
+func Read(dir string) (*Table, error) {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	var entry, table []byte
+	err := fdcap.Default.Do(func() (err error) {
+		if entry, err = os.ReadFile(filepath.Join(dir, "smbios_entry_point")); err != nil {
+			return err
+		}
+		table, err = os.ReadFile(filepath.Join(dir, "DMI"))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Parse(entry, table)
+}
+
+// Parse decodes an entry point structure and the structure table it
+// describes.
+func Parse(entry, table []byte) (*Table, error) {
+	t := &Table{}
+	switch {
+	case len(entry) >= 24 && bytes.HasPrefix(entry, []byte("_SM3_")):
+		t.Major, t.Minor = int(entry[7]), int(entry[8])
+		if max := binary.LittleEndian.Uint32(entry[12:16]); int64(max) < int64(len(table)) {
+			table = table[:max]
+		}
+	case len(entry) >= 31 && bytes.HasPrefix(entry, []byte("_SM_")):
+		t.Major, t.Minor = int(entry[6]), int(entry[7])
+		if n := int(binary.LittleEndian.Uint16(entry[0x16:0x18])); n < len(table) {
+			table = table[:n]
+		}
+	case len(entry) >= 15 && bytes.HasPrefix(entry, []byte("_DMI_")):
+		t.Major, t.Minor = int(entry[14]>>4), int(entry[14]&0x0f)
+	default:
+		return nil, errors.New("smbios: unknown entry point")
+	}
+	for len(table) >= 4 {
+		length := int(table[1])
+		if length < 4 || length > len(table) {
+			return t, fmt.Errorf("smbios: structure length %d out of range", length)
+		}
+		s := Structure{
+			Type:      table[0],
+			Handle:    binary.LittleEndian.Uint16(table[2:4]),
+			Formatted: table[:length],
+		}
+		rest := table[length:]
+		end := bytes.Index(rest, []byte{0, 0})
+		if end < 0 {
+			return t, errors.New("smbios: unterminated string set")
+		}
+		if end > 0 {
+			for _, str := range bytes.Split(rest[:end], []byte{0}) {
+				s.Strings = append(s.Strings, string(bytes.TrimSpace(str)))
+			}
+		}
+		table = rest[end+2:]
+		if s.Type == TypeEndOfTable {
+			break
+		}
+		t.Structures = append(t.Structures, s)
+	}
+	return t, nil
+}
+
+// AtLeast reports whether the table version is at least major.minor.
+func (t *Table) AtLeast(major, minor int) bool {
+	return t.Major > major || (t.Major == major && t.Minor >= minor)
+}
+
+// OfType returns all structures of type typ in table order.
+func (t *Table) OfType(typ uint8) []Structure {
+	var out []Structure
+	for _, s := range t.Structures {
+		if s.Type == typ {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Byte returns the byte at offset off of the formatted area, or 0.
+func (s Structure) Byte(off int) uint8 {
+	if off < len(s.Formatted) {
+		return s.Formatted[off]
+	}
+	return 0
+}
+
+// Word returns the little-endian uint16 at offset off, or 0.
+func (s Structure) Word(off int) uint16 {
+	if off+2 <= len(s.Formatted) {
+		return binary.LittleEndian.Uint16(s.Formatted[off:])
+	}
+	return 0
+}
+
+// DWord returns the little-endian uint32 at offset off, or 0.
+func (s Structure) DWord(off int) uint32 {
+	if off+4 <= len(s.Formatted) {
+		return binary.LittleEndian.Uint32(s.Formatted[off:])
+	}
+	return 0
+}
+
+// String returns the string referenced by the string-number byte at offset
+// off, or "" when absent.
+func (s Structure) String(off int) string {
+	n := int(s.Byte(off))
+	if n == 0 || n > len(s.Strings) {
+		return ""
+	}
+	return s.Strings[n-1]
+}
@@ -0,0 +1,269 @@
+package smbios
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// structure encodes a structure of type typ whose formatted area, header
+// included, is n bytes with the given values at their offsets: a byte, a
+// uint16, a uint32 or raw bytes.
+func structure(typ uint8, n int, fields map[int]any, strs ...string) []byte {
+	f := make([]byte, n)
+	f[0], f[1] = typ, byte(n)
+	binary.LittleEndian.PutUint16(f[2:], uint16(typ)<<8)
+	for off, v := range fields {
+		switch v := v.(type) {
+		case int:
+			f[off] = byte(v)
+		case uint16:
+			binary.LittleEndian.PutUint16(f[off:], v)
+		case uint32:
+			binary.LittleEndian.PutUint32(f[off:], v)
+		case []byte:
+			copy(f[off:], v)
+		}
+	}
+	if len(strs) == 0 {
+		return append(f, 0, 0)
+	}
+	for _, s := range strs {
+		f = append(append(f, s...), 0)
+	}
+	return append(f, 0)
+}
+
+func table(structs ...[]byte) []byte {
+	var b []byte
+	for _, s := range structs {
+		b = append(b, s...)
+	}
+	return append(b, structure(TypeEndOfTable, 4, nil)...)
+}
+
+// entry3 is a 64-bit entry point of the given version and maximum table
+// size.
+func entry3(major, minor int, max uint32) []byte {
+	e := make([]byte, 24)
+	copy(e, "_SM3_")
+	e[7], e[8] = byte(major), byte(minor)
+	binary.LittleEndian.PutUint32(e[12:], max)
+	return e
+}
+
+// entry2 is a 32-bit entry point of the given version and table length.
+func entry2(major, minor int, length uint16) []byte {
+	e := make([]byte, 31)
+	copy(e, "_SM_")
+	e[6], e[7] = byte(major), byte(minor)
+	binary.LittleEndian.PutUint16(e[0x16:], length)
+	copy(e[0x10:], "_DMI_")
+	return e
+}
+
+var uuidBytes = []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+
+func TestParse(t *testing.T) {
+	sys := structure(TypeSystem, 0x1b, map[int]any{0x04: 1, 0x05: 2, 0x08: uuidBytes}, "Acme", "Box 9")
+	bios := structure(TypeBIOS, 0x12, map[int]any{0x04: 1}, " Acme BIOS ")
+	tbl := table(sys, bios)
+	for _, tc := range []struct {
+		name         string
+		entry, table []byte
+		major, minor int
+		types        []uint8
+		err          bool
+	}{
+		{"SMBIOS 3", entry3(3, 4, uint32(len(tbl))), tbl, 3, 4, []uint8{TypeSystem, TypeBIOS}, false},
+		{"SMBIOS 3 maximum cuts the table", entry3(3, 0, uint32(len(sys))), tbl, 3, 0, []uint8{TypeSystem}, false},
+		{"SMBIOS 2", entry2(2, 8, uint16(len(tbl))), tbl, 2, 8, []uint8{TypeSystem, TypeBIOS}, false},
+		{"legacy DMI", append(append([]byte("_DMI_"), make([]byte, 9)...), 0x21), tbl, 2, 1, []uint8{TypeSystem, TypeBIOS}, false},
+		{"stops at the end of table", entry3(3, 0, 1<<16), append(tbl, sys...), 3, 0, []uint8{TypeSystem, TypeBIOS}, false},
+		{"unknown entry point", []byte("_XX_"), tbl, 0, 0, nil, true},
+		{"short entry point", []byte("_SM3_"), tbl, 0, 0, nil, true},
+		{"length below the header", entry3(3, 0, 1<<16), append(sys, TypeBIOS, 2, 0, 0), 3, 0, []uint8{TypeSystem}, true},
+		{"length beyond the table", entry3(3, 0, 1<<16), append(sys, TypeBIOS, 0x40, 0, 0), 3, 0, []uint8{TypeSystem}, true},
+		{"unterminated strings", entry3(3, 0, 1<<16), append(sys, bios[:len(bios)-1]...), 3, 0, []uint8{TypeSystem}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.entry, tc.table)
+			if (err != nil) != tc.err {
+				t.Fatalf("Parse error = %v, want error %v", err, tc.err)
+			}
+			if got == nil {
+				return
+			}
+			if got.Major != tc.major || got.Minor != tc.minor {
+				t.Errorf("version = %d.%d, want %d.%d", got.Major, got.Minor, tc.major, tc.minor)
+			}
+			var types []uint8
+			for _, s := range got.Structures {
+				types = append(types, s.Type)
+			}
+			if !reflect.DeepEqual(types, tc.types) {
+				t.Errorf("types = %v, want %v", types, tc.types)
+			}
+		})
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	parse := func(t *testing.T, major, minor int, structs ...[]byte) *Table {
+		t.Helper()
+		tbl := table(structs...)
+		got, err := Parse(entry3(major, minor, uint32(len(tbl))), tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	t.Run("system", func(t *testing.T) {
+		for _, tc := range []struct {
+			name         string
+			major, minor int
+			uuid         []byte
+			want         System
+		}{
+			{"little-endian UUID", 2, 6, uuidBytes, System{Manufacturer: "Acme", ProductName: "Box 9",
+				UUID: "00112233-4455-6677-8899-aabbccddeeff", SKU: "SKU1", Family: "Boxes"}},
+			{"big-endian UUID", 2, 5, uuidBytes, System{Manufacturer: "Acme", ProductName: "Box 9",
+				UUID: "33221100-5544-7766-8899-aabbccddeeff", SKU: "SKU1", Family: "Boxes"}},
+			{"unset UUID", 3, 0, make([]byte, 16), System{Manufacturer: "Acme", ProductName: "Box 9", SKU: "SKU1", Family: "Boxes"}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				s := structure(TypeSystem, 0x1b, map[int]any{0x04: 1, 0x05: 2, 0x08: tc.uuid, 0x19: 3, 0x1a: 4}, "Acme", "Box 9", "SKU1", "Boxes")
+				if got := parse(t, tc.major, tc.minor, s).System(); got == nil || *got != tc.want {
+					t.Errorf("System = %+v, want %+v", got, tc.want)
+				}
+			})
+		}
+		// SMBIOS 2.0 structures end before the UUID.
+		s := structure(TypeSystem, 0x08, map[int]any{0x04: 1, 0x07: 9}, "Acme")
+		if got := parse(t, 2, 0, s).System(); got == nil || *got != (System{Manufacturer: "Acme"}) {
+			t.Errorf("short System = %+v", got)
+		}
+		if got := parse(t, 3, 0).System(); got != nil {
+			t.Errorf("System without a structure = %+v", got)
+		}
+	})
+
+	t.Run("processors", func(t *testing.T) {
+		id := []byte{0x57, 0x06, 0x05, 0x00, 0xff, 0xfb, 0xeb, 0xbf}
+		tbl := parse(t, 3, 0,
+			structure(TypeProcessor, 0x30, map[int]any{0x04: 1, 0x07: 2, 0x08: id, 0x10: 3, 0x14: uint16(4000), 0x18: 0x41,
+				0x23: 0xff, 0x25: 0xff, 0x2a: uint16(288), 0x2e: uint16(576)}, "CPU0", "Intel(R) Corporation", "Xeon"),
+			structure(TypeProcessor, 0x28, map[int]any{0x04: 1, 0x23: 16, 0x25: 32}, "CPU1"),
+			structure(TypeProcessor, 0x1a, map[int]any{0x04: 1, 0x18: 0x41}, "Socket 7"),
+		)
+		want := []Processor{
+			{Socket: "CPU0", Manufacturer: "Intel(R) Corporation", Version: "Xeon", ID: "BFEBFBFF00050657", MaxSpeedMHz: 4000,
+				Populated: true, Cores: 288, Threads: 576},
+			{Socket: "CPU1", Cores: 16, Threads: 32, ID: "0000000000000000"},
+			{Socket: "Socket 7", Populated: true, ID: "0000000000000000"},
+		}
+		if got := tbl.Processors(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Processors = %+v\nwant %+v", got, want)
+		}
+	})
+
+	t.Run("memory devices", func(t *testing.T) {
+		dimm := func(size uint16, fields map[int]any) []byte {
+			f := map[int]any{0x0c: size, 0x10: 1, 0x12: 0x1a, 0x15: uint16(3200)}
+			for k, v := range fields {
+				f[k] = v
+			}
+			return structure(TypeMemoryDevice, 0x58, f, "DIMM_A1")
+		}
+		tbl := parse(t, 3, 2,
+			dimm(16384, map[int]any{0x17: 1}),
+			dimm(0, nil),
+			dimm(0xffff, nil),
+			dimm(0x8000|2048, map[int]any{0x12: 0x22}),
+			dimm(0x7fff, map[int]any{0x1c: uint32(65536), 0x15: uint16(0xffff), 0x54: uint32(8800)}),
+			dimm(8192, map[int]any{0x12: 0x42}),
+		)
+		var got []string
+		for _, m := range tbl.MemoryDevices() {
+			got = append(got, fmt.Sprintf("%s %s %dMB %d", m.Locator, m.Type, m.SizeMB, m.SpeedMTs))
+		}
+		want := []string{
+			"DIMM_A1 DDR4 16384MB 3200",
+			"DIMM_A1 DDR5 2MB 3200",
+			"DIMM_A1 DDR4 65536MB 8800",
+			"DIMM_A1 0x42 8192MB 3200",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("MemoryDevices = %q\nwant %q", got, want)
+		}
+	})
+
+	t.Run("OEM strings", func(t *testing.T) {
+		tbl := parse(t, 3, 0,
+			structure(TypeOEMStrings, 5, map[int]any{0x04: 2}, "svc-tag:ABC", "vendor:x", "beyond the count"),
+			structure(TypeOEMStrings, 5, map[int]any{0x04: 9}, "only"),
+		)
+		want := []string{"svc-tag:ABC", "vendor:x", "only"}
+		if got := tbl.OEMStrings(); !reflect.DeepEqual(got, want) {
+			t.Errorf("OEMStrings = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("baseboard chassis BIOS", func(t *testing.T) {
+		tbl := parse(t, 3, 0,
+			structure(TypeBaseboard, 0x09, map[int]any{0x04: 1, 0x05: 2, 0x07: 3}, "Acme", "X99", "BSN1"),
+			structure(TypeChassis, 0x09, map[int]any{0x04: 1, 0x05: 0x80 | 23}, "Acme"),
+			structure(TypeBIOS, 0x12, map[int]any{0x04: 1, 0x05: 2, 0x08: 3}, "Acme", "1.2", "01/02/2024"),
+		)
+		if got := tbl.Baseboards(); !reflect.DeepEqual(got, []Baseboard{{Manufacturer: "Acme", Product: "X99", SerialNumber: "BSN1"}}) {
+			t.Errorf("Baseboards = %+v", got)
+		}
+		if got := tbl.Chassis(); !reflect.DeepEqual(got, []Chassis{{Manufacturer: "Acme", Type: 23}}) {
+			t.Errorf("Chassis = %+v", got)
+		}
+		if got := tbl.BIOS(); got == nil || *got != (BIOS{Vendor: "Acme", Version: "1.2", ReleaseDate: "01/02/2024"}) {
+			t.Errorf("BIOS = %+v", got)
+		}
+	})
+}
+
+// FuzzParse checks that Parse and the decoders never panic and that every
+// parsed structure lies within the table.
+func FuzzParse(f *testing.F) {
+	sys := structure(TypeSystem, 0x1b, map[int]any{0x04: 1, 0x08: uuidBytes}, "Acme")
+	cpu := structure(TypeProcessor, 0x30, map[int]any{0x04: 1, 0x23: 0xff, 0x25: 0xff}, "CPU0")
+	dimm := structure(TypeMemoryDevice, 0x58, map[int]any{0x0c: uint16(0x7fff), 0x15: uint16(0xffff)}, "DIMM")
+	oem := structure(TypeOEMStrings, 5, map[int]any{0x04: 3}, "a")
+	tbl := table(sys, cpu, dimm, oem)
+	f.Add(entry3(3, 4, uint32(len(tbl))), tbl)
+	f.Add(entry2(2, 7, uint16(len(tbl))), tbl)
+	f.Add([]byte("_DMI_\x00\x00\x00\x00\x00\x00\x00\x00\x00\x24"), tbl[:len(tbl)/2])
+	f.Add(entry3(3, 0, 1<<20), []byte{TypeProcessor, 0xff, 0, 0})
+	f.Fuzz(func(t *testing.T, entry, table []byte) {
+		got, err := Parse(entry, table)
+		if got == nil {
+			if err == nil {
+				t.Fatal("Parse returned neither a table nor an error")
+			}
+			return
+		}
+		n := 0
+		for _, s := range got.Structures {
+			if len(s.Formatted) < 4 || s.Formatted[1] != byte(len(s.Formatted)) {
+				t.Fatalf("structure %d has a formatted area of %d bytes, length byte %d", s.Handle, len(s.Formatted), s.Formatted[1])
+			}
+			n += len(s.Formatted)
+		}
+		if n > len(table) {
+			t.Fatalf("structures span %d bytes of a %d byte table", n, len(table))
+		}
+		got.BIOS()
+		got.System()
+		got.Baseboards()
+		got.Chassis()
+		got.Processors()
+		got.OEMStrings()
+		got.MemoryDevices()
+	})
+}
@@ -0,0 +1,248 @@
+package smbios
+
+import "fmt"
+
+// BIOS is the type 0 structure.
+type BIOS struct {
+	Vendor      string `json:"vendor,omitempty"`
+	Version     string `json:"version,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+}
+
+// System is the type 1 structure.
+type System struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	ProductName  string `json:"product_name,omitempty"`
+	Version      string `json:"version,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	UUID         string `json:"uuid,omitempty"`
+	SKU          string `json:"sku,omitempty"`
+	Family       string `json:"family,omitempty"`
+}
+
+// Baseboard is the type 2 structure.
+type Baseboard struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Version      string `json:"version,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	AssetTag     string `json:"asset_tag,omitempty"`
+}
+
+// Chassis is the type 3 structure.
+type Chassis struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Type         int    `json:"type,omitempty"`
+	Version      string `json:"version,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	AssetTag     string `json:"asset_tag,omitempty"`
+}
+
+// Processor is the type 4 structure.
+type Processor struct {
+	Socket       string `json:"socket,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Version      string `json:"version,omitempty"`
+	ID           string `json:"id,omitempty"`
+	MaxSpeedMHz  int    `json:"max_speed_mhz,omitempty"`
+	Populated    bool   `json:"populated"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	AssetTag     string `json:"asset_tag,omitempty"`
+	PartNumber   string `json:"part_number,omitempty"`
+	Cores        int    `json:"cores,omitempty"`
+	Threads      int    `json:"threads,omitempty"`
+}
+
+// MemoryDevice is the type 17 structure.
+type MemoryDevice struct {
+	Locator      string `json:"locator,omitempty"`
+	BankLocator  string `json:"bank_locator,omitempty"`
+	SizeMB       uint64 `json:"size_mb,omitempty"`
+	Type         string `json:"type,omitempty"`
+	SpeedMTs     int    `json:"speed_mts,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	AssetTag     string `json:"asset_tag,omitempty"`
+	PartNumber   string `json:"part_number,omitempty"`
+}
+
+// BIOS decodes the first type 0 structure.
+func (t *Table) BIOS() *BIOS {
+	for _, s := range t.OfType(TypeBIOS) {
+		return &BIOS{Vendor: s.String(0x04), Version: s.String(0x05), ReleaseDate: s.String(0x08)}
+	}
+	return nil
+}
+
+// System decodes the first type 1 structure.
+func (t *Table) System() *System {
+	for _, s := range t.OfType(TypeSystem) {
+		sys := &System{
+			Manufacturer: s.String(0x04),
+			ProductName:  s.String(0x05),
+			Version:      s.String(0x06),
+			SerialNumber: s.String(0x07),
+		}
+		if len(s.Formatted) >= 0x18 {
+			sys.UUID = formatUUID(s.Formatted[0x08:0x18], t.AtLeast(2, 6))
+		}
+		if len(s.Formatted) >= 0x1b {
+			sys.SKU, sys.Family = s.String(0x19), s.String(0x1a)
+		}
+		return sys
+	}
+	return nil
+}
+
+// Baseboards decodes all type 2 structures.
+func (t *Table) Baseboards() []Baseboard {
+	var out []Baseboard
+	for _, s := range t.OfType(TypeBaseboard) {
+		out = append(out, Baseboard{
+			Manufacturer: s.String(0x04),
+			Product:      s.String(0x05),
+			Version:      s.String(0x06),
+			SerialNumber: s.String(0x07),
+			AssetTag:     s.String(0x08),
+		})
+	}
+	return out
+}
+
+// Chassis decodes all type 3 structures.
+func (t *Table) Chassis() []Chassis {
+	var out []Chassis
+	for _, s := range t.OfType(TypeChassis) {
+		out = append(out, Chassis{
+			Manufacturer: s.String(0x04),
+			Type:         int(s.Byte(0x05) & 0x7f),
+			Version:      s.String(0x06),
+			SerialNumber: s.String(0x07),
+			AssetTag:     s.String(0x08),
+		})
+	}
+	return out
+}
+
+// Processors decodes all type 4 structures.
+func (t *Table) Processors() []Processor {
+	var out []Processor
+	for _, s := range t.OfType(TypeProcessor) {
+		p := Processor{
+			Socket:       s.String(0x04),
+			Manufacturer: s.String(0x07),
+			Version:      s.String(0x10),
+			MaxSpeedMHz:  int(s.Word(0x14)),
+			Populated:    s.Byte(0x18)&0x40 != 0,
+			SerialNumber: s.String(0x20),
+			AssetTag:     s.String(0x21),
+			PartNumber:   s.String(0x22),
+			Cores:        int(s.Byte(0x23)),
+			Threads:      int(s.Byte(0x25)),
+		}
+		if len(s.Formatted) >= 0x10 {
+			p.ID = fmt.Sprintf("%X", reverse(s.Formatted[0x08:0x10]))
+		}
+		if p.Cores == 0xff && len(s.Formatted) >= 0x2c {
+			p.Cores = int(s.Word(0x2a))
+		}
+		if p.Threads == 0xff && len(s.Formatted) >= 0x30 {
+			p.Threads = int(s.Word(0x2e))
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// OEMStrings returns the strings of all type 11 structures, which vendors
+// use for service tags and cloud platforms for instance metadata.
+func (t *Table) OEMStrings() []string {
+	var out []string
+	for _, s := range t.OfType(TypeOEMStrings) {
+		n := min(int(s.Byte(0x04)), len(s.Strings))
+		out = append(out, s.Strings[:n]...)
+	}
+	return out
+}
+
+// MemoryDevices decodes all type 17 structures describing installed
+// modules. Empty slots are skipped.
+func (t *Table) MemoryDevices() []MemoryDevice {
+	var out []MemoryDevice
+	for _, s := range t.OfType(TypeMemoryDevice) {
+		size := s.Word(0x0c)
+		if size == 0 || size == 0xffff {
+			continue
+		}
+		var mb uint64
+		switch {
+		case size == 0x7fff:
+			mb = uint64(s.DWord(0x1c) & 0x7fffffff)
+		case size&0x8000 != 0:
+			mb = uint64(size&0x7fff) / 1024
+		default:
+			mb = uint64(size)
+		}
+		speed := int(s.Word(0x15))
+		if speed == 0xffff {
+			speed = int(s.DWord(0x54))
+		}
+		out = append(out, MemoryDevice{
+			Locator:      s.String(0x10),
+			BankLocator:  s.String(0x11),
+			SizeMB:       mb,
+			Type:         memoryTypeName(s.Byte(0x12)),
+			SpeedMTs:     speed,
+			Manufacturer: s.String(0x17),
+			SerialNumber: s.String(0x18),
+			AssetTag:     s.String(0x19),
+			PartNumber:   s.String(0x1a),
+		})
+	}
+	return out
+}
+
+// formatUUID renders a 16-byte SMBIOS UUID. Since SMBIOS 2.6 the first three
+// fields are stored little-endian.
+func formatUUID(b []byte, le bool) string {
+	u := append([]byte(nil), b...)
+	allSame := true
+	for _, c := range u {
+		if c != u[0] {
+			allSame = false
+		}
+	}
+	if allSame && (u[0] == 0x00 || u[0] == 0xff) {
+		return ""
+	}
+	if le {
+		u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+		u[4], u[5] = u[5], u[4]
+		u[6], u[7] = u[7], u[6]
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
+
+var memoryTypes = map[uint8]string{
+	0x03: "DRAM", 0x0f: "SDRAM", 0x12: "DDR", 0x13: "DDR2", 0x14: "DDR2 FB-DIMM",
+	0x18: "DDR3", 0x1a: "DDR4", 0x1b: "LPDDR", 0x1c: "LPDDR2", 0x1d: "LPDDR3",
+	0x1e: "LPDDR4", 0x20: "HBM", 0x21: "HBM2", 0x22: "DDR5", 0x23: "LPDDR5", 0x24: "HBM3",
+}
+
+func memoryTypeName(v uint8) string {
+	if n, ok := memoryTypes[v]; ok {
+		return n
+	}
+	if v <= 2 {
+		return ""
+	}
+	return fmt.Sprintf("0x%02x", v)
+}
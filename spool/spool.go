@@ -0,0 +1,185 @@
+// Package spool implements a bounded on-disk FIFO queue used to hold
+// snapshots while the inventory endpoint is unreachable.
+//
+// Every item is a file named after its enqueue time, written atomically via
+// rename. When the queue exceeds its item or byte limits, the oldest items
+// are evicted first.
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"AurFingerprintAgent/fdcap"
+)
+
+const suffix = ".json"
+
+// Queue is an on-disk FIFO queue rooted at Dir.
+type Queue struct {
+	Dir      string
+	MaxItems int
+	MaxBytes int64
+
+	mu   sync.Mutex
+	last int64
+}
+
+// Open creates dir if needed and returns a queue limited to maxItems items
+// and maxBytes bytes. A non-positive limit disables that bound.
+func Open(dir string, maxItems int, maxBytes int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Queue{Dir: dir, MaxItems: maxItems, MaxBytes: maxBytes}, nil
+}
+
+// Put appends b to the queue and evicts the oldest items beyond the limits.
+func (q *Queue) Put(b []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := time.Now().UnixNano()
+	if id <= q.last {
+		id = q.last + 1
+	}
+	q.last = id
+	name := fmt.Sprintf("%020d%s", id, suffix)
+	tmp := filepath.Join(q.Dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.Dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return q.evict()
+}
+
+// Peek returns the oldest item. It returns io.EOF when the queue is empty.
+func (q *Queue) Peek() (name string, b []byte, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, err := q.items()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, it := range items {
+		b, err := readFile(filepath.Join(q.Dir, it.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return it.name, b, err
+	}
+	return "", nil, io.EOF
+}
+
+// List returns the names of the queued items, oldest first. Read returns
+// an item's contents and QueuedAt the time it was queued.
+func (q *Queue) List() ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, err := q.items()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(items))
+	for i, it := range items {
+		names[i] = it.name
+	}
+	return names, nil
+}
+
+// Read returns the contents of the item name, as listed by List.
+func (q *Queue) Read(name string) ([]byte, error) {
+	return readFile(filepath.Join(q.Dir, filepath.Base(name)))
+}
+
+func readFile(name string) (b []byte, err error) {
+	err = fdcap.Default.Do(func() (err error) {
+		b, err = os.ReadFile(name)
+		return err
+	})
+	return b, err
+}
+
+// QueuedAt returns the time the item name was queued.
+func QueuedAt(name string) time.Time {
+	ns, err := strconv.ParseInt(strings.TrimSuffix(name, suffix), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Remove deletes an item previously returned by Peek or List.
+func (q *Queue) Remove(name string) error {
+	err := os.Remove(filepath.Join(q.Dir, filepath.Base(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Len returns the number of queued items.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, _ := q.items()
+	return len(items)
+}
+
+type item struct {
+	name string
+	size int64
+}
+
+func (q *Queue) items() ([]item, error) {
+	var entries []os.DirEntry
+	err := fdcap.Default.Do(func() (err error) {
+		entries, err = os.ReadDir(q.Dir)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []item
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || !strings.HasSuffix(e.Name(), suffix) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, item{name: e.Name(), size: fi.Size()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out, nil
+}
+
+func (q *Queue) evict() error {
+	items, err := q.items()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, it := range items {
+		total += it.size
+	}
+	for len(items) > 1 && ((q.MaxItems > 0 && len(items) > q.MaxItems) || (q.MaxBytes > 0 && total > q.MaxBytes)) {
+		if err := os.Remove(filepath.Join(q.Dir, items[0].name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= items[0].size
+		items = items[1:]
+	}
+	return nil
+}
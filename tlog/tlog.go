@@ -0,0 +1,303 @@
+// Package tlog records signed digests in a Rekor-compatible transparency
+// log, an append-only Merkle tree whose operator cannot later drop or
+// reorder entries without detection, and verifies the inclusion proofs it
+// returns.
+//
+// Entries are of Rekor's hashedrekord kind: a SHA-256 digest, a signature
+// over it and the PEM public key verifying that signature. Ed25519 keys
+// sign whole messages rather than digests and cannot be logged this way.
+package tlog
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"AurFingerprintAgent/httpclient"
+)
+
+// DefaultURL is the public Sigstore instance.
+const DefaultURL = "https://rekor.sigstore.dev"
+
+// ErrBadProof is returned when an inclusion proof does not lead from the
+// entry to the root hash.
+var ErrBadProof = errors.New("tlog: inclusion proof does not verify")
+
+// Client submits entries to the log at URL.
+type Client struct {
+	URL string
+	// HTTPClient is used for requests; httpclient.Shared() when nil.
+	HTTPClient *http.Client
+}
+
+// Entry is a logged digest with the log's promise and proof of inclusion.
+type Entry struct {
+	// URL is the log the entry was submitted to.
+	URL  string `json:"url"`
+	UUID string `json:"uuid"`
+	// LogID is the hex SHA-256 of the log's public key.
+	LogID    string `json:"log_id"`
+	LogIndex int64  `json:"log_index"`
+	// IntegratedTime is when the log accepted the entry, in Unix
+	// seconds.
+	IntegratedTime int64 `json:"integrated_time"`
+	// ArtifactHash is the hex SHA-256 digest that was logged.
+	ArtifactHash string `json:"artifact_hash"`
+	// Body is the canonical entry as the log stores it; the Merkle leaf
+	// is its hash.
+	Body []byte `json:"body"`
+	// SignedEntryTimestamp is the log's signature over the entry, a
+	// promise to include it.
+	SignedEntryTimestamp []byte          `json:"signed_entry_timestamp,omitempty"`
+	InclusionProof       *InclusionProof `json:"inclusion_proof,omitempty"`
+}
+
+// InclusionProof is an RFC 6962 audit path from an entry to the root of
+// the tree at TreeSize.
+type InclusionProof struct {
+	LogIndex int64    `json:"log_index"`
+	TreeSize int64    `json:"tree_size"`
+	RootHash string   `json:"root_hash"`
+	Hashes   []string `json:"hashes"`
+	// Checkpoint is the log's signed note over TreeSize and RootHash.
+	Checkpoint string `json:"checkpoint,omitempty"`
+}
+
+// Submit logs digest, a SHA-256 hash, with sig, the signature of pub over
+// it. An entry the log already holds is fetched instead.
+func (c *Client) Submit(ctx context.Context, digest, sig []byte, pub crypto.PublicKey) (*Entry, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	var req struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content   []byte `json:"content"`
+				PublicKey struct {
+					Content []byte `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+		} `json:"spec"`
+	}
+	req.APIVersion, req.Kind = "0.0.1", "hashedrekord"
+	req.Spec.Data.Hash.Algorithm = "sha256"
+	req.Spec.Data.Hash.Value = hex.EncodeToString(digest)
+	req.Spec.Signature.Content = sig
+	req.Spec.Signature.PublicKey.Content = key
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Accept", "application/json")
+	resp, err := c.client().Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		// The log returns the existing entry's location.
+		if loc := resp.Header.Get("Location"); loc != "" {
+			u, err := resp.Request.URL.Parse(loc)
+			if err != nil {
+				return nil, err
+			}
+			return c.fetch(ctx, u.String())
+		}
+	}
+	return c.decode(resp)
+}
+
+func (c *Client) fetch(ctx context.Context, url string) (*Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return c.decode(resp)
+}
+
+// decode reads the log's response, a map from entry UUID to the entry.
+func (c *Client) decode(resp *http.Response) (*Entry, error) {
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("tlog: log returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	type logEntry struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+		Verification   struct {
+			InclusionProof *struct {
+				LogIndex   int64    `json:"logIndex"`
+				TreeSize   int64    `json:"treeSize"`
+				RootHash   string   `json:"rootHash"`
+				Hashes     []string `json:"hashes"`
+				Checkpoint string   `json:"checkpoint"`
+			} `json:"inclusionProof"`
+			SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+		} `json:"verification"`
+	}
+	var entries map[string]logEntry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("tlog: decoding response: %w", err)
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("tlog: log returned %d entries, want 1", len(entries))
+	}
+	var uuid string
+	var le logEntry
+	for uuid, le = range entries {
+	}
+	body, err := base64.StdEncoding.DecodeString(le.Body)
+	if err != nil {
+		return nil, fmt.Errorf("tlog: entry body: %w", err)
+	}
+	hash, err := artifactHash(body)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{
+		URL:                  c.URL,
+		UUID:                 uuid,
+		LogID:                le.LogID,
+		LogIndex:             le.LogIndex,
+		IntegratedTime:       le.IntegratedTime,
+		ArtifactHash:         hash,
+		Body:                 body,
+		SignedEntryTimestamp: le.Verification.SignedEntryTimestamp,
+	}
+	if p := le.Verification.InclusionProof; p != nil {
+		e.InclusionProof = &InclusionProof{LogIndex: p.LogIndex, TreeSize: p.TreeSize,
+			RootHash: p.RootHash, Hashes: p.Hashes, Checkpoint: p.Checkpoint}
+	}
+	return e, nil
+}
+
+// artifactHash extracts the logged digest from a hashedrekord body.
+func artifactHash(body []byte) (string, error) {
+	var v struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", fmt.Errorf("tlog: entry body: %w", err)
+	}
+	if v.Kind != "hashedrekord" || v.Spec.Data.Hash.Algorithm != "sha256" {
+		return "", fmt.Errorf("tlog: unexpected %s entry with %s digest", v.Kind, v.Spec.Data.Hash.Algorithm)
+	}
+	return v.Spec.Data.Hash.Value, nil
+}
+
+// Verify checks that the entry logs digest and that its inclusion proof
+// leads to the proof's root hash. Trusting that root requires checking
+// the checkpoint signature against the log's key, which is left to
+// auditors holding it.
+func (e *Entry) Verify(digest []byte) error {
+	h, err := artifactHash(e.Body)
+	if err != nil {
+		return err
+	}
+	if h != hex.EncodeToString(digest) || h != e.ArtifactHash {
+		return fmt.Errorf("tlog: entry logs %s, not %x", h, digest)
+	}
+	p := e.InclusionProof
+	if p == nil {
+		return errors.New("tlog: entry has no inclusion proof")
+	}
+	root, err := hex.DecodeString(p.RootHash)
+	if err != nil || len(root) != sha256.Size {
+		return fmt.Errorf("tlog: root hash %q is not a SHA-256 hash", p.RootHash)
+	}
+	path := make([][]byte, len(p.Hashes))
+	for i, s := range p.Hashes {
+		if path[i], err = hex.DecodeString(s); err != nil {
+			return fmt.Errorf("tlog: proof hash: %w", err)
+		}
+	}
+	leaf := sha256.Sum256(append([]byte{0}, e.Body...))
+	if r := rootFromPath(p.LogIndex, p.TreeSize, leaf[:], path); r == nil || !bytes.Equal(r, root) {
+		return ErrBadProof
+	}
+	return nil
+}
+
+// rootFromPath computes the tree root from a leaf hash and its audit path,
+// as in RFC 9162 section 2.1.3.2; nil when the path does not fit the tree.
+func rootFromPath(index, size int64, leaf []byte, path [][]byte) []byte {
+	if index < 0 || index >= size {
+		return nil
+	}
+	node := func(l, r []byte) []byte {
+		s := sha256.Sum256(append(append([]byte{1}, l...), r...))
+		return s[:]
+	}
+	fn, sn, r := index, size-1, leaf
+	for _, p := range path {
+		if sn == 0 {
+			return nil
+		}
+		if fn&1 == 1 || fn == sn {
+			r = node(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			r = node(r, p)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+	if sn != 0 {
+		return nil
+	}
+	return r
+}
+
+func (c *Client) endpoint() string {
+	return strings.TrimSuffix(c.URL, "/") + "/api/v1/log/entries"
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return httpclient.Shared()
+}
@@ -0,0 +1,80 @@
+package tlog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func leafHash(i int) []byte {
+	h := sha256.Sum256(append([]byte{0}, fmt.Sprint(i)...))
+	return h[:]
+}
+
+func nodeHash(l, r []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{1}, l...), r...))
+	return h[:]
+}
+
+// split is the largest power of two smaller than n.
+func split(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// mth is the Merkle tree hash of RFC 9162 section 2.1.1.
+func mth(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return nodeHash(mth(leaves[:k]), mth(leaves[k:]))
+}
+
+// auditPath is PATH(m, D[n]) of RFC 9162 section 2.1.3.1.
+func auditPath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := split(len(leaves))
+	if m < k {
+		return append(auditPath(m, leaves[:k]), mth(leaves[k:]))
+	}
+	return append(auditPath(m-k, leaves[k:]), mth(leaves[:k]))
+}
+
+func TestRootFromPath(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			leaves[i] = leafHash(i)
+		}
+		root := mth(leaves)
+		for m := range n {
+			path := auditPath(m, leaves)
+			if r := rootFromPath(int64(m), int64(n), leaves[m], path); !bytes.Equal(r, root) {
+				t.Fatalf("leaf %d of %d: root %x, want %x", m, n, r, root)
+			}
+			if r := rootFromPath(int64(m), int64(n), leafHash(n), path); bytes.Equal(r, root) {
+				t.Fatalf("leaf %d of %d: a foreign leaf verifies", m, n)
+			}
+			if len(path) > 0 {
+				if r := rootFromPath(int64(m), int64(n), leaves[m], path[:len(path)-1]); r != nil {
+					t.Fatalf("leaf %d of %d: a short path gives a root", m, n)
+				}
+			}
+			if r := rootFromPath(int64(m), int64(n), leaves[m], append(path, root)); r != nil {
+				t.Fatalf("leaf %d of %d: a long path gives a root", m, n)
+			}
+		}
+	}
+	for _, c := range [][2]int64{{-1, 4}, {4, 4}, {0, 0}} {
+		if r := rootFromPath(c[0], c[1], leafHash(0), nil); r != nil {
+			t.Errorf("rootFromPath(%d, %d) = %x", c[0], c[1], r)
+		}
+	}
+}
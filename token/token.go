@@ -0,0 +1,245 @@
+// Package token issues short-lived JWTs whose claims are selected
+// fingerprint fields, letting backend APIs authenticate machines without
+// shipping raw snapshots on every request.
+//
+// Supported algorithms are HS256 ([]byte keys), EdDSA (ed25519 keys) and
+// ES256 (P-256 ECDSA keys).
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+var (
+	// ErrInvalid is returned for malformed tokens or bad signatures.
+	ErrInvalid = errors.New("token: invalid token")
+	// ErrExpired is returned for tokens past their exp claim.
+	ErrExpired = errors.New("token: expired")
+	// ErrKeySize is returned for Ed25519 keys of the wrong length, which
+	// package ed25519 would panic on.
+	ErrKeySize = errors.New("token: bad ed25519 key length")
+)
+
+// Claims is the JWT payload. Subject carries the fingerprint hash. A JWT
+// is only encoded, not encrypted, so the machine ID and the DMI product
+// UUID are carried as their component digests (see
+// fingerprint.Snapshot.ComponentDigests), which a backend compares with
+// those it stored at enrollment.
+type Claims struct {
+	Issuer            string `json:"iss,omitempty"`
+	Subject           string `json:"sub"`
+	IssuedAt          int64  `json:"iat"`
+	ExpiresAt         int64  `json:"exp"`
+	Hostname          string `json:"hostname,omitempty"`
+	MachineIDDigest   string `json:"machine_id_sha256,omitempty"`
+	ProductUUIDDigest string `json:"product_uuid_sha256,omitempty"`
+	OS                string `json:"os,omitempty"`
+}
+
+// Issue returns a signed JWT for snap valid for ttl. signKey is a []byte
+// HMAC secret, an ed25519.PrivateKey or a P-256 *ecdsa.PrivateKey.
+func Issue(signKey any, snap fingerprint.Snapshot, ttl time.Duration) (string, error) {
+	now := time.Now()
+	digests := snap.ComponentDigests()
+	c := Claims{
+		Subject:           snap.Hash(),
+		IssuedAt:          now.Unix(),
+		ExpiresAt:         now.Add(ttl).Unix(),
+		Hostname:          snap.Hostname,
+		MachineIDDigest:   digests["machine_id"],
+		ProductUUIDDigest: digests["dmi.product_uuid"],
+		OS:                strings.TrimSpace(snap.OS.Name + " " + snap.OS.Version),
+	}
+	return sign(signKey, c)
+}
+
+// Verify checks the signature and expiry of tok. verifyKey is the []byte
+// HMAC secret, an ed25519.PublicKey or an *ecdsa.PublicKey.
+func Verify(tok string, verifyKey any) (*Claims, error) {
+	if k, ok := verifyKey.(ed25519.PublicKey); ok && len(k) != ed25519.PublicKeySize {
+		return nil, ErrKeySize
+	}
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	hb, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var h header
+	if json.Unmarshal(hb, &h) != nil || h.Alg != algFor(verifyKey) {
+		return nil, ErrInvalid
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !verify(verifyKey, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalid
+	}
+	pb, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var c Claims
+	if err := json.Unmarshal(pb, &c); err != nil {
+		return nil, ErrInvalid
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return &c, ErrExpired
+	}
+	return &c, nil
+}
+
+// Source hands out a cached token and transparently re-collects and
+// re-issues it once less than a third of its lifetime is left.
+type Source struct {
+	Key any
+	TTL time.Duration
+	// Snapshot collects the snapshot; fingerprint.GetSnapshot when nil.
+	Snapshot func() fingerprint.Snapshot
+
+	mu  sync.Mutex
+	tok string
+	exp time.Time
+}
+
+// Token returns a valid token, refreshing it when needed.
+func (s *Source) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok != "" && time.Until(s.exp) > s.TTL/3 {
+		return s.tok, nil
+	}
+	collect := s.Snapshot
+	if collect == nil {
+		collect = func() fingerprint.Snapshot { return fingerprint.GetSnapshot() }
+	}
+	tok, err := Issue(s.Key, collect(), s.TTL)
+	if err != nil {
+		return "", err
+	}
+	s.tok, s.exp = tok, time.Now().Add(s.TTL)
+	return tok, nil
+}
+
+type ctxKey struct{}
+
+// Middleware rejects requests without a valid "Authorization: Bearer" token
+// and stores the verified claims in the request context.
+func Middleware(verifyKey any, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		c, err := Verify(strings.TrimSpace(tok), verifyKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, c)))
+	})
+}
+
+// FromContext returns the claims stored by Middleware.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(ctxKey{}).(*Claims)
+	return c, ok
+}
+
+var b64 = base64.RawURLEncoding
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+func algFor(key any) string {
+	switch k := key.(type) {
+	case []byte:
+		return "HS256"
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return "EdDSA"
+	case *ecdsa.PrivateKey:
+		if k.Curve == elliptic.P256() {
+			return "ES256"
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return "ES256"
+		}
+	}
+	return ""
+}
+
+func sign(key any, c Claims) (string, error) {
+	alg := algFor(key)
+	if alg == "" {
+		return "", fmt.Errorf("token: unsupported key type %T", key)
+	}
+	if k, ok := key.(ed25519.PrivateKey); ok && len(k) != ed25519.PrivateKeySize {
+		return "", ErrKeySize
+	}
+	hb, _ := json.Marshal(header{Alg: alg, Typ: "JWT"})
+	pb, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	input := b64.EncodeToString(hb) + "." + b64.EncodeToString(pb)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		m := hmac.New(sha256.New, k)
+		m.Write([]byte(input))
+		sig = m.Sum(nil)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, k, sum[:])
+		if err != nil {
+			return "", err
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + b64.EncodeToString(sig), nil
+}
+
+func verify(key any, input, sig []byte) bool {
+	switch k := key.(type) {
+	case []byte:
+		m := hmac.New(sha256.New, k)
+		m.Write(input)
+		return hmac.Equal(m.Sum(nil), sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, input, sig)
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+		sum := sha256.Sum256(input)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, sum[:], r, s)
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"AurFingerprintAgent/fingerprint"
+)
+
+var snap = fingerprint.Snapshot{
+	Hostname:  "app-03",
+	MachineID: "4c4c4544004d3110",
+	DMI:       fingerprint.DMIInfo{ProductUUID: "4C4C4544-004D-3110-8052-B4C04F4A4E32"},
+}
+
+func TestIssueVerify(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := []struct{ sign, verify any }{
+		{[]byte("s3cret"), []byte("s3cret")},
+		{edPriv, edPub},
+		{ec, &ec.PublicKey},
+	}
+	digests := snap.ComponentDigests()
+	for _, k := range keys {
+		tok, err := Issue(k.sign, snap, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := Verify(tok, k.verify)
+		if err != nil {
+			t.Fatalf("%T: %v", k.verify, err)
+		}
+		if c.Subject != snap.Hash() || c.Hostname != snap.Hostname ||
+			c.MachineIDDigest != digests["machine_id"] || c.ProductUUIDDigest != digests["dmi.product_uuid"] {
+			t.Errorf("%T: claims %+v", k.verify, c)
+		}
+		if strings.Contains(tok, "4c4c4544004d3110") {
+			t.Errorf("%T: token carries the raw machine ID", k.verify)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	tok, err := Issue(priv, snap, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := Issue(priv, snap, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(tok, ".")
+	tampered := parts[0] + "." + b64.EncodeToString([]byte(`{"sub":"x","exp":9999999999}`)) + "." + parts[2]
+	tests := []struct {
+		name string
+		tok  string
+		key  any
+		want error
+	}{
+		{"expired", expired, pub, ErrExpired},
+		{"other key", tok, otherPub, ErrInvalid},
+		{"tampered claims", tampered, pub, ErrInvalid},
+		{"algorithm mismatch", tok, []byte("s3cret"), ErrInvalid},
+		{"malformed", "a.b", pub, ErrInvalid},
+		{"short key", tok, ed25519.PublicKey(pub[:10]), ErrKeySize},
+	}
+	for _, tt := range tests {
+		if _, err := Verify(tt.tok, tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := Issue(priv[:10], snap, time.Minute); !errors.Is(err, ErrKeySize) {
+		t.Errorf("Issue with a short key = %v, want ErrKeySize", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	tok, err := Issue(priv, snap, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := func(key any) http.Handler {
+		return Middleware(key, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c, ok := FromContext(r.Context()); !ok || c.Hostname != snap.Hostname {
+				t.Errorf("claims %+v in context", c)
+			}
+		}))
+	}
+	for _, tt := range []struct {
+		auth string
+		key  any
+		want int
+	}{
+		{"Bearer " + tok, pub, http.StatusOK},
+		{"", pub, http.StatusUnauthorized},
+		{"Bearer " + tok, ed25519.PublicKey(pub[:10]), http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h(tt.key).ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("Authorization %.20q: status %d, want %d", tt.auth, w.Code, tt.want)
+		}
+	}
+}
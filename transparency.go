@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+
+	"AurFingerprintAgent/fingerprint"
+	"AurFingerprintAgent/tlog"
+)
+
+// logSnapshot signs the TransparencyDigest of snap with s, submits it to
+// the log at url and records the entry in snap.Meta.Transparency. Signing
+// the snapshot afterwards covers the entry too.
+func logSnapshot(ctx context.Context, url string, s crypto.Signer, snap *fingerprint.Snapshot) error {
+	if err := checkTlogKey(s); err != nil {
+		return err
+	}
+	digest, err := fingerprint.TransparencyDigest(*snap)
+	if err != nil {
+		return err
+	}
+	sig, err := s.Sign(rand.Reader, digest, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	e, err := (&tlog.Client{URL: url}).Submit(ctx, digest, sig, s.Public())
+	if err != nil {
+		return err
+	}
+	if snap.Meta == nil {
+		snap.Meta = &fingerprint.Meta{}
+	}
+	snap.Meta.Transparency = e
+	return nil
+}
+
+// checkTlogKey rejects keys whose signatures a transparency log cannot
+// record, so that -tlog fails at startup rather than on every push.
+func checkTlogKey(s crypto.Signer) error {
+	if _, ok := s.Public().(ed25519.PublicKey); ok {
+		return errors.New("-tlog needs an ECDSA or RSA -sign-key; Ed25519 keys cannot be logged")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Run shows root on the terminal tty until the user quits.
+func Run(tty *os.File, root *Node) error {
+	fd := int(tty.Fd())
+	old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("not a terminal: %w", err)
+	}
+	raw := *old
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return err
+	}
+	defer unix.IoctlSetTermios(fd, unix.TCSETS, old)
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(tty, "\x1b[?25h\x1b[?1049l")
+
+	v := &view{root: root}
+	buf := make([]byte, 16)
+	for {
+		rows, cols := 24, 80
+		if ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ); err == nil && ws.Row > 0 {
+			rows, cols = int(ws.Row), int(ws.Col)
+		}
+		fmt.Fprint(tty, v.draw(rows, cols))
+		n, err := tty.Read(buf)
+		if err != nil {
+			return err
+		}
+		if !v.key(string(buf[:n]), rows-2, tty) {
+			return nil
+		}
+	}
+}
+
+type view struct {
+	root   *Node
+	cursor int
+	top    int
+	status string
+}
+
+const help = "↑↓ move  ←→ fold  enter toggle  c copy  e/E expand/collapse all  n next change  q quit"
+
+// key applies a keypress and reports whether to keep running.
+func (v *view) key(k string, page int, tty *os.File) bool {
+	lines := visible(v.root)
+	if len(lines) == 0 {
+		return k != "q" && k != "\x03"
+	}
+	v.cursor = min(v.cursor, len(lines)-1)
+	cur := lines[v.cursor].node
+	v.status = ""
+	switch k {
+	case "q", "\x03", "\x1b":
+		return false
+	case "\x1b[A", "k":
+		v.cursor--
+	case "\x1b[B", "j":
+		v.cursor++
+	case "\x1b[5~":
+		v.cursor -= page
+	case "\x1b[6~", " ":
+		v.cursor += page
+	case "\x1b[H", "g":
+		v.cursor = 0
+	case "\x1b[F", "G":
+		v.cursor = len(lines) - 1
+	case "\x1b[C", "l":
+		cur.Expanded = true
+	case "\x1b[D", "h":
+		if cur.branch && cur.Expanded {
+			cur.Expanded = false
+		} else if cur.parent != v.root {
+			for i := v.cursor; i >= 0; i-- {
+				if lines[i].node == cur.parent {
+					v.cursor = i
+				}
+			}
+		}
+	case "\r", "\n":
+		cur.Expanded = !cur.Expanded
+	case "e":
+		v.root.SetExpanded(true)
+	case "E":
+		v.root.SetExpanded(false)
+		v.root.Expanded = true
+	case "n":
+		for i := v.cursor + 1; i < len(lines); i++ {
+			if lines[i].node.Status != Same {
+				v.cursor = i
+				break
+			}
+		}
+		for _, l := range lines[v.cursor:] {
+			if l.node.Status == Same && l.node.branch && !l.node.Expanded && l.node.changed() {
+				l.node.Expanded = true
+				break
+			}
+		}
+	case "c", "y":
+		val := strings.Trim(cur.Value, `"`)
+		if cur.branch {
+			val = cur.Path()
+		}
+		// OSC 52 lets the terminal emulator set the clipboard, which also
+		// works over SSH without any clipboard tool on the host.
+		fmt.Fprintf(tty, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(val)))
+		v.status = "copied " + cur.Path()
+	}
+	n := len(visible(v.root))
+	v.cursor = max(0, min(v.cursor, n-1))
+	return true
+}
+
+func (v *view) draw(rows, cols int) string {
+	lines := visible(v.root)
+	page := max(rows-2, 1)
+	if v.cursor < v.top {
+		v.top = v.cursor
+	}
+	if v.cursor >= v.top+page {
+		v.top = v.cursor - page + 1
+	}
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	for i := v.top; i < len(lines) && i < v.top+page; i++ {
+		s := clip(render(lines[i], true), cols)
+		if i == v.cursor {
+			s = "\x1b[7m" + s + "\x1b[27m"
+		}
+		sb.WriteString(s + "\x1b[0m\r\n")
+	}
+	status := v.status
+	if status == "" && v.cursor < len(lines) {
+		status = lines[v.cursor].node.Path()
+	}
+	fmt.Fprintf(&sb, "\x1b[%d;1H\x1b[1m%s\x1b[0m\r\n\x1b[2m%s\x1b[0m", rows-1, clip(status, cols), clip(help, cols))
+	return sb.String()
+}
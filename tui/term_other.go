@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+// Run is only implemented on Linux.
+func Run(*os.File, *Node) error {
+	return errors.New("tui: interactive mode is not supported on this platform")
+}
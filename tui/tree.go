@@ -0,0 +1,248 @@
+// Package tui renders JSON documents as a navigable, collapsible tree in a
+// terminal. It only depends on termios and ANSI escape sequences so it works
+// on minimal hosts and over SSH.
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Status marks how a node differs between two documents.
+type Status int
+
+const (
+	Same Status = iota
+	Added
+	Removed
+	Changed
+)
+
+// Node is an element of a JSON tree. Leaves carry Value; objects and arrays
+// carry Children.
+type Node struct {
+	Key      string
+	Value    string
+	Old      string
+	Status   Status
+	Children []*Node
+	Expanded bool
+	parent   *Node
+	branch   bool
+}
+
+// Path returns the dotted path of n from the root.
+func (n *Node) Path() string {
+	if n.parent == nil {
+		return ""
+	}
+	if p := n.parent.Path(); p != "" {
+		return p + "." + n.Key
+	}
+	return n.Key
+}
+
+// Parse decodes a JSON document into a tree, preserving key order.
+func Parse(b []byte) (*Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	root := &Node{Expanded: true}
+	if err := parseValue(dec, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func parseValue(dec *json.Decoder, n *Node) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n.branch = true
+		for i := 0; dec.More(); i++ {
+			c := &Node{parent: n}
+			if t == '{' {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				c.Key = fmt.Sprint(k)
+			} else {
+				c.Key = strconv.Itoa(i)
+			}
+			if err := parseValue(dec, c); err != nil {
+				return err
+			}
+			n.Children = append(n.Children, c)
+		}
+		_, err = dec.Token()
+		return err
+	case string:
+		n.Value = strconv.Quote(t)
+	case nil:
+		n.Value = "null"
+	default:
+		n.Value = fmt.Sprint(t)
+	}
+	return nil
+}
+
+// Diff merges two trees into one whose nodes are marked Added, Removed or
+// Changed relative to old. Branches containing differences start expanded.
+func Diff(old, cur *Node) *Node {
+	root := diff(old, cur, nil)
+	root.Expanded = true
+	return root
+}
+
+func diff(a, b *Node, parent *Node) *Node {
+	switch {
+	case a == nil:
+		return mark(b, Added, parent)
+	case b == nil:
+		return mark(a, Removed, parent)
+	}
+	n := &Node{Key: b.Key, Value: b.Value, parent: parent, branch: b.branch}
+	if !a.branch || !b.branch {
+		if a.branch != b.branch || a.Value != b.Value {
+			n.Status, n.Old = Changed, a.Value
+		}
+		if b.branch {
+			for _, c := range b.Children {
+				n.Children = append(n.Children, mark(c, Added, n))
+			}
+		}
+		return n
+	}
+	idx := map[string]*Node{}
+	for _, c := range a.Children {
+		idx[c.Key] = c
+	}
+	seen := map[string]bool{}
+	for _, c := range b.Children {
+		seen[c.Key] = true
+		n.Children = append(n.Children, diff(idx[c.Key], c, n))
+	}
+	for _, c := range a.Children {
+		if !seen[c.Key] {
+			n.Children = append(n.Children, diff(c, nil, n))
+		}
+	}
+	for _, c := range n.Children {
+		if c.Status != Same || c.Expanded {
+			n.Expanded = true
+		}
+	}
+	return n
+}
+
+func mark(src *Node, s Status, parent *Node) *Node {
+	n := &Node{Key: src.Key, Value: src.Value, Status: s, parent: parent, branch: src.branch}
+	for _, c := range src.Children {
+		n.Children = append(n.Children, mark(c, s, n))
+	}
+	return n
+}
+
+// SetExpanded expands or collapses n and all its descendants.
+func (n *Node) SetExpanded(v bool) {
+	n.Expanded = v
+	for _, c := range n.Children {
+		c.SetExpanded(v)
+	}
+}
+
+// changed reports whether n or a descendant differs.
+func (n *Node) changed() bool {
+	if n.Status != Same {
+		return true
+	}
+	for _, c := range n.Children {
+		if c.changed() {
+			return true
+		}
+	}
+	return false
+}
+
+// line is a visible row of the rendered tree.
+type line struct {
+	node  *Node
+	depth int
+}
+
+func visible(root *Node) []line {
+	var out []line
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		for _, c := range n.Children {
+			out = append(out, line{c, depth})
+			if c.branch && c.Expanded {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(root, 0)
+	return out
+}
+
+// Print writes the fully expanded tree without colors, for non-terminals.
+func Print(w io.Writer, root *Node) {
+	root.SetExpanded(true)
+	for _, l := range visible(root) {
+		fmt.Fprintln(w, render(l, false))
+	}
+}
+
+func render(l line, color bool) string {
+	n := l.node
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat("  ", l.depth))
+	mark := map[Status]string{Same: " ", Added: "+", Removed: "-", Changed: "~"}[n.Status]
+	if color {
+		sb.WriteString(map[Status]string{Same: "", Added: "\x1b[32m", Removed: "\x1b[31m", Changed: "\x1b[33m"}[n.Status])
+	}
+	if mark != " " {
+		sb.WriteString(mark + " ")
+	}
+	switch {
+	case n.branch && n.Expanded:
+		sb.WriteString("▾ " + n.Key)
+	case n.branch:
+		fmt.Fprintf(&sb, "▸ %s (%d)", n.Key, len(n.Children))
+	case n.Status == Changed:
+		fmt.Fprintf(&sb, "%s: %s → %s", n.Key, n.Old, n.Value)
+	default:
+		fmt.Fprintf(&sb, "%s: %s", n.Key, n.Value)
+	}
+	return sb.String()
+}
+
+// clip truncates s to width display columns, ignoring escape sequences.
+func clip(s string, width int) string {
+	var sb strings.Builder
+	w, esc := 0, false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			esc = true
+		case esc:
+			if r >= '@' && r <= '~' && r != '[' {
+				esc = false
+			}
+		default:
+			if w >= width {
+				return sb.String()
+			}
+			w++
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}